@@ -2,8 +2,10 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/domalab/uma/daemon/domain"
+	"github.com/domalab/uma/daemon/services/auth"
 	"github.com/domalab/uma/daemon/services/config"
 )
 
@@ -38,6 +40,72 @@ func (c *ConfigShowCmd) Run(ctx *domain.Context) error {
 	fmt.Printf("  Max Size: %d MB\n", cfg.Logging.MaxSize)
 	fmt.Printf("  Max Backups: %d\n", cfg.Logging.MaxBackups)
 	fmt.Printf("  Max Age: %d days\n", cfg.Logging.MaxAge)
+	fmt.Printf("\n")
+	fmt.Printf("Operations History:\n")
+	fmt.Printf("  Journal: %s\n", cfg.Operations.JournalPath)
+	fmt.Printf("  Max Count: %d\n", cfg.Operations.MaxCount)
+	fmt.Printf("  Max Age: %d hours\n", cfg.Operations.MaxAgeHours)
+	fmt.Printf("\n")
+	fmt.Printf("Metric History:\n")
+	fmt.Printf("  Enabled: %t\n", cfg.History.Enabled)
+	fmt.Printf("  Path: %s\n", cfg.History.Path)
+	fmt.Printf("  Max Size: %d MB\n", cfg.History.MaxSizeMB)
+	fmt.Printf("  Keep: %s\n", strings.Join(cfg.History.Keep, ", "))
+	fmt.Printf("  Stale After: %d hours\n", cfg.History.StaleHours)
+	fmt.Printf("\n")
+	fmt.Printf("Storage Forecast:\n")
+	fmt.Printf("  Enabled: %t\n", cfg.Forecast.Enabled)
+	fmt.Printf("  Threshold: %d days\n", cfg.Forecast.ThresholdDays)
+	fmt.Printf("  Window: %d days\n", cfg.Forecast.WindowDays)
+	fmt.Printf("  State: %s\n", cfg.Forecast.StatePath)
+	fmt.Printf("\n")
+	fmt.Printf("Alert Rules:\n")
+	fmt.Printf("  Enabled: %t\n", cfg.Alerts.Enabled)
+	for _, rule := range cfg.Alerts.Rules {
+		operand := fmt.Sprintf("%g", rule.Threshold)
+		if rule.Value != "" {
+			operand = rule.Value
+		}
+		fmt.Printf("  - %s: %s %s %s %s\n", rule.Name, rule.Metric, rule.Field, rule.Operator, operand)
+	}
+	fmt.Printf("\n")
+	fmt.Printf("Notification Channels:\n")
+	fmt.Printf("  Max Attempts: %d\n", cfg.Notifications.MaxAttempts)
+	fmt.Printf("  Retry Delay: %d seconds\n", cfg.Notifications.RetryDelaySeconds)
+	fmt.Printf("  Unraid Sync: %t\n", cfg.Notifications.UnraidSync)
+	for _, channel := range cfg.Notifications.Channels {
+		minLevel := channel.MinLevel
+		if minLevel == "" {
+			minLevel = "info"
+		}
+		state := ""
+		if channel.Disabled {
+			state = " (disabled)"
+		}
+		fmt.Printf("  - %s: %s and above%s\n", channel.Name, minLevel, state)
+	}
+	fmt.Printf("\n")
+	fmt.Printf("MQTT:\n")
+	fmt.Printf("  Enabled: %t\n", cfg.MQTT.Enabled)
+	fmt.Printf("  Broker: %s\n", cfg.MQTT.Broker)
+	fmt.Printf("  Topic Prefix: %s\n", cfg.MQTT.TopicPrefix)
+	fmt.Printf("  Metrics: %v\n", cfg.MQTT.Metrics)
+	fmt.Printf("  Interval: %d seconds\n", cfg.MQTT.IntervalSeconds)
+	fmt.Printf("  Home Assistant Discovery: %t (%s)\n", cfg.MQTT.Discovery, cfg.MQTT.DiscoveryPrefix)
+	fmt.Printf("  Commands: %t\n", cfg.MQTT.Commands)
+	fmt.Printf("\n")
+	fmt.Printf("HTTP Middleware:\n")
+	fmt.Printf("  Order: %v\n", cfg.Middleware.Order)
+	fmt.Printf("  Compression Min Length: %d bytes\n", cfg.Middleware.Compression.MinLength)
+	fmt.Printf("  CORS Allowed Origins: %v\n", cfg.Middleware.CORS.AllowedOrigins)
+	fmt.Printf("\n")
+	fmt.Printf("Authentication:\n")
+	fmt.Printf("  Enabled: %t\n", cfg.Auth.Enabled)
+	fmt.Printf("  Public Paths: %v\n", cfg.Auth.PublicPaths)
+	fmt.Printf("  API Keys: %d\n", len(cfg.Auth.APIKeys))
+	for _, key := range cfg.Auth.APIKeys {
+		fmt.Printf("    %s  %-20s %-10s created %s\n", key.ID, key.Name, key.Scope, key.Created.Format("2006-01-02"))
+	}
 
 	return nil
 }
@@ -47,6 +115,12 @@ type ConfigSetCmd struct {
 	HTTPEnabled *bool   `help:"Enable/disable HTTP server"`
 	Port        *int    `name:"port" help:"Set HTTP server port"`
 	LogLevel    *string `help:"Set log level"`
+
+	OperationsMaxCount *int `help:"Set how many finished async operations to keep"`
+	OperationsMaxAge   *int `help:"Set how many hours to keep finished async operations"`
+
+	AuthEnabled  *bool   `help:"Enable/disable API key authentication"`
+	RevokeAPIKey *string `name:"revoke-api-key" help:"Revoke the API key with the given ID"`
 }
 
 func (c *ConfigSetCmd) Run(ctx *domain.Context) error {
@@ -79,6 +153,51 @@ func (c *ConfigSetCmd) Run(ctx *domain.Context) error {
 		fmt.Printf("Log level: %s\n", *c.LogLevel)
 	}
 
+	if c.OperationsMaxCount != nil {
+		if *c.OperationsMaxCount <= 0 {
+			return fmt.Errorf("invalid operations max count: %d", *c.OperationsMaxCount)
+		}
+		cfg.Operations.MaxCount = *c.OperationsMaxCount
+		changed = true
+		fmt.Printf("Operations max count: %d\n", *c.OperationsMaxCount)
+	}
+
+	if c.OperationsMaxAge != nil {
+		if *c.OperationsMaxAge <= 0 {
+			return fmt.Errorf("invalid operations max age: %d", *c.OperationsMaxAge)
+		}
+		cfg.Operations.MaxAgeHours = *c.OperationsMaxAge
+		changed = true
+		fmt.Printf("Operations max age: %d hours\n", *c.OperationsMaxAge)
+	}
+
+	if c.AuthEnabled != nil {
+		if *c.AuthEnabled && len(cfg.Auth.APIKeys) == 0 {
+			return fmt.Errorf("cannot enable authentication without an API key; run 'uma config generate api-key' first")
+		}
+		cfg.Auth.Enabled = *c.AuthEnabled
+		changed = true
+		fmt.Printf("Authentication enabled: %t\n", *c.AuthEnabled)
+	}
+
+	if c.RevokeAPIKey != nil {
+		keys := make([]domain.APIKey, 0, len(cfg.Auth.APIKeys))
+		for _, key := range cfg.Auth.APIKeys {
+			if key.ID != *c.RevokeAPIKey {
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) == len(cfg.Auth.APIKeys) {
+			return fmt.Errorf("no API key with id %s", *c.RevokeAPIKey)
+		}
+		cfg.Auth.APIKeys = keys
+		changed = true
+		fmt.Printf("Revoked API key: %s\n", *c.RevokeAPIKey)
+		if len(keys) == 0 && cfg.Auth.Enabled {
+			fmt.Printf("Warning: authentication is enabled but no API keys remain\n")
+		}
+	}
+
 	if !changed {
 		return fmt.Errorf("no configuration changes specified")
 	}
@@ -94,10 +213,43 @@ func (c *ConfigSetCmd) Run(ctx *domain.Context) error {
 
 // ConfigGenerateCmd generates configuration values
 type ConfigGenerateCmd struct {
-	// No generation options available - authentication removed
+	APIKey ConfigGenerateAPIKeyCmd `cmd:"" name:"api-key" help:"Generate an API key for the HTTP API, stream and MCP server"`
+}
+
+// ConfigGenerateAPIKeyCmd generates an API key and stores its hash
+type ConfigGenerateAPIKeyCmd struct {
+	Name  string `help:"Name identifying the key's client" required:""`
+	Scope string `help:"Key scope: read-only, operator or admin" enum:"read-only,operator,admin" default:"read-only"`
 }
 
-func (c *ConfigGenerateCmd) Run(ctx *domain.Context) error {
-	fmt.Println("No generation options available - authentication has been removed from UMA")
+func (c *ConfigGenerateAPIKeyCmd) Run(ctx *domain.Context) error {
+	scope, err := auth.ParseScope(c.Scope)
+	if err != nil {
+		return fmt.Errorf("invalid scope %q: %w", c.Scope, err)
+	}
+
+	manager := config.NewManager("")
+	if err := manager.Load(); err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	key, record, err := auth.GenerateAPIKey(c.Name, scope)
+	if err != nil {
+		return fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	cfg := manager.GetConfig()
+	cfg.Auth.APIKeys = append(cfg.Auth.APIKeys, record)
+	cfg.Auth.Enabled = true
+
+	manager.UpdateConfig(cfg)
+	if err := manager.Save(); err != nil {
+		return fmt.Errorf("failed to save configuration: %w", err)
+	}
+
+	fmt.Printf("Generated %s API key %q (id %s)\n", record.Scope, record.Name, record.ID)
+	fmt.Printf("\n  %s\n\n", key)
+	fmt.Printf("Store this key now; only its hash is saved and it cannot be shown again.\n")
+	fmt.Printf("Restart UMA to apply the change.\n")
 	return nil
 }
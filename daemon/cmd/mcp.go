@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"github.com/domalab/uma/daemon/common"
+	"github.com/domalab/uma/daemon/domain"
+	"github.com/domalab/uma/daemon/dto"
+)
+
+// MCPCmd serves MCP over stdio for local clients by proxying to the running daemon
+type MCPCmd struct {
+	Socket string `default:"" help:"daemon Unix socket (defaults to /var/run/uma-api.sock)"`
+}
+
+func (c *MCPCmd) Run(ctx *domain.Context) error {
+	socket := c.Socket
+	if socket == "" {
+		socket = common.Socket
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return fmt.Errorf("failed to connect to the UMA daemon at %s: %w", socket, err)
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(dto.Request{Action: "mcp"}); err != nil {
+		return fmt.Errorf("failed to start MCP session: %w", err)
+	}
+
+	return proxyStream(conn, os.Stdin, os.Stdout)
+}
+
+// proxyStream copies in to conn and conn to out until the daemon hangs up
+func proxyStream(conn net.Conn, in io.Reader, out io.Writer) error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(out, conn)
+		done <- err
+	}()
+
+	go func() {
+		io.Copy(conn, in)
+
+		// Let the daemon answer outstanding requests before it hangs up
+		if unixConn, ok := conn.(*net.UnixConn); ok {
+			unixConn.CloseWrite()
+		}
+	}()
+
+	return <-done
+}
@@ -0,0 +1,176 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cskr/pubsub"
+)
+
+// Type identifies what happened. The part before the dot names the topic.
+type Type string
+
+const (
+	ContainerCreated  Type = "container.created"
+	ContainerStarted  Type = "container.started"
+	ContainerStopped  Type = "container.stopped"
+	ContainerDied     Type = "container.died"
+	ContainerPaused   Type = "container.paused"
+	ContainerUnpaused Type = "container.unpaused"
+	ContainerRemoved  Type = "container.removed"
+
+	ContainerHealthChanged Type = "container.health_changed"
+
+	VMStateChanged Type = "vm.state_changed"
+
+	ArrayStateChanged Type = "array.state_changed"
+
+	DiskSpunDown Type = "disk.spun_down"
+	DiskSpunUp   Type = "disk.spun_up"
+
+	ParityStarted  Type = "parity.started"
+	ParityFinished Type = "parity.finished"
+
+	UPSOnBattery Type = "ups.on_battery"
+	UPSOnline    Type = "ups.online"
+
+	NotificationCreated Type = "notification.created"
+)
+
+// Hub topics, which are also the WebSocket stream channels
+const (
+	TopicContainer    = "events.container"
+	TopicVM           = "events.vm"
+	TopicArray        = "events.array"
+	TopicDisk         = "events.disk"
+	TopicParity       = "events.parity"
+	TopicUPS          = "events.ups"
+	TopicNotification = "events.notification"
+
+	// TopicAll is a stream channel carrying every topic; nothing is published to it directly
+	TopicAll = "events.*"
+)
+
+// Topics lists every topic events are published on
+var Topics = []string{TopicContainer, TopicVM, TopicArray, TopicDisk, TopicParity, TopicUPS, TopicNotification}
+
+// Topic returns the hub topic an event type is published on
+func (t Type) Topic() string {
+	category, _, _ := strings.Cut(string(t), ".")
+	return "events." + category
+}
+
+// Event is a discrete state change published on the hub
+type Event struct {
+	ID        string      `json:"id"`
+	Type      Type        `json:"type"`
+	Timestamp time.Time   `json:"timestamp"`
+	Source    string      `json:"source"` // Container, VM or disk name, or the subsystem
+	Data      interface{} `json:"data,omitempty"`
+}
+
+// ContainerEvent describes a container lifecycle change
+type ContainerEvent struct {
+	ID            string `json:"id,omitempty"`
+	Name          string `json:"name"`
+	Image         string `json:"image,omitempty"`
+	State         string `json:"state"`
+	PreviousState string `json:"previous_state,omitempty"`
+	Health        string `json:"health,omitempty"`
+	ExitCode      int    `json:"exit_code,omitempty"`
+}
+
+// VMEvent describes a virtual machine state change
+type VMEvent struct {
+	Name          string `json:"name"`
+	State         string `json:"state"`
+	PreviousState string `json:"previous_state,omitempty"`
+}
+
+// ArrayEvent describes an array state change
+type ArrayEvent struct {
+	State         string `json:"state"`
+	PreviousState string `json:"previous_state,omitempty"`
+}
+
+// DiskEvent describes a disk spinning down or up
+type DiskEvent struct {
+	Name   string `json:"name"`
+	Device string `json:"device,omitempty"`
+	Role   string `json:"role,omitempty"`
+}
+
+// ParityEvent describes a parity check starting or finishing
+type ParityEvent struct {
+	Type      string  `json:"type,omitempty"` // "check" or "correct"
+	Progress  float64 `json:"progress,omitempty"`
+	Errors    int     `json:"errors,omitempty"`
+	Cancelled bool    `json:"cancelled,omitempty"`
+}
+
+// UPSEvent describes the UPS switching between mains and battery
+type UPSEvent struct {
+	Status        string  `json:"status"`
+	BatteryCharge float64 `json:"battery_charge,omitempty"`
+}
+
+// NotificationEvent describes a newly created notification
+type NotificationEvent struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Level    string `json:"level"`
+	Category string `json:"category"`
+}
+
+// Bus publishes events on the daemon's pubsub hub. A nil Bus discards
+// events, so plugins work without one.
+type Bus struct {
+	hub      *pubsub.PubSub
+	prefix   string
+	sequence atomic.Uint64
+}
+
+// NewBus creates a bus on hub
+func NewBus(hub *pubsub.PubSub) *Bus {
+	return &Bus{
+		hub:    hub,
+		prefix: fmt.Sprintf("%x", time.Now().UnixMilli()),
+	}
+}
+
+// Publish stamps an event with an ID and timestamp and publishes it on its
+// topic. Slow subscribers miss events rather than blocking the publisher.
+func (b *Bus) Publish(eventType Type, source string, data interface{}) Event {
+	event := Event{
+		Type:      eventType,
+		Timestamp: time.Now(),
+		Source:    source,
+		Data:      data,
+	}
+
+	if b == nil || b.hub == nil {
+		return event
+	}
+
+	// IDs stay unique across restarts; the padded sequence sorts them in
+	// publish order within a run
+	event.ID = fmt.Sprintf("%s-%012d", b.prefix, b.sequence.Add(1))
+	b.hub.TryPub(event, eventType.Topic())
+	return event
+}
+
+// Subscribe returns a channel receiving events on topics, or on every topic if none are given
+func (b *Bus) Subscribe(topics ...string) chan interface{} {
+	if len(topics) == 0 {
+		topics = Topics
+	}
+	return b.hub.Sub(topics...)
+}
+
+// Unsubscribe stops delivery to ch. The caller must keep draining ch until it is closed.
+func (b *Bus) Unsubscribe(ch chan interface{}) {
+	b.hub.Unsub(ch)
+}
@@ -0,0 +1,79 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cskr/pubsub"
+)
+
+func TestTypeTopic(t *testing.T) {
+	tests := map[Type]string{
+		ContainerDied:       TopicContainer,
+		VMStateChanged:      TopicVM,
+		ArrayStateChanged:   TopicArray,
+		DiskSpunDown:        TopicDisk,
+		ParityFinished:      TopicParity,
+		UPSOnBattery:        TopicUPS,
+		NotificationCreated: TopicNotification,
+	}
+
+	for eventType, want := range tests {
+		if got := eventType.Topic(); got != want {
+			t.Errorf("%s.Topic() = %s, want %s", eventType, got, want)
+		}
+	}
+}
+
+func TestBusPublish(t *testing.T) {
+	hub := pubsub.New(8)
+	defer hub.Shutdown()
+
+	bus := NewBus(hub)
+	all := bus.Subscribe()
+	containers := bus.Subscribe(TopicContainer)
+
+	first := bus.Publish(ContainerStarted, "plex", ContainerEvent{Name: "plex", State: "running"})
+	second := bus.Publish(DiskSpunDown, "disk1", DiskEvent{Name: "disk1"})
+
+	if first.ID == "" || first.ID == second.ID {
+		t.Fatalf("expected distinct event IDs, got %q and %q", first.ID, second.ID)
+	}
+	if first.Timestamp.IsZero() {
+		t.Error("expected a timestamp")
+	}
+
+	for _, want := range []Event{first, second} {
+		select {
+		case msg := <-all:
+			if got := msg.(Event); got.ID != want.ID {
+				t.Errorf("got event %s, want %s", got.ID, want.ID)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %s not delivered to the catch-all subscriber", want.Type)
+		}
+	}
+
+	select {
+	case msg := <-containers:
+		if got := msg.(Event); got.Type != ContainerStarted {
+			t.Errorf("got %s on %s", got.Type, TopicContainer)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("container event not delivered")
+	}
+
+	select {
+	case msg := <-containers:
+		t.Errorf("unexpected event on %s: %v", TopicContainer, msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestNilBus(t *testing.T) {
+	var bus *Bus
+	event := bus.Publish(NotificationCreated, "uma", nil)
+	if event.Type != NotificationCreated || event.ID != "" {
+		t.Errorf("unexpected event from nil bus: %+v", event)
+	}
+}
@@ -0,0 +1,18 @@
+package httputil
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/domalab/uma/daemon/logger"
+)
+
+// DisableWriteDeadline lifts the HTTP server's write timeout from a response
+// streamed for longer than it, such as server-sent events; stream names the
+// response in the log if that fails
+func DisableWriteDeadline(w http.ResponseWriter, stream string) {
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		logger.Yellow("Failed to clear write deadline for %s: %v", stream, err)
+	}
+}
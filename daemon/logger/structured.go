@@ -228,6 +228,24 @@ func LogSecurityEvent(eventType, clientIP, userAgent, requestID string, success
 	event.Msg("Security event")
 }
 
+// LogAuditEvent records who did what with elevated access, such as opening a
+// shell in a container
+func LogAuditEvent(action, actor, clientIP, requestID string, details map[string]interface{}) {
+	event := Logger.Info().
+		Str("component", "audit").
+		Str("action", action).
+		Str("actor", actor).
+		Str("client_ip", clientIP).
+		Str("request_id", requestID)
+
+	// Add audit event details
+	for key, value := range details {
+		event = event.Interface(key, value)
+	}
+
+	event.Msg("Audit event")
+}
+
 // LogErrorWithContext logs errors with rich context information
 func LogErrorWithContext(component, operation string, err error, requestID string, context map[string]interface{}) {
 	event := Logger.Error().
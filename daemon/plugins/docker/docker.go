@@ -1,31 +1,41 @@
 package docker
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
-	"github.com/domalab/uma/daemon/lib"
+	"github.com/domalab/uma/daemon/events"
 	"github.com/domalab/uma/daemon/logger"
 )
 
-// CommandExecutor interface for dependency injection in tests
-type CommandExecutor interface {
-	GetCmdOutput(command string, args ...string) []string
-}
+var (
+	// ErrDockerUnavailable is returned when the Docker daemon cannot be reached
+	ErrDockerUnavailable = errors.New("docker is not available")
 
-// DefaultCommandExecutor uses the real lib.GetCmdOutput
-type DefaultCommandExecutor struct{}
+	// ErrContainerNotFound is returned when a container name or ID does not resolve
+	ErrContainerNotFound = errors.New("container not found")
 
-func (d *DefaultCommandExecutor) GetCmdOutput(command string, args ...string) []string {
-	return lib.GetCmdOutput(command, args...)
-}
+	// ErrContainerNotRunning is returned when a container must be running, as for exec
+	ErrContainerNotRunning = errors.New("container is not running")
+)
 
 // DockerManager provides Docker container management capabilities
 type DockerManager struct {
-	cmdExecutor CommandExecutor
+	client EngineClient
+	events *events.Bus
+
+	// watching is set while a Watcher follows the daemon's events, which
+	// then announces the changes the manager's actions cause
+	watching atomic.Bool
 }
 
 // ContainerInfo represents information about a Docker container
@@ -35,6 +45,7 @@ type ContainerInfo struct {
 	Image         string            `json:"image"`
 	Status        string            `json:"status"`
 	State         string            `json:"state"`
+	Health        string            `json:"health,omitempty"` // healthy, unhealthy or starting with a health check
 	Created       time.Time         `json:"created"`
 	StartedAt     time.Time         `json:"started_at,omitempty"`
 	Ports         []PortMapping     `json:"ports"`
@@ -84,6 +95,10 @@ type DockerStats struct {
 	MemPercent  float64 `json:"memory_percent"`
 	NetIO       string  `json:"net_io"`
 	BlockIO     string  `json:"block_io"`
+	NetRx       uint64  `json:"network_rx_bytes"`
+	NetTx       uint64  `json:"network_tx_bytes"`
+	BlockRead   uint64  `json:"block_read_bytes"`
+	BlockWrite  uint64  `json:"block_write_bytes"`
 }
 
 // DockerNetwork represents a Docker network
@@ -127,444 +142,399 @@ type DockerImage struct {
 	RepoDigests []string          `json:"repo_digests,omitempty"`
 }
 
-// NewDockerManager creates a new Docker manager
+// DockerVolume represents a Docker volume
+type DockerVolume struct {
+	Name       string            `json:"name"`
+	Driver     string            `json:"driver"`
+	Mountpoint string            `json:"mountpoint"`
+	Scope      string            `json:"scope"`
+	Created    time.Time         `json:"created"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Options    map[string]string `json:"options,omitempty"`
+}
+
+// NewDockerManager creates a new Docker manager talking to dockerd over its socket
 func NewDockerManager() *DockerManager {
 	return &DockerManager{
-		cmdExecutor: &DefaultCommandExecutor{},
+		client: NewSocketClient(DefaultSocketPath),
 	}
 }
 
-// NewDockerManagerWithExecutor creates a new Docker manager with custom command executor (for testing)
-func NewDockerManagerWithExecutor(executor CommandExecutor) *DockerManager {
+// NewDockerManagerWithClient creates a new Docker manager with a custom Engine API client (for testing)
+func NewDockerManagerWithClient(client EngineClient) *DockerManager {
 	return &DockerManager{
-		cmdExecutor: executor,
+		client: client,
+	}
+}
+
+// SetEventBus publishes container lifecycle events on bus
+func (d *DockerManager) SetEventBus(bus *events.Bus) {
+	d.events = bus
+}
+
+// publish announces a container action that completed successfully
+func (d *DockerManager) publish(eventType events.Type, nameOrID string, state string) {
+	if d.watching.Load() {
+		return
 	}
+	d.events.Publish(eventType, nameOrID, events.ContainerEvent{Name: nameOrID, State: state})
 }
 
 // IsDockerAvailable checks if Docker is available and running
 func (d *DockerManager) IsDockerAvailable() bool {
-	output := d.cmdExecutor.GetCmdOutput("docker", "version", "--format", "{{.Server.Version}}")
-	return len(output) > 0 && !strings.Contains(strings.Join(output, ""), "Cannot connect")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return engineCall(ctx, d.client, http.MethodGet, "/_ping", nil) == nil
 }
 
 // ListContainers returns a list of all containers
 func (d *DockerManager) ListContainers(all bool) ([]ContainerInfo, error) {
-	containers := make([]ContainerInfo, 0)
-
-	if !d.IsDockerAvailable() {
-		return containers, fmt.Errorf("docker is not available")
-	}
-
-	args := []string{"ps", "--format", "json", "--no-trunc"}
-	if all {
-		args = append(args, "--all")
+	containers, err := d.ListContainerSummaries(all)
+	if err != nil {
+		return containers, err
 	}
 
-	output := d.cmdExecutor.GetCmdOutput("docker", args...)
-	// Only log Docker ps output in debug scenarios or when there are issues
-	if len(output) == 0 {
-		logger.Yellow("Docker ps returned no output - Docker may not be running")
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), engineTimeout)
+	defer cancel()
 
-	for _, line := range output {
-		if strings.TrimSpace(line) == "" {
+	// The list lacks the environment, restart policy and start time
+	for i := range containers {
+		var inspect engineContainerJSON
+		if err := engineJSON(ctx, d.client, http.MethodGet, containerPath(containers[i].ID, "json"), nil, &inspect); err != nil {
+			logger.Yellow("Failed to get container details for %s: %v", containers[i].ID, err)
 			continue
 		}
-
-		// Remove verbose per-line processing logs - only log errors
-
-		// Parse the docker ps JSON format first
-		var dockerPsData map[string]interface{}
-		if err := json.Unmarshal([]byte(line), &dockerPsData); err != nil {
-			logger.Yellow("Failed to parse container JSON: %v", err)
-			continue
-		}
-
-		// Convert to our ContainerInfo format
-		container := ContainerInfo{}
-		d.parseDockerPsData(&container, dockerPsData)
-
-		// Get detailed information
-		if err := d.getContainerDetails(&container); err != nil {
-			logger.Yellow("Failed to get container details for %s: %v", container.ID, err)
-		}
-
-		containers = append(containers, container)
+		containers[i] = inspect.info()
 	}
-
-	// Use structured logging for container summary - only log when count changes significantly or errors occur
-	// This reduces log noise from routine monitoring while preserving important information
 	return containers, nil
 }
 
-// parseDockerPsData parses docker ps JSON output into ContainerInfo
-func (d *DockerManager) parseDockerPsData(container *ContainerInfo, data map[string]interface{}) {
-	if id, ok := data["ID"].(string); ok {
-		container.ID = id
-	}
+// ListContainerSummaries returns containers as the container list reports
+// them, without inspecting each; environment, restart policy and start time
+// are left empty
+func (d *DockerManager) ListContainerSummaries(all bool) ([]ContainerInfo, error) {
+	containers := make([]ContainerInfo, 0)
 
-	if names, ok := data["Names"].(string); ok {
-		container.Name = names
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), engineTimeout)
+	defer cancel()
 
-	if image, ok := data["Image"].(string); ok {
-		container.Image = image
+	query := url.Values{}
+	if all {
+		query.Set("all", "1")
+	}
+	var list []engineContainer
+	if err := engineJSON(ctx, d.client, http.MethodGet, "/containers/json", query, &list); err != nil {
+		return containers, fmt.Errorf("%w: %v", ErrDockerUnavailable, err)
 	}
 
-	if status, ok := data["Status"].(string); ok {
-		container.Status = status
+	for _, entry := range list {
+		containers = append(containers, entry.info())
 	}
+	return containers, nil
+}
 
-	if state, ok := data["State"].(string); ok {
-		container.State = state
+// info converts a container list entry
+func (c *engineContainer) info() ContainerInfo {
+	container := ContainerInfo{
+		ID:       c.ID,
+		Image:    c.Image,
+		Status:   c.Status,
+		State:    c.State,
+		Created:  time.Unix(c.Created, 0),
+		Ports:    make([]PortMapping, 0, len(c.Ports)),
+		Mounts:   mountInfo(c.Mounts),
+		Networks: networkInfo(c.NetworkSettings.Networks),
+		Labels:   c.Labels,
+	}
+	if len(c.Names) > 0 {
+		container.Name = strings.TrimPrefix(c.Names[0], "/")
+	}
+	for _, port := range c.Ports {
+		mapping := PortMapping{
+			HostIP:        port.IP,
+			ContainerPort: strconv.Itoa(port.PrivatePort),
+			Protocol:      port.Type,
+		}
+		if port.PublicPort > 0 {
+			mapping.HostPort = strconv.Itoa(port.PublicPort)
+		}
+		container.Ports = append(container.Ports, mapping)
+	}
+	return container
+}
+
+// info converts a container inspect
+func (c *engineContainerJSON) info() ContainerInfo {
+	container := ContainerInfo{
+		ID:            c.ID,
+		Name:          strings.TrimPrefix(c.Name, "/"),
+		Image:         c.Config.Image,
+		Status:        c.State.Status,
+		State:         c.State.Status,
+		Ports:         make([]PortMapping, 0),
+		Mounts:        mountInfo(c.Mounts),
+		Networks:      networkInfo(c.NetworkSettings.Networks),
+		Labels:        c.Config.Labels,
+		Environment:   c.Config.Env,
+		RestartPolicy: c.HostConfig.RestartPolicy.Name,
+	}
+	if c.State.Health != nil {
+		container.Health = c.State.Health.Status
+	}
+	if t, err := time.Parse(time.RFC3339Nano, c.Created); err == nil {
+		container.Created = t
+	}
+	if t, err := time.Parse(time.RFC3339Nano, c.State.StartedAt); err == nil {
+		container.StartedAt = t
+	}
+
+	for containerPort, bindings := range c.HostConfig.PortBindings {
+		port, protocol, _ := strings.Cut(containerPort, "/")
+		for _, binding := range bindings {
+			container.Ports = append(container.Ports, PortMapping{
+				HostIP:        binding.HostIP,
+				HostPort:      binding.HostPort,
+				ContainerPort: port,
+				Protocol:      protocol,
+			})
+		}
+	}
+	sort.Slice(container.Ports, func(i, j int) bool {
+		return container.Ports[i].ContainerPort+container.Ports[i].Protocol < container.Ports[j].ContainerPort+container.Ports[j].Protocol
+	})
+	return container
+}
+
+func mountInfo(mounts []engineMount) []MountInfo {
+	mountList := make([]MountInfo, 0, len(mounts))
+	for _, mount := range mounts {
+		mountList = append(mountList, MountInfo{
+			Type:        mount.Type,
+			Source:      mount.Source,
+			Destination: mount.Destination,
+			Mode:        mount.Mode,
+			ReadWrite:   mount.RW,
+		})
 	}
+	return mountList
+}
 
-	if createdAt, ok := data["CreatedAt"].(string); ok {
-		// Parse the created time - format: "2025-06-13 04:01:43 +1000 AEST"
-		if t, err := time.Parse("2006-01-02 15:04:05 -0700 MST", createdAt); err == nil {
-			container.Created = t
-		}
+func networkInfo(networks map[string]engineEndpoint) []NetworkInfo {
+	networkList := make([]NetworkInfo, 0, len(networks))
+	for name, network := range networks {
+		networkList = append(networkList, NetworkInfo{
+			Name:      name,
+			IPAddress: network.IPAddress,
+			Gateway:   network.Gateway,
+		})
 	}
+	sort.Slice(networkList, func(i, j int) bool { return networkList[i].Name < networkList[j].Name })
+	return networkList
+}
 
+// containerPath is the Engine API path of a container endpoint
+func containerPath(nameOrID, endpoint string) string {
+	return "/containers/" + url.PathEscape(nameOrID) + "/" + endpoint
 }
 
-// min helper function
-func min(a, b int) int {
-	if a < b {
-		return a
+// inspectContainer returns the inspect data of a container
+func (d *DockerManager) inspectContainer(ctx context.Context, nameOrID string) (*engineContainerJSON, error) {
+	var inspect engineContainerJSON
+	if err := engineJSON(ctx, d.client, http.MethodGet, containerPath(nameOrID, "json"), nil, &inspect); err != nil {
+		return nil, containerError(nameOrID, err)
 	}
-	return b
+	return &inspect, nil
 }
 
 // GetContainer returns information about a specific container
 func (d *DockerManager) GetContainer(nameOrID string) (*ContainerInfo, error) {
-	if !d.IsDockerAvailable() {
-		return nil, fmt.Errorf("docker is not available")
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), engineTimeout)
+	defer cancel()
 
-	output := d.cmdExecutor.GetCmdOutput("docker", "inspect", nameOrID)
-	if len(output) == 0 {
-		return nil, fmt.Errorf("container not found: %s", nameOrID)
+	inspect, err := d.inspectContainer(ctx, nameOrID)
+	if err != nil {
+		return nil, err
 	}
+	container := inspect.info()
+	return &container, nil
+}
 
-	// Parse the JSON output
-	jsonStr := strings.Join(output, "")
-	var inspectData []map[string]interface{}
-	if err := json.Unmarshal([]byte(jsonStr), &inspectData); err != nil {
-		return nil, fmt.Errorf("failed to parse inspect output: %w", err)
-	}
+// containerAction posts a lifecycle action such as start to a container.
+// Starting a running or stopping a stopped container is not an error.
+func (d *DockerManager) containerAction(nameOrID, action string, query url.Values, timeout int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), engineTimeout+time.Duration(timeout)*time.Second)
+	defer cancel()
 
-	if len(inspectData) == 0 {
-		return nil, fmt.Errorf("no container data found")
+	if err := engineCall(ctx, d.client, http.MethodPost, containerPath(nameOrID, action), query); err != nil {
+		return containerError(nameOrID, err)
 	}
+	return nil
+}
 
-	container := &ContainerInfo{}
-	if err := d.parseInspectData(container, inspectData[0]); err != nil {
-		return nil, err
+// stopQuery sets how long a container gets to stop before it is killed
+func stopQuery(timeout int) url.Values {
+	query := url.Values{}
+	if timeout > 0 {
+		query.Set("t", strconv.Itoa(timeout))
 	}
-
-	return container, nil
+	return query
 }
 
 // StartContainer starts a container
 func (d *DockerManager) StartContainer(nameOrID string) error {
-	if !d.IsDockerAvailable() {
-		return fmt.Errorf("docker is not available")
-	}
-
-	output := d.cmdExecutor.GetCmdOutput("docker", "start", nameOrID)
-	if len(output) == 0 {
-		return fmt.Errorf("failed to start container: %s", nameOrID)
-	}
-
-	// Check if there were any errors
-	for _, line := range output {
-		if strings.Contains(line, "Error") {
-			return fmt.Errorf("error starting container: %s", line)
-		}
+	if err := d.containerAction(nameOrID, "start", nil, 0); err != nil {
+		return fmt.Errorf("error starting container: %w", err)
 	}
 
 	logger.Blue("Started container: %s", nameOrID)
+	d.publish(events.ContainerStarted, nameOrID, "running")
 	return nil
 }
 
 // StopContainer stops a container
 func (d *DockerManager) StopContainer(nameOrID string, timeout int) error {
-	if !d.IsDockerAvailable() {
-		return fmt.Errorf("docker is not available")
-	}
-
-	args := []string{"stop"}
-	if timeout > 0 {
-		args = append(args, "--time", strconv.Itoa(timeout))
-	}
-	args = append(args, nameOrID)
-
-	output := d.cmdExecutor.GetCmdOutput("docker", args...)
-
-	// Check if there were any errors
-	for _, line := range output {
-		if strings.Contains(line, "Error") {
-			return fmt.Errorf("error stopping container: %s", line)
-		}
+	if err := d.containerAction(nameOrID, "stop", stopQuery(timeout), timeout); err != nil {
+		return fmt.Errorf("error stopping container: %w", err)
 	}
 
 	logger.Blue("Stopped container: %s", nameOrID)
+	d.publish(events.ContainerStopped, nameOrID, "exited")
 	return nil
 }
 
 // RestartContainer restarts a container
 func (d *DockerManager) RestartContainer(nameOrID string, timeout int) error {
-	if !d.IsDockerAvailable() {
-		return fmt.Errorf("docker is not available")
-	}
-
-	args := []string{"restart"}
-	if timeout > 0 {
-		args = append(args, "--time", strconv.Itoa(timeout))
-	}
-	args = append(args, nameOrID)
-
-	output := d.cmdExecutor.GetCmdOutput("docker", args...)
-
-	// Check if there were any errors
-	for _, line := range output {
-		if strings.Contains(line, "Error") {
-			return fmt.Errorf("error restarting container: %s", line)
-		}
+	if err := d.containerAction(nameOrID, "restart", stopQuery(timeout), timeout); err != nil {
+		return fmt.Errorf("error restarting container: %w", err)
 	}
 
 	logger.Blue("Restarted container: %s", nameOrID)
+	d.publish(events.ContainerStarted, nameOrID, "running")
 	return nil
 }
 
 // GetContainerLogs returns logs for a container
 func (d *DockerManager) GetContainerLogs(nameOrID string, lines int, follow bool) ([]string, error) {
-	if !d.IsDockerAvailable() {
-		return nil, fmt.Errorf("docker is not available")
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), engineTimeout)
+	defer cancel()
 
-	args := []string{"logs"}
-	if lines > 0 {
-		args = append(args, "--tail", strconv.Itoa(lines))
-	}
-	if follow {
-		args = append(args, "--follow")
+	options := LogOptions{Tail: lines, Follow: follow}
+	if lines <= 0 {
+		options.Tail = -1
 	}
-	args = append(args, nameOrID)
 
-	output := d.cmdExecutor.GetCmdOutput("docker", args...)
+	output := make([]string, 0)
+	err := d.StreamContainerLogs(ctx, nameOrID, options, func(line LogLine) error {
+		output = append(output, line.Text)
+		return nil
+	})
+	if err != nil && !(follow && errors.Is(err, context.DeadlineExceeded)) {
+		return nil, err
+	}
 	return output, nil
 }
 
-// GetContainerStats returns real-time statistics for containers
+// GetContainerStats returns real-time statistics for a container
 func (d *DockerManager) GetContainerStats(nameOrID string) (*DockerStats, error) {
-	if !d.IsDockerAvailable() {
-		return nil, fmt.Errorf("docker is not available")
+	if nameOrID == "" {
+		return nil, fmt.Errorf("a container name or ID is required")
 	}
 
-	args := []string{"stats", "--no-stream", "--format", "json"}
-	if nameOrID != "" {
-		args = append(args, nameOrID)
-	}
-
-	output := d.cmdExecutor.GetCmdOutput("docker", args...)
-	if len(output) == 0 {
-		return nil, fmt.Errorf("no stats available")
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), engineTimeout)
+	defer cancel()
 
-	// Parse the raw Docker stats JSON format
-	var rawStats map[string]interface{}
-	if err := json.Unmarshal([]byte(output[0]), &rawStats); err != nil {
-		return nil, fmt.Errorf("failed to parse stats: %w", err)
+	// A single sample that is not one-shot includes the previous CPU reading
+	var sample ContainerStatsSample
+	if err := engineJSON(ctx, d.client, http.MethodGet, containerPath(nameOrID, "stats"), url.Values{"stream": {"0"}}, &sample); err != nil {
+		return nil, containerError(nameOrID, err)
 	}
-
-	// Convert to our DockerStats format
-	stats := &DockerStats{}
-	d.parseDockerStats(stats, rawStats)
-
-	return stats, nil
+	return sample.stats(), nil
 }
 
-// parseDockerStats parses raw Docker stats JSON into DockerStats struct
-func (d *DockerManager) parseDockerStats(stats *DockerStats, rawStats map[string]interface{}) {
-	// Parse container ID and name
-	if id, ok := rawStats["ID"].(string); ok {
-		stats.ContainerID = id
-	}
-	if name, ok := rawStats["Name"].(string); ok {
-		stats.Name = name
-	}
-
-	// Parse CPU percentage - format: "0.67%"
-	if cpuPerc, ok := rawStats["CPUPerc"].(string); ok {
-		cpuStr := strings.TrimSuffix(cpuPerc, "%")
-		if cpu, err := strconv.ParseFloat(cpuStr, 64); err == nil {
-			stats.CPUPercent = cpu
+// StreamContainerStats calls fn with a stats sample of a running container
+// about every second until ctx is done, the container stops or fn fails
+func (d *DockerManager) StreamContainerStats(ctx context.Context, nameOrID string, fn func(*ContainerStatsSample) error) error {
+	err := engineStream(ctx, d.client, containerPath(nameOrID, "stats"), url.Values{"stream": {"1"}}, func(decoder *json.Decoder) error {
+		var sample ContainerStatsSample
+		if err := decoder.Decode(&sample); err != nil {
+			return err
 		}
+		return fn(&sample)
+	})
+	if err != nil && ctx.Err() == nil {
+		return containerError(nameOrID, err)
 	}
-
-	// Parse memory percentage - format: "0.38%"
-	if memPerc, ok := rawStats["MemPerc"].(string); ok {
-		memStr := strings.TrimSuffix(memPerc, "%")
-		if mem, err := strconv.ParseFloat(memStr, 64); err == nil {
-			stats.MemPercent = mem
-		}
-	}
-
-	// Parse memory usage - format: "121.5MiB / 31.04GiB"
-	if memUsage, ok := rawStats["MemUsage"].(string); ok {
-		parts := strings.Split(memUsage, " / ")
-		if len(parts) == 2 {
-			// Parse memory usage (first part)
-			if usage := d.parseMemoryValue(strings.TrimSpace(parts[0])); usage > 0 {
-				stats.MemUsage = usage
-			}
-			// Parse memory limit (second part)
-			if limit := d.parseMemoryValue(strings.TrimSpace(parts[1])); limit > 0 {
-				stats.MemLimit = limit
-			}
-		}
-	}
-
-	// Parse network I/O - format: "0B / 0B"
-	if netIO, ok := rawStats["NetIO"].(string); ok {
-		stats.NetIO = netIO
-	}
-
-	// Parse block I/O - format: "138MB / 24MB"
-	if blockIO, ok := rawStats["BlockIO"].(string); ok {
-		stats.BlockIO = blockIO
-	}
+	return err
 }
 
-// parseMemoryValue parses memory values like "121.5MiB", "31.04GiB" into bytes
-func (d *DockerManager) parseMemoryValue(memStr string) uint64 {
-	// Remove any whitespace
-	memStr = strings.TrimSpace(memStr)
-	if memStr == "" {
-		return 0
-	}
-
-	// Extract numeric part and unit
-	var value float64
-	var unit string
-
-	// Find where the unit starts
-	i := len(memStr) - 1
-	for i >= 0 && (memStr[i] < '0' || memStr[i] > '9') && memStr[i] != '.' {
-		i--
-	}
-
-	if i < 0 {
-		return 0
-	}
-
-	valueStr := memStr[:i+1]
-	unit = memStr[i+1:]
-
-	var err error
-	if value, err = strconv.ParseFloat(valueStr, 64); err != nil {
-		return 0
+// stats summarises a sample as docker stats does
+func (s *ContainerStatsSample) stats() *DockerStats {
+	stats := &DockerStats{
+		ContainerID: s.ID,
+		Name:        strings.TrimPrefix(s.Name, "/"),
+		CPUPercent:  s.CPUPercent(),
+		MemUsage:    s.MemoryUsage(),
+		MemLimit:    s.MemoryStats.Limit,
 	}
-
-	// Convert to bytes based on unit
-	switch strings.ToLower(unit) {
-	case "b", "":
-		return uint64(value)
-	case "kb", "kib":
-		return uint64(value * 1024)
-	case "mb", "mib":
-		return uint64(value * 1024 * 1024)
-	case "gb", "gib":
-		return uint64(value * 1024 * 1024 * 1024)
-	case "tb", "tib":
-		return uint64(value * 1024 * 1024 * 1024 * 1024)
-	default:
-		return uint64(value)
+	if stats.MemLimit > 0 {
+		stats.MemPercent = float64(stats.MemUsage) / float64(stats.MemLimit) * 100
 	}
+	stats.NetRx, stats.NetTx = s.NetworkIO()
+	stats.BlockRead, stats.BlockWrite = s.BlockIO()
+	stats.NetIO = humanSize(stats.NetRx) + " / " + humanSize(stats.NetTx)
+	stats.BlockIO = humanSize(stats.BlockRead) + " / " + humanSize(stats.BlockWrite)
+	return stats
 }
 
 // PauseContainer pauses a container
 func (d *DockerManager) PauseContainer(nameOrID string) error {
-	if !d.IsDockerAvailable() {
-		return fmt.Errorf("docker is not available")
-	}
-
-	output := d.cmdExecutor.GetCmdOutput("docker", "pause", nameOrID)
-
-	// Check if there were any errors
-	for _, line := range output {
-		if strings.Contains(line, "Error") {
-			return fmt.Errorf("error pausing container: %s", line)
-		}
+	if err := d.containerAction(nameOrID, "pause", nil, 0); err != nil {
+		return fmt.Errorf("error pausing container: %w", err)
 	}
 
 	logger.Blue("Paused container: %s", nameOrID)
+	d.publish(events.ContainerPaused, nameOrID, "paused")
 	return nil
 }
 
 // UnpauseContainer unpauses a container
 func (d *DockerManager) UnpauseContainer(nameOrID string) error {
-	if !d.IsDockerAvailable() {
-		return fmt.Errorf("docker is not available")
-	}
-
-	output := d.cmdExecutor.GetCmdOutput("docker", "unpause", nameOrID)
-
-	// Check if there were any errors
-	for _, line := range output {
-		if strings.Contains(line, "Error") {
-			return fmt.Errorf("error unpausing container: %s", line)
-		}
+	if err := d.containerAction(nameOrID, "unpause", nil, 0); err != nil {
+		return fmt.Errorf("error unpausing container: %w", err)
 	}
 
 	logger.Blue("Unpaused container: %s", nameOrID)
+	d.publish(events.ContainerUnpaused, nameOrID, "running")
 	return nil
 }
 
 // RemoveContainer removes a container
 func (d *DockerManager) RemoveContainer(nameOrID string, force bool) error {
-	if !d.IsDockerAvailable() {
-		return fmt.Errorf("docker is not available")
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), engineTimeout)
+	defer cancel()
 
-	args := []string{"rm"}
+	query := url.Values{}
 	if force {
-		args = append(args, "--force")
+		query.Set("force", "1")
 	}
-	args = append(args, nameOrID)
-
-	output := d.cmdExecutor.GetCmdOutput("docker", args...)
-
-	// Check if there were any errors
-	for _, line := range output {
-		if strings.Contains(line, "Error") {
-			return fmt.Errorf("error removing container: %s", line)
-		}
+	if err := engineCall(ctx, d.client, http.MethodDelete, "/containers/"+url.PathEscape(nameOrID), query); err != nil {
+		return fmt.Errorf("error removing container: %w", containerError(nameOrID, err))
 	}
 
 	logger.Blue("Removed container: %s", nameOrID)
+	d.publish(events.ContainerRemoved, nameOrID, "removed")
 	return nil
 }
 
 // GetDockerInfo returns Docker system information
 func (d *DockerManager) GetDockerInfo() (map[string]interface{}, error) {
-	if !d.IsDockerAvailable() {
-		return nil, fmt.Errorf("docker is not available")
-	}
-
-	output := d.cmdExecutor.GetCmdOutput("docker", "info", "--format", "json")
-	if len(output) == 0 {
-		return nil, fmt.Errorf("failed to get Docker info")
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), engineTimeout)
+	defer cancel()
 
 	var info map[string]interface{}
-	jsonStr := strings.Join(output, "")
-	if err := json.Unmarshal([]byte(jsonStr), &info); err != nil {
-		return nil, fmt.Errorf("failed to parse Docker info: %w", err)
+	if err := engineJSON(ctx, d.client, http.MethodGet, "/info", nil, &info); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDockerUnavailable, err)
 	}
-
 	return info, nil
 }
 
@@ -572,39 +542,33 @@ func (d *DockerManager) GetDockerInfo() (map[string]interface{}, error) {
 func (d *DockerManager) ListNetworks() ([]DockerNetwork, error) {
 	networks := make([]DockerNetwork, 0)
 
-	if !d.IsDockerAvailable() {
-		return networks, fmt.Errorf("docker is not available")
+	ctx, cancel := context.WithTimeout(context.Background(), engineTimeout)
+	defer cancel()
+
+	var list []engineNetwork
+	if err := engineJSON(ctx, d.client, http.MethodGet, "/networks", nil, &list); err != nil {
+		return networks, fmt.Errorf("%w: %v", ErrDockerUnavailable, err)
+	}
+
+	for _, entry := range list {
+		networks = append(networks, DockerNetwork{
+			ID:         entry.ID,
+			Name:       entry.Name,
+			Driver:     entry.Driver,
+			Scope:      entry.Scope,
+			Internal:   entry.Internal,
+			Attachable: entry.Attachable,
+			Ingress:    entry.Ingress,
+			IPAM: DockerIPAM{
+				Driver:  entry.IPAM.Driver,
+				Config:  entry.IPAM.Config,
+				Options: entry.IPAM.Options,
+			},
+			Created: entry.Created,
+			Options: entry.Options,
+			Labels:  entry.Labels,
+		})
 	}
-
-	output := d.cmdExecutor.GetCmdOutput("docker", "network", "ls", "--format", "json", "--no-trunc")
-	logger.Blue("Docker network ls output: %d lines", len(output))
-
-	for i, line := range output {
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
-
-		logger.Blue("Processing network line %d: %s", i, line[:min(100, len(line))])
-
-		// Parse the docker network ls JSON format
-		var networkData map[string]interface{}
-		if err := json.Unmarshal([]byte(line), &networkData); err != nil {
-			logger.Yellow("Failed to parse network JSON: %v", err)
-			continue
-		}
-
-		// Convert to our DockerNetwork format
-		network := DockerNetwork{}
-		d.parseNetworkData(&network, networkData)
-
-		// Get detailed network information
-		if err := d.getNetworkDetails(&network); err != nil {
-			logger.Yellow("Failed to get network details for %s: %v", network.ID, err)
-		}
-
-		networks = append(networks, network)
-	}
-
 	return networks, nil
 }
 
@@ -612,532 +576,101 @@ func (d *DockerManager) ListNetworks() ([]DockerNetwork, error) {
 func (d *DockerManager) ListImages() ([]DockerImage, error) {
 	images := make([]DockerImage, 0)
 
-	if !d.IsDockerAvailable() {
-		return images, fmt.Errorf("docker is not available")
-	}
-
-	output := d.cmdExecutor.GetCmdOutput("docker", "images", "--format", "json", "--no-trunc")
-	logger.Blue("Docker images output: %d lines", len(output))
-
-	for i, line := range output {
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
-
-		logger.Blue("Processing image line %d: %s", i, line[:min(100, len(line))])
-
-		// Parse the docker images JSON format
-		var imageData map[string]interface{}
-		if err := json.Unmarshal([]byte(line), &imageData); err != nil {
-			logger.Yellow("Failed to parse image JSON: %v", err)
-			continue
-		}
-
-		// Convert to our DockerImage format
-		image := DockerImage{}
-		d.parseImageData(&image, imageData)
-
-		// Get detailed image information
-		if err := d.getImageDetails(&image); err != nil {
-			logger.Yellow("Failed to get image details for %s: %v", image.ID, err)
-		}
-
-		images = append(images, image)
-	}
-
-	return images, nil
-}
-
-// parseImageData parses docker images JSON output into DockerImage
-func (d *DockerManager) parseImageData(image *DockerImage, data map[string]interface{}) {
-	if id, ok := data["ID"].(string); ok {
-		image.ID = id
-	}
-
-	if repository, ok := data["Repository"].(string); ok {
-		image.Repository = repository
-	}
-
-	if tag, ok := data["Tag"].(string); ok {
-		image.Tag = tag
-	}
-
-	if digest, ok := data["Digest"].(string); ok {
-		image.Digest = digest
-	}
-
-	if size, ok := data["Size"].(string); ok {
-		// Parse size string (e.g., "1.23GB", "456MB")
-		if sizeBytes, err := d.parseSizeString(size); err == nil {
-			image.Size = sizeBytes
-		}
-	}
-
-	if createdAt, ok := data["CreatedAt"].(string); ok {
-		// Parse the created time - format: "2025-06-13 04:01:43 +1000 AEST"
-		if t, err := time.Parse("2006-01-02 15:04:05 -0700 MST", createdAt); err == nil {
-			image.Created = t
-		}
-	}
-
-}
-
-// getImageDetails gets detailed information about an image using docker image inspect
-func (d *DockerManager) getImageDetails(image *DockerImage) error {
-	output := d.cmdExecutor.GetCmdOutput("docker", "image", "inspect", image.ID)
-	if len(output) == 0 {
-		return fmt.Errorf("failed to inspect image %s", image.ID)
-	}
-
-	// Parse the JSON output
-	jsonStr := strings.Join(output, "")
-	var inspectData []map[string]interface{}
-	if err := json.Unmarshal([]byte(jsonStr), &inspectData); err != nil {
-		return fmt.Errorf("failed to parse image inspect output: %w", err)
-	}
-
-	if len(inspectData) == 0 {
-		return fmt.Errorf("no image data found")
-	}
-
-	return d.parseImageInspectData(image, inspectData[0])
-}
-
-// parseImageInspectData parses Docker image inspect data into DockerImage
-func (d *DockerManager) parseImageInspectData(image *DockerImage, data map[string]interface{}) error {
-	// Parse basic information
-	if id, ok := data["Id"].(string); ok {
-		image.ID = id
-	}
-
-	if repoTags, ok := data["RepoTags"].([]interface{}); ok {
-		image.RepoTags = make([]string, 0, len(repoTags))
-		for _, tag := range repoTags {
-			if str, ok := tag.(string); ok {
-				image.RepoTags = append(image.RepoTags, str)
-			}
-		}
-	}
-
-	if repoDigests, ok := data["RepoDigests"].([]interface{}); ok {
-		image.RepoDigests = make([]string, 0, len(repoDigests))
-		for _, digest := range repoDigests {
-			if str, ok := digest.(string); ok {
-				image.RepoDigests = append(image.RepoDigests, str)
-			}
-		}
-	}
-
-	// Parse created time
-	if created, ok := data["Created"].(string); ok {
-		if t, err := time.Parse(time.RFC3339Nano, created); err == nil {
-			image.Created = t
-		}
-	}
-
-	// Parse size
-	if size, ok := data["Size"].(float64); ok {
-		image.Size = int64(size)
-	}
-
-	// Parse config for labels
-	if config, ok := data["Config"].(map[string]interface{}); ok {
-		if labels, ok := config["Labels"].(map[string]interface{}); ok {
-			image.Labels = make(map[string]string)
-			for k, v := range labels {
-				if str, ok := v.(string); ok {
-					image.Labels[k] = str
-				}
-			}
-		}
-	}
-
-	return nil
-}
-
-// parseSizeString parses Docker size strings like "1.23GB", "456MB" into bytes
-func (d *DockerManager) parseSizeString(sizeStr string) (int64, error) {
-	sizeStr = strings.TrimSpace(sizeStr)
-	if sizeStr == "" {
-		return 0, nil
-	}
-
-	// Extract numeric part and unit
-	var numStr string
-	var unit string
-
-	for i, r := range sizeStr {
-		if r >= '0' && r <= '9' || r == '.' {
-			numStr += string(r)
-		} else {
-			unit = sizeStr[i:]
-			break
-		}
-	}
-
-	if numStr == "" {
-		return 0, fmt.Errorf("invalid size format: %s", sizeStr)
-	}
-
-	size, err := strconv.ParseFloat(numStr, 64)
-	if err != nil {
-		return 0, fmt.Errorf("invalid size number: %s", numStr)
-	}
-
-	// Convert to bytes based on unit
-	unit = strings.ToUpper(strings.TrimSpace(unit))
-	switch unit {
-	case "B", "BYTES":
-		return int64(size), nil
-	case "KB", "K":
-		return int64(size * 1024), nil
-	case "MB", "M":
-		return int64(size * 1024 * 1024), nil
-	case "GB", "G":
-		return int64(size * 1024 * 1024 * 1024), nil
-	case "TB", "T":
-		return int64(size * 1024 * 1024 * 1024 * 1024), nil
-	default:
-		// If no unit, assume bytes
-		return int64(size), nil
-	}
-}
-
-// parseNetworkData parses docker network ls JSON output into DockerNetwork
-func (d *DockerManager) parseNetworkData(network *DockerNetwork, data map[string]interface{}) {
-	if id, ok := data["ID"].(string); ok {
-		network.ID = id
-	}
-
-	if name, ok := data["Name"].(string); ok {
-		network.Name = name
-	}
-
-	if driver, ok := data["Driver"].(string); ok {
-		network.Driver = driver
-	}
-
-	if scope, ok := data["Scope"].(string); ok {
-		network.Scope = scope
-	}
-
-	if createdAt, ok := data["CreatedAt"].(string); ok {
-		// Parse the created time - format: "2025-06-13 04:01:43 +1000 AEST"
-		if t, err := time.Parse("2006-01-02 15:04:05 -0700 MST", createdAt); err == nil {
-			network.Created = t
-		}
-	}
-
-}
-
-// getNetworkDetails gets detailed information about a network using docker network inspect
-func (d *DockerManager) getNetworkDetails(network *DockerNetwork) error {
-	output := d.cmdExecutor.GetCmdOutput("docker", "network", "inspect", network.ID)
-	if len(output) == 0 {
-		return fmt.Errorf("failed to inspect network %s", network.ID)
-	}
-
-	// Parse the JSON output
-	jsonStr := strings.Join(output, "")
-	var inspectData []map[string]interface{}
-	if err := json.Unmarshal([]byte(jsonStr), &inspectData); err != nil {
-		return fmt.Errorf("failed to parse network inspect output: %w", err)
-	}
-
-	if len(inspectData) == 0 {
-		return fmt.Errorf("no network data found")
-	}
-
-	return d.parseNetworkInspectData(network, inspectData[0])
-}
-
-// parseNetworkInspectData parses Docker network inspect data into DockerNetwork
-func (d *DockerManager) parseNetworkInspectData(network *DockerNetwork, data map[string]interface{}) error {
-	// Parse basic information
-	if id, ok := data["Id"].(string); ok {
-		network.ID = id
-	}
-
-	if name, ok := data["Name"].(string); ok {
-		network.Name = name
-	}
-
-	if driver, ok := data["Driver"].(string); ok {
-		network.Driver = driver
-	}
-
-	if scope, ok := data["Scope"].(string); ok {
-		network.Scope = scope
-	}
-
-	if internal, ok := data["Internal"].(bool); ok {
-		network.Internal = internal
-	}
-
-	if attachable, ok := data["Attachable"].(bool); ok {
-		network.Attachable = attachable
-	}
-
-	if ingress, ok := data["Ingress"].(bool); ok {
-		network.Ingress = ingress
-	}
-
-	// Parse created time
-	if created, ok := data["Created"].(string); ok {
-		if t, err := time.Parse(time.RFC3339Nano, created); err == nil {
-			network.Created = t
-		}
-	}
-
-	// Parse IPAM configuration
-	if ipamData, ok := data["IPAM"].(map[string]interface{}); ok {
-		network.IPAM = DockerIPAM{}
-		if driver, ok := ipamData["Driver"].(string); ok {
-			network.IPAM.Driver = driver
-		}
-
-		if configData, ok := ipamData["Config"].([]interface{}); ok {
-			network.IPAM.Config = make([]DockerIPAMConfig, 0)
-			for _, configItem := range configData {
-				if configMap, ok := configItem.(map[string]interface{}); ok {
-					config := DockerIPAMConfig{}
-					if subnet, ok := configMap["Subnet"].(string); ok {
-						config.Subnet = subnet
-					}
-					if gateway, ok := configMap["Gateway"].(string); ok {
-						config.Gateway = gateway
-					}
-					network.IPAM.Config = append(network.IPAM.Config, config)
-				}
+	ctx, cancel := context.WithTimeout(context.Background(), engineTimeout)
+	defer cancel()
+
+	var list []engineImage
+	if err := engineJSON(ctx, d.client, http.MethodGet, "/images/json", nil, &list); err != nil {
+		return images, fmt.Errorf("%w: %v", ErrDockerUnavailable, err)
+	}
+
+	for _, entry := range list {
+		image := DockerImage{
+			ID:          entry.ID,
+			Repository:  "<none>",
+			Tag:         "<none>",
+			Size:        entry.Size,
+			Created:     time.Unix(entry.Created, 0),
+			Labels:      entry.Labels,
+			RepoTags:    entry.RepoTags,
+			RepoDigests: entry.RepoDigests,
+		}
+		if len(entry.RepoTags) > 0 && entry.RepoTags[0] != "<none>:<none>" {
+			// The tag follows the last colon unless it belongs to a registry port
+			tag := entry.RepoTags[0]
+			if i := strings.LastIndex(tag, ":"); i > strings.LastIndex(tag, "/") {
+				image.Repository, image.Tag = tag[:i], tag[i+1:]
+			} else {
+				image.Repository = tag
 			}
 		}
-
-		if options, ok := ipamData["Options"].(map[string]interface{}); ok {
-			network.IPAM.Options = make(map[string]string)
-			for k, v := range options {
-				if str, ok := v.(string); ok {
-					network.IPAM.Options[k] = str
-				}
-			}
-		}
-	}
-
-	// Parse options
-	if options, ok := data["Options"].(map[string]interface{}); ok {
-		network.Options = make(map[string]string)
-		for k, v := range options {
-			if str, ok := v.(string); ok {
-				network.Options[k] = str
-			}
-		}
-	}
-
-	// Parse labels
-	if labels, ok := data["Labels"].(map[string]interface{}); ok {
-		network.Labels = make(map[string]string)
-		for k, v := range labels {
-			if str, ok := v.(string); ok {
-				network.Labels[k] = str
+		if len(entry.RepoDigests) > 0 {
+			if _, digest, ok := strings.Cut(entry.RepoDigests[0], "@"); ok {
+				image.Digest = digest
 			}
 		}
+		images = append(images, image)
 	}
-
-	return nil
+	return images, nil
 }
 
-// getContainerDetails gets detailed information about a container
-func (d *DockerManager) getContainerDetails(container *ContainerInfo) error {
-	// Get detailed inspect information
-	output := d.cmdExecutor.GetCmdOutput("docker", "inspect", container.ID)
-	if len(output) == 0 {
-		return fmt.Errorf("failed to inspect container")
-	}
+// ListVolumes returns a list of Docker volumes
+func (d *DockerManager) ListVolumes() ([]DockerVolume, error) {
+	volumes := make([]DockerVolume, 0)
 
-	// Parse the JSON output
-	jsonStr := strings.Join(output, "")
-	var inspectData []map[string]interface{}
-	if err := json.Unmarshal([]byte(jsonStr), &inspectData); err != nil {
-		return err
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), engineTimeout)
+	defer cancel()
 
-	if len(inspectData) > 0 {
-		return d.parseInspectData(container, inspectData[0])
+	var list struct {
+		Volumes []engineVolume `json:"Volumes"`
 	}
-
-	return nil
-}
-
-// parseInspectData parses Docker inspect data into ContainerInfo
-func (d *DockerManager) parseInspectData(container *ContainerInfo, data map[string]interface{}) error {
-	// Parse basic information
-	if id, ok := data["Id"].(string); ok {
-		container.ID = id
-	}
-
-	if name, ok := data["Name"].(string); ok {
-		container.Name = strings.TrimPrefix(name, "/")
+	if err := engineJSON(ctx, d.client, http.MethodGet, "/volumes", nil, &list); err != nil {
+		return volumes, fmt.Errorf("%w: %v", ErrDockerUnavailable, err)
 	}
 
-	// Parse config
-	if config, ok := data["Config"].(map[string]interface{}); ok {
-		if image, ok := config["Image"].(string); ok {
-			container.Image = image
+	for _, entry := range list.Volumes {
+		volume := DockerVolume{
+			Name:       entry.Name,
+			Driver:     entry.Driver,
+			Mountpoint: entry.Mountpoint,
+			Scope:      entry.Scope,
+			Labels:     entry.Labels,
+			Options:    entry.Options,
 		}
-
-		if labels, ok := config["Labels"].(map[string]interface{}); ok {
-			container.Labels = make(map[string]string)
-			for k, v := range labels {
-				if str, ok := v.(string); ok {
-					container.Labels[k] = str
-				}
-			}
-		}
-
-		if env, ok := config["Env"].([]interface{}); ok {
-			container.Environment = make([]string, 0, len(env))
-			for _, e := range env {
-				if str, ok := e.(string); ok {
-					container.Environment = append(container.Environment, str)
-				}
-			}
-		}
-	}
-
-	// Parse state
-	if state, ok := data["State"].(map[string]interface{}); ok {
-		if status, ok := state["Status"].(string); ok {
-			container.Status = status
-			container.State = status
-		}
-
-		if startedAt, ok := state["StartedAt"].(string); ok {
-			if t, err := time.Parse(time.RFC3339Nano, startedAt); err == nil {
-				container.StartedAt = t
-			}
+		if t, err := time.Parse(time.RFC3339Nano, entry.CreatedAt); err == nil {
+			volume.Created = t
 		}
+		volumes = append(volumes, volume)
 	}
-
-	// Parse created time
-	if created, ok := data["Created"].(string); ok {
-		if t, err := time.Parse(time.RFC3339Nano, created); err == nil {
-			container.Created = t
-		}
-	}
-
-	// Parse host config
-	if hostConfig, ok := data["HostConfig"].(map[string]interface{}); ok {
-		if restartPolicy, ok := hostConfig["RestartPolicy"].(map[string]interface{}); ok {
-			if name, ok := restartPolicy["Name"].(string); ok {
-				container.RestartPolicy = name
-			}
-		}
-
-		// Parse port bindings
-		if portBindings, ok := hostConfig["PortBindings"].(map[string]interface{}); ok {
-			container.Ports = d.parsePortBindings(portBindings)
-		}
-
-		// Parse mounts
-		if mounts, ok := hostConfig["Mounts"].([]interface{}); ok {
-			container.Mounts = d.parseMounts(mounts)
-		}
-	}
-
-	// Parse network settings
-	if networkSettings, ok := data["NetworkSettings"].(map[string]interface{}); ok {
-		if networks, ok := networkSettings["Networks"].(map[string]interface{}); ok {
-			container.Networks = d.parseNetworks(networks)
-		}
-	}
-
-	return nil
+	return volumes, nil
 }
 
-// parsePortBindings parses port binding information
-func (d *DockerManager) parsePortBindings(portBindings map[string]interface{}) []PortMapping {
-	ports := make([]PortMapping, 0)
-
-	for containerPort, bindings := range portBindings {
-		if bindingList, ok := bindings.([]interface{}); ok {
-			for _, binding := range bindingList {
-				if bindingMap, ok := binding.(map[string]interface{}); ok {
-					port := PortMapping{
-						ContainerPort: containerPort,
-					}
-
-					if hostIP, ok := bindingMap["HostIp"].(string); ok {
-						port.HostIP = hostIP
-					}
-					if hostPort, ok := bindingMap["HostPort"].(string); ok {
-						port.HostPort = hostPort
-					}
-
-					// Extract protocol from container port (e.g., "80/tcp")
-					if parts := strings.Split(containerPort, "/"); len(parts) == 2 {
-						port.ContainerPort = parts[0]
-						port.Protocol = parts[1]
-					}
-
-					ports = append(ports, port)
-				}
-			}
-		}
+// StreamEvents calls fn with each daemon event matching filters, such as
+// {"type": ["container"]}, until ctx is done, the daemon closes the stream
+// or fn fails. A non-zero since replays the events from then on first.
+func (d *DockerManager) StreamEvents(ctx context.Context, since time.Time, filters map[string][]string, fn func(*EngineEvent) error) error {
+	query := url.Values{}
+	if !since.IsZero() {
+		query.Set("since", strconv.FormatInt(since.Unix(), 10))
 	}
-
-	return ports
-}
-
-// parseMounts parses mount information
-func (d *DockerManager) parseMounts(mounts []interface{}) []MountInfo {
-	mountList := make([]MountInfo, 0)
-
-	for _, mount := range mounts {
-		if mountMap, ok := mount.(map[string]interface{}); ok {
-			mountInfo := MountInfo{}
-
-			if mountType, ok := mountMap["Type"].(string); ok {
-				mountInfo.Type = mountType
-			}
-			if source, ok := mountMap["Source"].(string); ok {
-				mountInfo.Source = source
-			}
-			if destination, ok := mountMap["Destination"].(string); ok {
-				mountInfo.Destination = destination
-			}
-			if mode, ok := mountMap["Mode"].(string); ok {
-				mountInfo.Mode = mode
-			}
-			if rw, ok := mountMap["RW"].(bool); ok {
-				mountInfo.ReadWrite = rw
-			}
-
-			mountList = append(mountList, mountInfo)
+	if len(filters) > 0 {
+		encoded, err := json.Marshal(filters)
+		if err != nil {
+			return err
 		}
+		query.Set("filters", string(encoded))
 	}
 
-	return mountList
-}
-
-// parseNetworks parses network information
-func (d *DockerManager) parseNetworks(networks map[string]interface{}) []NetworkInfo {
-	networkList := make([]NetworkInfo, 0)
-
-	for name, network := range networks {
-		if networkMap, ok := network.(map[string]interface{}); ok {
-			networkInfo := NetworkInfo{
-				Name: name,
-			}
-
-			if ipAddress, ok := networkMap["IPAddress"].(string); ok {
-				networkInfo.IPAddress = ipAddress
-			}
-			if gateway, ok := networkMap["Gateway"].(string); ok {
-				networkInfo.Gateway = gateway
-			}
-
-			networkList = append(networkList, networkInfo)
+	err := engineStream(ctx, d.client, "/events", query, func(decoder *json.Decoder) error {
+		var event EngineEvent
+		if err := decoder.Decode(&event); err != nil {
+			return err
 		}
+		return fn(&event)
+	})
+	var engineErr *EngineError
+	if err != nil && ctx.Err() == nil && !errors.As(err, &engineErr) {
+		return fmt.Errorf("%w: %v", ErrDockerUnavailable, err)
 	}
-
-	return networkList
+	return err
 }
@@ -1,113 +1,197 @@
 package docker
 
 import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 )
 
-// MockCommandExecutor provides mock Docker command responses for testing
-type MockCommandExecutor struct {
-	responses map[string][]string
+// MockEngine is a fake Docker daemon serving canned Engine API responses on a unix socket
+type MockEngine struct {
+	socketPath string
+	responses  map[string]mockResponse
+	handlers   map[string]http.HandlerFunc
+	requests   []string
+	mutex      sync.Mutex
 }
 
-func NewMockCommandExecutor() *MockCommandExecutor {
-	return &MockCommandExecutor{
-		responses: make(map[string][]string),
+type mockResponse struct {
+	status int
+	body   string
+}
+
+func NewMockEngine(tb testing.TB) *MockEngine {
+	tb.Helper()
+
+	dir, err := os.MkdirTemp("", "uma-docker")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	m := &MockEngine{
+		socketPath: filepath.Join(dir, "docker.sock"),
+		responses:  make(map[string]mockResponse),
+		handlers:   make(map[string]http.HandlerFunc),
 	}
+	listener, err := net.Listen("unix", m.socketPath)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(m.serveHTTP))
+	server.Listener = listener
+	server.Start()
+	tb.Cleanup(func() {
+		server.Close()
+		os.RemoveAll(dir)
+	})
+	return m
 }
 
-func (m *MockCommandExecutor) GetCmdOutput(command string, args ...string) []string {
-	key := command + " " + strings.Join(args, " ")
-	if response, exists := m.responses[key]; exists {
-		return response
+func (m *MockEngine) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/"+engineAPIVersion)
+	request := r.Method + " " + path
+	if r.URL.RawQuery != "" {
+		request += "?" + r.URL.RawQuery
 	}
-	// Return empty slice for unknown commands (simulates command not found)
-	return []string{}
+
+	m.mutex.Lock()
+	m.requests = append(m.requests, request)
+	response, exists := m.responses[r.Method+" "+path]
+	handler := m.handlers[r.Method+" "+path]
+	m.mutex.Unlock()
+
+	if handler != nil {
+		handler(w, r)
+		return
+	}
+
+	if !exists {
+		// Unknown paths answer as the daemon does for unknown containers
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"message":"No such container: %s"}`, path)
+		return
+	}
+	w.WriteHeader(response.status)
+	io.WriteString(w, response.body)
 }
 
-func (m *MockCommandExecutor) SetResponse(command string, args []string, response []string) {
-	key := command + " " + strings.Join(args, " ")
-	m.responses[key] = response
+func (m *MockEngine) SetResponse(method, path string, status int, body string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.responses[method+" "+path] = mockResponse{status: status, body: body}
 }
 
-// setupMockDockerManager creates a Docker manager with mocked responses for testing
-func setupMockDockerManager() (*DockerManager, *MockCommandExecutor) {
-	mockExecutor := NewMockCommandExecutor()
+// SetHandler serves requests for method and path with handler, for responses
+// that are not canned such as upgraded connections
+func (m *MockEngine) SetHandler(method, path string, handler http.HandlerFunc) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.handlers[method+" "+path] = handler
+}
+
+// Requests returns the requests received, such as "POST /containers/web/stop?t=10"
+func (m *MockEngine) Requests() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]string(nil), m.requests...)
+}
+
+func (m *MockEngine) Manager() *DockerManager {
+	return NewDockerManagerWithClient(NewSocketClient(m.socketPath))
+}
+
+// logFrame multiplexes text as the daemon does for containers without a TTY
+func logFrame(stream byte, text string) string {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(text)))
+	return string(header) + text
+}
+
+const mockStats = `{"id":"abc123","name":"/test-container",` +
+	`"cpu_stats":{"cpu_usage":{"total_usage":2000000000},"system_cpu_usage":20000000000,"online_cpus":4},` +
+	`"precpu_stats":{"cpu_usage":{"total_usage":1000000000},"system_cpu_usage":10000000000,"online_cpus":4},` +
+	`"memory_stats":{"usage":300000000,"limit":2000000000,"stats":{"inactive_file":100000000}},` +
+	`"networks":{"eth0":{"rx_bytes":1200,"tx_bytes":2300}},` +
+	`"blkio_stats":{"io_service_bytes_recursive":[{"op":"read","value":10000000},{"op":"write","value":5000000}]}}`
+
+// setupMockDockerManager creates a Docker manager talking to a fake daemon for testing
+func setupMockDockerManager(tb testing.TB) (*DockerManager, *MockEngine) {
+	engine := NewMockEngine(tb)
 
 	// Mock Docker availability check
-	mockExecutor.SetResponse("docker", []string{"version", "--format", "{{.Server.Version}}"}, []string{"20.10.8"})
+	engine.SetResponse("GET", "/_ping", http.StatusOK, "OK")
 
 	// Mock Docker info
-	mockExecutor.SetResponse("docker", []string{"info", "--format", "json"}, []string{
-		`{"ServerVersion":"20.10.8","Containers":5,"ContainersRunning":3,"ContainersPaused":0,"ContainersStopped":2,"Images":10}`,
-	})
+	engine.SetResponse("GET", "/info", http.StatusOK,
+		`{"ServerVersion":"20.10.8","Containers":5,"ContainersRunning":3,"ContainersPaused":0,"ContainersStopped":2,"Images":10}`)
 
 	// Mock container listing
-	mockExecutor.SetResponse("docker", []string{"ps", "--format", "json", "--no-trunc", "--all"}, []string{
-		`{"ID":"abc123","Names":"test-container","Image":"nginx:latest","Status":"Up 2 hours","State":"running","CreatedAt":"2024-06-21 10:00:00 +0000 UTC"}`,
-		`{"ID":"def456","Names":"test-container-2","Image":"redis:latest","Status":"Exited (0) 1 hour ago","State":"exited","CreatedAt":"2024-06-21 09:00:00 +0000 UTC"}`,
-	})
+	engine.SetResponse("GET", "/containers/json", http.StatusOK, `[
+		{"Id":"abc123","Names":["/test-container"],"Image":"nginx:latest","Created":1718964000,"State":"running","Status":"Up 2 hours",
+		 "Ports":[{"IP":"0.0.0.0","PrivatePort":80,"PublicPort":8080,"Type":"tcp"}]},
+		{"Id":"def456","Names":["/test-container-2"],"Image":"redis:latest","Created":1718960400,"State":"exited","Status":"Exited (0) 1 hour ago"}
+	]`)
 
 	// Mock container inspect
-	mockExecutor.SetResponse("docker", []string{"inspect", "test_container"}, []string{
-		`[{"Id":"abc123","Name":"/test-container","State":{"Status":"running","Running":true},"Config":{"Image":"nginx:latest"}}]`,
-	})
+	engine.SetResponse("GET", "/containers/test_container/json", http.StatusOK,
+		`{"Id":"abc123","Name":"/test-container","Created":"2024-06-21T10:00:00.000000000Z","State":{"Status":"running","Running":true},"Config":{"Image":"nginx:latest"},
+		  "HostConfig":{"RestartPolicy":{"Name":"unless-stopped"},"PortBindings":{"80/tcp":[{"HostIp":"","HostPort":"8080"}]}},
+		  "NetworkSettings":{"Networks":{"bridge":{"IPAddress":"172.17.0.2","Gateway":"172.17.0.1"}}}}`)
 
-	// Mock inspect for container IDs returned by ps command
-	mockExecutor.SetResponse("docker", []string{"inspect", "abc123"}, []string{
-		`[{"Id":"abc123","Name":"/test-container","State":{"Status":"running","Running":true},"Config":{"Image":"nginx:latest"},"Created":"2024-06-21T10:00:00.000000000Z"}]`,
-	})
-	mockExecutor.SetResponse("docker", []string{"inspect", "def456"}, []string{
-		`[{"Id":"def456","Name":"/test-container-2","State":{"Status":"exited","Running":false},"Config":{"Image":"redis:latest"},"Created":"2024-06-21T09:00:00.000000000Z"}]`,
-	})
+	// Mock inspect for container IDs returned by the list
+	engine.SetResponse("GET", "/containers/abc123/json", http.StatusOK,
+		`{"Id":"abc123","Name":"/test-container","Created":"2024-06-21T10:00:00.000000000Z","State":{"Status":"running","Running":true},"Config":{"Image":"nginx:latest"}}`)
+	engine.SetResponse("GET", "/containers/def456/json", http.StatusOK,
+		`{"Id":"def456","Name":"/test-container-2","Created":"2024-06-21T09:00:00.000000000Z","State":{"Status":"exited","Running":false},"Config":{"Image":"redis:latest"}}`)
 
 	// Mock container operations
-	mockExecutor.SetResponse("docker", []string{"start", "test_container"}, []string{"test_container"})
-	mockExecutor.SetResponse("docker", []string{"stop", "--time", "10", "test_container"}, []string{"test_container"})
-	mockExecutor.SetResponse("docker", []string{"restart", "--time", "10", "test_container"}, []string{"test_container"})
+	engine.SetResponse("POST", "/containers/test_container/start", http.StatusNoContent, "")
+	engine.SetResponse("POST", "/containers/test_container/stop", http.StatusNoContent, "")
+	engine.SetResponse("POST", "/containers/test_container/restart", http.StatusNoContent, "")
+	engine.SetResponse("POST", "/containers/test_container/pause", http.StatusNoContent, "")
+	engine.SetResponse("POST", "/containers/test_container/unpause", http.StatusNoContent, "")
+	engine.SetResponse("DELETE", "/containers/test_container", http.StatusNoContent, "")
+
+	// Mock logs and stats
+	engine.SetResponse("GET", "/containers/test_container/logs", http.StatusOK,
+		logFrame(1, "2024-06-21 10:00:00 Starting application\n")+logFrame(2, "2024-06-21 10:00:01 Application ready\n"))
+	engine.SetResponse("GET", "/containers/test_container/stats", http.StatusOK, mockStats)
 
 	// Mock image listing
-	mockExecutor.SetResponse("docker", []string{"images", "--format", "json", "--no-trunc"}, []string{
-		`{"ID":"sha256:abc123","Repository":"nginx","Tag":"latest","Size":133000000,"CreatedAt":"2024-06-20 10:00:00 +0000 UTC"}`,
-	})
-
-	// Mock image inspect
-	mockExecutor.SetResponse("docker", []string{"image", "inspect", "sha256:abc123"}, []string{
-		`[{"Id":"sha256:abc123","RepoTags":["nginx:latest"],"Size":133000000,"Created":"2024-06-20T10:00:00.000000000Z"}]`,
-	})
+	engine.SetResponse("GET", "/images/json", http.StatusOK,
+		`[{"Id":"sha256:abc123","RepoTags":["nginx:latest"],"RepoDigests":["nginx@sha256:def789"],"Size":133000000,"Created":1718877600}]`)
 
 	// Mock network listing
-	mockExecutor.SetResponse("docker", []string{"network", "ls", "--format", "json", "--no-trunc"}, []string{
-		`{"ID":"net123","Name":"bridge","Driver":"bridge","Scope":"local"}`,
-	})
+	engine.SetResponse("GET", "/networks", http.StatusOK,
+		`[{"Id":"net123","Name":"bridge","Driver":"bridge","Scope":"local","Created":"2024-06-20T10:00:00.000000000Z"}]`)
 
-	// Mock network inspect
-	mockExecutor.SetResponse("docker", []string{"network", "inspect", "net123"}, []string{
-		`[{"Id":"net123","Name":"bridge","Driver":"bridge","Scope":"local","Created":"2024-06-20T10:00:00.000000000Z"}]`,
-	})
+	// Mock volume listing
+	engine.SetResponse("GET", "/volumes", http.StatusOK,
+		`{"Volumes":[{"Name":"data","Driver":"local","Mountpoint":"/var/lib/docker/volumes/data/_data","CreatedAt":"2024-06-20T10:00:00Z","Scope":"local"}]}`)
 
-	manager := NewDockerManagerWithExecutor(mockExecutor)
-	return manager, mockExecutor
+	return engine.Manager(), engine
 }
 
 // TestContainerOperations tests pause, unpause, and remove operations
 func TestContainerOperations(t *testing.T) {
-	mockExecutor := NewMockCommandExecutor()
-
-	// Mock Docker availability check
-	mockExecutor.SetResponse("docker", []string{"version", "--format", "{{.Server.Version}}"}, []string{"20.10.8"})
-
-	// Mock pause operation
-	mockExecutor.SetResponse("docker", []string{"pause", "test_container"}, []string{"test_container"})
-
-	// Mock unpause operation
-	mockExecutor.SetResponse("docker", []string{"unpause", "test_container"}, []string{"test_container"})
-
-	// Mock remove operation
-	mockExecutor.SetResponse("docker", []string{"rm", "--force", "test_container"}, []string{"test_container"})
-
-	manager := NewDockerManagerWithExecutor(mockExecutor)
+	manager, engine := setupMockDockerManager(t)
 
 	// Test pause
 	err := manager.PauseContainer("test_container")
@@ -126,22 +210,16 @@ func TestContainerOperations(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Unexpected error removing container: %v", err)
 	}
+
+	want := "[POST /containers/test_container/pause POST /containers/test_container/unpause DELETE /containers/test_container?force=1]"
+	if got := fmt.Sprint(engine.Requests()); got != want {
+		t.Errorf("Expected requests %s, got %s", want, got)
+	}
 }
 
 // TestContainerLogs tests container log retrieval
 func TestContainerLogs(t *testing.T) {
-	mockExecutor := NewMockCommandExecutor()
-
-	// Mock Docker availability check
-	mockExecutor.SetResponse("docker", []string{"version", "--format", "{{.Server.Version}}"}, []string{"20.10.8"})
-
-	// Mock logs operation
-	mockExecutor.SetResponse("docker", []string{"logs", "--tail", "100", "test_container"}, []string{
-		"2024-06-21 10:00:00 Starting application",
-		"2024-06-21 10:00:01 Application ready",
-	})
-
-	manager := NewDockerManagerWithExecutor(mockExecutor)
+	manager, engine := setupMockDockerManager(t)
 
 	logs, err := manager.GetContainerLogs("test_container", 100, false)
 	if err != nil {
@@ -149,23 +227,197 @@ func TestContainerLogs(t *testing.T) {
 	}
 
 	if len(logs) != 2 {
-		t.Errorf("Expected 2 log lines, got %d", len(logs))
+		t.Fatalf("Expected 2 log lines, got %d", len(logs))
+	}
+	if logs[1] != "2024-06-21 10:00:01 Application ready" {
+		t.Errorf("Unexpected stderr line: %q", logs[1])
+	}
+
+	requests := engine.Requests()
+	if last := requests[len(requests)-1]; last != "GET /containers/test_container/logs?stderr=1&stdout=1&tail=100" {
+		t.Errorf("Unexpected logs request: %s", last)
+	}
+
+	// Containers with a TTY send raw output
+	engine.SetResponse("GET", "/containers/tty/json", http.StatusOK, `{"Id":"tty","Name":"/tty","Config":{"Tty":true}}`)
+	engine.SetResponse("GET", "/containers/tty/logs", http.StatusOK, "one\ntwo\nthree\n")
+	logs, err = manager.GetContainerLogs("tty", 0, false)
+	if err != nil || len(logs) != 3 {
+		t.Errorf("Expected 3 TTY log lines, got %v (%v)", logs, err)
 	}
 }
 
-// TestContainerStats tests container statistics retrieval
-func TestContainerStats(t *testing.T) {
-	mockExecutor := NewMockCommandExecutor()
+// TestStreamContainerLogs tests demultiplexing and filtering log lines
+func TestStreamContainerLogs(t *testing.T) {
+	manager, engine := setupMockDockerManager(t)
+
+	// Lines split across frames and interleaved between streams, the last without a newline
+	engine.SetResponse("GET", "/containers/test_container/logs", http.StatusOK,
+		logFrame(1, "2024-06-21T10:00:00.5Z GET /hea")+
+			logFrame(2, "2024-06-21T10:00:01Z error: disk full\n")+
+			logFrame(1, "lth 200\n2024-06-21T10:00:02Z GET /api 500\n")+
+			logFrame(2, "2024-06-21T10:00:03Z error: retrying"))
+
+	var lines []LogLine
+	options := LogOptions{Tail: -1, Timestamps: true, Since: time.Unix(1718964000, 500)}
+	err := manager.StreamContainerLogs(context.Background(), "test_container", options, func(line LogLine) error {
+		lines = append(lines, line)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error streaming logs: %v", err)
+	}
+	var got []string
+	for _, line := range lines {
+		got = append(got, line.Stream+" "+line.Text)
+	}
+	want := "[stderr error: disk full stdout GET /health 200 stdout GET /api 500 stderr error: retrying]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("Expected %s, got %s", want, fmt.Sprint(got))
+	}
+	if !lines[0].Timestamp.Equal(time.Date(2024, 6, 21, 10, 0, 1, 0, time.UTC)) {
+		t.Errorf("Unexpected timestamp %v", lines[0].Timestamp)
+	}
 
-	// Mock Docker availability check
-	mockExecutor.SetResponse("docker", []string{"version", "--format", "{{.Server.Version}}"}, []string{"20.10.8"})
+	requests := engine.Requests()
+	if last := requests[len(requests)-1]; last != "GET /containers/test_container/logs?since=1718964000.000000500&stderr=1&stdout=1&timestamps=1" {
+		t.Errorf("Unexpected logs request: %s", last)
+	}
 
-	// Mock stats operation
-	mockExecutor.SetResponse("docker", []string{"stats", "--no-stream", "--format", "json", "test_container"}, []string{
-		`{"ID":"abc123","Name":"test_container","CPUPerc":"5.25%","MemUsage":"256MiB / 2GiB","MemPerc":"12.5%","NetIO":"1.2kB / 2.3kB","BlockIO":"10MB / 5MB","PIDs":"15"}`,
+	options.Grep = regexp.MustCompile(`error`)
+	options.Invert = true
+	got = nil
+	err = manager.StreamContainerLogs(context.Background(), "test_container", options, func(line LogLine) error {
+		got = append(got, line.Text)
+		return nil
 	})
+	if err != nil || fmt.Sprint(got) != "[GET /health 200 GET /api 500]" {
+		t.Errorf("Expected lines without errors, got %v (%v)", got, err)
+	}
+}
+
+// TestExec tests attaching to a process started in a container
+func TestExec(t *testing.T) {
+	manager, engine := setupMockDockerManager(t)
+	engine.SetResponse("POST", "/containers/test_container/exec", http.StatusCreated, `{"Id":"exec1"}`)
+	engine.SetResponse("POST", "/exec/exec1/resize", http.StatusOK, "")
+	engine.SetResponse("GET", "/exec/exec1/json", http.StatusOK, `{"Running":false,"ExitCode":3,"Pid":42}`)
+	engine.SetResponse("POST", "/containers/stopped/exec", http.StatusConflict, `{"message":"container stopped is not running"}`)
+
+	// The process echoes its input on stdout and exits with it
+	engine.SetHandler("POST", "/exec/exec1/start", func(w http.ResponseWriter, r *http.Request) {
+		start, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Upgrade") != "tcp" || string(start) != `{"Detach":false,"Tty":false}` {
+			t.Errorf("Expected an upgrade request, got %s with headers %v", start, r.Header)
+		}
+		conn, buffered, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		buffered.WriteString("HTTP/1.1 101 UPGRADED\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+		buffered.Flush()
 
-	manager := NewDockerManagerWithExecutor(mockExecutor)
+		line, _ := buffered.ReadString('\n')
+		conn.Write([]byte(logFrame(1, line) + logFrame(2, "bye\n")))
+	})
+
+	session, err := manager.Exec(context.Background(), "test_container", ExecOptions{Cmd: []string{"/bin/sh"}})
+	if err != nil {
+		t.Fatalf("Unexpected error starting exec session: %v", err)
+	}
+	defer session.Close()
+
+	if _, err := session.Write([]byte("echo hi\n")); err != nil {
+		t.Fatal(err)
+	}
+	var output []string
+	err = session.Output(func(stream int, data []byte) error {
+		output = append(output, fmt.Sprintf("%d:%s", stream, data))
+		return nil
+	})
+	if err != nil || fmt.Sprint(output) != fmt.Sprint([]string{"1:echo hi\n", "2:bye\n"}) {
+		t.Errorf("Unexpected output %q (%v)", output, err)
+	}
+
+	if err := session.Resize(context.Background(), 120, 40); err != nil {
+		t.Errorf("Unexpected error resizing: %v", err)
+	}
+	if state, err := session.State(context.Background()); err != nil || state.Running || state.ExitCode != 3 {
+		t.Errorf("Unexpected state %+v (%v)", state, err)
+	}
+	requests := strings.Join(engine.Requests(), "\n")
+	if !strings.Contains(requests, "POST /exec/exec1/resize?h=40&w=120") {
+		t.Errorf("Expected a resize request, got %s", requests)
+	}
+
+	if _, err := manager.Exec(context.Background(), "stopped", ExecOptions{Cmd: []string{"sh"}}); !errors.Is(err, ErrContainerNotRunning) {
+		t.Errorf("Expected ErrContainerNotRunning, got %v", err)
+	}
+	if _, err := manager.Exec(context.Background(), "missing", ExecOptions{Cmd: []string{"sh"}}); !errors.Is(err, ErrContainerNotFound) {
+		t.Errorf("Expected ErrContainerNotFound, got %v", err)
+	}
+}
+
+// TestExecTerminate tests stopping a process left running by a closed session
+func TestExecTerminate(t *testing.T) {
+	manager, engine := setupMockDockerManager(t)
+	engine.SetResponse("POST", "/containers/test_container/exec", http.StatusCreated, `{"Id":"exec1"}`)
+	engine.SetHandler("POST", "/exec/exec1/start", func(w http.ResponseWriter, r *http.Request) {
+		conn, buffered, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		buffered.WriteString("HTTP/1.1 101 UPGRADED\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+		buffered.Flush()
+	})
+
+	// A local process stands in for the one in the container
+	process := exec.Command("sleep", "60")
+	if err := process.Start(); err != nil {
+		t.Skipf("Cannot start a process to stop: %v", err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- process.Wait() }()
+	var done atomic.Bool
+	engine.SetHandler("GET", "/exec/exec1/json", func(w http.ResponseWriter, r *http.Request) {
+		if !done.Load() {
+			select {
+			case err := <-exited:
+				exited <- err
+				done.Store(true)
+			default:
+			}
+		}
+		fmt.Fprintf(w, `{"Running":%t,"Pid":%d}`, !done.Load(), process.Process.Pid)
+	})
+
+	session, err := manager.Exec(context.Background(), "test_container", ExecOptions{Cmd: []string{"sleep", "60"}})
+	if err != nil {
+		t.Fatalf("Unexpected error starting exec session: %v", err)
+	}
+	session.Close()
+	if err := session.Terminate(context.Background(), 5*time.Second); err != nil {
+		t.Fatalf("Unexpected error terminating: %v", err)
+	}
+
+	select {
+	case err := <-exited:
+		if status, ok := process.ProcessState.Sys().(syscall.WaitStatus); !ok || status.Signal() != syscall.SIGTERM {
+			t.Errorf("Expected the process to end on SIGTERM, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		process.Process.Kill()
+		t.Fatal("Expected the process to be stopped")
+	}
+}
+
+// TestContainerStats tests container statistics retrieval
+func TestContainerStats(t *testing.T) {
+	manager, _ := setupMockDockerManager(t)
 
 	stats, err := manager.GetContainerStats("test_container")
 	if err != nil {
@@ -179,6 +431,62 @@ func TestContainerStats(t *testing.T) {
 	if stats.CPUPercent == 0 {
 		t.Error("Expected non-zero CPU percentage")
 	}
+
+	// One of four cores busy for the interval, and memory without the page cache
+	if stats.CPUPercent != 40 || stats.MemUsage != 200000000 || stats.MemPercent != 10 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if stats.Name != "test-container" || stats.NetIO != "1.2kB / 2.3kB" || stats.BlockIO != "10MB / 5MB" {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+// TestStreamContainerStats tests following container statistics
+func TestStreamContainerStats(t *testing.T) {
+	manager, engine := setupMockDockerManager(t)
+	engine.SetResponse("GET", "/containers/test_container/stats", http.StatusOK, mockStats+"\n"+mockStats+"\n")
+
+	samples := 0
+	err := manager.StreamContainerStats(context.Background(), "test_container", func(sample *ContainerStatsSample) error {
+		samples++
+		if sample.CPUPercent() != 40 {
+			t.Errorf("Expected 40%% CPU, got %v", sample.CPUPercent())
+		}
+		return nil
+	})
+	if err != nil || samples != 2 {
+		t.Errorf("Expected 2 samples, got %d (%v)", samples, err)
+	}
+
+	err = manager.StreamContainerStats(context.Background(), "missing", func(*ContainerStatsSample) error { return nil })
+	if !errors.Is(err, ErrContainerNotFound) {
+		t.Errorf("Expected ErrContainerNotFound, got %v", err)
+	}
+}
+
+// TestStreamEvents tests following daemon events
+func TestStreamEvents(t *testing.T) {
+	manager, engine := setupMockDockerManager(t)
+	engine.SetResponse("GET", "/events", http.StatusOK,
+		`{"Type":"container","Action":"start","Actor":{"ID":"abc123","Attributes":{"name":"test-container"}},"time":1718964000}`+"\n"+
+			`{"Type":"container","Action":"die","Actor":{"ID":"abc123","Attributes":{"name":"test-container","exitCode":"0"}},"time":1718964060}`)
+
+	var actions []string
+	err := manager.StreamEvents(context.Background(), time.Unix(1718960000, 0), map[string][]string{"type": {"container"}}, func(event *EngineEvent) error {
+		actions = append(actions, event.Action+" "+event.Actor.Attributes["name"])
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error streaming events: %v", err)
+	}
+	if fmt.Sprint(actions) != "[start test-container die test-container]" {
+		t.Errorf("Unexpected events: %v", actions)
+	}
+
+	requests := engine.Requests()
+	if want := "GET /events?filters=%7B%22type%22%3A%5B%22container%22%5D%7D&since=1718960000"; requests[len(requests)-1] != want {
+		t.Errorf("Expected %s, got %s", want, requests[len(requests)-1])
+	}
 }
 
 // TestNewDockerManager tests the creation of a new Docker manager
@@ -189,14 +497,14 @@ func TestNewDockerManager(t *testing.T) {
 		t.Fatal("Expected non-nil Docker manager")
 	}
 
-	if manager.cmdExecutor == nil {
-		t.Fatal("Expected non-nil command executor")
+	if manager.client == nil {
+		t.Fatal("Expected non-nil Engine API client")
 	}
 }
 
 // TestListContainers tests container listing
 func TestListContainers(t *testing.T) {
-	manager, _ := setupMockDockerManager()
+	manager, _ := setupMockDockerManager(t)
 
 	containers, err := manager.ListContainers(true)
 
@@ -241,7 +549,7 @@ func TestListContainers(t *testing.T) {
 
 // TestGetContainer tests individual container retrieval
 func TestGetContainer(t *testing.T) {
-	manager, _ := setupMockDockerManager()
+	manager, _ := setupMockDockerManager(t)
 
 	// Test with a mock container ID
 	container, err := manager.GetContainer("test_container")
@@ -278,9 +586,27 @@ func TestGetContainer(t *testing.T) {
 	}
 }
 
+// TestGetContainerNotFound tests that unknown containers and an unreachable daemon are distinguishable
+func TestGetContainerNotFound(t *testing.T) {
+	manager, _ := setupMockDockerManager(t)
+
+	if _, err := manager.GetContainer("missing"); !errors.Is(err, ErrContainerNotFound) {
+		t.Errorf("Expected ErrContainerNotFound, got %v", err)
+	}
+
+	if err := manager.StopContainer("missing", 10); !errors.Is(err, ErrContainerNotFound) {
+		t.Errorf("Expected ErrContainerNotFound stopping, got %v", err)
+	}
+
+	unavailable := NewDockerManagerWithClient(NewSocketClient(filepath.Join(t.TempDir(), "docker.sock")))
+	if _, err := unavailable.GetContainer("missing"); !errors.Is(err, ErrDockerUnavailable) {
+		t.Errorf("Expected ErrDockerUnavailable, got %v", err)
+	}
+}
+
 // TestStartContainer tests container starting
 func TestStartContainer(t *testing.T) {
-	manager, _ := setupMockDockerManager()
+	manager, _ := setupMockDockerManager(t)
 
 	// Test with a mock container ID
 	err := manager.StartContainer("test_container")
@@ -293,7 +619,7 @@ func TestStartContainer(t *testing.T) {
 
 // TestStopContainer tests container stopping
 func TestStopContainer(t *testing.T) {
-	manager, _ := setupMockDockerManager()
+	manager, _ := setupMockDockerManager(t)
 
 	// Test with a mock container ID
 	err := manager.StopContainer("test_container", 10)
@@ -306,7 +632,7 @@ func TestStopContainer(t *testing.T) {
 
 // TestRestartContainer tests container restarting
 func TestRestartContainer(t *testing.T) {
-	manager, _ := setupMockDockerManager()
+	manager, _ := setupMockDockerManager(t)
 
 	// Test with a mock container ID
 	err := manager.RestartContainer("test_container", 10)
@@ -319,7 +645,7 @@ func TestRestartContainer(t *testing.T) {
 
 // TestListImages tests image listing
 func TestListImages(t *testing.T) {
-	manager, _ := setupMockDockerManager()
+	manager, _ := setupMockDockerManager(t)
 
 	images, err := manager.ListImages()
 
@@ -359,7 +685,7 @@ func TestListImages(t *testing.T) {
 
 // TestListNetworks tests network listing
 func TestListNetworks(t *testing.T) {
-	manager, _ := setupMockDockerManager()
+	manager, _ := setupMockDockerManager(t)
 
 	networks, err := manager.ListNetworks()
 
@@ -402,9 +728,23 @@ func TestListNetworks(t *testing.T) {
 	}
 }
 
+// TestListVolumes tests volume listing
+func TestListVolumes(t *testing.T) {
+	manager, _ := setupMockDockerManager(t)
+
+	volumes, err := manager.ListVolumes()
+	if err != nil {
+		t.Fatalf("Unexpected error with mock data: %v", err)
+	}
+
+	if len(volumes) != 1 || volumes[0].Name != "data" || volumes[0].Created.IsZero() {
+		t.Errorf("Unexpected volumes: %+v", volumes)
+	}
+}
+
 // TestGetDockerInfo tests Docker daemon information
 func TestGetDockerInfo(t *testing.T) {
-	manager, _ := setupMockDockerManager()
+	manager, _ := setupMockDockerManager(t)
 
 	info, err := manager.GetDockerInfo()
 
@@ -447,7 +787,7 @@ func TestGetDockerInfo(t *testing.T) {
 
 // TestDockerAvailability tests Docker availability check
 func TestDockerAvailability(t *testing.T) {
-	manager, _ := setupMockDockerManager()
+	manager, _ := setupMockDockerManager(t)
 
 	// Test Docker availability check with mock
 	available := manager.IsDockerAvailable()
@@ -458,27 +798,18 @@ func TestDockerAvailability(t *testing.T) {
 	}
 
 	// Test unavailable Docker scenario
-	mockExecutor := NewMockCommandExecutor()
-	mockExecutor.SetResponse("docker", []string{"version", "--format", "{{.Server.Version}}"}, []string{})
-	unavailableManager := NewDockerManagerWithExecutor(mockExecutor)
+	unavailableManager := NewDockerManagerWithClient(NewSocketClient(filepath.Join(t.TempDir(), "docker.sock")))
 
 	unavailable := unavailableManager.IsDockerAvailable()
 	if unavailable {
-		t.Error("Expected Docker to be unavailable with empty mock response")
+		t.Error("Expected Docker to be unavailable without a daemon socket")
 	}
 }
 
 // TestDockerErrorHandling tests error handling in various scenarios
 func TestDockerErrorHandling(t *testing.T) {
-	// Create mock executor for error scenarios
-	mockExecutor := NewMockCommandExecutor()
-
-	// Mock error responses for invalid operations
-	mockExecutor.SetResponse("docker", []string{"start", "invalid_container_xyz"}, []string{"Error: No such container: invalid_container_xyz"})
-	mockExecutor.SetResponse("docker", []string{"start", ""}, []string{"Error: container name cannot be empty"})
-	mockExecutor.SetResponse("docker", []string{"inspect", "invalid_container_xyz"}, []string{})
-
-	manager := NewDockerManagerWithExecutor(mockExecutor)
+	// The fake daemon answers unknown containers with 404
+	manager := NewMockEngine(t).Manager()
 
 	// Test with invalid container ID
 	err := manager.StartContainer("invalid_container_xyz")
@@ -501,7 +832,7 @@ func TestDockerErrorHandling(t *testing.T) {
 
 // TestDockerDataValidation tests data validation and sanitization
 func TestDockerDataValidation(t *testing.T) {
-	manager, _ := setupMockDockerManager()
+	manager, _ := setupMockDockerManager(t)
 
 	containers, err := manager.ListContainers(true)
 	if err != nil {
@@ -533,7 +864,7 @@ func TestDockerDataValidation(t *testing.T) {
 
 // TestDockerPerformance tests performance characteristics
 func TestDockerPerformance(t *testing.T) {
-	manager, _ := setupMockDockerManager()
+	manager, _ := setupMockDockerManager(t)
 
 	// Test that operations complete within reasonable time
 	start := time.Now()
@@ -564,7 +895,7 @@ func TestDockerPerformance(t *testing.T) {
 
 // BenchmarkListContainers benchmarks container listing
 func BenchmarkListContainers(b *testing.B) {
-	manager, _ := setupMockDockerManager()
+	manager, _ := setupMockDockerManager(b)
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
@@ -577,7 +908,7 @@ func BenchmarkListContainers(b *testing.B) {
 
 // BenchmarkGetDockerInfo benchmarks Docker info retrieval
 func BenchmarkGetDockerInfo(b *testing.B) {
-	manager, _ := setupMockDockerManager()
+	manager, _ := setupMockDockerManager(b)
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
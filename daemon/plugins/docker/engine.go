@@ -0,0 +1,474 @@
+package docker
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultSocketPath is where dockerd listens on Unraid
+	DefaultSocketPath = "/var/run/docker.sock"
+
+	// engineAPIVersion is the API version requests are made against; Docker
+	// 20.10, the oldest release Unraid 6.9 ships, supports it
+	engineAPIVersion = "v1.41"
+
+	// engineTimeout bounds requests that are not streamed
+	engineTimeout = 30 * time.Second
+)
+
+// EngineClient sends requests to the Docker Engine API, as CommandExecutor
+// runs commands, so tests can substitute a fake daemon. Error statuses are
+// returned as *EngineError; callers close the body of any other response.
+type EngineClient interface {
+	Do(ctx context.Context, method, path string, query url.Values, body io.Reader) (*http.Response, error)
+}
+
+// EngineUpgrader is implemented by clients that can take over a connection
+// the daemon switches to a raw stream, as it does when attaching to an exec
+// session
+type EngineUpgrader interface {
+	Upgrade(ctx context.Context, path string, query url.Values, body io.Reader) (io.ReadWriteCloser, error)
+}
+
+// EngineError is an error status returned by the Docker Engine API
+type EngineError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *EngineError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("docker engine returned %d", e.StatusCode)
+	}
+	return e.Message
+}
+
+// SocketClient talks to dockerd over its unix socket
+type SocketClient struct {
+	client *http.Client
+}
+
+// NewSocketClient creates a client for the daemon listening on socketPath
+func NewSocketClient(socketPath string) *SocketClient {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	return &SocketClient{
+		// No client timeout: stats, events and logs stream for as long as the context allows
+		client: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, "unix", socketPath)
+				},
+				MaxIdleConns:    8,
+				IdleConnTimeout: 90 * time.Second,
+			},
+		},
+	}
+}
+
+// Do sends a request to path, such as /containers/json
+func (c *SocketClient) Do(ctx context.Context, method, path string, query url.Values, body io.Reader) (*http.Response, error) {
+	target := "http://docker/" + engineAPIVersion + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, responseError(resp)
+	}
+	return resp, nil
+}
+
+// Upgrade posts to path asking the daemon to switch the connection to a raw
+// stream, and returns the connection for the caller to close
+func (c *SocketClient) Upgrade(ctx context.Context, path string, query url.Values, body io.Reader) (io.ReadWriteCloser, error) {
+	target := "http://docker/" + engineAPIVersion + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, responseError(resp)
+	}
+	conn, ok := resp.Body.(io.ReadWriteCloser)
+	if resp.StatusCode != http.StatusSwitchingProtocols || !ok {
+		resp.Body.Close()
+		return nil, fmt.Errorf("docker engine did not upgrade the connection: %s", resp.Status)
+	}
+	return conn, nil
+}
+
+// responseError reads an error status and its message, closing the body
+func responseError(resp *http.Response) error {
+	defer resp.Body.Close()
+	engineErr := &EngineError{StatusCode: resp.StatusCode}
+	var message struct {
+		Message string `json:"message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(data, &message) == nil {
+		engineErr.Message = message.Message
+	} else {
+		engineErr.Message = strings.TrimSpace(string(data))
+	}
+	return engineErr
+}
+
+// engineJSON sends a request and decodes the JSON response into out
+func engineJSON(ctx context.Context, client EngineClient, method, path string, query url.Values, out interface{}) error {
+	resp, err := client.Do(ctx, method, path, query, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// engineCall sends a request whose response body is not needed
+func engineCall(ctx context.Context, client EngineClient, method, path string, query url.Values) error {
+	resp, err := client.Do(ctx, method, path, query, nil)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// engineStream reads a stream of JSON values, calling next to decode and
+// handle each until the stream or ctx ends or next returns an error
+func engineStream(ctx context.Context, client EngineClient, path string, query url.Values, next func(decoder *json.Decoder) error) error {
+	resp, err := client.Do(ctx, http.MethodGet, path, query, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		if err := next(decoder); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// Log streams of a container's output
+const (
+	StreamStdout = 1
+	StreamStderr = 2
+)
+
+// demux reads container output, calling fn with each chunk and the stream it
+// came from. Without a TTY the daemon multiplexes stdout and stderr into
+// frames with an 8 byte header: the stream, three zero bytes and the length.
+func demux(r io.Reader, tty bool, fn func(stream int, data []byte) error) error {
+	if tty {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				if err := fn(StreamStdout, buf[:n]); err != nil {
+					return err
+				}
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	header := make([]byte, 8)
+	var frame []byte
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		size := int(binary.BigEndian.Uint32(header[4:]))
+		if cap(frame) < size {
+			frame = make([]byte, size)
+		}
+		frame = frame[:size]
+		if _, err := io.ReadFull(r, frame); err != nil {
+			return err
+		}
+		stream := int(header[0])
+		if stream != StreamStderr {
+			stream = StreamStdout
+		}
+		if err := fn(stream, frame); err != nil {
+			return err
+		}
+	}
+}
+
+// containerError maps an engine error for a container to the package errors
+func containerError(nameOrID string, err error) error {
+	var engineErr *EngineError
+	switch {
+	case errors.As(err, &engineErr):
+		if engineErr.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("%w: %s", ErrContainerNotFound, nameOrID)
+		}
+		return err
+	case errors.Is(err, context.Canceled):
+		return err
+	default:
+		return fmt.Errorf("%w: %v", ErrDockerUnavailable, err)
+	}
+}
+
+// engineContainer is an entry of the container list
+type engineContainer struct {
+	ID      string            `json:"Id"`
+	Names   []string          `json:"Names"`
+	Image   string            `json:"Image"`
+	Created int64             `json:"Created"`
+	State   string            `json:"State"`
+	Status  string            `json:"Status"`
+	Labels  map[string]string `json:"Labels"`
+	Ports   []struct {
+		IP          string `json:"IP"`
+		PrivatePort int    `json:"PrivatePort"`
+		PublicPort  int    `json:"PublicPort"`
+		Type        string `json:"Type"`
+	} `json:"Ports"`
+	Mounts          []engineMount `json:"Mounts"`
+	NetworkSettings struct {
+		Networks map[string]engineEndpoint `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+// engineContainerJSON is the subset of a container inspect UMA uses
+type engineContainerJSON struct {
+	ID      string `json:"Id"`
+	Name    string `json:"Name"`
+	Created string `json:"Created"`
+	State   struct {
+		Status    string `json:"Status"`
+		Running   bool   `json:"Running"`
+		StartedAt string `json:"StartedAt"`
+		ExitCode  int    `json:"ExitCode"`
+		Health    *struct {
+			Status string `json:"Status"`
+		} `json:"Health"`
+	} `json:"State"`
+	Config struct {
+		Image  string            `json:"Image"`
+		Labels map[string]string `json:"Labels"`
+		Env    []string          `json:"Env"`
+		Tty    bool              `json:"Tty"`
+	} `json:"Config"`
+	HostConfig struct {
+		RestartPolicy struct {
+			Name string `json:"Name"`
+		} `json:"RestartPolicy"`
+		PortBindings map[string][]struct {
+			HostIP   string `json:"HostIp"`
+			HostPort string `json:"HostPort"`
+		} `json:"PortBindings"`
+	} `json:"HostConfig"`
+	Mounts          []engineMount `json:"Mounts"`
+	NetworkSettings struct {
+		Networks map[string]engineEndpoint `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+type engineMount struct {
+	Type        string `json:"Type"`
+	Source      string `json:"Source"`
+	Destination string `json:"Destination"`
+	Mode        string `json:"Mode"`
+	RW          bool   `json:"RW"`
+}
+
+type engineEndpoint struct {
+	IPAddress string `json:"IPAddress"`
+	Gateway   string `json:"Gateway"`
+}
+
+// engineImage is an entry of the image list
+type engineImage struct {
+	ID          string            `json:"Id"`
+	RepoTags    []string          `json:"RepoTags"`
+	RepoDigests []string          `json:"RepoDigests"`
+	Created     int64             `json:"Created"`
+	Size        int64             `json:"Size"`
+	Labels      map[string]string `json:"Labels"`
+}
+
+// engineNetwork is an entry of the network list
+type engineNetwork struct {
+	ID         string    `json:"Id"`
+	Name       string    `json:"Name"`
+	Created    time.Time `json:"Created"`
+	Scope      string    `json:"Scope"`
+	Driver     string    `json:"Driver"`
+	Internal   bool      `json:"Internal"`
+	Attachable bool      `json:"Attachable"`
+	Ingress    bool      `json:"Ingress"`
+	IPAM       struct {
+		Driver  string             `json:"Driver"`
+		Config  []DockerIPAMConfig `json:"Config"`
+		Options map[string]string  `json:"Options"`
+	} `json:"IPAM"`
+	Options map[string]string `json:"Options"`
+	Labels  map[string]string `json:"Labels"`
+}
+
+// engineVolume is an entry of the volume list
+type engineVolume struct {
+	Name       string            `json:"Name"`
+	Driver     string            `json:"Driver"`
+	Mountpoint string            `json:"Mountpoint"`
+	CreatedAt  string            `json:"CreatedAt"`
+	Scope      string            `json:"Scope"`
+	Labels     map[string]string `json:"Labels"`
+	Options    map[string]string `json:"Options"`
+}
+
+// ContainerStatsSample is one sample of the container stats endpoint
+type ContainerStatsSample struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Read        time.Time      `json:"read"`
+	CPUStats    engineCPUStats `json:"cpu_stats"`
+	PreCPUStats engineCPUStats `json:"precpu_stats"`
+	MemoryStats struct {
+		Usage uint64            `json:"usage"`
+		Limit uint64            `json:"limit"`
+		Stats map[string]uint64 `json:"stats"`
+	} `json:"memory_stats"`
+	Networks map[string]struct {
+		RxBytes uint64 `json:"rx_bytes"`
+		TxBytes uint64 `json:"tx_bytes"`
+	} `json:"networks"`
+	BlkioStats struct {
+		IOServiceBytesRecursive []struct {
+			Op    string `json:"op"`
+			Value uint64 `json:"value"`
+		} `json:"io_service_bytes_recursive"`
+	} `json:"blkio_stats"`
+}
+
+type engineCPUStats struct {
+	CPUUsage struct {
+		TotalUsage  uint64   `json:"total_usage"`
+		PercpuUsage []uint64 `json:"percpu_usage"`
+	} `json:"cpu_usage"`
+	SystemUsage uint64 `json:"system_cpu_usage"`
+	OnlineCPUs  uint32 `json:"online_cpus"`
+}
+
+// CPUPercent is the CPU use since the previous sample, 100 per core as docker stats reports it
+func (s *ContainerStatsSample) CPUPercent() float64 {
+	cpuDelta := float64(s.CPUStats.CPUUsage.TotalUsage) - float64(s.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(s.CPUStats.SystemUsage) - float64(s.PreCPUStats.SystemUsage)
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+	cpus := float64(s.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(s.CPUStats.CPUUsage.PercpuUsage))
+	}
+	return cpuDelta / systemDelta * cpus * 100
+}
+
+// MemoryUsage is the memory in use without the page cache, as docker stats reports it
+func (s *ContainerStatsSample) MemoryUsage() uint64 {
+	cache := s.MemoryStats.Stats["inactive_file"] // cgroup v2
+	if cache == 0 {
+		cache = s.MemoryStats.Stats["total_inactive_file"] // cgroup v1
+	}
+	if cache > s.MemoryStats.Usage {
+		return s.MemoryStats.Usage
+	}
+	return s.MemoryStats.Usage - cache
+}
+
+// NetworkIO is the bytes received and sent over every network
+func (s *ContainerStatsSample) NetworkIO() (rx, tx uint64) {
+	for _, network := range s.Networks {
+		rx += network.RxBytes
+		tx += network.TxBytes
+	}
+	return rx, tx
+}
+
+// BlockIO is the bytes read from and written to block devices
+func (s *ContainerStatsSample) BlockIO() (read, write uint64) {
+	for _, entry := range s.BlkioStats.IOServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			read += entry.Value
+		case "write":
+			write += entry.Value
+		}
+	}
+	return read, write
+}
+
+// EngineEvent is an event from the Docker daemon's event stream
+type EngineEvent struct {
+	Type   string `json:"Type"`   // container, image, network, volume...
+	Action string `json:"Action"` // create, start, die, destroy, health_status: healthy...
+	Actor  struct {
+		ID         string            `json:"ID"`
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+	Time     int64 `json:"time"`
+	TimeNano int64 `json:"timeNano"`
+}
+
+// humanSize formats bytes with decimal units as docker stats does, such as 1.2kB
+func humanSize(bytes uint64) string {
+	units := []string{"B", "kB", "MB", "GB", "TB", "PB"}
+	size := float64(bytes)
+	unit := 0
+	for size >= 1000 && unit < len(units)-1 {
+		size /= 1000
+		unit++
+	}
+	return fmt.Sprintf("%.3g%s", size, units[unit])
+}
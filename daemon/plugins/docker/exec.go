@@ -0,0 +1,173 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// ExecOptions describes a process to start in a container
+type ExecOptions struct {
+	Cmd        []string
+	Env        []string // KEY=value
+	User       string
+	WorkingDir string
+	Tty        bool // Allocate a terminal; stdout and stderr are then one stream
+}
+
+// ExecState is the state of an exec session's process
+type ExecState struct {
+	Running  bool `json:"Running"`
+	ExitCode int  `json:"ExitCode"`
+	Pid      int  `json:"Pid"`
+}
+
+// ExecSession is a process started in a container with its stdin, stdout and
+// stderr attached. It is not safe for concurrent writes.
+type ExecSession struct {
+	ID  string
+	Tty bool
+
+	client EngineClient
+	conn   io.ReadWriteCloser
+}
+
+// Exec starts a process in a running container and attaches to it. The
+// caller reads its output with Output, writes its input with Write, and
+// closes the session when done.
+func (d *DockerManager) Exec(ctx context.Context, nameOrID string, options ExecOptions) (*ExecSession, error) {
+	upgrader, ok := d.client.(EngineUpgrader)
+	if !ok {
+		return nil, errors.New("docker client cannot attach to exec sessions")
+	}
+	if len(options.Cmd) == 0 {
+		return nil, errors.New("no command to run")
+	}
+
+	config, err := json.Marshal(map[string]interface{}{
+		"AttachStdin":  true,
+		"AttachStdout": true,
+		"AttachStderr": true,
+		"Tty":          options.Tty,
+		"Cmd":          options.Cmd,
+		"Env":          options.Env,
+		"User":         options.User,
+		"WorkingDir":   options.WorkingDir,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(ctx, http.MethodPost, containerPath(nameOrID, "exec"), nil, bytes.NewReader(config))
+	if err != nil {
+		var engineErr *EngineError
+		if errors.As(err, &engineErr) && engineErr.StatusCode == http.StatusConflict {
+			return nil, fmt.Errorf("%w: %s", ErrContainerNotRunning, engineErr.Message)
+		}
+		return nil, containerError(nameOrID, err)
+	}
+	var created struct {
+		ID string `json:"Id"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exec session: %w", err)
+	}
+
+	start, _ := json.Marshal(map[string]bool{"Detach": false, "Tty": options.Tty})
+	conn, err := upgrader.Upgrade(ctx, execPath(created.ID, "start"), nil, bytes.NewReader(start))
+	if err != nil {
+		return nil, fmt.Errorf("failed to start exec session: %w", err)
+	}
+	return &ExecSession{ID: created.ID, Tty: options.Tty, client: d.client, conn: conn}, nil
+}
+
+// execPath is the Engine API path of an exec session endpoint
+func execPath(id, endpoint string) string {
+	return "/exec/" + url.PathEscape(id) + "/" + endpoint
+}
+
+// Output calls fn with the process output and the stream it came from until
+// the process exits, fn fails or the session is closed
+func (s *ExecSession) Output(fn func(stream int, data []byte) error) error {
+	return demux(s.conn, s.Tty, fn)
+}
+
+// Write sends input to the process
+func (s *ExecSession) Write(p []byte) (int, error) {
+	return s.conn.Write(p)
+}
+
+// Resize sets the size of the session's terminal in characters
+func (s *ExecSession) Resize(ctx context.Context, width, height uint) error {
+	query := url.Values{
+		"w": {strconv.FormatUint(uint64(width), 10)},
+		"h": {strconv.FormatUint(uint64(height), 10)},
+	}
+	return engineCall(ctx, s.client, http.MethodPost, execPath(s.ID, "resize"), query)
+}
+
+// State returns whether the process is still running and its exit code
+func (s *ExecSession) State(ctx context.Context) (*ExecState, error) {
+	var state ExecState
+	if err := engineJSON(ctx, s.client, http.MethodGet, execPath(s.ID, "json"), nil, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Close detaches from the process. A shell on a terminal sees its input end and exits.
+func (s *ExecSession) Close() error {
+	return s.conn.Close()
+}
+
+// Terminate stops the process if it is still running, with SIGTERM and then
+// SIGKILL once grace has passed. Detaching leaves processes without a
+// terminal running. The Engine API cannot signal exec processes, so the
+// process is signalled through its host PID, which the daemon can see as it
+// runs on the host.
+func (s *ExecSession) Terminate(ctx context.Context, grace time.Duration) error {
+	state, err := s.State(ctx)
+	if err != nil {
+		return err
+	}
+	if !state.Running || state.Pid <= 0 {
+		return nil
+	}
+	if err := signalProcess(state.Pid, syscall.SIGTERM); err != nil {
+		return err
+	}
+
+	deadline := time.NewTimer(grace)
+	defer deadline.Stop()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.C:
+			return signalProcess(state.Pid, syscall.SIGKILL)
+		case <-ticker.C:
+			if state, err := s.State(ctx); err == nil && !state.Running {
+				return nil
+			}
+		}
+	}
+}
+
+// signalProcess sends sig to pid, ignoring a process that has already exited
+func signalProcess(pid int, sig syscall.Signal) error {
+	if err := syscall.Kill(pid, sig); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return fmt.Errorf("failed to signal process %d: %w", pid, err)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// maxLogLine bounds how much of a line without a newline is buffered before
+// it is passed on as is
+const maxLogLine = 1024 * 1024
+
+// LogOptions selects which log lines StreamContainerLogs returns
+type LogOptions struct {
+	Tail       int       // Lines from the end to start with; negative for all
+	Since      time.Time // Only lines logged at or after this time, when set
+	Until      time.Time // Only lines logged before this time, when set
+	Timestamps bool      // Fill in LogLine.Timestamp
+	Follow     bool      // Keep streaming new lines until the context ends
+
+	Grep   *regexp.Regexp // Only lines matching, when set
+	Invert bool           // Only lines not matching Grep instead
+}
+
+// LogLine is a line of container output
+type LogLine struct {
+	Stream    string    `json:"stream"` // stdout or stderr
+	Timestamp time.Time `json:"timestamp,omitzero"`
+	Text      string    `json:"line"`
+}
+
+// StreamContainerLogs calls fn with each log line matching options, in the
+// order the container wrote them, until the log ends or, when following,
+// ctx is done. A container without a TTY has stdout and stderr told apart.
+func (d *DockerManager) StreamContainerLogs(ctx context.Context, nameOrID string, options LogOptions, fn func(LogLine) error) error {
+	inspect, err := d.inspectContainer(ctx, nameOrID)
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{"stdout": {"1"}, "stderr": {"1"}}
+	if options.Tail >= 0 {
+		query.Set("tail", strconv.Itoa(options.Tail))
+	}
+	if !options.Since.IsZero() {
+		query.Set("since", unixNano(options.Since))
+	}
+	if !options.Until.IsZero() {
+		query.Set("until", unixNano(options.Until))
+	}
+	if options.Timestamps {
+		query.Set("timestamps", "1")
+	}
+	if options.Follow {
+		query.Set("follow", "1")
+	}
+	resp, err := d.client.Do(ctx, http.MethodGet, containerPath(nameOrID, "logs"), query, nil)
+	if err != nil {
+		return containerError(nameOrID, err)
+	}
+	defer resp.Body.Close()
+
+	// Frames need not end on line boundaries, so each stream keeps its partial line
+	var partial [StreamStderr + 1][]byte
+	emit := func(stream int, line []byte) error {
+		logLine := LogLine{Stream: "stdout", Text: string(bytes.TrimSuffix(line, []byte("\r")))}
+		if stream == StreamStderr {
+			logLine.Stream = "stderr"
+		}
+		if options.Timestamps {
+			if stamp, text, found := bytes.Cut(line, []byte(" ")); found {
+				if t, err := time.Parse(time.RFC3339Nano, string(stamp)); err == nil {
+					logLine.Timestamp = t
+					logLine.Text = string(bytes.TrimSuffix(text, []byte("\r")))
+				}
+			}
+		}
+		if options.Grep != nil && options.Grep.MatchString(logLine.Text) == options.Invert {
+			return nil
+		}
+		return fn(logLine)
+	}
+
+	err = demux(resp.Body, inspect.Config.Tty, func(stream int, data []byte) error {
+		buffered := append(partial[stream], data...)
+		for {
+			i := bytes.IndexByte(buffered, '\n')
+			if i < 0 {
+				break
+			}
+			if err := emit(stream, buffered[:i]); err != nil {
+				return err
+			}
+			buffered = buffered[i+1:]
+		}
+		if len(buffered) > maxLogLine {
+			if err := emit(stream, buffered); err != nil {
+				return err
+			}
+			buffered = nil
+		}
+		partial[stream] = append([]byte(nil), buffered...)
+		return nil
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("failed to read logs: %w", err)
+	}
+
+	// The last line may lack a newline
+	for stream, line := range partial {
+		if len(line) > 0 {
+			if err := emit(stream, line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// unixNano formats t as the daemon's fractional Unix timestamps
+func unixNano(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
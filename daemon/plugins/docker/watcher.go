@@ -0,0 +1,269 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/domalab/uma/daemon/events"
+	"github.com/domalab/uma/daemon/logger"
+)
+
+const (
+	watchRetryMin = time.Second
+	watchRetryMax = 30 * time.Second
+)
+
+// Container event actions the watcher follows; exec, attach and the like are ignored
+var watchedActions = map[string]bool{
+	"create":        true,
+	"start":         true,
+	"restart":       true,
+	"kill":          true,
+	"die":           true,
+	"stop":          true,
+	"pause":         true,
+	"unpause":       true,
+	"rename":        true,
+	"update":        true,
+	"destroy":       true,
+	"health_status": true,
+}
+
+// WatchListener is told about each container event the watcher handled, with
+// the action without its detail (health_status rather than health_status:
+// healthy) and the container as it is now
+type WatchListener func(action string, container ContainerInfo)
+
+// Watcher follows the Docker daemon's event stream to keep an inventory of
+// containers current between polls and to announce lifecycle changes as they
+// happen. It reconnects when dockerd restarts, resyncing the inventory.
+type Watcher struct {
+	manager    *DockerManager
+	retryDelay time.Duration
+
+	containers map[string]ContainerInfo // By ID
+	killed     map[string]bool          // IDs sent a signal since they last started
+	synced     bool
+	listeners  []WatchListener
+	mutex      sync.RWMutex
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewWatcher creates a watcher for the daemon manager talks to. Events are
+// published on the manager's event bus.
+func NewWatcher(manager *DockerManager) *Watcher {
+	return &Watcher{
+		manager:    manager,
+		retryDelay: watchRetryMin,
+		containers: make(map[string]ContainerInfo),
+		killed:     make(map[string]bool),
+	}
+}
+
+// AddListener registers fn to be called for every container event. Listeners
+// run on the watcher goroutine and must not block.
+func (w *Watcher) AddListener(fn WatchListener) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.listeners = append(w.listeners, fn)
+}
+
+// Start follows the daemon in the background until Stop
+func (w *Watcher) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	w.done = make(chan struct{})
+	go w.run(ctx)
+}
+
+// Stop ends the event stream and waits for the watcher to finish
+func (w *Watcher) Stop() {
+	if w.cancel == nil {
+		return
+	}
+	w.cancel()
+	<-w.done
+}
+
+// Containers returns the current inventory sorted by name, and false while
+// the watcher is not connected and the inventory may be stale
+func (w *Watcher) Containers() ([]ContainerInfo, bool) {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	containers := make([]ContainerInfo, 0, len(w.containers))
+	for _, container := range w.containers {
+		containers = append(containers, container)
+	}
+	sort.Slice(containers, func(i, j int) bool { return containers[i].Name < containers[j].Name })
+	return containers, w.synced
+}
+
+func (w *Watcher) run(ctx context.Context) {
+	defer close(w.done)
+
+	delay := w.retryDelay
+	for {
+		connected, err := w.watch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			delay = w.retryDelay
+			logger.Yellow("Docker event stream ended, reconnecting: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > watchRetryMax {
+			delay = watchRetryMax
+		}
+	}
+}
+
+// watch resyncs the inventory and follows events until the stream ends. It
+// reports whether the daemon was reachable.
+func (w *Watcher) watch(ctx context.Context) (bool, error) {
+	defer w.setSynced(false)
+
+	// Events from the second before listing are replayed so none fall between the two
+	since := time.Now().Add(-time.Second)
+	containers, err := w.manager.ListContainers(true)
+	if err != nil {
+		return false, err
+	}
+
+	w.mutex.Lock()
+	w.containers = make(map[string]ContainerInfo, len(containers))
+	for _, container := range containers {
+		w.containers[container.ID] = container
+	}
+	w.killed = make(map[string]bool)
+	w.synced = true
+	w.mutex.Unlock()
+	w.manager.watching.Store(true)
+	logger.Blue("Following Docker events for %d containers", len(containers))
+
+	err = w.manager.StreamEvents(ctx, since, map[string][]string{"type": {"container"}}, func(event *EngineEvent) error {
+		w.handle(ctx, event)
+		return nil
+	})
+	if err == nil {
+		err = errors.New("stream closed by the daemon")
+	}
+	return true, err
+}
+
+func (w *Watcher) setSynced(synced bool) {
+	w.mutex.Lock()
+	w.synced = synced
+	w.mutex.Unlock()
+	w.manager.watching.Store(synced)
+}
+
+// handle applies an event to the inventory, publishes the lifecycle change it
+// represents and tells the listeners
+func (w *Watcher) handle(ctx context.Context, event *EngineEvent) {
+	action, detail, _ := strings.Cut(event.Action, ":")
+	if !watchedActions[action] || event.Actor.ID == "" {
+		return
+	}
+	id := event.Actor.ID
+
+	w.mutex.Lock()
+	before, existed := w.containers[id]
+	if action == "kill" {
+		w.killed[id] = true
+		w.mutex.Unlock()
+		return
+	}
+	killed := w.killed[id]
+	if action == "start" || action == "destroy" {
+		delete(w.killed, id)
+	}
+	w.mutex.Unlock()
+
+	// Inspect rather than patch the entry, so the inventory matches the daemon
+	// even if events were missed
+	container := before
+	if action != "destroy" {
+		inspect, err := w.manager.inspectContainer(ctx, id)
+		switch {
+		case err == nil:
+			container = inspect.info()
+		case errors.Is(err, ErrContainerNotFound):
+			action = "destroy" // Removed before we got to it
+		default:
+			logger.Yellow("Failed to inspect container %s after %s event: %v", id, action, err)
+			return
+		}
+	}
+	if action == "health_status" {
+		container.Health = strings.TrimSpace(detail)
+	}
+	if container.ID == "" {
+		container.ID = id
+		container.Name = event.Actor.Attributes["name"]
+		container.Image = event.Actor.Attributes["image"]
+	}
+
+	w.mutex.Lock()
+	if action == "destroy" {
+		delete(w.containers, id)
+		delete(w.killed, id)
+	} else {
+		w.containers[id] = container
+	}
+	listeners := w.listeners
+	w.mutex.Unlock()
+
+	data := events.ContainerEvent{
+		ID:            id,
+		Name:          container.Name,
+		Image:         container.Image,
+		State:         container.State,
+		PreviousState: before.State,
+		Health:        container.Health,
+	}
+	switch action {
+	case "create":
+		w.manager.events.Publish(events.ContainerCreated, container.Name, data)
+	case "start":
+		w.manager.events.Publish(events.ContainerStarted, container.Name, data)
+	case "die":
+		// Stops and kills signal the container first; anything else ended on its own
+		data.ExitCode, _ = strconv.Atoi(event.Actor.Attributes["exitCode"])
+		if killed {
+			w.manager.events.Publish(events.ContainerStopped, container.Name, data)
+		} else {
+			w.manager.events.Publish(events.ContainerDied, container.Name, data)
+		}
+	case "pause":
+		w.manager.events.Publish(events.ContainerPaused, container.Name, data)
+	case "unpause":
+		w.manager.events.Publish(events.ContainerUnpaused, container.Name, data)
+	case "destroy":
+		data.State = "removed"
+		w.manager.events.Publish(events.ContainerRemoved, container.Name, data)
+	case "health_status":
+		if existed && data.Health == before.Health {
+			break
+		}
+		w.manager.events.Publish(events.ContainerHealthChanged, container.Name, data)
+	}
+
+	for _, fn := range listeners {
+		fn(action, container)
+	}
+}
@@ -0,0 +1,113 @@
+package docker
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cskr/pubsub"
+
+	"github.com/domalab/uma/daemon/events"
+)
+
+// TestWatcher tests following daemon events into the inventory and event bus
+func TestWatcher(t *testing.T) {
+	manager, engine := setupMockDockerManager(t)
+	engine.SetResponse("GET", "/containers/json", http.StatusOK, `[
+		{"Id":"web1","Names":["/web"],"Image":"nginx","State":"running"},
+		{"Id":"db1","Names":["/db"],"Image":"postgres","State":"running"}
+	]`)
+	engine.SetResponse("GET", "/containers/web1/json", http.StatusOK,
+		`{"Id":"web1","Name":"/web","State":{"Status":"running","Health":{"Status":"healthy"}},"Config":{"Image":"nginx"}}`)
+	engine.SetResponse("GET", "/containers/db1/json", http.StatusOK,
+		`{"Id":"db1","Name":"/db","State":{"Status":"exited"},"Config":{"Image":"postgres"}}`)
+	engine.SetResponse("GET", "/containers/cache1/json", http.StatusOK,
+		`{"Id":"cache1","Name":"/cache","State":{"Status":"created"},"Config":{"Image":"redis"}}`)
+
+	stream := []string{
+		`{"Type":"container","Action":"health_status: unhealthy","Actor":{"ID":"web1","Attributes":{"name":"web"}}}`,
+		`{"Type":"container","Action":"exec_start: sh","Actor":{"ID":"web1","Attributes":{"name":"web"}}}`,
+		`{"Type":"container","Action":"die","Actor":{"ID":"db1","Attributes":{"name":"db","exitCode":"137"}}}`,
+		`{"Type":"container","Action":"kill","Actor":{"ID":"web1","Attributes":{"name":"web","signal":"15"}}}`,
+		`{"Type":"container","Action":"die","Actor":{"ID":"web1","Attributes":{"name":"web","exitCode":"0"}}}`,
+		`{"Type":"container","Action":"create","Actor":{"ID":"cache1","Attributes":{"name":"cache","image":"redis"}}}`,
+		`{"Type":"container","Action":"destroy","Actor":{"ID":"db1","Attributes":{"name":"db","image":"postgres"}}}`,
+	}
+	engine.SetResponse("GET", "/events", http.StatusOK, strings.Join(stream, "\n"))
+
+	hub := pubsub.New(16)
+	defer hub.Shutdown()
+	bus := events.NewBus(hub)
+	manager.SetEventBus(bus)
+	ch := bus.Subscribe(events.TopicContainer)
+
+	var actions []string
+	var mutex sync.Mutex
+	watcher := NewWatcher(manager)
+	watcher.retryDelay = 10 * time.Millisecond
+	watcher.AddListener(func(action string, container ContainerInfo) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		actions = append(actions, action+" "+container.Name)
+	})
+	watcher.Start()
+	defer watcher.Stop()
+
+	var published []string
+	for len(published) < 5 {
+		select {
+		case msg := <-ch:
+			event := msg.(events.Event)
+			data := event.Data.(events.ContainerEvent)
+			published = append(published, fmt.Sprintf("%s %s %s", event.Type, data.Name, data.State))
+			if event.Type == events.ContainerDied && data.ExitCode != 137 {
+				t.Errorf("Expected exit code 137, got %d", data.ExitCode)
+			}
+			if event.Type == events.ContainerHealthChanged && data.Health != "unhealthy" {
+				t.Errorf("Expected unhealthy, got %q", data.Health)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out after events %v", published)
+		}
+	}
+	want := "[container.health_changed web running container.died db exited container.stopped web running " +
+		"container.created cache created container.removed db removed]"
+	if got := fmt.Sprint(published); got != want {
+		t.Errorf("Expected events %s, got %s", want, got)
+	}
+
+	// The daemon closing the stream is a restart; the watcher resyncs and follows again
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		streams := 0
+		for _, request := range engine.Requests() {
+			if strings.HasPrefix(request, "GET /events") {
+				streams++
+			}
+		}
+		if streams >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the watcher to reconnect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mutex.Lock()
+	if got := fmt.Sprint(actions[:5]); got != "[health_status web die db die web create cache destroy db]" {
+		t.Errorf("Unexpected listener calls %s", got)
+	}
+	mutex.Unlock()
+
+	watcher.Stop()
+	if containers, synced := watcher.Containers(); synced || len(containers) == 0 {
+		t.Errorf("Expected a stale inventory after stopping, got %d containers (synced %v)", len(containers), synced)
+	}
+	if manager.watching.Load() {
+		t.Error("Expected the manager to announce its own actions again")
+	}
+}
@@ -0,0 +1,496 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/domalab/uma/daemon/domain"
+)
+
+// Channel types
+const (
+	ChannelWebhook  = "webhook"
+	ChannelNtfy     = "ntfy"
+	ChannelGotify   = "gotify"
+	ChannelPushover = "pushover"
+	ChannelSMTP     = "smtp"
+	ChannelDiscord  = "discord"
+	ChannelSlack    = "slack"
+)
+
+const (
+	pushoverURL = "https://api.pushover.net/1/messages.json"
+
+	// SignatureHeader carries the HMAC-SHA256 of a webhook body as sha256=<hex>
+	SignatureHeader = "X-UMA-Signature"
+)
+
+// sender delivers a notification to one kind of service
+type sender interface {
+	send(ctx context.Context, notification *Notification) error
+}
+
+// statusError is an unsuccessful HTTP response
+type statusError struct {
+	status int
+	body   string
+}
+
+func (e *statusError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("HTTP %d", e.status)
+	}
+	return fmt.Sprintf("HTTP %d: %s", e.status, e.body)
+}
+
+// retryable reports whether the request may succeed later; other client
+// errors mean the channel is misconfigured
+func (e *statusError) retryable() bool {
+	return e.status >= 500 || e.status == http.StatusRequestTimeout || e.status == http.StatusTooManyRequests
+}
+
+// resolveChannel expands an Apprise-style URL into the channel's type,
+// endpoint and credentials. Explicitly configured fields take precedence.
+func resolveChannel(c domain.NotificationChannel) (domain.NotificationChannel, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil || u.Scheme == "" {
+		return c, fmt.Errorf("invalid URL")
+	}
+
+	secure := strings.HasSuffix(u.Scheme, "s")
+	web := "http"
+	if secure {
+		web = "https"
+	}
+	segments := strings.FieldsFunc(u.Path, func(r rune) bool { return r == '/' })
+	inferred := ""
+
+	switch u.Scheme {
+	case "http", "https":
+		inferred = ChannelWebhook
+	case "json", "jsons":
+		inferred = ChannelWebhook
+		c.URL = web + "://" + u.Host + u.RequestURI()
+	case "ntfy", "ntfys":
+		inferred = ChannelNtfy
+		if len(segments) == 0 {
+			// ntfy://topic uses the public server
+			c.URL = "https://ntfy.sh/" + u.Host
+		} else {
+			c.URL = web + "://" + u.Host + u.Path
+		}
+		setDefault(&c.Token, u.Query().Get("token"))
+	case "gotify", "gotifys":
+		if len(segments) == 0 {
+			return c, fmt.Errorf("gotify URL needs an app token")
+		}
+		inferred = ChannelGotify
+		setDefault(&c.Token, segments[len(segments)-1])
+		c.URL = web + "://" + u.Host + "/" + strings.Join(segments[:len(segments)-1], "/")
+	case "pover":
+		inferred = ChannelPushover
+		setDefault(&c.User, u.User.Username())
+		setDefault(&c.Token, u.Host)
+		c.URL = ""
+	case "discord":
+		if len(segments) != 1 {
+			return c, fmt.Errorf("discord URL must be discord://webhook_id/webhook_token")
+		}
+		inferred = ChannelDiscord
+		c.URL = "https://discord.com/api/webhooks/" + u.Host + "/" + segments[0]
+	case "slack":
+		if len(segments) != 2 {
+			return c, fmt.Errorf("slack URL must be slack://T/B/token")
+		}
+		inferred = ChannelSlack
+		c.URL = "https://hooks.slack.com/services/" + u.Host + "/" + strings.Join(segments, "/")
+	case "smtp", "smtps", "mailto", "mailtos":
+		inferred = ChannelSMTP
+	default:
+		return c, fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+
+	if c.Type == "" {
+		c.Type = inferred
+	}
+	if c.Type == ChannelPushover && c.URL == "" {
+		c.URL = pushoverURL
+	}
+	return c, nil
+}
+
+func setDefault(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
+
+// newSender returns the driver for a resolved channel
+func newSender(c domain.NotificationChannel, client *http.Client) (sender, error) {
+	switch c.Type {
+	case ChannelWebhook:
+		return newWebhookSender(c, client)
+	case ChannelNtfy:
+		return &ntfySender{client: client, url: c.URL, token: c.Token}, nil
+	case ChannelGotify:
+		if c.Token == "" {
+			return nil, fmt.Errorf("gotify needs an app token")
+		}
+		return &gotifySender{client: client, url: strings.TrimSuffix(c.URL, "/") + "/message", token: c.Token}, nil
+	case ChannelPushover:
+		if c.Token == "" || c.User == "" {
+			return nil, fmt.Errorf("pushover needs an API token and user key")
+		}
+		return &pushoverSender{client: client, url: c.URL, token: c.Token, user: c.User}, nil
+	case ChannelSMTP:
+		return newSMTPSender(c)
+	case ChannelDiscord:
+		return &discordSender{client: client, url: c.URL}, nil
+	case ChannelSlack:
+		return &slackSender{client: client, url: c.URL}, nil
+	}
+	return nil, fmt.Errorf("unknown channel type %q", c.Type)
+}
+
+// post sends body and treats any non-2xx response as an error
+func post(ctx context.Context, client *http.Client, endpoint, contentType string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return redactURL(err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return redactURL(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return &statusError{status: resp.StatusCode, body: strings.TrimSpace(string(snippet))}
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// redactURL replaces the URL in a request error with its host: Discord and
+// Slack webhook URLs carry their token, and delivery errors are stored and logged
+func redactURL(err error) error {
+	urlErr, ok := err.(*url.Error)
+	if !ok {
+		return err
+	}
+	host := "webhook"
+	if u, parseErr := url.Parse(urlErr.URL); parseErr == nil && u.Host != "" {
+		host = u.Host
+	}
+	return &url.Error{Op: urlErr.Op, URL: host, Err: urlErr.Err}
+}
+
+func postJSON(ctx context.Context, client *http.Client, endpoint string, payload interface{}, headers map[string]string) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return post(ctx, client, endpoint, "application/json", body, headers)
+}
+
+// webhookSender posts the notification as JSON, or as rendered by a template
+type webhookSender struct {
+	client   *http.Client
+	url      string
+	template *template.Template
+	secret   []byte
+	headers  map[string]string
+}
+
+func newWebhookSender(c domain.NotificationChannel, client *http.Client) (*webhookSender, error) {
+	s := &webhookSender{client: client, url: c.URL, headers: c.Headers}
+	if c.Secret != "" {
+		s.secret = []byte(c.Secret)
+	}
+	if c.Template != "" {
+		// json quotes a value for templates producing JSON, as in {"text": {{json .Title}}}
+		tmpl, err := template.New(c.Name).Funcs(template.FuncMap{
+			"json": func(v interface{}) (string, error) {
+				data, err := json.Marshal(v)
+				return string(data), err
+			},
+		}).Parse(c.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template: %v", err)
+		}
+		s.template = tmpl
+	}
+	return s, nil
+}
+
+func (s *webhookSender) send(ctx context.Context, notification *Notification) error {
+	var body []byte
+	if s.template != nil {
+		var buf bytes.Buffer
+		if err := s.template.Execute(&buf, notification); err != nil {
+			return fmt.Errorf("template: %v", err)
+		}
+		body = buf.Bytes()
+	} else {
+		data, err := json.Marshal(notification)
+		if err != nil {
+			return err
+		}
+		body = data
+	}
+
+	headers := make(map[string]string, len(s.headers)+1)
+	for name, value := range s.headers {
+		headers[name] = value
+	}
+	if s.secret != nil {
+		mac := hmac.New(sha256.New, s.secret)
+		mac.Write(body)
+		headers[SignatureHeader] = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	return post(ctx, s.client, s.url, "application/json", body, headers)
+}
+
+// ntfySender publishes to an ntfy topic URL
+type ntfySender struct {
+	client *http.Client
+	url    string
+	token  string
+}
+
+func (s *ntfySender) send(ctx context.Context, notification *Notification) error {
+	priority := map[NotificationLevel]string{LevelInfo: "3", LevelWarning: "4", LevelError: "5", LevelCritical: "5"}
+	headers := map[string]string{
+		"Title":    headerValue(notification.Title),
+		"Priority": priority[notification.Level],
+		"Tags":     string(notification.Level) + "," + string(notification.Category),
+	}
+	if s.token != "" {
+		headers["Authorization"] = "Bearer " + s.token
+	}
+	return post(ctx, s.client, s.url, "text/plain; charset=utf-8", []byte(notification.Message), headers)
+}
+
+// gotifySender posts to a Gotify server's message endpoint
+type gotifySender struct {
+	client *http.Client
+	url    string
+	token  string
+}
+
+func (s *gotifySender) send(ctx context.Context, notification *Notification) error {
+	priority := map[NotificationLevel]int{LevelInfo: 2, LevelWarning: 5, LevelError: 8, LevelCritical: 10}
+	return postJSON(ctx, s.client, s.url, map[string]interface{}{
+		"title":    notification.Title,
+		"message":  notification.Message,
+		"priority": priority[notification.Level],
+	}, map[string]string{"X-Gotify-Key": s.token})
+}
+
+// pushoverSender posts to the Pushover message API
+type pushoverSender struct {
+	client *http.Client
+	url    string
+	token  string
+	user   string
+}
+
+func (s *pushoverSender) send(ctx context.Context, notification *Notification) error {
+	priority := "0"
+	if notification.Level == LevelError || notification.Level == LevelCritical {
+		priority = "1"
+	}
+	form := url.Values{
+		"token":     {s.token},
+		"user":      {s.user},
+		"title":     {notification.Title},
+		"message":   {notification.Message},
+		"priority":  {priority},
+		"timestamp": {strconv.FormatInt(notification.Timestamp.Unix(), 10)},
+	}
+	return post(ctx, s.client, s.url, "application/x-www-form-urlencoded", []byte(form.Encode()), nil)
+}
+
+// discordSender posts an embed to a Discord webhook
+type discordSender struct {
+	client *http.Client
+	url    string
+}
+
+func (s *discordSender) send(ctx context.Context, notification *Notification) error {
+	color := map[NotificationLevel]int{LevelInfo: 0x3498db, LevelWarning: 0xf1c40f, LevelError: 0xe67e22, LevelCritical: 0xe74c3c}
+	return postJSON(ctx, s.client, s.url, map[string]interface{}{
+		"username": "UMA",
+		"embeds": []map[string]interface{}{{
+			"title":       notification.Title,
+			"description": notification.Message,
+			"color":       color[notification.Level],
+			"timestamp":   notification.Timestamp.Format(time.RFC3339),
+			"footer":      map[string]string{"text": fmt.Sprintf("%s · %s", notification.Level, notification.Category)},
+		}},
+	}, nil)
+}
+
+// slackSender posts to a Slack-compatible incoming webhook, which
+// Mattermost and Rocket.Chat accept as well
+type slackSender struct {
+	client *http.Client
+	url    string
+}
+
+func (s *slackSender) send(ctx context.Context, notification *Notification) error {
+	return postJSON(ctx, s.client, s.url, map[string]string{
+		"text": fmt.Sprintf("*%s* [%s/%s]\n%s", notification.Title, notification.Level, notification.Category, notification.Message),
+	}, nil)
+}
+
+// smtpSender emails the notification. smtps URLs connect with TLS; others
+// upgrade with STARTTLS when the server offers it.
+type smtpSender struct {
+	addr        string
+	host        string
+	implicitTLS bool
+	username    string
+	password    string
+	from        string
+	to          []string
+}
+
+func newSMTPSender(c domain.NotificationChannel) (*smtpSender, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil || u.Hostname() == "" {
+		return nil, fmt.Errorf("smtp needs a server URL such as smtp://mail.example.com:587")
+	}
+
+	s := &smtpSender{
+		host:        u.Hostname(),
+		implicitTLS: u.Scheme == "smtps" || u.Scheme == "mailtos",
+		username:    c.Username,
+		password:    c.Password,
+		from:        c.From,
+		to:          c.To,
+	}
+	port := u.Port()
+	if port == "" {
+		port = "25"
+		if s.implicitTLS {
+			port = "465"
+		}
+	}
+	s.addr = net.JoinHostPort(s.host, port)
+
+	if u.User != nil {
+		setDefault(&s.username, u.User.Username())
+		password, _ := u.User.Password()
+		setDefault(&s.password, password)
+	}
+	query := u.Query()
+	setDefault(&s.from, query.Get("from"))
+	if strings.Contains(s.username, "@") {
+		setDefault(&s.from, s.username)
+	}
+	if to := query.Get("to"); to != "" {
+		s.to = append(s.to, strings.Split(to, ",")...)
+	}
+
+	if s.from == "" || len(s.to) == 0 {
+		return nil, fmt.Errorf("smtp needs a sender and at least one recipient")
+	}
+	return s, nil
+}
+
+func (s *smtpSender) send(ctx context.Context, notification *Notification) error {
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	var err error
+	if s.implicitTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: s.host}}).DialContext(ctx, "tcp", s.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", s.addr)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && !s.implicitTLS {
+		if err := client.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return err
+		}
+	}
+	if s.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(s.from); err != nil {
+		return err
+	}
+	for _, recipient := range s.to {
+		if err := client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(s.message(notification)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// message formats the notification as a plain text email
+func (s *smtpSender) message(notification *Notification) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", s.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", fmt.Sprintf("[UMA %s] %s", notification.Level, headerValue(notification.Title))))
+	fmt.Fprintf(&buf, "Date: %s\r\n", notification.Timestamp.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	buf.WriteString(strings.ReplaceAll(notification.Message, "\n", "\r\n"))
+	fmt.Fprintf(&buf, "\r\n\r\nLevel: %s\r\nCategory: %s\r\n", notification.Level, notification.Category)
+	return buf.Bytes()
+}
+
+// headerValue keeps a value on one header line
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
@@ -0,0 +1,252 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/domalab/uma/daemon/domain"
+	"github.com/domalab/uma/daemon/logger"
+)
+
+const (
+	sendTimeout   = 30 * time.Second
+	maxRetryDelay = 15 * time.Minute
+)
+
+// DeliveryState is how far delivery to a channel has got
+type DeliveryState string
+
+const (
+	DeliveryPending   DeliveryState = "pending"
+	DeliveryDelivered DeliveryState = "delivered"
+	DeliveryFailed    DeliveryState = "failed"
+)
+
+// Delivery records delivering a notification to one channel
+type Delivery struct {
+	Channel     string        `json:"channel"`
+	State       DeliveryState `json:"state"`
+	Attempts    int           `json:"attempts"`
+	LastError   string        `json:"last_error,omitempty"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+	DeliveredAt *time.Time    `json:"delivered_at,omitempty"`
+}
+
+var levelRank = map[NotificationLevel]int{LevelInfo: 0, LevelWarning: 1, LevelError: 2, LevelCritical: 3}
+
+// channel is a configured delivery target with its routing
+type channel struct {
+	name       string
+	minLevel   int
+	categories map[NotificationCategory]bool // All when empty
+	sender     sender
+}
+
+// routes reports whether notification is delivered to the channel
+func (c *channel) routes(notification *Notification) bool {
+	if levelRank[notification.Level] < c.minLevel {
+		return false
+	}
+	return len(c.categories) == 0 || c.categories[notification.Category]
+}
+
+// Dispatcher delivers notifications to external channels, retrying failed
+// attempts with exponential backoff
+type Dispatcher struct {
+	channels    []*channel
+	maxAttempts int
+	retryDelay  time.Duration
+}
+
+// NewDispatcher creates a dispatcher for the enabled channels of config
+func NewDispatcher(config domain.NotificationsConfig) (*Dispatcher, error) {
+	d := &Dispatcher{
+		maxAttempts: config.MaxAttempts,
+		retryDelay:  time.Duration(config.RetryDelaySeconds) * time.Second,
+	}
+	if d.maxAttempts <= 0 {
+		d.maxAttempts = 1
+	}
+
+	client := &http.Client{Timeout: sendTimeout}
+	names := make(map[string]bool)
+	for _, configured := range config.Channels {
+		if configured.Disabled {
+			continue
+		}
+		c, err := newChannel(configured, client)
+		if err != nil {
+			return nil, fmt.Errorf("notification channel %q: %w", configured.Name, err)
+		}
+		if names[c.name] {
+			return nil, fmt.Errorf("notification channel %q: duplicate name", c.name)
+		}
+		names[c.name] = true
+		d.channels = append(d.channels, c)
+	}
+	return d, nil
+}
+
+func newChannel(configured domain.NotificationChannel, client *http.Client) (*channel, error) {
+	if configured.Name == "" {
+		return nil, fmt.Errorf("a name is required")
+	}
+	resolved, err := resolveChannel(configured)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &channel{name: resolved.Name}
+	if resolved.MinLevel != "" {
+		level := NotificationLevel(resolved.MinLevel)
+		if !level.Valid() {
+			return nil, fmt.Errorf("unknown level %q", resolved.MinLevel)
+		}
+		c.minLevel = levelRank[level]
+	}
+	if len(resolved.Categories) > 0 {
+		c.categories = make(map[NotificationCategory]bool)
+		for _, category := range resolved.Categories {
+			if !NotificationCategory(category).Valid() {
+				return nil, fmt.Errorf("unknown category %q", category)
+			}
+			c.categories[NotificationCategory(category)] = true
+		}
+	}
+
+	c.sender, err = newSender(resolved, client)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// route returns the channels notification is delivered to
+func (d *Dispatcher) route(notification *Notification) []*channel {
+	var routed []*channel
+	for _, c := range d.channels {
+		if c.routes(notification) {
+			routed = append(routed, c)
+		}
+	}
+	return routed
+}
+
+// deliver sends notification to c until it succeeds, fails permanently, runs
+// out of attempts or ctx is done, reporting each attempt to record
+func (d *Dispatcher) deliver(ctx context.Context, c *channel, notification *Notification, record func(attempt int, err error, final bool)) {
+	delay := d.retryDelay
+	for attempt := 1; ; attempt++ {
+		sendCtx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		err := c.sender.send(sendCtx, notification)
+		cancel()
+
+		var status *statusError
+		final := err == nil || attempt >= d.maxAttempts || (errors.As(err, &status) && !status.retryable()) || ctx.Err() != nil
+		record(attempt, err, final)
+		if final {
+			return
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			// Stopped while waiting to retry: the last attempt stands
+			timer.Stop()
+			record(attempt, err, true)
+			return
+		}
+		if delay *= 2; delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// SetDispatcher delivers notifications created from now on through d
+func (nm *NotificationManager) SetDispatcher(d *Dispatcher) {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+	nm.dispatcher = d
+}
+
+// dispatch delivers a saved notification to the channels it routes to in
+// the background; the caller holds the mutex
+func (nm *NotificationManager) dispatch(notification *Notification, routed []*channel) {
+	// Senders get a copy so callers may keep using the notification
+	sent := *notification
+	sent.Deliveries = nil
+	dispatcher := nm.dispatcher
+	for _, c := range routed {
+		nm.delivering.Add(1)
+		go func(c *channel) {
+			defer nm.delivering.Done()
+			dispatcher.deliver(nm.deliveryCtx, c, &sent, func(attempt int, err error, final bool) {
+				nm.recordDelivery(sent.ID, c.name, attempt, err, final)
+			})
+		}(c)
+	}
+}
+
+// Stop cancels delivery retries, so deliveries waiting to retry fail with
+// their last error, and waits until deliveries in flight finish or ctx is done.
+// Notifications created afterwards are not delivered.
+func (nm *NotificationManager) Stop(ctx context.Context) error {
+	nm.mutex.Lock()
+	nm.stopDelivery()
+	nm.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		nm.delivering.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// recordDelivery stores the outcome of a delivery attempt on the notification
+func (nm *NotificationManager) recordDelivery(id, channelName string, attempt int, err error, final bool) {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if err != nil && final {
+		logger.Yellow("Failed to deliver notification %s to %s after %d attempt(s): %v", id, channelName, attempt, err)
+	}
+
+	notification, getErr := nm.GetNotification(id)
+	if getErr != nil {
+		// Deleted while being delivered
+		return
+	}
+	for i := range notification.Deliveries {
+		delivery := &notification.Deliveries[i]
+		if delivery.Channel != channelName {
+			continue
+		}
+		now := time.Now()
+		delivery.Attempts = attempt
+		delivery.UpdatedAt = now
+		switch {
+		case err == nil:
+			delivery.State = DeliveryDelivered
+			delivery.LastError = ""
+			delivery.DeliveredAt = &now
+		case final:
+			delivery.State = DeliveryFailed
+			delivery.LastError = err.Error()
+		default:
+			delivery.LastError = err.Error()
+		}
+	}
+	if err := nm.saveNotification(notification); err != nil {
+		logger.Yellow("Failed to record delivery of notification %s: %v", id, err)
+	}
+}
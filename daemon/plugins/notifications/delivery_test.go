@@ -0,0 +1,403 @@
+package notifications
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/domalab/uma/daemon/domain"
+)
+
+type receivedRequest struct {
+	header http.Header
+	body   string
+}
+
+// recorder is an HTTP stand-in that records requests by path and answers
+// each path with the queued status codes, then 200
+type recorder struct {
+	mutex    sync.Mutex
+	requests map[string][]receivedRequest
+	statuses map[string][]int
+}
+
+func newRecorder(t *testing.T) (*recorder, *httptest.Server) {
+	rec := &recorder{requests: make(map[string][]receivedRequest), statuses: make(map[string][]int)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rec.mutex.Lock()
+		defer rec.mutex.Unlock()
+		rec.requests[r.URL.Path] = append(rec.requests[r.URL.Path], receivedRequest{header: r.Header, body: string(body)})
+		if queued := rec.statuses[r.URL.Path]; len(queued) > 0 {
+			rec.statuses[r.URL.Path] = queued[1:]
+			w.WriteHeader(queued[0])
+		}
+	}))
+	t.Cleanup(server.Close)
+	return rec, server
+}
+
+func (rec *recorder) received(path string) []receivedRequest {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	return rec.requests[path]
+}
+
+func newTestManager(t *testing.T, config domain.NotificationsConfig) *NotificationManager {
+	t.Helper()
+	dispatcher, err := NewDispatcher(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dispatcher.retryDelay = time.Millisecond
+	nm := NewNotificationManagerWithStorage(t.TempDir())
+	nm.SetDispatcher(dispatcher)
+	return nm
+}
+
+// deliveries waits for background deliveries and returns the stored records
+func deliveries(t *testing.T, nm *NotificationManager, id string) map[string]Delivery {
+	t.Helper()
+	nm.delivering.Wait()
+	notification, err := nm.GetNotification(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := make(map[string]Delivery)
+	for _, delivery := range notification.Deliveries {
+		records[delivery.Channel] = delivery
+	}
+	return records
+}
+
+func TestDeliveryChannels(t *testing.T) {
+	rec, server := newRecorder(t)
+	serverURL, _ := url.Parse(server.URL)
+	nm := newTestManager(t, domain.NotificationsConfig{
+		MaxAttempts: 1,
+		Channels: []domain.NotificationChannel{
+			{Name: "hook", URL: server.URL + "/hook", Secret: "s3cret", Template: `{"text": {{json .Title}}, "level": "{{.Level}}"}`},
+			{Name: "ntfy", URL: "ntfy://" + serverURL.Host + "/alerts?token=tk"},
+			{Name: "gotify", URL: "gotify://" + serverURL.Host + "/gotify/apptoken"},
+			{Name: "pushover", Type: ChannelPushover, URL: server.URL + "/pushover", User: "userkey", Token: "apptoken"},
+			{Name: "discord", Type: ChannelDiscord, URL: server.URL + "/discord"},
+			{Name: "slack", Type: ChannelSlack, URL: server.URL + "/slack"},
+		},
+	})
+
+	notification, err := nm.CreateNotification(`Disk "1" hot`, "disk1 is at 55C", LevelWarning, CategoryStorage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notification.Deliveries) != 6 || notification.Deliveries[0].State != DeliveryPending {
+		t.Fatalf("expected six pending deliveries, got %+v", notification.Deliveries)
+	}
+	for name, delivery := range deliveries(t, nm, notification.ID) {
+		if delivery.State != DeliveryDelivered || delivery.Attempts != 1 || delivery.DeliveredAt == nil {
+			t.Errorf("%s: expected delivered on the first attempt, got %+v", name, delivery)
+		}
+	}
+
+	hook := rec.received("/hook")
+	if len(hook) != 1 || hook[0].body != `{"text": "Disk \"1\" hot", "level": "warning"}` {
+		t.Fatalf("unexpected webhook requests %+v", hook)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(hook[0].body))
+	if got := hook[0].header.Get(SignatureHeader); got != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("unexpected signature %q", got)
+	}
+
+	ntfy := rec.received("/alerts")
+	if len(ntfy) != 1 || ntfy[0].body != "disk1 is at 55C" || ntfy[0].header.Get("Title") != `Disk "1" hot` ||
+		ntfy[0].header.Get("Priority") != "4" || ntfy[0].header.Get("Authorization") != "Bearer tk" {
+		t.Errorf("unexpected ntfy requests %+v", ntfy)
+	}
+
+	var gotify struct {
+		Title    string `json:"title"`
+		Priority int    `json:"priority"`
+	}
+	if requests := rec.received("/gotify/message"); len(requests) != 1 || requests[0].header.Get("X-Gotify-Key") != "apptoken" {
+		t.Errorf("unexpected gotify requests %+v", requests)
+	} else if json.Unmarshal([]byte(requests[0].body), &gotify); gotify.Title != `Disk "1" hot` || gotify.Priority != 5 {
+		t.Errorf("unexpected gotify message %+v", gotify)
+	}
+
+	if requests := rec.received("/pushover"); len(requests) != 1 {
+		t.Errorf("unexpected pushover requests %+v", requests)
+	} else if form, _ := url.ParseQuery(requests[0].body); form.Get("user") != "userkey" || form.Get("token") != "apptoken" || form.Get("message") != "disk1 is at 55C" {
+		t.Errorf("unexpected pushover form %v", form)
+	}
+
+	if requests := rec.received("/discord"); len(requests) != 1 || !strings.Contains(requests[0].body, `"description":"disk1 is at 55C"`) {
+		t.Errorf("unexpected discord requests %+v", requests)
+	}
+	if requests := rec.received("/slack"); len(requests) != 1 || !strings.Contains(requests[0].body, `[warning/storage]\ndisk1 is at 55C`) {
+		t.Errorf("unexpected slack requests %+v", requests)
+	}
+}
+
+func TestDeliveryRouting(t *testing.T) {
+	_, server := newRecorder(t)
+	nm := newTestManager(t, domain.NotificationsConfig{
+		MaxAttempts: 1,
+		Channels: []domain.NotificationChannel{
+			{Name: "all", URL: server.URL},
+			{Name: "errors", URL: server.URL, MinLevel: "error"},
+			{Name: "docker", URL: server.URL, Categories: []string{"docker", "vm"}},
+			{Name: "off", URL: server.URL, Disabled: true},
+		},
+	})
+
+	for _, tc := range []struct {
+		level    NotificationLevel
+		category NotificationCategory
+		channels string
+	}{
+		{LevelInfo, CategoryStorage, "all"},
+		{LevelCritical, CategoryArray, "all,errors"},
+		{LevelWarning, CategoryVM, "all,docker"},
+		{LevelError, CategoryDocker, "all,errors,docker"},
+	} {
+		notification, err := nm.CreateNotification("title", "message", tc.level, tc.category)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var channels []string
+		for _, delivery := range notification.Deliveries {
+			channels = append(channels, delivery.Channel)
+		}
+		if got := strings.Join(channels, ","); got != tc.channels {
+			t.Errorf("%s %s: expected %s, got %s", tc.level, tc.category, tc.channels, got)
+		}
+	}
+	nm.delivering.Wait()
+}
+
+func TestDeliveryRetries(t *testing.T) {
+	rec, server := newRecorder(t)
+	rec.statuses["/flaky"] = []int{http.StatusBadGateway, http.StatusTooManyRequests}
+	rec.statuses["/down"] = []int{500, 500, 500, 500}
+	rec.statuses["/rejected"] = []int{http.StatusUnauthorized}
+	nm := newTestManager(t, domain.NotificationsConfig{
+		MaxAttempts: 3,
+		Channels: []domain.NotificationChannel{
+			{Name: "flaky", URL: server.URL + "/flaky"},
+			{Name: "down", URL: server.URL + "/down"},
+			{Name: "rejected", URL: server.URL + "/rejected"},
+		},
+	})
+
+	notification, err := nm.CreateNotification("title", "message", LevelError, CategorySystem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := deliveries(t, nm, notification.ID)
+
+	if flaky := records["flaky"]; flaky.State != DeliveryDelivered || flaky.Attempts != 3 || flaky.LastError != "" {
+		t.Errorf("expected flaky delivered on the third attempt, got %+v", flaky)
+	}
+	if down := records["down"]; down.State != DeliveryFailed || down.Attempts != 3 || down.LastError != "HTTP 500" {
+		t.Errorf("expected down to fail after three attempts, got %+v", down)
+	}
+	if rejected := records["rejected"]; rejected.State != DeliveryFailed || rejected.Attempts != 1 || len(rec.received("/rejected")) != 1 {
+		t.Errorf("expected rejected to fail without retrying, got %+v", rejected)
+	}
+}
+
+func TestDeliveryStop(t *testing.T) {
+	rec, server := newRecorder(t)
+	rec.statuses["/down"] = []int{500, 500}
+	nm := newTestManager(t, domain.NotificationsConfig{
+		MaxAttempts: 3,
+		Channels:    []domain.NotificationChannel{{Name: "down", URL: server.URL + "/down"}},
+	})
+	nm.dispatcher.retryDelay = time.Hour
+
+	notification, err := nm.CreateNotification("title", "message", LevelError, CategorySystem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); len(rec.received("/down")) == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the first attempt was not made")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := nm.Stop(ctx); err != nil {
+		t.Fatalf("expected the retry to be cancelled, got %v", err)
+	}
+	if down := deliveries(t, nm, notification.ID)["down"]; down.State != DeliveryFailed || down.Attempts != 1 || down.LastError != "HTTP 500" {
+		t.Errorf("expected down to fail with its first attempt, got %+v", down)
+	}
+
+	later, err := nm.CreateNotification("title", "message", LevelError, CategorySystem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(later.Deliveries) != 0 {
+		t.Errorf("expected no deliveries once stopped, got %+v", later.Deliveries)
+	}
+}
+
+func TestDeliveryErrorHidesToken(t *testing.T) {
+	// Nothing listens on the port once the listener is closed
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	nm := newTestManager(t, domain.NotificationsConfig{
+		MaxAttempts: 1,
+		Channels: []domain.NotificationChannel{
+			{Name: "discord", Type: ChannelDiscord, URL: "http://" + addr + "/api/webhooks/123/secret-token"},
+		},
+	})
+	notification, err := nm.CreateNotification("title", "message", LevelError, CategorySystem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	discord := deliveries(t, nm, notification.ID)["discord"]
+	if discord.State != DeliveryFailed || !strings.Contains(discord.LastError, addr) || strings.Contains(discord.LastError, "secret-token") {
+		t.Errorf("expected a failure naming only the host, got %+v", discord)
+	}
+}
+
+// smtpStandIn accepts one message without TLS or authentication and
+// returns what the client sent
+func smtpStandIn(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	transcript := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var received strings.Builder
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP")
+		for data := false; ; {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			received.WriteString(line)
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case data:
+				if command == "." {
+					data = false
+					reply("250 queued")
+				}
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 localhost")
+			case command == "DATA":
+				data = true
+				reply("354 go ahead")
+			case command == "QUIT":
+				reply("221 bye")
+				transcript <- received.String()
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return listener.Addr().String(), transcript
+}
+
+func TestSMTPDelivery(t *testing.T) {
+	addr, transcript := smtpStandIn(t)
+	nm := newTestManager(t, domain.NotificationsConfig{
+		MaxAttempts: 1,
+		Channels: []domain.NotificationChannel{
+			{Name: "mail", URL: "mailto://" + addr + "?from=uma@tower.local&to=admin@example.com,ops@example.com"},
+		},
+	})
+
+	notification, err := nm.CreateNotification("Parity check finished", "0 errors\nTook 9h", LevelInfo, CategoryArray)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mail := deliveries(t, nm, notification.ID)["mail"]; mail.State != DeliveryDelivered {
+		t.Fatalf("expected mail delivered, got %+v", mail)
+	}
+
+	got := <-transcript
+	for _, want := range []string{
+		"MAIL FROM:<uma@tower.local>",
+		"RCPT TO:<admin@example.com>",
+		"RCPT TO:<ops@example.com>",
+		"Subject: [UMA info] Parity check finished",
+		"0 errors\r\nTook 9h",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in the SMTP transcript:\n%s", want, got)
+		}
+	}
+}
+
+func TestResolveChannel(t *testing.T) {
+	for raw, want := range map[string]domain.NotificationChannel{
+		"https://example.com/hook":             {Type: ChannelWebhook, URL: "https://example.com/hook"},
+		"jsons://example.com/hook?a=b":         {Type: ChannelWebhook, URL: "https://example.com/hook?a=b"},
+		"ntfy://uma-alerts":                    {Type: ChannelNtfy, URL: "https://ntfy.sh/uma-alerts"},
+		"ntfys://ntfy.example.com/uma?token=t": {Type: ChannelNtfy, URL: "https://ntfy.example.com/uma", Token: "t"},
+		"gotify://gotify.lan:8080/apptoken":    {Type: ChannelGotify, URL: "http://gotify.lan:8080/", Token: "apptoken"},
+		"pover://userkey@apptoken":             {Type: ChannelPushover, URL: pushoverURL, User: "userkey", Token: "apptoken"},
+		"discord://1234/abcd":                  {Type: ChannelDiscord, URL: "https://discord.com/api/webhooks/1234/abcd"},
+		"slack://T000/B000/XXXX":               {Type: ChannelSlack, URL: "https://hooks.slack.com/services/T000/B000/XXXX"},
+		"mailtos://me:pw@smtp.example.com":     {Type: ChannelSMTP, URL: "mailtos://me:pw@smtp.example.com"},
+	} {
+		got, err := resolveChannel(domain.NotificationChannel{URL: raw})
+		if err != nil {
+			t.Errorf("%s: %v", raw, err)
+			continue
+		}
+		if got.Type != want.Type || got.URL != want.URL || got.Token != want.Token || got.User != want.User {
+			t.Errorf("%s: expected %+v, got %+v", raw, want, got)
+		}
+	}
+
+	for _, invalid := range []domain.NotificationChannel{
+		{Name: "x", URL: "irc://example.com"},
+		{Name: "x", URL: "gotify://gotify.lan"},
+		{Name: "x", URL: "pover://apptoken"},
+		{Name: "x", URL: "mailto://smtp.example.com"},
+		{Name: "x", URL: "https://example.com", MinLevel: "loud"},
+		{Name: "x", URL: "https://example.com", Categories: []string{"weather"}},
+		{Name: "x", URL: "https://example.com", Template: "{{.Title"},
+		{URL: "https://example.com"},
+	} {
+		if _, err := NewDispatcher(domain.NotificationsConfig{Channels: []domain.NotificationChannel{invalid}}); err == nil {
+			t.Errorf("expected an error for %+v", invalid)
+		}
+	}
+}
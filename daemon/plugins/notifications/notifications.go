@@ -1,15 +1,19 @@
 package notifications
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/domalab/uma/daemon/events"
 	"github.com/domalab/uma/daemon/lib"
 	"github.com/domalab/uma/daemon/logger"
 )
@@ -24,6 +28,12 @@ const (
 	LevelCritical NotificationLevel = "critical"
 )
 
+// Valid reports whether l is a known level
+func (l NotificationLevel) Valid() bool {
+	_, ok := levelRank[l]
+	return ok
+}
+
 // NotificationCategory represents the category/source of a notification
 type NotificationCategory string
 
@@ -38,6 +48,19 @@ const (
 	CategoryCustom   NotificationCategory = "custom"
 )
 
+// Valid reports whether c is a known category
+func (c NotificationCategory) Valid() bool {
+	switch c {
+	case CategorySystem, CategoryArray, CategoryDocker, CategoryVM,
+		CategoryStorage, CategoryNetwork, CategorySecurity, CategoryCustom:
+		return true
+	}
+	return false
+}
+
+// ErrNotificationNotFound is returned for an unknown notification ID
+var ErrNotificationNotFound = errors.New("notification not found")
+
 // Notification represents a system notification
 type Notification struct {
 	ID         string               `json:"id"`
@@ -51,6 +74,7 @@ type Notification struct {
 	Source     string               `json:"source"`
 	Actions    []NotificationAction `json:"actions,omitempty"`
 	Metadata   map[string]string    `json:"metadata,omitempty"`
+	Deliveries []Delivery           `json:"deliveries,omitempty"`
 }
 
 // NotificationAction represents an action that can be taken on a notification
@@ -71,6 +95,8 @@ type NotificationFilter struct {
 	Persistent *bool                `json:"persistent,omitempty"`
 	Since      *time.Time           `json:"since,omitempty"`
 	Until      *time.Time           `json:"until,omitempty"`
+	Source     string               `json:"source,omitempty"`
+	Offset     int                  `json:"offset,omitempty"`
 	Limit      int                  `json:"limit,omitempty"`
 }
 
@@ -78,6 +104,18 @@ type NotificationFilter struct {
 type NotificationManager struct {
 	storageDir string
 	nextID     int
+	events     *events.Bus
+	dispatcher *Dispatcher
+	delivering sync.WaitGroup
+	unraidDir  string // Unraid's notification folders, when synced
+
+	// deliveryCtx is cancelled by Stop to end delivery retries
+	deliveryCtx  context.Context
+	stopDelivery context.CancelFunc
+
+	// mutex serialises ID assignment and read-modify-write updates, which
+	// deliveries make from their own goroutines
+	mutex sync.Mutex
 }
 
 // NewNotificationManager creates a new notification manager
@@ -91,10 +129,17 @@ func NewNotificationManager() *NotificationManager {
 		os.MkdirAll(storageDir, 0755)
 	}
 
+	return NewNotificationManagerWithStorage(storageDir)
+}
+
+// NewNotificationManagerWithStorage creates a notification manager keeping
+// notifications in storageDir, which must exist
+func NewNotificationManagerWithStorage(storageDir string) *NotificationManager {
 	nm := &NotificationManager{
 		storageDir: storageDir,
 		nextID:     1,
 	}
+	nm.deliveryCtx, nm.stopDelivery = context.WithCancel(context.Background())
 
 	// Load existing notifications to determine next ID
 	notifications, _ := nm.loadAllNotifications()
@@ -108,8 +153,16 @@ func NewNotificationManager() *NotificationManager {
 	return nm
 }
 
+// SetEventBus publishes created notifications on bus
+func (nm *NotificationManager) SetEventBus(bus *events.Bus) {
+	nm.events = bus
+}
+
 // CreateNotification creates a new notification
 func (nm *NotificationManager) CreateNotification(title, message string, level NotificationLevel, category NotificationCategory) (*Notification, error) {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
 	notification := &Notification{
 		ID:         strconv.Itoa(nm.nextID),
 		Title:      title,
@@ -125,23 +178,51 @@ func (nm *NotificationManager) CreateNotification(title, message string, level N
 
 	nm.nextID++
 
+	// Nothing is delivered once stopped
+	var routed []*channel
+	if nm.dispatcher != nil && nm.deliveryCtx.Err() == nil {
+		routed = nm.dispatcher.route(notification)
+		for _, c := range routed {
+			notification.Deliveries = append(notification.Deliveries, Delivery{
+				Channel:   c.name,
+				State:     DeliveryPending,
+				UpdatedAt: notification.Timestamp,
+			})
+		}
+	}
+
 	// Save notification to disk
 	if err := nm.saveNotification(notification); err != nil {
 		return nil, fmt.Errorf("failed to save notification: %v", err)
 	}
+	nm.dispatch(notification, routed)
 
 	// Log to system log as well
 	nm.logToSyslog(notification)
 
 	logger.Blue("Created notification: %s [%s] %s", notification.Level, notification.Category, notification.Title)
+	nm.events.Publish(events.NotificationCreated, notification.Source, events.NotificationEvent{
+		ID:       notification.ID,
+		Title:    notification.Title,
+		Message:  notification.Message,
+		Level:    string(notification.Level),
+		Category: string(notification.Category),
+	})
 	return notification, nil
 }
 
 // GetNotifications retrieves notifications with optional filtering
 func (nm *NotificationManager) GetNotifications(filter *NotificationFilter) ([]*Notification, error) {
+	notifications, _, err := nm.ListNotifications(filter)
+	return notifications, err
+}
+
+// ListNotifications returns the page of matching notifications selected by
+// the filter's offset and limit, newest first, with the number that match
+func (nm *NotificationManager) ListNotifications(filter *NotificationFilter) ([]*Notification, int, error) {
 	notifications, err := nm.loadAllNotifications()
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	// Apply filters
@@ -157,12 +238,18 @@ func (nm *NotificationManager) GetNotifications(filter *NotificationFilter) ([]*
 		return filtered[i].Timestamp.After(filtered[j].Timestamp)
 	})
 
-	// Apply limit
-	if filter != nil && filter.Limit > 0 && len(filtered) > filter.Limit {
-		filtered = filtered[:filter.Limit]
+	// Apply offset and limit
+	total := len(filtered)
+	if filter != nil {
+		if filter.Offset > 0 {
+			filtered = filtered[min(filter.Offset, total):]
+		}
+		if filter.Limit > 0 && len(filtered) > filter.Limit {
+			filtered = filtered[:filter.Limit]
+		}
 	}
 
-	return filtered, nil
+	return filtered, total, nil
 }
 
 // GetNotification retrieves a specific notification by ID
@@ -172,7 +259,7 @@ func (nm *NotificationManager) GetNotification(id string) (*Notification, error)
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("notification not found")
+			return nil, ErrNotificationNotFound
 		}
 		return nil, fmt.Errorf("failed to read notification: %v", err)
 	}
@@ -187,6 +274,9 @@ func (nm *NotificationManager) GetNotification(id string) (*Notification, error)
 
 // UpdateNotification updates an existing notification
 func (nm *NotificationManager) UpdateNotification(id string, updates map[string]interface{}) (*Notification, error) {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
 	notification, err := nm.GetNotification(id)
 	if err != nil {
 		return nil, err
@@ -199,6 +289,7 @@ func (nm *NotificationManager) UpdateNotification(id string, updates map[string]
 	if message, ok := updates["message"].(string); ok {
 		notification.Message = message
 	}
+	wasRead := notification.Read
 	if read, ok := updates["read"].(bool); ok {
 		notification.Read = read
 	}
@@ -210,6 +301,9 @@ func (nm *NotificationManager) UpdateNotification(id string, updates map[string]
 	if err := nm.saveNotification(notification); err != nil {
 		return nil, fmt.Errorf("failed to save updated notification: %v", err)
 	}
+	if notification.Read && !wasRead {
+		nm.archiveUnraid(notification)
+	}
 
 	logger.Blue("Updated notification: %s", id)
 	return notification, nil
@@ -221,7 +315,7 @@ func (nm *NotificationManager) DeleteNotification(id string) error {
 
 	if err := os.Remove(filePath); err != nil {
 		if os.IsNotExist(err) {
-			return fmt.Errorf("notification not found")
+			return ErrNotificationNotFound
 		}
 		return fmt.Errorf("failed to delete notification: %v", err)
 	}
@@ -255,6 +349,9 @@ func (nm *NotificationManager) ClearAllNotifications() error {
 
 // MarkAllAsRead marks all notifications as read
 func (nm *NotificationManager) MarkAllAsRead() error {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
 	notifications, err := nm.loadAllNotifications()
 	if err != nil {
 		return err
@@ -267,6 +364,7 @@ func (nm *NotificationManager) MarkAllAsRead() error {
 			if err := nm.saveNotification(notification); err != nil {
 				logger.Yellow("Failed to update notification %s: %v", notification.ID, err)
 			} else {
+				nm.archiveUnraid(notification)
 				count++
 			}
 		}
@@ -388,6 +486,10 @@ func (nm *NotificationManager) matchesFilter(notification *Notification, filter
 		return false
 	}
 
+	if filter.Source != "" && notification.Source != filter.Source {
+		return false
+	}
+
 	return true
 }
 
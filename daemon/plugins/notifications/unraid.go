@@ -0,0 +1,317 @@
+package notifications
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/domalab/uma/daemon/events"
+	"github.com/domalab/uma/daemon/logger"
+)
+
+const (
+	// UnraidNotificationsDir holds Unraid's unread and archive notification folders
+	UnraidNotificationsDir = "/tmp/notifications"
+
+	// MetadataUnraidFile names the .notify file a notification was imported from
+	MetadataUnraidFile = "unraid_file"
+
+	// SourceUnraid is the source of notifications imported from Unraid
+	SourceUnraid = "unraid"
+
+	unraidSyncInterval = 30 * time.Second
+	unraidIndexFile    = "unraid-sync.index"
+)
+
+// UnraidSync imports the .notify files Unraid's notify script writes and
+// archives them in Unraid once they are read through UMA. Unraid keeps every
+// notification in archive and an extra copy in unread until it is read.
+type UnraidSync struct {
+	nm     *NotificationManager
+	dir    string
+	index  map[string]string // .notify file name to notification ID
+	mutex  sync.Mutex
+	stopCh chan struct{}
+}
+
+// NewUnraidSync creates a sync between nm and the notification folders in dir
+func NewUnraidSync(nm *NotificationManager, dir string) *UnraidSync {
+	s := &UnraidSync{
+		nm:     nm,
+		dir:    dir,
+		index:  make(map[string]string),
+		stopCh: make(chan struct{}),
+	}
+
+	// The index remembers imported files, so deleting a notification in UMA
+	// does not import it again
+	if data, err := os.ReadFile(filepath.Join(nm.storageDir, unraidIndexFile)); err == nil {
+		if err := json.Unmarshal(data, &s.index); err != nil {
+			logger.Yellow("Failed to parse Unraid notification index: %v", err)
+		}
+	}
+
+	nm.mutex.Lock()
+	nm.unraidDir = dir
+	nm.mutex.Unlock()
+	return s
+}
+
+// Start imports Unraid's notifications and keeps polling for new ones
+func (s *UnraidSync) Start() {
+	logger.Blue("Syncing Unraid notifications from %s", s.dir)
+	if err := s.Sync(); err != nil {
+		logger.Yellow("Failed to sync Unraid notifications: %v", err)
+	}
+	go s.periodicSync()
+}
+
+// Stop stops polling
+func (s *UnraidSync) Stop() {
+	close(s.stopCh)
+}
+
+func (s *UnraidSync) periodicSync() {
+	ticker := time.NewTicker(unraidSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			if err := s.Sync(); err != nil {
+				logger.Yellow("Failed to sync Unraid notifications: %v", err)
+			}
+		}
+	}
+}
+
+// Sync imports new .notify files and marks notifications read once Unraid
+// has archived them
+func (s *UnraidSync) Sync() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	unread, err := notifyFiles(filepath.Join(s.dir, "unread"))
+	if err != nil {
+		return err
+	}
+	archived, err := notifyFiles(filepath.Join(s.dir, "archive"))
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for _, files := range []map[string]string{unread, archived} {
+		for name, path := range files {
+			read := unread[name] == ""
+			if id, known := s.index[name]; known {
+				if read {
+					s.nm.markRead(id)
+				}
+				continue
+			}
+
+			notification, err := parseNotifyFile(path)
+			if err != nil {
+				logger.Yellow("Failed to parse Unraid notification %s: %v", name, err)
+				continue
+			}
+			notification.Read = read
+			if err := s.nm.importNotification(notification); err != nil {
+				return err
+			}
+			s.index[name] = notification.ID
+			changed = true
+		}
+	}
+
+	// Forget files Unraid has deleted
+	for name := range s.index {
+		if unread[name] == "" && archived[name] == "" {
+			delete(s.index, name)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+	data, err := json.Marshal(s.index)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.nm.storageDir, unraidIndexFile), data, 0644)
+}
+
+// notifyFiles returns the paths of the .notify files in dir by name
+func notifyFiles(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+
+	files := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".notify") {
+			files[entry.Name()] = filepath.Join(dir, entry.Name())
+		}
+	}
+	return files, nil
+}
+
+// parseNotifyFile reads the key=value lines of a .notify file
+func parseNotifyFile(path string) (*Notification, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		fields[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if fields["subject"] == "" && fields["event"] == "" {
+		return nil, fmt.Errorf("no subject or event")
+	}
+
+	notification := &Notification{
+		Title:    fields["subject"],
+		Message:  fields["description"],
+		Level:    LevelInfo,
+		Category: unraidCategory(fields["event"] + " " + fields["subject"]),
+		Source:   SourceUnraid,
+		Metadata: map[string]string{MetadataUnraidFile: filepath.Base(path)},
+	}
+	if notification.Title == "" {
+		notification.Title = fields["event"]
+	}
+	if message := fields["message"]; message != "" {
+		if notification.Message != "" {
+			notification.Message += "\n\n"
+		}
+		notification.Message += strings.ReplaceAll(message, "<br>", "\n")
+	}
+	switch fields["importance"] {
+	case "warning":
+		notification.Level = LevelWarning
+	case "alert":
+		notification.Level = LevelError
+	}
+	for _, key := range []string{"event", "link"} {
+		if fields[key] != "" {
+			notification.Metadata[key] = fields[key]
+		}
+	}
+
+	notification.Timestamp = time.Now()
+	if seconds, err := strconv.ParseInt(fields["timestamp"], 10, 64); err == nil {
+		notification.Timestamp = time.Unix(seconds, 0)
+	} else if info, err := file.Stat(); err == nil {
+		notification.Timestamp = info.ModTime()
+	}
+	return notification, nil
+}
+
+// unraidCategory guesses a category from the event and subject
+func unraidCategory(text string) NotificationCategory {
+	text = strings.ToLower(text)
+	for _, guess := range []struct {
+		keywords []string
+		category NotificationCategory
+	}{
+		{[]string{"docker", "container"}, CategoryDocker},
+		{[]string{"vm ", "virtual machine", "libvirt"}, CategoryVM},
+		{[]string{"array", "parity", "disk"}, CategoryArray},
+		{[]string{"pool", "cache", "share", "zfs", "btrfs"}, CategoryStorage},
+		{[]string{"network", "interface", "bond"}, CategoryNetwork},
+		{[]string{"login", "ssh", "password", "security"}, CategorySecurity},
+	} {
+		for _, keyword := range guess.keywords {
+			if strings.Contains(text, keyword) {
+				return guess.category
+			}
+		}
+	}
+	return CategorySystem
+}
+
+// importNotification stores a notification from Unraid. Unraid logs and
+// delivers its own notifications, so these skip syslog and the channels.
+func (nm *NotificationManager) importNotification(notification *Notification) error {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	notification.ID = strconv.Itoa(nm.nextID)
+	notification.Persistent = notification.Level == LevelError || notification.Level == LevelCritical
+	nm.nextID++
+	if err := nm.saveNotification(notification); err != nil {
+		return fmt.Errorf("failed to save notification: %v", err)
+	}
+
+	if !notification.Read {
+		nm.events.Publish(events.NotificationCreated, notification.Source, events.NotificationEvent{
+			ID:       notification.ID,
+			Title:    notification.Title,
+			Message:  notification.Message,
+			Level:    string(notification.Level),
+			Category: string(notification.Category),
+		})
+	}
+	return nil
+}
+
+// markRead marks a notification read without archiving it in Unraid
+func (nm *NotificationManager) markRead(id string) {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	notification, err := nm.GetNotification(id)
+	if err != nil || notification.Read {
+		return
+	}
+	notification.Read = true
+	if err := nm.saveNotification(notification); err != nil {
+		logger.Yellow("Failed to update notification %s: %v", id, err)
+	}
+}
+
+// archiveUnraid archives the Unraid copy of a notification that was read
+// through UMA, as Unraid's own archive action does; the caller holds the mutex
+func (nm *NotificationManager) archiveUnraid(notification *Notification) {
+	name := notification.Metadata[MetadataUnraidFile]
+	if nm.unraidDir == "" || name == "" {
+		return
+	}
+
+	unread := filepath.Join(nm.unraidDir, "unread", name)
+	archive := filepath.Join(nm.unraidDir, "archive", name)
+	var err error
+	if _, statErr := os.Stat(archive); statErr == nil {
+		err = os.Remove(unread)
+	} else if err = os.MkdirAll(filepath.Dir(archive), 0755); err == nil {
+		err = os.Rename(unread, archive)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		logger.Yellow("Failed to archive Unraid notification %s: %v", name, err)
+	}
+}
@@ -0,0 +1,112 @@
+package notifications
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeNotify(t *testing.T, dir, folder, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(dir, folder), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, folder, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestUnraidSync(t *testing.T) {
+	dir := t.TempDir()
+	nm := NewNotificationManagerWithStorage(t.TempDir())
+	unraid := NewUnraidSync(nm, dir)
+
+	parity := "timestamp=1700000000\nevent=Parity Check\nsubject=\"Notice [TOWER] - Parity check finished (0 errors)\"\ndescription=Duration: 9 hours\nimportance=normal\nlink=/Main\n"
+	docker := "timestamp=1700000100\nevent=Docker Auto Update\nsubject=Warning [TOWER] - plex update failed\ndescription=pull failed\nimportance=alert\n"
+	writeNotify(t, dir, "unread", "Parity_Check_1700000000.notify", parity)
+	writeNotify(t, dir, "archive", "Parity_Check_1700000000.notify", parity)
+	writeNotify(t, dir, "unread", "Docker_Auto_Update_1700000100.notify", docker)
+	writeNotify(t, dir, "archive", "Old_1600000000.notify", "timestamp=1600000000\nevent=Old\nsubject=Old news\nimportance=warning\n")
+
+	if err := unraid.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	// Syncing again imports nothing new
+	if err := unraid.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	imported, total, err := nm.ListNotifications(&NotificationFilter{Source: SourceUnraid})
+	if err != nil || total != 3 {
+		t.Fatalf("expected three imported notifications, got %d (%v)", total, err)
+	}
+	byFile := make(map[string]*Notification)
+	for _, notification := range imported {
+		byFile[notification.Metadata[MetadataUnraidFile]] = notification
+	}
+
+	parityNotification := byFile["Parity_Check_1700000000.notify"]
+	if parityNotification.Title != "Notice [TOWER] - Parity check finished (0 errors)" || parityNotification.Read ||
+		parityNotification.Level != LevelInfo || parityNotification.Category != CategoryArray ||
+		parityNotification.Timestamp.Unix() != 1700000000 || parityNotification.Metadata["link"] != "/Main" {
+		t.Errorf("unexpected parity notification %+v", parityNotification)
+	}
+	if n := byFile["Docker_Auto_Update_1700000100.notify"]; n.Level != LevelError || !n.Persistent || n.Category != CategoryDocker || n.Read {
+		t.Errorf("unexpected docker notification %+v", n)
+	}
+	if n := byFile["Old_1600000000.notify"]; !n.Read || n.Level != LevelWarning {
+		t.Errorf("expected the archived notification imported read, got %+v", n)
+	}
+
+	// Reading through UMA archives in Unraid
+	if _, err := nm.UpdateNotification(parityNotification.ID, map[string]interface{}{"read": true}); err != nil {
+		t.Fatal(err)
+	}
+	if exists(filepath.Join(dir, "unread", "Parity_Check_1700000000.notify")) || !exists(filepath.Join(dir, "archive", "Parity_Check_1700000000.notify")) {
+		t.Error("expected the parity notification archived in Unraid")
+	}
+
+	// Archiving in Unraid marks read in UMA; here the file only exists in unread
+	dockerID := byFile["Docker_Auto_Update_1700000100.notify"].ID
+	if err := os.Rename(filepath.Join(dir, "unread", "Docker_Auto_Update_1700000100.notify"), filepath.Join(dir, "archive", "Docker_Auto_Update_1700000100.notify")); err != nil {
+		t.Fatal(err)
+	}
+	if err := unraid.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := nm.GetNotification(dockerID); err != nil || !n.Read {
+		t.Errorf("expected the docker notification read after Unraid archived it, got %+v (%v)", n, err)
+	}
+
+	// Deleted notifications are not imported again, even after a restart
+	if err := nm.DeleteNotification(dockerID); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewUnraidSync(nm, dir).Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if _, total, _ := nm.ListNotifications(&NotificationFilter{Source: SourceUnraid}); total != 2 {
+		t.Errorf("expected the deleted notification to stay deleted, got %d", total)
+	}
+}
+
+func TestListNotificationsPages(t *testing.T) {
+	nm := NewNotificationManagerWithStorage(t.TempDir())
+	for i := 0; i < 5; i++ {
+		if _, err := nm.CreateNotification("title", "message", LevelInfo, CategoryCustom); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct{ offset, limit, want int }{{0, 2, 2}, {4, 2, 1}, {9, 2, 0}, {1, 0, 4}} {
+		page, total, err := nm.ListNotifications(&NotificationFilter{Offset: tc.offset, Limit: tc.limit})
+		if err != nil || total != 5 || len(page) != tc.want {
+			t.Errorf("offset %d limit %d: expected %d of 5, got %d of %d (%v)", tc.offset, tc.limit, tc.want, len(page), total, err)
+		}
+	}
+}
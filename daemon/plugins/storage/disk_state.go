@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DisksIniPath is where emhttp publishes the live state of every assigned disk
+const DisksIniPath = "/var/local/emhttp/disks.ini"
+
+// DiskState is a lightweight disk snapshot read from emhttp's state file.
+// Unlike GetConsolidatedDisksInfo it never runs smartctl or wakes spun-down disks.
+type DiskState struct {
+	Name        string `json:"name"`
+	Device      string `json:"device"`
+	Role        string `json:"role"` // parity, data, cache, flash
+	Status      string `json:"status"`
+	Temperature int    `json:"temperature,omitempty"` // 0 when unknown or spun down
+	SpunDown    bool   `json:"spun_down"`
+	Size        uint64 `json:"size"`
+	FsSize      uint64 `json:"fs_size"`
+	FsUsed      uint64 `json:"fs_used"`
+	FsFree      uint64 `json:"fs_free"`
+	Errors      uint64 `json:"errors"`
+}
+
+// GetDiskStates returns the current state of all assigned disks from emhttp
+func (s *StorageMonitor) GetDiskStates() ([]DiskState, error) {
+	return ReadDiskStates(DisksIniPath)
+}
+
+// ReadDiskStates parses an emhttp disks.ini file. Sizes in the file are in KiB.
+func ReadDiskStates(path string) ([]DiskState, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open disk state: %w", err)
+	}
+	defer file.Close()
+
+	var disks []DiskState
+	var current *DiskState
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			disks = append(disks, DiskState{Name: strings.Trim(line, "[]\"")})
+			current = &disks[len(disks)-1]
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.Trim(strings.TrimSpace(value), "\"")
+
+		switch key {
+		case "name":
+			if value != "" {
+				current.Name = value
+			}
+		case "device":
+			if value != "" {
+				current.Device = "/dev/" + value
+			}
+		case "type":
+			current.Role = strings.ToLower(value)
+		case "status":
+			current.Status = value
+		case "temp":
+			// emhttp reports "*" for spun-down or unreadable disks
+			current.Temperature, _ = strconv.Atoi(value)
+		case "spundown":
+			current.SpunDown = value == "1"
+		case "size":
+			current.Size = parseKiB(value)
+		case "fsSize":
+			current.FsSize = parseKiB(value)
+		case "fsUsed":
+			current.FsUsed = parseKiB(value)
+		case "fsFree":
+			current.FsFree = parseKiB(value)
+		case "numErrors":
+			current.Errors, _ = strconv.ParseUint(value, 10, 64)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read disk state: %w", err)
+	}
+
+	// Drop unassigned slots
+	assigned := disks[:0]
+	for _, disk := range disks {
+		if disk.Device != "" && disk.Status != "DISK_NP" {
+			assigned = append(assigned, disk)
+		}
+	}
+
+	return assigned, nil
+}
+
+// parseKiB converts a KiB count to bytes, returning 0 for non-numeric values
+func parseKiB(value string) uint64 {
+	kib, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return kib * 1024
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -11,13 +12,21 @@ import (
 	"strings"
 	"time"
 
+	"github.com/domalab/uma/daemon/events"
 	"github.com/domalab/uma/daemon/lib"
 	"github.com/domalab/uma/daemon/logger"
 )
 
+// ErrArrayPrecondition is returned when a safety pre-check blocks an array state change
+var ErrArrayPrecondition = errors.New("array precondition failed")
+
+// ArrayProgressFunc receives progress (0-100) and the current step during array orchestration
+type ArrayProgressFunc func(progress int, step string)
+
 // StorageMonitor provides storage monitoring capabilities
 type StorageMonitor struct {
 	// Removed unused fields: arrayDisks, cacheDisks, bootDisk
+	events *events.Bus
 }
 
 // SMARTAttribute represents a SMART attribute
@@ -224,6 +233,11 @@ func NewStorageMonitor() *StorageMonitor {
 	return &StorageMonitor{}
 }
 
+// SetEventBus publishes array and parity events on bus
+func (s *StorageMonitor) SetEventBus(bus *events.Bus) {
+	s.events = bus
+}
+
 // GetArrayInfo returns information about the Unraid array
 func (s *StorageMonitor) GetArrayInfo() (*ArrayInfo, error) {
 	arrayInfo := &ArrayInfo{
@@ -1631,16 +1645,18 @@ func (s *StorageMonitor) calculateCacheTotals(cache *CacheInfo) {
 
 // StartArray starts the Unraid array with proper orchestration sequence
 func (s *StorageMonitor) StartArray(maintenanceMode bool, checkFilesystem bool) error {
-	logger.Blue("Starting Unraid array with orchestration (maintenance: %v, check_fs: %v)", maintenanceMode, checkFilesystem)
+	return s.StartArrayWithProgress(maintenanceMode, checkFilesystem, nil)
+}
 
-	// Step 1: Validate array configuration
-	if err := s.validateArrayConfiguration(); err != nil {
-		return fmt.Errorf("array configuration validation failed: %v", err)
-	}
+// StartArrayWithProgress starts the array, reporting each orchestration step to progress
+func (s *StorageMonitor) StartArrayWithProgress(maintenanceMode bool, checkFilesystem bool, progress ArrayProgressFunc) error {
+	logger.Blue("Starting Unraid array with orchestration (maintenance: %v, check_fs: %v)", maintenanceMode, checkFilesystem)
+	report := progressReporter(progress)
 
-	// Step 2: Check for any running parity operations
-	if err := s.checkParityOperations(); err != nil {
-		return fmt.Errorf("parity operation check failed: %v", err)
+	// Steps 1-2: Validate array configuration and check for running parity operations
+	report(5, "validating array configuration")
+	if err := s.CheckArrayStart(); err != nil {
+		return err
 	}
 
 	// Step 3: Start array via mdcmd with proper parameters
@@ -1652,6 +1668,7 @@ func (s *StorageMonitor) StartArray(maintenanceMode bool, checkFilesystem bool)
 		cmd += " CHECK=1"
 	}
 
+	report(20, "starting md devices")
 	logger.Blue("Executing array start command: %s", cmd)
 	output := lib.GetCmdOutput("sh", "-c", cmd)
 
@@ -1664,26 +1681,79 @@ func (s *StorageMonitor) StartArray(maintenanceMode bool, checkFilesystem bool)
 	}
 
 	// Step 4: Wait for array to become available
+	report(40, "waiting for array to start")
 	if err := s.waitForArrayState("started", 60); err != nil {
 		return fmt.Errorf("array failed to start within timeout: %v", err)
 	}
 
 	// Step 5: Verify filesystem mounts
+	report(90, "verifying filesystem mounts")
 	if err := s.verifyFilesystemMounts(); err != nil {
 		logger.Yellow("Warning: Some filesystems may not have mounted properly: %v", err)
 	}
 
+	report(100, "array started")
+	s.events.Publish(events.ArrayStateChanged, "array", events.ArrayEvent{State: "started", PreviousState: "stopped"})
 	logger.Blue("Array start orchestration completed successfully")
 	return nil
 }
 
+// CheckArrayStart runs the safety pre-checks for starting the array without changing its state
+func (s *StorageMonitor) CheckArrayStart() error {
+	if state := s.getArrayState(); state == "started" {
+		return fmt.Errorf("%w: array is already started", ErrArrayPrecondition)
+	}
+
+	if err := s.validateArrayConfiguration(); err != nil {
+		return fmt.Errorf("%w: array configuration validation failed: %v", ErrArrayPrecondition, err)
+	}
+
+	if err := s.checkParityOperations(); err != nil {
+		return fmt.Errorf("%w: parity operation check failed: %v", ErrArrayPrecondition, err)
+	}
+
+	return nil
+}
+
+// CheckArrayStop runs the safety pre-checks for stopping the array without changing its state.
+// Running parity operations only block the stop when force is not set.
+func (s *StorageMonitor) CheckArrayStop(force bool) error {
+	if state := s.getArrayState(); state == "stopped" {
+		return fmt.Errorf("%w: array is already stopped", ErrArrayPrecondition)
+	}
+
+	if !force {
+		if err := s.checkParityOperations(); err != nil {
+			return fmt.Errorf("%w: parity operation check failed: %v", ErrArrayPrecondition, err)
+		}
+	}
+
+	return nil
+}
+
+// progressReporter wraps an optional progress callback so callers can report unconditionally
+func progressReporter(progress ArrayProgressFunc) ArrayProgressFunc {
+	return func(percent int, step string) {
+		if progress != nil {
+			progress(percent, step)
+		}
+	}
+}
+
 // StopArray stops the Unraid array with proper orchestration sequence
 func (s *StorageMonitor) StopArray(force bool, unmountShares bool, stopContainers bool, stopVMs bool) error {
+	return s.StopArrayWithProgress(force, unmountShares, stopContainers, stopVMs, nil)
+}
+
+// StopArrayWithProgress stops the array, reporting each orchestration step to progress
+func (s *StorageMonitor) StopArrayWithProgress(force bool, unmountShares bool, stopContainers bool, stopVMs bool, progress ArrayProgressFunc) error {
 	logger.Blue("Stopping Unraid array with orchestration (force: %v, unmount_shares: %v, stop_containers: %v, stop_vms: %v)",
 		force, unmountShares, stopContainers, stopVMs)
+	report := progressReporter(progress)
 
 	// Step 1: Stop Docker containers if requested
 	if stopContainers {
+		report(10, "stopping docker containers")
 		logger.Blue("Step 1: Stopping Docker containers...")
 		if err := s.stopDockerContainers(); err != nil && !force {
 			return fmt.Errorf("failed to stop Docker containers: %v", err)
@@ -1692,6 +1762,7 @@ func (s *StorageMonitor) StopArray(force bool, unmountShares bool, stopContainer
 
 	// Step 2: Stop VMs if requested
 	if stopVMs {
+		report(25, "stopping virtual machines")
 		logger.Blue("Step 2: Stopping virtual machines...")
 		if err := s.stopVirtualMachines(); err != nil && !force {
 			return fmt.Errorf("failed to stop virtual machines: %v", err)
@@ -1700,6 +1771,7 @@ func (s *StorageMonitor) StopArray(force bool, unmountShares bool, stopContainer
 
 	// Step 3: Handle running parity operations
 	if !force {
+		report(40, "handling parity operations")
 		logger.Blue("Step 3: Checking for running parity operations...")
 		if err := s.handleParityOperations(); err != nil {
 			return fmt.Errorf("failed to handle parity operations: %v", err)
@@ -1708,6 +1780,7 @@ func (s *StorageMonitor) StopArray(force bool, unmountShares bool, stopContainer
 
 	// Step 4: Unmount user shares (FUSE mounts)
 	if unmountShares {
+		report(50, "unmounting user shares")
 		logger.Blue("Step 4: Unmounting user shares...")
 		if err := s.unmountUserShares(); err != nil && !force {
 			return fmt.Errorf("failed to unmount user shares: %v", err)
@@ -1715,12 +1788,14 @@ func (s *StorageMonitor) StopArray(force bool, unmountShares bool, stopContainer
 	}
 
 	// Step 5: Unmount array disks in reverse dependency order
+	report(60, "unmounting array disks")
 	logger.Blue("Step 5: Unmounting array disks...")
 	if err := s.unmountArrayDisks(); err != nil && !force {
 		return fmt.Errorf("failed to unmount array disks: %v", err)
 	}
 
 	// Step 6: Stop MD devices using mdcmd
+	report(75, "stopping md devices")
 	logger.Blue("Step 6: Stopping MD devices...")
 	cmd := "mdcmd stop"
 	if force {
@@ -1739,10 +1814,13 @@ func (s *StorageMonitor) StopArray(force bool, unmountShares bool, stopContainer
 	}
 
 	// Step 7: Wait for array to stop
+	report(85, "waiting for array to stop")
 	if err := s.waitForArrayState("stopped", 120); err != nil {
 		return fmt.Errorf("array failed to stop within timeout: %v", err)
 	}
 
+	report(100, "array stopped")
+	s.events.Publish(events.ArrayStateChanged, "array", events.ArrayEvent{State: "stopped", PreviousState: "started"})
 	logger.Blue("Array stop orchestration completed successfully")
 	return nil
 }
@@ -1845,6 +1923,7 @@ func (s *StorageMonitor) StartParityCheck(checkType string, priority string) err
 	}
 
 	logger.Blue("Parity %s started successfully", checkType)
+	s.events.Publish(events.ParityStarted, "parity", events.ParityEvent{Type: checkType})
 	return nil
 }
 
@@ -1864,6 +1943,7 @@ func (s *StorageMonitor) CancelParityCheck() error {
 	}
 
 	logger.Blue("Parity check cancelled successfully")
+	s.events.Publish(events.ParityFinished, "parity", events.ParityEvent{Cancelled: true})
 	return nil
 }
 
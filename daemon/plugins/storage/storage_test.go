@@ -1,6 +1,9 @@
 package storage
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -287,3 +290,86 @@ func BenchmarkGetConsolidatedDisksInfo(b *testing.B) {
 		}
 	}
 }
+
+// TestArrayPreChecks tests that array safety pre-checks report precondition failures
+func TestArrayPreChecks(t *testing.T) {
+	for _, path := range []string{"/proc/mdstat", "/var/local/emhttp/array_state", "/boot/config/disk.cfg"} {
+		if _, err := os.Stat(path); err == nil {
+			t.Skipf("%s exists; the checks depend on this host's array", path)
+		}
+	}
+	monitor := NewStorageMonitor()
+
+	// Without an array the state reads as stopped and there is no disk configuration,
+	// so starting fails validation and stopping finds the array already stopped
+	checks := map[string]func() error{
+		"start":       monitor.CheckArrayStart,
+		"stop":        func() error { return monitor.CheckArrayStop(false) },
+		"forced stop": func() error { return monitor.CheckArrayStop(true) },
+	}
+	for name, check := range checks {
+		if err := check(); !errors.Is(err, ErrArrayPrecondition) {
+			t.Errorf("%s: expected ErrArrayPrecondition, got: %v", name, err)
+		}
+	}
+}
+
+// TestReadDiskStates tests parsing emhttp's disks.ini without touching the disks
+func TestReadDiskStates(t *testing.T) {
+	content := `["parity"]
+name="parity"
+device="sdb"
+type="Parity"
+status="DISK_OK"
+temp="34"
+spundown="0"
+size="7814026532"
+numErrors="0"
+["disk1"]
+name="disk1"
+device="sdc"
+type="Data"
+status="DISK_OK"
+temp="*"
+spundown="1"
+size="7814026532"
+fsSize="7811939620"
+fsUsed="5242880"
+fsFree="7806696740"
+numErrors="3"
+["disk2"]
+name="disk2"
+device=""
+type="Data"
+status="DISK_NP"
+`
+	path := filepath.Join(t.TempDir(), "disks.ini")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write disks.ini: %v", err)
+	}
+
+	disks, err := ReadDiskStates(path)
+	if err != nil {
+		t.Fatalf("Failed to read disk states: %v", err)
+	}
+	if len(disks) != 2 {
+		t.Fatalf("Expected 2 assigned disks, got %d", len(disks))
+	}
+
+	parity := disks[0]
+	if parity.Role != "parity" || parity.Temperature != 34 || parity.Device != "/dev/sdb" {
+		t.Errorf("Unexpected parity state: %+v", parity)
+	}
+
+	data := disks[1]
+	if !data.SpunDown || data.Temperature != 0 {
+		t.Errorf("Expected spun-down disk without temperature, got %+v", data)
+	}
+	if data.FsUsed != 5242880*1024 || data.Errors != 3 {
+		t.Errorf("Expected sizes in bytes and error count, got %+v", data)
+	}
+
+	if _, err := ReadDiskStates(filepath.Join(t.TempDir(), "missing.ini")); err == nil {
+		t.Error("Expected error for missing disks.ini")
+	}
+}
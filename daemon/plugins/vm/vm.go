@@ -6,12 +6,15 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/domalab/uma/daemon/events"
 	"github.com/domalab/uma/daemon/lib"
 	"github.com/domalab/uma/daemon/logger"
 )
 
 // VMManager provides virtual machine management capabilities
-type VMManager struct{}
+type VMManager struct {
+	events *events.Bus
+}
 
 // VMInfo represents information about a virtual machine
 type VMInfo struct {
@@ -98,6 +101,16 @@ func NewVMManager() *VMManager {
 	return &VMManager{}
 }
 
+// SetEventBus publishes VM state changes on bus
+func (v *VMManager) SetEventBus(bus *events.Bus) {
+	v.events = bus
+}
+
+// publish announces the state a successful VM action left the VM in
+func (v *VMManager) publish(name string, state string) {
+	v.events.Publish(events.VMStateChanged, name, events.VMEvent{Name: name, State: state})
+}
+
 // IsLibvirtAvailable checks if libvirt is available
 func (v *VMManager) IsLibvirtAvailable() bool {
 	output := lib.GetCmdOutput("which", "virsh")
@@ -193,6 +206,7 @@ func (v *VMManager) StartVM(name string) error {
 	}
 
 	logger.Blue("Started VM: %s", name)
+	v.publish(name, "running")
 	return nil
 }
 
@@ -217,6 +231,11 @@ func (v *VMManager) StopVM(name string, force bool) error {
 	}
 
 	logger.Blue("Stopped VM: %s", name)
+
+	// A graceful shutdown is only requested here; change detection reports when the guest is off
+	if force {
+		v.publish(name, "shut off")
+	}
 	return nil
 }
 
@@ -255,6 +274,7 @@ func (v *VMManager) PauseVM(name string) error {
 	}
 
 	logger.Blue("Paused VM: %s", name)
+	v.publish(name, "paused")
 	return nil
 }
 
@@ -274,6 +294,7 @@ func (v *VMManager) ResumeVM(name string) error {
 	}
 
 	logger.Blue("Resumed VM: %s", name)
+	v.publish(name, "running")
 	return nil
 }
 
@@ -295,6 +316,7 @@ func (v *VMManager) HibernateVM(name string) error {
 	}
 
 	logger.Blue("Hibernated VM: %s (saved to %s)", name, saveFile)
+	v.publish(name, "shut off")
 	return nil
 }
 
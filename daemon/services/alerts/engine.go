@@ -0,0 +1,406 @@
+package alerts
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/domalab/uma/daemon/domain"
+	"github.com/domalab/uma/daemon/logger"
+	"github.com/domalab/uma/daemon/plugins/notifications"
+	"github.com/domalab/uma/daemon/services/collectors"
+)
+
+// State is where an alert is in its lifecycle
+type State string
+
+const (
+	StatePending  State = "pending"  // The condition holds but not yet for long enough
+	StateFiring   State = "firing"   // The condition has held for the rule's duration
+	StateResolved State = "resolved" // A firing alert whose condition no longer holds
+)
+
+// resolvedRetention is how long resolved alerts are still listed
+const resolvedRetention = time.Hour
+
+var (
+	// ErrSilenceNotFound is returned when deleting an unknown or expired silence
+	ErrSilenceNotFound = errors.New("silence not found")
+	// ErrInvalidSilence is returned for a silence that is over, names an
+	// unknown rule or has a malformed field pattern
+	ErrInvalidSilence = errors.New("invalid silence")
+)
+
+// Notifier creates notifications; NotificationManager implements it
+type Notifier interface {
+	CreateNotification(title, message string, level notifications.NotificationLevel, category notifications.NotificationCategory) (*notifications.Notification, error)
+}
+
+// Alert is one field of a collector meeting a rule's condition
+type Alert struct {
+	ID         string      `json:"id"` // Rule name and field
+	Rule       string      `json:"rule"`
+	Metric     string      `json:"metric"`
+	Field      string      `json:"field"`
+	Condition  string      `json:"condition"`
+	Severity   string      `json:"severity"`
+	State      State       `json:"state"`
+	Value      interface{} `json:"value"` // Latest value of the field
+	ActiveAt   int64       `json:"active_at"`
+	FiredAt    int64       `json:"fired_at,omitempty"`
+	ResolvedAt int64       `json:"resolved_at,omitempty"`
+	Silenced   bool        `json:"silenced"`
+
+	notified bool // Whether firing was notified, so resolving is notified too
+}
+
+// Silence suppresses notifications for alerts of a rule, or of every rule,
+// whose field matches during a window
+type Silence struct {
+	ID        string    `json:"id"`
+	Rule      string    `json:"rule,omitempty"`  // Every rule when empty
+	Field     string    `json:"field,omitempty"` // Dotted pattern like rule fields; every field when empty
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	Comment   string    `json:"comment,omitempty"`
+	CreatedBy string    `json:"created_by,omitempty"`
+}
+
+// active reports whether the silence applies at now
+func (s *Silence) active(now time.Time) bool {
+	return !now.Before(s.StartsAt) && now.Before(s.EndsAt)
+}
+
+// matches reports whether the silence covers alert
+func (s *Silence) matches(alert *Alert) bool {
+	if s.Rule != "" && s.Rule != alert.Rule {
+		return false
+	}
+	return s.Field == "" || matchSegments(strings.Split(s.Field, "."), alert.Field)
+}
+
+// notification is created after the engine's lock is released
+type notification struct {
+	title, message string
+	level          notifications.NotificationLevel
+	category       notifications.NotificationCategory
+}
+
+// Engine evaluates alert rules against collector snapshots. An alert is
+// pending while its condition holds for less than the rule's duration,
+// then firing until the condition no longer holds, allowing for hysteresis,
+// when it is resolved. Firing and resolving create notifications unless a
+// silence covers the alert.
+type Engine struct {
+	rules    []*rule
+	byMetric map[string][]*rule
+	notifier Notifier
+
+	alerts   map[string]*Alert
+	silences map[string]*Silence
+	mutex    sync.Mutex
+	now      func() time.Time
+}
+
+// NewEngine validates rules and creates an engine notifying through notifier
+func NewEngine(configured []domain.AlertRule, notifier Notifier) (*Engine, error) {
+	rules, err := compileRules(configured)
+	if err != nil {
+		return nil, err
+	}
+
+	engine := &Engine{
+		rules:    rules,
+		byMetric: make(map[string][]*rule),
+		notifier: notifier,
+		alerts:   make(map[string]*Alert),
+		silences: make(map[string]*Silence),
+		now:      time.Now,
+	}
+	for _, r := range rules {
+		engine.byMetric[r.Metric] = append(engine.byMetric[r.Metric], r)
+	}
+	return engine, nil
+}
+
+// Observe evaluates the rules on a collector snapshot. It has the signature
+// of a SystemCollector listener.
+func (e *Engine) Observe(name string, data interface{}) {
+	rules := e.byMetric[name]
+	if len(rules) == 0 {
+		return
+	}
+
+	fields, err := collectors.Flatten(data)
+	if err != nil {
+		logger.Yellow("Alert rules cannot evaluate %s: %v", name, err)
+		return
+	}
+
+	e.mutex.Lock()
+	now := e.now()
+	var pending []notification
+	for _, r := range rules {
+		pending = append(pending, e.evaluate(r, fields, now)...)
+	}
+	e.prune(now)
+	e.mutex.Unlock()
+
+	e.send(pending)
+}
+
+// evaluate moves the rule's alerts through their states. The caller must hold the mutex.
+func (e *Engine) evaluate(r *rule, fields map[string]interface{}, now time.Time) []notification {
+	var pending []notification
+
+	seen := make(map[string]bool)
+	for field, value := range r.matchFields(fields) {
+		id := r.Name + ":" + field
+		seen[id] = true
+
+		alert := e.alerts[id]
+		active := alert != nil && alert.State != StateResolved
+		if !r.holds(value, active && alert.State == StateFiring) {
+			if active {
+				pending = append(pending, e.clear(r, alert, value, now)...)
+			}
+			continue
+		}
+
+		if !active {
+			alert = &Alert{
+				ID:        id,
+				Rule:      r.Name,
+				Metric:    r.Metric,
+				Field:     field,
+				Condition: r.condition(),
+				Severity:  r.Severity,
+				State:     StatePending,
+				ActiveAt:  now.Unix(),
+			}
+			e.alerts[id] = alert
+		}
+		alert.Value = value
+
+		if alert.State == StatePending && now.Sub(time.Unix(alert.ActiveAt, 0)) >= r.hold {
+			alert.State = StateFiring
+			alert.FiredAt = now.Unix()
+		}
+		// Also notifies alerts that fired during a silence once it ends
+		if alert.State == StateFiring && !alert.notified && !e.silenced(alert, now) {
+			alert.notified = true
+			pending = append(pending, firingNotification(r, alert))
+		}
+	}
+
+	// Alerts on fields that left the snapshot, such as a removed disk, clear
+	for id, alert := range e.alerts {
+		if alert.Rule == r.Name && !seen[id] && alert.State != StateResolved {
+			pending = append(pending, e.clear(r, alert, nil, now)...)
+		}
+	}
+	return pending
+}
+
+// clear drops a pending alert or resolves a firing one. The caller must hold the mutex.
+func (e *Engine) clear(r *rule, alert *Alert, value interface{}, now time.Time) []notification {
+	if alert.State == StatePending {
+		delete(e.alerts, alert.ID)
+		return nil
+	}
+
+	alert.State = StateResolved
+	alert.ResolvedAt = now.Unix()
+	message := fmt.Sprintf("%s %s is no longer reported.", r.Metric, alert.Field)
+	if value != nil {
+		alert.Value = value
+		message = fmt.Sprintf("%s %s is %s, no longer %s.", r.Metric, alert.Field, formatValue(value), alert.Condition)
+	}
+	if !alert.notified {
+		return nil
+	}
+	return []notification{{
+		title:    fmt.Sprintf("Resolved: %s", r.Name),
+		message:  message,
+		level:    notifications.LevelInfo,
+		category: category(r.Metric),
+	}}
+}
+
+func firingNotification(r *rule, alert *Alert) notification {
+	level := notifications.LevelWarning
+	if r.Severity == SeverityCritical {
+		level = notifications.LevelCritical
+	}
+	message := fmt.Sprintf("%s %s is %s (%s)", r.Metric, alert.Field, formatValue(alert.Value), alert.Condition)
+	if r.hold > 0 {
+		message += fmt.Sprintf(" for %s", r.hold)
+	}
+	return notification{
+		title:    fmt.Sprintf("Alert: %s", r.Name),
+		message:  message + ".",
+		level:    level,
+		category: category(r.Metric),
+	}
+}
+
+// category files notifications by the collector that raised them
+func category(metric string) notifications.NotificationCategory {
+	switch strings.SplitN(metric, ".", 2)[0] {
+	case "storage":
+		return notifications.CategoryStorage
+	case "array":
+		return notifications.CategoryArray
+	case "docker":
+		return notifications.CategoryDocker
+	case "vm":
+		return notifications.CategoryVM
+	case "network":
+		return notifications.CategoryNetwork
+	}
+	return notifications.CategorySystem
+}
+
+func (e *Engine) send(pending []notification) {
+	if e.notifier == nil {
+		return
+	}
+	for _, n := range pending {
+		if _, err := e.notifier.CreateNotification(n.title, n.message, n.level, n.category); err != nil {
+			logger.Yellow("Failed to notify alert %q: %v", n.title, err)
+		}
+	}
+}
+
+// silenced reports whether an active silence covers alert. The caller must hold the mutex.
+func (e *Engine) silenced(alert *Alert, now time.Time) bool {
+	for _, silence := range e.silences {
+		if silence.active(now) && silence.matches(alert) {
+			return true
+		}
+	}
+	return false
+}
+
+// prune forgets old resolved alerts and expired silences. The caller must hold the mutex.
+func (e *Engine) prune(now time.Time) {
+	for id, alert := range e.alerts {
+		if alert.State == StateResolved && now.Sub(time.Unix(alert.ResolvedAt, 0)) > resolvedRetention {
+			delete(e.alerts, id)
+		}
+	}
+	for id, silence := range e.silences {
+		if !now.Before(silence.EndsAt) {
+			delete(e.silences, id)
+		}
+	}
+}
+
+// Alerts returns the pending, firing and recently resolved alerts, firing first
+func (e *Engine) Alerts() []Alert {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	now := e.now()
+	e.prune(now)
+
+	alerts := make([]Alert, 0, len(e.alerts))
+	for _, alert := range e.alerts {
+		a := *alert
+		a.Silenced = e.silenced(alert, now)
+		alerts = append(alerts, a)
+	}
+
+	order := map[State]int{StateFiring: 0, StatePending: 1, StateResolved: 2}
+	sort.Slice(alerts, func(i, j int) bool {
+		if order[alerts[i].State] != order[alerts[j].State] {
+			return order[alerts[i].State] < order[alerts[j].State]
+		}
+		return alerts[i].ID < alerts[j].ID
+	})
+	return alerts
+}
+
+// Rules returns the configured rules
+func (e *Engine) Rules() []domain.AlertRule {
+	rules := make([]domain.AlertRule, len(e.rules))
+	for i, r := range e.rules {
+		rules[i] = r.AlertRule
+	}
+	return rules
+}
+
+func (e *Engine) hasRule(name string) bool {
+	for _, r := range e.rules {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Silences returns the active and upcoming silences, ending soonest first
+func (e *Engine) Silences() []Silence {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.prune(e.now())
+	silences := make([]Silence, 0, len(e.silences))
+	for _, silence := range e.silences {
+		silences = append(silences, *silence)
+	}
+	sort.Slice(silences, func(i, j int) bool {
+		return silences[i].EndsAt.Before(silences[j].EndsAt)
+	})
+	return silences
+}
+
+// AddSilence adds a silence, starting now when StartsAt is zero, and returns it with its ID
+func (e *Engine) AddSilence(silence Silence) (Silence, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	now := e.now()
+	if silence.StartsAt.IsZero() {
+		silence.StartsAt = now
+	}
+	if !silence.EndsAt.After(silence.StartsAt) || !silence.EndsAt.After(now) {
+		return Silence{}, fmt.Errorf("%w: it must end in the future and after it starts", ErrInvalidSilence)
+	}
+	if silence.Rule != "" && !e.hasRule(silence.Rule) {
+		return Silence{}, fmt.Errorf("%w: unknown rule %q", ErrInvalidSilence, silence.Rule)
+	}
+	if silence.Field != "" {
+		for _, segment := range strings.Split(silence.Field, ".") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return Silence{}, fmt.Errorf("%w: malformed field pattern %q", ErrInvalidSilence, silence.Field)
+			}
+		}
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return Silence{}, fmt.Errorf("failed to generate silence ID: %w", err)
+	}
+	silence.ID = hex.EncodeToString(id)
+	e.silences[silence.ID] = &silence
+	return silence, nil
+}
+
+// DeleteSilence ends a silence early
+func (e *Engine) DeleteSilence(id string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if _, ok := e.silences[id]; !ok {
+		return ErrSilenceNotFound
+	}
+	delete(e.silences, id)
+	return nil
+}
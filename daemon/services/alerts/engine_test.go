@@ -0,0 +1,219 @@
+package alerts
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/domalab/uma/daemon/domain"
+	"github.com/domalab/uma/daemon/dto"
+	"github.com/domalab/uma/daemon/plugins/notifications"
+)
+
+type sentNotification struct {
+	title    string
+	level    notifications.NotificationLevel
+	category notifications.NotificationCategory
+}
+
+type fakeNotifier struct {
+	sent []sentNotification
+}
+
+func (f *fakeNotifier) CreateNotification(title, message string, level notifications.NotificationLevel, category notifications.NotificationCategory) (*notifications.Notification, error) {
+	f.sent = append(f.sent, sentNotification{title: title, level: level, category: category})
+	return &notifications.Notification{Title: title, Message: message}, nil
+}
+
+type disk struct {
+	Name        string `json:"name"`
+	Temperature int    `json:"temperature"`
+}
+
+type container struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+// newTestEngine returns an engine whose clock is advanced by the returned function
+func newTestEngine(t *testing.T, rules ...domain.AlertRule) (*Engine, *fakeNotifier, func(time.Duration)) {
+	t.Helper()
+
+	notifier := &fakeNotifier{}
+	engine, err := NewEngine(rules, notifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	engine.now = func() time.Time { return now }
+	return engine, notifier, func(d time.Duration) { now = now.Add(d) }
+}
+
+func alertStates(engine *Engine) map[string]State {
+	states := make(map[string]State)
+	for _, alert := range engine.Alerts() {
+		states[alert.ID] = alert.State
+	}
+	return states
+}
+
+func TestEngineLifecycle(t *testing.T) {
+	engine, notifier, advance := newTestEngine(t, domain.AlertRule{
+		Name: "Disk temperature", Metric: "storage.disks", Field: "*.temperature",
+		Operator: ">", Threshold: 50, For: "5m", Hysteresis: 3,
+	})
+	observe := func(temperature int) {
+		engine.Observe("storage.disks", []disk{{Name: "disk1", Temperature: temperature}, {Name: "disk2", Temperature: 30}})
+	}
+
+	observe(52)
+	advance(4 * time.Minute)
+	observe(53)
+	if states := alertStates(engine); len(states) != 1 || states["Disk temperature:disk1.temperature"] != StatePending || len(notifier.sent) != 0 {
+		t.Fatalf("expected disk1 pending without notifying, got %v and %v", states, notifier.sent)
+	}
+
+	advance(time.Minute)
+	observe(53)
+	if states := alertStates(engine); states["Disk temperature:disk1.temperature"] != StateFiring {
+		t.Fatalf("expected disk1 firing after 5m, got %v", states)
+	}
+	if len(notifier.sent) != 1 || notifier.sent[0].level != notifications.LevelWarning || notifier.sent[0].category != notifications.CategoryStorage {
+		t.Fatalf("expected one storage warning, got %v", notifier.sent)
+	}
+
+	// Within the hysteresis of the threshold it keeps firing
+	advance(time.Minute)
+	observe(48)
+	if states := alertStates(engine); states["Disk temperature:disk1.temperature"] != StateFiring || len(notifier.sent) != 1 {
+		t.Fatalf("expected disk1 still firing at 48, got %v", states)
+	}
+
+	advance(time.Minute)
+	observe(47)
+	alerts := engine.Alerts()
+	if len(alerts) != 1 || alerts[0].State != StateResolved || alerts[0].Value != 47.0 {
+		t.Fatalf("expected disk1 resolved at 47, got %+v", alerts)
+	}
+	if len(notifier.sent) != 2 || notifier.sent[1].level != notifications.LevelInfo {
+		t.Fatalf("expected a resolved notification, got %v", notifier.sent)
+	}
+
+	// Resolved alerts are listed for an hour
+	advance(resolvedRetention + time.Minute)
+	if alerts := engine.Alerts(); len(alerts) != 0 {
+		t.Errorf("expected resolved alerts to expire, got %+v", alerts)
+	}
+
+	// A pending alert that clears is dropped without notifying
+	observe(51)
+	observe(40)
+	if states := alertStates(engine); len(states) != 0 || len(notifier.sent) != 2 {
+		t.Errorf("expected no alerts, got %v and %v", states, notifier.sent)
+	}
+}
+
+func TestEngineTextRules(t *testing.T) {
+	engine, notifier, _ := newTestEngine(t,
+		domain.AlertRule{Name: "Plex down", Metric: "docker.containers", Field: "plex.state", Operator: "!=", Value: "running"},
+		domain.AlertRule{Name: "UPS on battery", Metric: "ups.status", Field: "UPS STATUS.condition", Operator: "==", Value: "red", Severity: "critical"},
+	)
+
+	engine.Observe("docker.containers", []container{{Name: "plex", State: "running"}})
+	engine.Observe("ups.status", []dto.Sample{{Key: "UPS STATUS", Value: "Online", Condition: "green"}})
+	if len(engine.Alerts()) != 0 {
+		t.Fatalf("expected no alerts, got %+v", engine.Alerts())
+	}
+
+	// A container missing from the inventory is not running either
+	engine.Observe("docker.containers", []container{{Name: "sonarr", State: "running"}})
+	engine.Observe("ups.status", []dto.Sample{{Key: "UPS STATUS", Value: "On Battery", Condition: "red"}})
+	states := alertStates(engine)
+	if states["Plex down:plex.state"] != StateFiring || states["UPS on battery:UPS STATUS.condition"] != StateFiring {
+		t.Fatalf("expected both alerts firing, got %v", states)
+	}
+	if len(notifier.sent) != 2 || notifier.sent[0].category != notifications.CategoryDocker || notifier.sent[1].level != notifications.LevelCritical {
+		t.Errorf("unexpected notifications %v", notifier.sent)
+	}
+}
+
+func TestEngineSilences(t *testing.T) {
+	engine, notifier, advance := newTestEngine(t, domain.AlertRule{
+		Name: "Disk temperature", Metric: "storage.disks", Field: "*.temperature", Operator: ">", Threshold: 50,
+	})
+
+	silence, err := engine.AddSilence(Silence{Rule: "Disk temperature", Field: "disk1.*", EndsAt: engine.now().Add(time.Hour), Comment: "replacing fan"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine.Observe("storage.disks", []disk{{Name: "disk1", Temperature: 55}, {Name: "disk2", Temperature: 56}})
+	if len(notifier.sent) != 1 {
+		t.Fatalf("expected only disk2 to notify, got %v", notifier.sent)
+	}
+	for _, alert := range engine.Alerts() {
+		if alert.State != StateFiring || alert.Silenced != (alert.Field == "disk1.temperature") {
+			t.Errorf("unexpected alert %+v", alert)
+		}
+	}
+
+	// Still firing when the silence ends, so it notifies then
+	if err := engine.DeleteSilence(silence.ID); err != nil {
+		t.Fatal(err)
+	}
+	advance(time.Minute)
+	engine.Observe("storage.disks", []disk{{Name: "disk1", Temperature: 55}, {Name: "disk2", Temperature: 56}})
+	if len(notifier.sent) != 2 {
+		t.Errorf("expected disk1 to notify after the silence, got %v", notifier.sent)
+	}
+
+	if err := engine.DeleteSilence(silence.ID); !errors.Is(err, ErrSilenceNotFound) {
+		t.Errorf("expected ErrSilenceNotFound, got %v", err)
+	}
+	for _, invalid := range []Silence{
+		{EndsAt: engine.now().Add(-time.Minute)},
+		{StartsAt: engine.now().Add(time.Hour), EndsAt: engine.now().Add(time.Minute)},
+		{Rule: "nope", EndsAt: engine.now().Add(time.Hour)},
+		{Field: "[", EndsAt: engine.now().Add(time.Hour)},
+	} {
+		if _, err := engine.AddSilence(invalid); !errors.Is(err, ErrInvalidSilence) {
+			t.Errorf("expected ErrInvalidSilence for %+v, got %v", invalid, err)
+		}
+	}
+
+	// Expired silences are dropped
+	if _, err := engine.AddSilence(Silence{EndsAt: engine.now().Add(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	advance(2 * time.Minute)
+	if silences := engine.Silences(); len(silences) != 0 {
+		t.Errorf("expected the silence to expire, got %+v", silences)
+	}
+}
+
+func TestCompileRules(t *testing.T) {
+	valid := domain.AlertRule{Name: "r", Metric: "m", Field: "f", Operator: ">"}
+	if _, err := compileRules(domain.DefaultConfig().Alerts.Rules); err != nil {
+		t.Errorf("default rules are invalid: %v", err)
+	}
+
+	for name, mutate := range map[string]func(*domain.AlertRule){
+		"no field":      func(r *domain.AlertRule) { r.Field = "" },
+		"operator":      func(r *domain.AlertRule) { r.Operator = "~" },
+		"value with >":  func(r *domain.AlertRule) { r.Value = "running" },
+		"duration":      func(r *domain.AlertRule) { r.For = "soon" },
+		"hysteresis":    func(r *domain.AlertRule) { r.Hysteresis = -1 },
+		"severity":      func(r *domain.AlertRule) { r.Severity = "meh" },
+		"field pattern": func(r *domain.AlertRule) { r.Field = "[.x" },
+	} {
+		rule := valid
+		mutate(&rule)
+		if _, err := compileRules([]domain.AlertRule{rule}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	if _, err := compileRules([]domain.AlertRule{valid, valid}); err == nil {
+		t.Error("expected an error for duplicate names")
+	}
+}
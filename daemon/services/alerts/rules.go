@@ -0,0 +1,183 @@
+package alerts
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/domalab/uma/daemon/domain"
+)
+
+// Rule severities
+const (
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+var operators = map[string]bool{">": true, ">=": true, "<": true, "<=": true, "==": true, "!=": true}
+
+// rule is a validated domain.AlertRule
+type rule struct {
+	domain.AlertRule
+	segments []string // Field split on dots
+	wildcard bool     // Whether the field has a pattern segment
+	text     bool     // Whether fields are compared with Value as text
+	hold     time.Duration
+}
+
+// compileRules validates the configured rules
+func compileRules(configured []domain.AlertRule) ([]*rule, error) {
+	rules := make([]*rule, 0, len(configured))
+	names := make(map[string]bool)
+	for _, r := range configured {
+		compiled, err := compileRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("alert rule %q: %w", r.Name, err)
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("alert rule %q: duplicate name", r.Name)
+		}
+		names[r.Name] = true
+		rules = append(rules, compiled)
+	}
+	return rules, nil
+}
+
+func compileRule(r domain.AlertRule) (*rule, error) {
+	if r.Name == "" || r.Metric == "" || r.Field == "" {
+		return nil, fmt.Errorf("name, metric and field are required")
+	}
+	if !operators[r.Operator] {
+		return nil, fmt.Errorf("unknown operator %q", r.Operator)
+	}
+	if r.Value != "" && r.Operator != "==" && r.Operator != "!=" {
+		return nil, fmt.Errorf("a value can only be compared with == or !=")
+	}
+	if r.Hysteresis < 0 {
+		return nil, fmt.Errorf("hysteresis cannot be negative")
+	}
+	switch r.Severity {
+	case "":
+		r.Severity = SeverityWarning
+	case SeverityWarning, SeverityCritical:
+	default:
+		return nil, fmt.Errorf("unknown severity %q", r.Severity)
+	}
+
+	compiled := &rule{AlertRule: r, segments: strings.Split(r.Field, "."), text: r.Value != ""}
+	for _, segment := range compiled.segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return nil, fmt.Errorf("invalid field pattern %q", r.Field)
+		}
+		if strings.ContainsAny(segment, "*?[") {
+			compiled.wildcard = true
+		}
+	}
+	if r.For != "" {
+		hold, err := time.ParseDuration(r.For)
+		if err != nil || hold < 0 {
+			return nil, fmt.Errorf("invalid duration %q", r.For)
+		}
+		compiled.hold = hold
+	}
+	return compiled, nil
+}
+
+// matchFields returns the snapshot fields the rule applies to. A field
+// without patterns that is missing from the snapshot is compared as empty
+// text, so a rule such as plex.state != running fires once plex is gone.
+func (r *rule) matchFields(fields map[string]interface{}) map[string]interface{} {
+	matched := make(map[string]interface{})
+	if !r.wildcard {
+		if value, ok := fields[r.Field]; ok {
+			matched[r.Field] = value
+		} else if r.text {
+			matched[r.Field] = ""
+		}
+		return matched
+	}
+
+	for field, value := range fields {
+		if matchSegments(r.segments, field) {
+			matched[field] = value
+		}
+	}
+	return matched
+}
+
+// matchSegments reports whether each dotted segment of field matches the
+// pattern segment in the same position
+func matchSegments(pattern []string, field string) bool {
+	segments := strings.Split(field, ".")
+	if len(segments) != len(pattern) {
+		return false
+	}
+	for i, segment := range segments {
+		if ok, _ := path.Match(pattern[i], segment); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// holds reports whether value meets the condition. A firing alert on a
+// numeric field keeps holding until the value recovers past the threshold
+// by the rule's hysteresis.
+func (r *rule) holds(value interface{}, firing bool) bool {
+	if r.text {
+		equal := formatValue(value) == r.Value
+		return equal == (r.Operator == "==")
+	}
+
+	v, ok := value.(float64)
+	if !ok {
+		return false
+	}
+	threshold := r.Threshold
+	if firing {
+		switch r.Operator {
+		case ">", ">=":
+			threshold -= r.Hysteresis
+		case "<", "<=":
+			threshold += r.Hysteresis
+		}
+	}
+
+	switch r.Operator {
+	case ">":
+		return v > threshold
+	case ">=":
+		return v >= threshold
+	case "<":
+		return v < threshold
+	case "<=":
+		return v <= threshold
+	case "==":
+		return v == threshold
+	default:
+		return v != threshold
+	}
+}
+
+// condition describes the rule's comparison, such as "> 50"
+func (r *rule) condition() string {
+	if r.text {
+		return fmt.Sprintf("%s %s", r.Operator, r.Value)
+	}
+	return fmt.Sprintf("%s %s", r.Operator, formatValue(r.Threshold))
+}
+
+// formatValue renders a snapshot value as text
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return v
+	}
+	return fmt.Sprint(value)
+}
@@ -1,14 +1,20 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"log"
 	"net"
 	"os"
+	"syscall"
+	"time"
 
 	"github.com/domalab/uma/daemon/common"
 	"github.com/domalab/uma/daemon/domain"
 	"github.com/domalab/uma/daemon/dto"
+	"github.com/domalab/uma/daemon/events"
 	"github.com/domalab/uma/daemon/logger"
 	"github.com/domalab/uma/daemon/plugins/diagnostics"
 	"github.com/domalab/uma/daemon/plugins/docker"
@@ -37,6 +43,7 @@ type Api struct {
 	// Services
 	configManager *config.Manager
 	asyncManager  *async.AsyncManager
+	events        *events.Bus
 
 	// Data providers
 	origin        *dto.Origin
@@ -47,9 +54,11 @@ type Api struct {
 	system        *system.SystemMonitor
 	gpu           *gpu.GPUMonitor
 	docker        *docker.DockerManager
+	dockerWatcher *docker.Watcher
 	vm            *vm.VMManager
 	diagnostics   *diagnostics.DiagnosticsManager
 	notifications *notifications.NotificationManager
+	unraidSync    *notifications.UnraidSync
 }
 
 func Create(ctx *domain.Context) *Api {
@@ -66,8 +75,14 @@ func Create(ctx *domain.Context) *Api {
 		ctx.Config.Version = loadedConfig.Version
 	}
 
-	// Initialize async manager
-	asyncManager := async.NewAsyncManager()
+	// Initialize async manager with persisted operation history
+	asyncManager := async.NewAsyncManagerWithStore(
+		async.NewJournalStore(loadedConfig.Operations.JournalPath),
+		async.RetentionPolicy{
+			MaxCount: loadedConfig.Operations.MaxCount,
+			MaxAge:   time.Duration(loadedConfig.Operations.MaxAgeHours) * time.Hour,
+		},
+	)
 
 	api := &Api{
 		ctx:           ctx,
@@ -107,9 +122,33 @@ func (a *Api) Run() error {
 	a.diagnostics = diagnostics.NewDiagnosticsManager()
 	a.notifications = notifications.NewNotificationManager()
 
+	// Plugin managers announce the state changes they cause on the hub
+	a.events = events.NewBus(a.ctx.Hub)
+	a.storage.SetEventBus(a.events)
+	a.docker.SetEventBus(a.events)
+	a.vm.SetEventBus(a.events)
+	a.notifications.SetEventBus(a.events)
+
+	// Deliver notifications to the configured external channels
+	notificationsConfig := a.configManager.GetConfig().Notifications
+	if dispatcher, err := notifications.NewDispatcher(notificationsConfig); err != nil {
+		logger.Yellow("Notification delivery disabled: %v", err)
+	} else {
+		a.notifications.SetDispatcher(dispatcher)
+	}
+	if notificationsConfig.UnraidSync {
+		a.unraidSync = notifications.NewUnraidSync(a.notifications, notifications.UnraidNotificationsDir)
+		a.unraidSync.Start()
+	}
+
 	// Initialize cache system
 	cache.InitializeGlobalInvalidator()
 
+	// Follow Docker events so container state and caches don't wait for the next poll
+	a.dockerWatcher = docker.NewWatcher(a.docker)
+	a.dockerWatcher.AddListener(invalidateContainerCaches)
+	a.dockerWatcher.Start()
+
 	// Register async operation executors
 	a.registerAsyncExecutors()
 
@@ -138,6 +177,10 @@ func (a *Api) startUnixSocketServer() {
 	if err != nil {
 		log.Fatalf("Failed to listen on %s: %v", common.Socket, err)
 	}
+	// The socket serves unrestricted MCP sessions, so only root may connect
+	if err := os.Chmod(common.Socket, 0o600); err != nil {
+		log.Fatalf("Failed to restrict %s: %v", common.Socket, err)
+	}
 	defer func() {
 		a.listener.Close()
 		os.Remove(common.Socket)
@@ -165,6 +208,16 @@ func (a *Api) Stop() error {
 		a.upsDetector.Stop()
 	}
 
+	// Stop Unraid notification sync
+	if a.unraidSync != nil {
+		a.unraidSync.Stop()
+	}
+
+	// Stop following Docker events
+	if a.dockerWatcher != nil {
+		a.dockerWatcher.Stop()
+	}
+
 	// Stop HTTP server
 	if a.httpServer != nil {
 		if err := a.httpServer.Stop(); err != nil {
@@ -174,6 +227,15 @@ func (a *Api) Stop() error {
 
 	// v2 WebSocket cleanup is handled by v2RESTServer
 
+	// Stop retrying notification deliveries, letting sends in flight finish
+	if a.notifications != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := a.notifications.Stop(ctx); err != nil {
+			logger.Yellow("Notification deliveries still in flight at shutdown: %v", err)
+		}
+		cancel()
+	}
+
 	// Stop async manager
 	if a.asyncManager != nil {
 		a.asyncManager.Stop()
@@ -196,7 +258,8 @@ func (a *Api) handleUnixSocketConnection(conn net.Conn) {
 	defer conn.Close()
 
 	var req dto.Request
-	err := json.NewDecoder(conn).Decode(&req)
+	decoder := json.NewDecoder(conn)
+	err := decoder.Decode(&req)
 	if err != nil {
 		log.Printf("Error decoding request: %v", err)
 		conn.Write([]byte(`{"error": "Invalid request"}` + "\n"))
@@ -207,6 +270,11 @@ func (a *Api) handleUnixSocketConnection(conn net.Conn) {
 
 	var resp []byte
 	switch req.Action {
+	case "mcp":
+		// The rest of the connection is an MCP stdio session for `uma mcp`
+		a.serveMCPStream(conn, io.MultiReader(decoder.Buffered(), conn))
+		return
+
 	case "get_info", "get_logs", "get_origin":
 		// v1 methods removed - use v2 API endpoints instead
 		resp, _ = json.Marshal(map[string]string{"error": "Use UMA v2 API endpoints instead"})
@@ -221,6 +289,53 @@ func (a *Api) handleUnixSocketConnection(conn net.Conn) {
 	conn.Write([]byte("\n"))
 }
 
+// serveMCPStream serves MCP over a Unix socket connection. Only root may
+// connect, so the session is not restricted to an API key scope.
+func (a *Api) serveMCPStream(conn net.Conn, r io.Reader) {
+	uid, err := peerUID(conn)
+	if err != nil {
+		logger.Yellow("Refused MCP socket session from an unidentified peer: %v", err)
+	} else if uid != 0 {
+		logger.Yellow("Refused MCP socket session from uid %d", uid)
+	}
+	if err != nil || uid != 0 {
+		conn.Write([]byte(`{"jsonrpc":"2.0","id":null,"error":{"code":-32603,"message":"MCP over the socket requires root"}}` + "\n"))
+		return
+	}
+	if a.httpServer == nil {
+		conn.Write([]byte(`{"jsonrpc":"2.0","id":null,"error":{"code":-32603,"message":"MCP requires the HTTP server to be enabled"}}` + "\n"))
+		return
+	}
+
+	if err := a.httpServer.MCPServer().ServeStream(context.Background(), r, conn); err != nil {
+		logger.Yellow("MCP socket session ended: %v", err)
+	}
+}
+
+// peerUID returns the user ID of the process at the other end of a Unix socket connection
+func peerUID(conn net.Conn) (uint32, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return 0, errors.New("not a unix socket connection")
+	}
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	var cred *syscall.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return 0, err
+	}
+	if credErr != nil {
+		return 0, credErr
+	}
+	return cred.Uid, nil
+}
+
 func (a *Api) createSensor() sensor.Sensor {
 	s, err := sensor.IdentifySensor()
 	if err != nil {
@@ -292,6 +407,33 @@ func (a *Api) GetDockerManager() *docker.DockerManager {
 	return a.docker
 }
 
+// GetDockerWatcher returns the Docker event watcher instance
+func (a *Api) GetDockerWatcher() *docker.Watcher {
+	return a.dockerWatcher
+}
+
+// dockerCacheEvents maps Docker event actions to their cache invalidation events
+var dockerCacheEvents = map[string]string{
+	"create":        "container_created",
+	"start":         "container_started",
+	"die":           "container_stopped",
+	"destroy":       "container_removed",
+	"health_status": "container_health_changed",
+}
+
+// invalidateContainerCaches drops cached container data on lifecycle events
+func invalidateContainerCaches(action string, container docker.ContainerInfo) {
+	event, ok := dockerCacheEvents[action]
+	if !ok {
+		return
+	}
+	cache.InvalidateCache(cache.CreateDockerEvent(container.Name, event, map[string]interface{}{
+		"id":     container.ID,
+		"name":   container.Name,
+		"action": action,
+	}))
+}
+
 // GetStorageMonitor returns the storage monitor instance
 func (a *Api) GetStorageMonitor() *storage.StorageMonitor {
 	return a.storage
@@ -302,6 +444,26 @@ func (a *Api) GetSystemMonitor() *system.SystemMonitor {
 	return a.system
 }
 
+// GetAsyncManager returns the async operation manager instance
+func (a *Api) GetAsyncManager() *async.AsyncManager {
+	return a.asyncManager
+}
+
+// GetDiagnosticsManager returns the diagnostics manager instance
+func (a *Api) GetDiagnosticsManager() *diagnostics.DiagnosticsManager {
+	return a.diagnostics
+}
+
+// GetNotificationManager returns the notification manager instance
+func (a *Api) GetNotificationManager() *notifications.NotificationManager {
+	return a.notifications
+}
+
+// GetEventBus returns the bus events are published on
+func (a *Api) GetEventBus() *events.Bus {
+	return a.events
+}
+
 // GetVMManager returns the VM manager instance
 func (a *Api) GetVMManager() *vm.VMManager {
 	return a.vm
@@ -312,6 +474,11 @@ func (a *Api) GetUPSDetector() *upsDetector.Detector {
 	return a.upsDetector
 }
 
+// GetUPS returns the current UPS instance
+func (a *Api) GetUPS() ups.Ups {
+	return a.ups
+}
+
 // RefreshUPS recreates the UPS instance based on current detection status
 func (a *Api) RefreshUPS() {
 	a.ups = a.createUps()
@@ -4,14 +4,26 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/domalab/uma/daemon/logger"
+	"github.com/domalab/uma/daemon/plugins/docker"
+	"github.com/domalab/uma/daemon/services/alerts"
+	"github.com/domalab/uma/daemon/services/api/middleware"
 	restapi "github.com/domalab/uma/daemon/services/api/rest"
 	"github.com/domalab/uma/daemon/services/api/services"
+	"github.com/domalab/uma/daemon/services/async"
+	"github.com/domalab/uma/daemon/services/auth"
+	"github.com/domalab/uma/daemon/services/changes"
 	"github.com/domalab/uma/daemon/services/collectors"
 	"github.com/domalab/uma/daemon/services/command"
 	"github.com/domalab/uma/daemon/services/config"
+	"github.com/domalab/uma/daemon/services/forecast"
+	"github.com/domalab/uma/daemon/services/history"
+	"github.com/domalab/uma/daemon/services/mcp"
+	"github.com/domalab/uma/daemon/services/metrics"
+	"github.com/domalab/uma/daemon/services/mqtt"
 	"github.com/domalab/uma/daemon/services/streaming"
 	"github.com/go-playground/validator/v10"
 )
@@ -32,6 +44,10 @@ type HTTPServer struct {
 	v2Collector  *collectors.SystemCollector
 	v2Streamer   *streaming.WebSocketEngine
 	v2RESTServer *restapi.RESTServer
+	history      *history.Store
+	forecast     *forecast.Service
+	alerts       *alerts.Engine
+	mqtt         *mqtt.Publisher
 }
 
 // NewHTTPServer creates a new HTTP server instance - UMA v2 only
@@ -55,16 +71,134 @@ func NewHTTPServer(api *Api, port int) *HTTPServer {
 
 // Start starts the HTTP server - UMA v2 only
 func (h *HTTPServer) Start() error {
+	cfg := h.api.configManager.GetConfig()
+
+	// Build the configured middleware pipeline before anything starts
+	pipeline, err := middleware.Pipeline(cfg.Middleware)
+	if err != nil {
+		logger.Red("Invalid middleware configuration: %v", err)
+		return err
+	}
+
+	// Plugin-backed collectors must be registered before the collector starts
+	h.registerPluginCollectors()
+
+	// Record every collector's numeric fields for /api/v2/history
+	if cfg.History.Enabled {
+		store, err := history.NewStore(history.Config{
+			Path:       cfg.History.Path,
+			MaxSize:    int64(cfg.History.MaxSizeMB) << 20,
+			Keep:       cfg.History.Keep,
+			StaleAfter: time.Duration(cfg.History.StaleHours) * time.Hour,
+		})
+		if err != nil {
+			logger.Yellow("Metric history disabled: %v", err)
+		} else {
+			h.history = store
+			h.v2Collector.AddListener(store.Observe)
+		}
+	}
+
+	// Project storage usage recorded in the history and notify before anything fills up
+	if cfg.Forecast.Enabled && h.history != nil {
+		h.forecast = forecast.NewService(h.history, h.api.GetNotificationManager(), forecast.Config{
+			Window:        time.Duration(cfg.Forecast.WindowDays) * 24 * time.Hour,
+			ThresholdDays: float64(cfg.Forecast.ThresholdDays),
+			StatePath:     cfg.Forecast.StatePath,
+		})
+		h.forecast.Start()
+	}
+
+	// Evaluate the configured alert rules on every snapshot and notify as they fire and resolve
+	if cfg.Alerts.Enabled {
+		engine, err := alerts.NewEngine(cfg.Alerts.Rules, h.api.GetNotificationManager())
+		if err != nil {
+			logger.Yellow("Alert rules disabled: %v", err)
+		} else {
+			h.alerts = engine
+			h.v2Collector.AddListener(engine.Observe)
+		}
+	}
+
+	// Publish snapshots and entity states to MQTT for Home Assistant, which can switch containers and VMs back
+	if cfg.MQTT.Enabled {
+		publisher, err := mqtt.NewPublisher(cfg.MQTT, cfg.Version, mqtt.Controllers{
+			Containers: h.api.GetDockerManager(),
+			VMs:        h.api.GetVMManager(),
+			Parity:     h.api.GetStorageMonitor(),
+		})
+		if err != nil {
+			logger.Yellow("MQTT publishing disabled: %v", err)
+		} else {
+			h.mqtt = publisher
+			publisher.Start()
+			h.v2Collector.AddListener(publisher.Observe)
+		}
+	}
+
 	// Start v2 collector
 	if err := h.v2Collector.Start(); err != nil {
 		logger.Red("Failed to start v2 collector: %v", err)
 		return err
 	}
 
-	// UMA v2 API - Pure v2 implementation without v1 compatibility
+	// Plugins are created in Api.Run, so wire them in before serving
+	h.v2RESTServer.SetServices(restapi.Services{
+		Docker:        h.api.GetDockerManager(),
+		Storage:       h.api.GetStorageMonitor(),
+		Async:         h.api.GetAsyncManager(),
+		Diagnostics:   h.api.GetDiagnosticsManager(),
+		Notifications: h.api.GetNotificationManager(),
+		History:       h.history,
+		Forecast:      h.forecast,
+		Alerts:        h.alerts,
+	})
+
+	// MCP shares the HTTP port; apply its connection limit and enable flag
+	h.v2RESTServer.SetMCPConfig(cfg.MCP)
+
+	// Container exec sessions only accept browser origins on the CORS allowlist
+	h.v2RESTServer.SetCORSConfig(cfg.Middleware.CORS)
+
+	// Push operation progress and completion to stream subscribers
+	h.v2Streamer.RegisterChannel(streaming.ChannelOperations, async.OperationEvent{})
+	h.api.GetAsyncManager().AddListener(func(event async.OperationEvent) {
+		h.v2Streamer.Publish(streaming.ChannelOperations, event)
+	})
+
+	// Follow a container's output only while someone is subscribed to its logs channel
+	h.v2Streamer.RegisterChannelSource(streaming.ChannelContainerLogs, docker.LogLine{}, func(ctx context.Context, channel string) error {
+		containerID := strings.TrimPrefix(channel, streaming.ChannelContainerLogs)
+		options := docker.LogOptions{Tail: 0, Timestamps: true, Follow: true}
+		return h.api.GetDockerManager().StreamContainerLogs(ctx, containerID, options, func(line docker.LogLine) error {
+			h.v2Streamer.Publish(channel, line)
+			return nil
+		})
+	})
+
+	// Turn collector snapshot changes into events and stream them on the events.* channels
+	bus := h.api.GetEventBus()
+	detector := changes.NewDetector(bus)
+	detector.Start()
+	h.v2Collector.AddListener(detector.Observe)
+	h.v2Streamer.StreamEvents(bus)
+
+	// Export collector snapshots to Prometheus at /metrics
+	if err := middleware.RegisterCustomMetrics(metrics.NewPrometheusCollector(h.v2Collector)); err != nil {
+		logger.Yellow("Failed to register Prometheus collector: %v", err)
+	}
+
+	// Require API keys on REST, streaming and MCP when enabled in the config
+	authenticator := auth.NewAuthenticator(cfg.Auth)
+	if authenticator.Enabled() {
+		logger.Blue("API key authentication enabled")
+	}
+
+	// UMA v2 API - Pure v2 implementation without v1 compatibility.
+	// Auth sits innermost so rejected requests are still traced, logged and CORS-tagged.
 	h.server = &http.Server{
 		Addr:         fmt.Sprintf(":%d", h.port),
-		Handler:      h.v2RESTServer,
+		Handler:      pipeline(middleware.Auth(authenticator)(h.v2RESTServer)),
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -83,6 +217,56 @@ func (h *HTTPServer) Start() error {
 	return nil
 }
 
+// registerPluginCollectors feeds plugin data into the system collector. These
+// run on a fixed cadence so consumers such as /metrics only ever read the cache.
+func (h *HTTPServer) registerPluginCollectors() {
+	storageMonitor := h.api.GetStorageMonitor()
+
+	// emhttp's disks.ini is read instead of smartctl so spun-down disks stay asleep
+	h.v2Collector.RegisterCollector("storage.disks", 30*time.Second, collectors.LowPriority, 50*time.Millisecond, func() (interface{}, error) {
+		return storageMonitor.GetDiskStates()
+	})
+
+	h.v2Collector.RegisterCollector("array.parity", 10*time.Second, collectors.MediumPriority, 10*time.Millisecond, func() (interface{}, error) {
+		return storageMonitor.GetParityCheckStatus()
+	})
+
+	h.v2Collector.RegisterCollector(changes.CollectorArray, 30*time.Second, collectors.LowPriority, 50*time.Millisecond, func() (interface{}, error) {
+		return storageMonitor.GetArrayInfo()
+	})
+
+	// Pool usage changes slowly and is only recorded for the storage forecast
+	h.v2Collector.RegisterCollector(forecast.MetricPools, 5*time.Minute, collectors.LowPriority, 200*time.Millisecond, func() (interface{}, error) {
+		return storageMonitor.GetCacheInfo()
+	})
+
+	// Container and VM inventories feed change detection, which reports crashes and external state changes
+	h.v2Collector.RegisterCollector(changes.CollectorContainers, 15*time.Second, collectors.LowPriority, 500*time.Millisecond, func() (interface{}, error) {
+		// The event watcher keeps the inventory current; poll only while it is reconnecting
+		if containers, synced := h.api.GetDockerWatcher().Containers(); synced {
+			return containers, nil
+		}
+		return h.api.GetDockerManager().ListContainers(true)
+	})
+
+	h.v2Collector.RegisterCollector(changes.CollectorVMs, 15*time.Second, collectors.LowPriority, 200*time.Millisecond, func() (interface{}, error) {
+		return h.api.GetVMManager().ListVMs(true)
+	})
+
+	h.v2Collector.RegisterCollector("ups.status", 15*time.Second, collectors.LowPriority, 100*time.Millisecond, func() (interface{}, error) {
+		current := h.api.GetUPS()
+		if current == nil {
+			return nil, nil
+		}
+		return current.GetStatus(), nil
+	})
+}
+
+// MCPServer returns the MCP server mounted at /mcp
+func (h *HTTPServer) MCPServer() *mcp.Server {
+	return h.v2RESTServer.MCPServer()
+}
+
 // Stop gracefully stops the HTTP server
 func (h *HTTPServer) Stop() error {
 	if h.server == nil {
@@ -93,7 +277,23 @@ func (h *HTTPServer) Stop() error {
 	defer cancel()
 
 	logger.Blue("Shutting down HTTP API server...")
-	return h.server.Shutdown(ctx)
+
+	// Shutdown neither closes hijacked MCP connections nor waits out SSE streams
+	h.v2RESTServer.Stop()
+	err := h.server.Shutdown(ctx)
+
+	if h.forecast != nil {
+		h.forecast.Stop()
+	}
+	if h.mqtt != nil {
+		h.mqtt.Stop()
+	}
+	if h.history != nil {
+		if closeErr := h.history.Close(); closeErr != nil {
+			logger.Yellow("Failed to close metric history: %v", closeErr)
+		}
+	}
+	return err
 }
 
 // Legacy handler methods moved to respective handler files
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/domalab/uma/daemon/services/auth"
+)
+
+// Auth returns a middleware that requires a valid API key with a sufficient
+// scope. It is a no-op when authentication is disabled in the config.
+func Auth(authenticator *auth.Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Preflight requests never carry credentials
+			if !authenticator.Enabled() || r.Method == http.MethodOptions || authenticator.IsPublic(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key, err := authenticator.Authenticate(auth.KeyFromRequest(r))
+			if err != nil {
+				challenge := `Bearer realm="uma"`
+				if errors.Is(err, auth.ErrInvalidKey) {
+					challenge += `, error="invalid_token"`
+				}
+				w.Header().Set("WWW-Authenticate", challenge)
+				writeAuthError(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+
+			required := auth.RequiredScope(r)
+			if !auth.Scope(key.Scope).Allows(required) {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="uma", error="insufficient_scope", scope="%s"`, required))
+				writeAuthError(w, http.StatusForbidden, fmt.Sprintf("api key %q lacks the %s scope", key.Name, required))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), key)))
+		})
+	}
+}
+
+// writeAuthError writes an error body in the same shape as the REST handlers
+func writeAuthError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":     message,
+		"timestamp": time.Now().Unix(),
+		"status":    status,
+	})
+}
@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"compress/gzip"
 	"fmt"
-	"io"
 	"net"
 	"net/http"
 	"strings"
@@ -15,7 +14,8 @@ func Compression() func(http.Handler) http.Handler {
 	return CompressionWithConfig(DefaultCompressionConfig())
 }
 
-// CompressionWithConfig returns a compression middleware with custom configuration
+// CompressionWithConfig returns a compression middleware with custom configuration.
+// Responses are buffered up to MinLength bytes so small bodies are sent as-is.
 func CompressionWithConfig(config CompressionConfig) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -25,26 +25,19 @@ func CompressionWithConfig(config CompressionConfig) func(http.Handler) http.Han
 				return
 			}
 
-			// Only compress responses for certain endpoints or large responses
-			shouldCompress := shouldCompressResponse(r, config)
-			if !shouldCompress {
+			// WebSocket upgrades and excluded endpoints are never compressed
+			if r.Header.Get("Upgrade") != "" || isExcludedPath(r.URL.Path, config) {
 				next.ServeHTTP(w, r)
 				return
 			}
 
-			// Create gzip writer
-			gz := gzip.NewWriter(w)
-			defer gz.Close()
-
-			// Set compression headers
-			w.Header().Set("Content-Encoding", "gzip")
-			w.Header().Set("Vary", "Accept-Encoding")
-
-			// Wrap response writer
 			gzw := &gzipResponseWriter{
 				ResponseWriter: w,
-				Writer:         gz,
+				config:         config,
+				forced:         isCompressiblePath(r.URL.Path, config),
+				statusCode:     http.StatusOK,
 			}
+			defer gzw.Close()
 
 			next.ServeHTTP(gzw, r)
 		})
@@ -84,6 +77,7 @@ func DefaultCompressionConfig() CompressionConfig {
 		},
 		ExcludedPaths: []string{
 			"/api/v2/stream", // WebSocket streaming endpoint
+			"/api/v2/events", // Server-Sent Events stream
 		},
 		ExcludedTypes: []string{
 			"image/",
@@ -94,69 +88,159 @@ func DefaultCompressionConfig() CompressionConfig {
 	}
 }
 
-// shouldCompressResponse determines if a response should be compressed
-func shouldCompressResponse(r *http.Request, config CompressionConfig) bool {
-	path := r.URL.Path
-
-	// Check excluded paths first
+// isExcludedPath reports whether a path is never compressed
+func isExcludedPath(path string, config CompressionConfig) bool {
 	for _, excludedPath := range config.ExcludedPaths {
 		if strings.HasPrefix(path, excludedPath) {
-			return false
+			return true
 		}
 	}
+	return false
+}
 
-	// Check compressible paths
+// isCompressiblePath reports whether a path is compressed regardless of content type
+func isCompressiblePath(path string, config CompressionConfig) bool {
 	for _, compressiblePath := range config.CompressiblePaths {
 		if strings.HasPrefix(path, compressiblePath) {
 			return true
 		}
 	}
+	return false
+}
 
-	// Check content type if available in request
-	contentType := r.Header.Get("Content-Type")
-	if contentType != "" {
-		// Check excluded types
-		for _, excludedType := range config.ExcludedTypes {
-			if strings.HasPrefix(contentType, excludedType) {
-				return false
-			}
+// isCompressibleType reports whether a response content type should be compressed
+func isCompressibleType(contentType string, config CompressionConfig) bool {
+	for _, excludedType := range config.ExcludedTypes {
+		if strings.HasPrefix(contentType, excludedType) {
+			return false
 		}
-
-		// Check compressible types
-		for _, compressibleType := range config.CompressibleTypes {
-			if strings.HasPrefix(contentType, compressibleType) {
-				return true
-			}
+	}
+	for _, compressibleType := range config.CompressibleTypes {
+		if strings.HasPrefix(contentType, compressibleType) {
+			return true
 		}
 	}
-
 	return false
 }
 
-// gzipResponseWriter wraps http.ResponseWriter to compress responses
+// gzipResponseWriter defers the compression decision until MinLength bytes
+// have been written or the response ends, whichever comes first
 type gzipResponseWriter struct {
 	http.ResponseWriter
-	Writer io.Writer
+	config     CompressionConfig
+	forced     bool
+	buffer     []byte
+	statusCode int
+	decided    bool
+	gz         *gzip.Writer
 }
 
 func (w *gzipResponseWriter) Write(b []byte) (int, error) {
-	return w.Writer.Write(b)
+	if !w.decided {
+		w.buffer = append(w.buffer, b...)
+		if len(w.buffer) < w.config.MinLength {
+			return len(b), nil
+		}
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
 }
 
 func (w *gzipResponseWriter) WriteHeader(code int) {
-	w.ResponseWriter.WriteHeader(code)
+	if w.decided {
+		return
+	}
+	w.statusCode = code
+
+	// Bodiless responses have nothing to compress
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified {
+		w.decided = true
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+// decide chooses between gzip and passthrough, then writes the header and buffered body
+func (w *gzipResponseWriter) decide() error {
+	w.decided = true
+
+	header := w.Header()
+	if header.Get("Content-Type") == "" && len(w.buffer) > 0 {
+		header.Set("Content-Type", http.DetectContentType(w.buffer))
+	}
+
+	compress := len(w.buffer) >= w.config.MinLength && len(w.buffer) > 0 &&
+		header.Get("Content-Encoding") == "" &&
+		(w.forced || isCompressibleType(header.Get("Content-Type"), w.config))
+
+	if compress {
+		gz, err := gzip.NewWriterLevel(w.ResponseWriter, w.config.Level)
+		if err != nil {
+			gz = gzip.NewWriter(w.ResponseWriter)
+		}
+		w.gz = gz
+		header.Set("Content-Encoding", "gzip")
+		header.Add("Vary", "Accept-Encoding")
+		header.Del("Content-Length")
+	}
+
+	w.ResponseWriter.WriteHeader(w.statusCode)
+
+	buffered := w.buffer
+	w.buffer = nil
+	if len(buffered) == 0 {
+		return nil
+	}
+	if w.gz != nil {
+		_, err := w.gz.Write(buffered)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buffered)
+	return err
+}
+
+// Close flushes any buffered response and finishes the gzip stream
+func (w *gzipResponseWriter) Close() error {
+	if !w.decided {
+		if err := w.decide(); err != nil {
+			return err
+		}
+	}
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+	return nil
 }
 
 // Flush implements http.Flusher interface
 func (w *gzipResponseWriter) Flush() {
+	if !w.decided {
+		w.decide()
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
 	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
 		flusher.Flush()
 	}
 }
 
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 // Hijack implements http.Hijacker interface
 func (w *gzipResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		// A hijacked connection bypasses this writer entirely
+		w.decided = true
 		return hijacker.Hijack()
 	}
 	return nil, nil, fmt.Errorf("response writer does not support hijacking")
@@ -2,34 +2,56 @@ package middleware
 
 import (
 	"net/http"
+	"strconv"
 	"strings"
 )
 
 // CORS returns a middleware that handles Cross-Origin Resource Sharing (CORS) headers
 func CORS() func(http.Handler) http.Handler {
+	return CORSWithConfig(DefaultCORSConfig())
+}
+
+// CORSWithConfig returns a CORS middleware with custom configuration
+func CORSWithConfig(config CORSConfig) func(http.Handler) http.Handler {
+	allowedMethods := strings.Join(config.AllowedMethods, ", ")
+	allowedHeaders := strings.Join(config.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(config.MaxAge)
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Set CORS headers
 			origin := r.Header.Get("Origin")
-			
-			// Allow all origins for internal API (since this is an internal-only service)
-			// In production, you might want to restrict this to specific origins
-			if origin != "" {
-				// Validate origin to prevent malicious origins
-				if isValidOrigin(origin) {
+			// Credentials are only shared with origins listed by name, never with any origin
+			credentials := false
+
+			if len(config.AllowedOrigins) == 0 {
+				// Allow all origins for internal API (since this is an internal-only service)
+				if origin != "" && isValidOrigin(origin) {
 					w.Header().Set("Access-Control-Allow-Origin", origin)
 				} else {
-					// For invalid origins, set a safe default
+					// For invalid or missing origins, set a safe default
 					w.Header().Set("Access-Control-Allow-Origin", "*")
 				}
-			} else {
-				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else if origin != "" {
+				// Only echo origins on the allowlist; others get no CORS grant
+				w.Header().Add("Vary", "Origin")
+				if !isAllowedOrigin(origin, config.AllowedOrigins) {
+					if r.Method == http.MethodOptions {
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					next.ServeHTTP(w, r)
+					return
+				}
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				credentials = config.AllowCredentials && isListedOrigin(origin, config.AllowedOrigins)
 			}
-			
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
-			w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Request-ID")
-			w.Header().Set("Access-Control-Allow-Credentials", "true")
-			w.Header().Set("Access-Control-Max-Age", "86400") // 24 hours
+
+			w.Header().Set("Access-Control-Allow-Methods", allowedMethods)
+			w.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
+			if credentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+			w.Header().Set("Access-Control-Max-Age", maxAge)
 
 			// Handle preflight requests
 			if r.Method == "OPTIONS" {
@@ -42,6 +64,46 @@ func CORS() func(http.Handler) http.Handler {
 	}
 }
 
+// CORSConfig represents CORS middleware configuration
+type CORSConfig struct {
+	AllowedOrigins   []string `json:"allowed_origins"`   // Empty allows any valid origin
+	AllowedMethods   []string `json:"allowed_methods"`
+	AllowedHeaders   []string `json:"allowed_headers"`
+	AllowCredentials bool     `json:"allow_credentials"` // Only for origins listed by name in AllowedOrigins
+	MaxAge           int      `json:"max_age"`           // Preflight cache duration in seconds
+}
+
+// DefaultCORSConfig returns a default CORS configuration
+func DefaultCORSConfig() CORSConfig {
+	return CORSConfig{
+		AllowedOrigins:   nil,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
+		AllowedHeaders:   []string{"Accept", "Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "X-Request-ID", "X-API-Key"},
+		AllowCredentials: false,
+		MaxAge:           86400, // 24 hours
+	}
+}
+
+// isAllowedOrigin checks the origin against the allowlist; "*" matches any origin
+func isAllowedOrigin(origin string, allowed []string) bool {
+	for _, candidate := range allowed {
+		if candidate == "*" || strings.EqualFold(candidate, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// isListedOrigin checks the origin against the allowlist entries that name it, ignoring "*"
+func isListedOrigin(origin string, allowed []string) bool {
+	for _, candidate := range allowed {
+		if candidate != "*" && strings.EqualFold(candidate, origin) {
+			return true
+		}
+	}
+	return false
+}
+
 // isValidOrigin checks if the origin is valid and safe
 func isValidOrigin(origin string) bool {
 	// Block obviously malicious origins
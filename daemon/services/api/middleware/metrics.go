@@ -1,6 +1,9 @@
 package middleware
 
 import (
+	"bufio"
+	"fmt"
+	"net"
 	"net/http"
 	"strconv"
 	"time"
@@ -168,6 +171,26 @@ func (mrw *metricsResponseWriter) Write(b []byte) (int, error) {
 	return size, err
 }
 
+// Flush implements http.Flusher interface
+func (mrw *metricsResponseWriter) Flush() {
+	if flusher, ok := mrw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (mrw *metricsResponseWriter) Unwrap() http.ResponseWriter {
+	return mrw.ResponseWriter
+}
+
+// Hijack implements http.Hijacker interface
+func (mrw *metricsResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := mrw.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, fmt.Errorf("response writer does not support hijacking")
+}
+
 // RecordCustomMetric records a custom metric with labels
 func RecordCustomMetric(name string, value float64, labels map[string]string) {
 	// This would be implemented based on specific metric requirements
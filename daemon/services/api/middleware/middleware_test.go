@@ -9,6 +9,9 @@ import (
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/domalab/uma/daemon/domain"
+	"github.com/domalab/uma/daemon/services/auth"
 )
 
 // TestCORS tests the CORS middleware
@@ -37,7 +40,7 @@ func TestCORS(t *testing.T) {
 			checkHeaders: map[string]string{
 				"Access-Control-Allow-Origin":  "http://localhost:3000",
 				"Access-Control-Allow-Methods": "GET, POST, PUT, DELETE, OPTIONS, PATCH",
-				"Access-Control-Allow-Headers": "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Request-ID",
+				"Access-Control-Allow-Headers": "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Request-ID, X-API-Key",
 			},
 		},
 		{
@@ -48,7 +51,7 @@ func TestCORS(t *testing.T) {
 			checkHeaders: map[string]string{
 				"Access-Control-Allow-Origin":  "http://localhost:3000",
 				"Access-Control-Allow-Methods": "GET, POST, PUT, DELETE, OPTIONS, PATCH",
-				"Access-Control-Allow-Headers": "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Request-ID",
+				"Access-Control-Allow-Headers": "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Request-ID, X-API-Key",
 			},
 		},
 	}
@@ -695,3 +698,250 @@ func TestGetMetricsHandler(t *testing.T) {
 		t.Error("Expected Prometheus metrics format in response")
 	}
 }
+
+// TestAuth tests API key enforcement and scope checks
+func TestAuth(t *testing.T) {
+	readKey, readRecord, _ := auth.GenerateAPIKey("dashboard", auth.ScopeReadOnly)
+	opKey, opRecord, _ := auth.GenerateAPIKey("automation", auth.ScopeOperator)
+
+	authenticator := auth.NewAuthenticator(domain.AuthConfig{
+		Enabled:     true,
+		APIKeys:     []domain.APIKey{readRecord, opRecord},
+		PublicPaths: []string{"/api/v2/system/health"},
+	})
+
+	var caller string
+	handler := Auth(authenticator)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key, ok := auth.FromContext(r.Context()); ok {
+			caller = key.Name
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		key            string
+		expectedStatus int
+		expectedCaller string
+	}{
+		{"public path without key", "GET", "/api/v2/system/health", "", http.StatusOK, ""},
+		{"missing key", "GET", "/api/v2/system/info", "", http.StatusUnauthorized, ""},
+		{"invalid key", "GET", "/api/v2/system/info", "uma_00000000_nope", http.StatusUnauthorized, ""},
+		{"read-only read", "GET", "/api/v2/system/info", readKey, http.StatusOK, "dashboard"},
+		{"read-only action", "POST", "/api/v2/containers/plex/restart", readKey, http.StatusForbidden, ""},
+		{"operator action", "POST", "/api/v2/containers/plex/restart", opKey, http.StatusOK, "automation"},
+		{"operator admin action", "POST", "/api/v2/system/reboot", opKey, http.StatusForbidden, ""},
+		{"preflight", "OPTIONS", "/api/v2/system/info", "", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caller = ""
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.key)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if caller != tt.expectedCaller {
+				t.Errorf("Expected caller %q, got %q", tt.expectedCaller, caller)
+			}
+			if w.Code == http.StatusUnauthorized && !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Bearer") {
+				t.Error("Expected WWW-Authenticate challenge on 401")
+			}
+		})
+	}
+}
+
+// TestAuthDisabled tests that the middleware is transparent when auth is disabled
+func TestAuthDisabled(t *testing.T) {
+	handler := Auth(auth.NewAuthenticator(domain.AuthConfig{}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("POST", "/api/v2/system/reboot", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+}
+
+// TestPipeline tests building the middleware chain from config
+func TestPipeline(t *testing.T) {
+	var seenIDs []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenIDs = append(seenIDs, GetRequestIDFromContext(r))
+		w.WriteHeader(http.StatusOK)
+	})
+
+	config := domain.DefaultMiddlewareConfig()
+	config.Compression.Enabled = false
+	pipeline, err := Pipeline(config)
+	if err != nil {
+		t.Fatalf("Failed to build default pipeline: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/v2/system/info", nil)
+	req.Header.Set("X-Request-ID", "client-id")
+	req.Header.Set("Origin", "http://localhost:3000")
+	w := httptest.NewRecorder()
+	pipeline(handler).ServeHTTP(w, req)
+
+	if w.Header().Get("X-Request-ID") != "client-id" || len(seenIDs) != 1 || seenIDs[0] != "client-id" {
+		t.Errorf("Expected client request ID to be reused, got header %q and context %v", w.Header().Get("X-Request-ID"), seenIDs)
+	}
+	if w.Header().Get("X-API-Version") != "v2" {
+		t.Errorf("Expected negotiated version v2, got %q", w.Header().Get("X-API-Version"))
+	}
+	if w.Header().Get("Access-Control-Allow-Origin") == "" {
+		t.Error("Expected CORS headers from the pipeline")
+	}
+
+	// Disabled middlewares are skipped
+	config.Order = []string{"request_id", "cors"}
+	config.CORS.Enabled = false
+	pipeline, err = Pipeline(config)
+	if err != nil {
+		t.Fatalf("Failed to build pipeline: %v", err)
+	}
+	w = httptest.NewRecorder()
+	pipeline(handler).ServeHTTP(w, httptest.NewRequest("GET", "/api/v2/system/info", nil))
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("Expected disabled CORS middleware to be skipped")
+	}
+	if w.Header().Get("X-API-Version") != "" {
+		t.Error("Expected middlewares missing from the order to be skipped")
+	}
+
+	// Invalid orders are rejected
+	if _, err := Pipeline(domain.MiddlewareConfig{Order: []string{"gzip"}}); err == nil {
+		t.Error("Expected error for unknown middleware")
+	}
+	if _, err := Pipeline(domain.MiddlewareConfig{Order: []string{"cors", "cors"}}); err == nil {
+		t.Error("Expected error for duplicate middleware")
+	}
+}
+
+// TestCompressionMinLength tests that the compression threshold is honoured
+func TestCompressionMinLength(t *testing.T) {
+	config := DefaultCompressionConfig()
+	config.MinLength = 64
+
+	tests := []struct {
+		name       string
+		body       string
+		expectGzip bool
+	}{
+		{"below threshold", strings.Repeat("a", 63), false},
+		{"at threshold", strings.Repeat("a", 64), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := CompressionWithConfig(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(tt.body[:10]))
+				w.Write([]byte(tt.body[10:]))
+			}))
+
+			req := httptest.NewRequest("GET", "/api/v2/shares", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			gzipped := w.Header().Get("Content-Encoding") == "gzip"
+			if gzipped != tt.expectGzip {
+				t.Fatalf("Expected gzip=%t, got %t", tt.expectGzip, gzipped)
+			}
+
+			body := w.Body.Bytes()
+			if gzipped {
+				reader, err := gzip.NewReader(bytes.NewReader(body))
+				if err != nil {
+					t.Fatalf("Failed to create gzip reader: %v", err)
+				}
+				var decompressed bytes.Buffer
+				decompressed.ReadFrom(reader)
+				body = decompressed.Bytes()
+			}
+			if string(body) != tt.body {
+				t.Errorf("Expected body to round-trip, got %d bytes", len(body))
+			}
+		})
+	}
+}
+
+// TestCORSAllowlist tests restricting CORS to configured origins
+func TestCORSAllowlist(t *testing.T) {
+	config := DefaultCORSConfig()
+	config.AllowedOrigins = []string{"http://tower.local"}
+	handler := CORSWithConfig(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/api/v2/system/info", nil)
+	req.Header.Set("Origin", "http://tower.local")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Header().Get("Access-Control-Allow-Origin") != "http://tower.local" {
+		t.Errorf("Expected allowed origin to be echoed, got %q", w.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	req = httptest.NewRequest("GET", "/api/v2/system/info", nil)
+	req.Header.Set("Origin", "http://evil.example")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("Expected no CORS grant for an origin outside the allowlist")
+	}
+
+	req = httptest.NewRequest("OPTIONS", "/api/v2/system/info", nil)
+	req.Header.Set("Origin", "http://evil.example")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected preflight from disallowed origin to be rejected, got %d", w.Code)
+	}
+}
+
+// TestCORSCredentials tests that credentials are only shared with origins listed by name
+func TestCORSCredentials(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	if DefaultCORSConfig().AllowCredentials {
+		t.Error("Expected credentials to be off by default")
+	}
+
+	tests := []struct {
+		name    string
+		allowed []string
+		want    string
+	}{
+		{"any origin", nil, ""},
+		{"wildcard", []string{"*"}, ""},
+		{"listed origin", []string{"http://tower.local"}, "true"},
+	}
+	for _, tt := range tests {
+		config := DefaultCORSConfig()
+		config.AllowedOrigins = tt.allowed
+		config.AllowCredentials = true
+		req := httptest.NewRequest("GET", "/api/v2/system/info", nil)
+		req.Header.Set("Origin", "http://tower.local")
+		w := httptest.NewRecorder()
+		CORSWithConfig(config)(ok).ServeHTTP(w, req)
+		if got := w.Header().Get("Access-Control-Allow-Credentials"); got != tt.want {
+			t.Errorf("%s: expected Access-Control-Allow-Credentials %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"fmt"
+	"net/http"
+
+	"github.com/domalab/uma/daemon/domain"
+)
+
+// Chain wraps handler with middlewares, the first being the outermost
+func Chain(handler http.Handler, middlewares ...func(http.Handler) http.Handler) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// Pipeline builds the middleware chain described by the config. Middlewares
+// run in the configured order; disabled ones are skipped.
+func Pipeline(config domain.MiddlewareConfig) (func(http.Handler) http.Handler, error) {
+	order := config.Order
+	if len(order) == 0 {
+		order = domain.DefaultMiddlewareOrder()
+	}
+
+	seen := make(map[string]bool, len(order))
+	middlewares := make([]func(http.Handler) http.Handler, 0, len(order))
+
+	for _, name := range order {
+		if seen[name] {
+			return nil, fmt.Errorf("middleware %q is listed more than once", name)
+		}
+		seen[name] = true
+
+		middleware, enabled, err := buildMiddleware(name, config)
+		if err != nil {
+			return nil, err
+		}
+		if enabled {
+			middlewares = append(middlewares, middleware)
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return Chain(next, middlewares...)
+	}, nil
+}
+
+// buildMiddleware creates a single named middleware from its config section
+func buildMiddleware(name string, config domain.MiddlewareConfig) (func(http.Handler) http.Handler, bool, error) {
+	switch name {
+	case "request_id":
+		requestIDConfig := DefaultRequestIDConfig()
+		requestIDConfig.AllowClientRequestID = config.RequestID.AllowClientID
+		return RequestIDWithConfig(requestIDConfig), config.RequestID.Enabled, nil
+
+	case "logging":
+		loggingConfig := DefaultLoggingConfig()
+		loggingConfig.LogErrors = config.Logging.LogErrors
+		if config.Logging.SkipPaths != nil {
+			loggingConfig.SkipPaths = config.Logging.SkipPaths
+		}
+		return LoggingWithConfig(loggingConfig), config.Logging.Enabled, nil
+
+	case "metrics":
+		metricsConfig := DefaultMetricsConfig()
+		if config.Metrics.SkipPaths != nil {
+			metricsConfig.SkipPaths = config.Metrics.SkipPaths
+		}
+		return MetricsWithConfig(metricsConfig), config.Metrics.Enabled, nil
+
+	case "compression":
+		compressionConfig := DefaultCompressionConfig()
+		compressionConfig.MinLength = config.Compression.MinLength
+		if config.Compression.Level != 0 {
+			compressionConfig.Level = config.Compression.Level
+		} else {
+			compressionConfig.Level = gzip.DefaultCompression
+		}
+		if config.Compression.ExcludedPaths != nil {
+			compressionConfig.ExcludedPaths = config.Compression.ExcludedPaths
+		}
+		return CompressionWithConfig(compressionConfig), config.Compression.Enabled, nil
+
+	case "cors":
+		corsConfig := DefaultCORSConfig()
+		corsConfig.AllowedOrigins = config.CORS.AllowedOrigins
+		corsConfig.AllowCredentials = config.CORS.AllowCredentials
+		return CORSWithConfig(corsConfig), config.CORS.Enabled, nil
+
+	case "sentry":
+		return Sentry(), config.Sentry.Enabled, nil
+
+	case "versioning":
+		versioningConfig := DefaultVersioningConfig()
+		versioningConfig.DefaultVersion = "v2"
+		versioningConfig.SupportedVersions = []string{"v2"}
+		return VersioningWithConfig(versioningConfig), config.Versioning.Enabled, nil
+	}
+
+	return nil, false, fmt.Errorf("unknown middleware %q", name)
+}
@@ -1,7 +1,9 @@
 package middleware
 
 import (
+	"bufio"
 	"fmt"
+	"net"
 	"net/http"
 
 	"github.com/getsentry/sentry-go"
@@ -24,7 +26,11 @@ func Sentry() func(http.Handler) http.Handler {
 			hub.Scope().SetTag("http.remote_addr", r.RemoteAddr)
 
 			// Add request ID if available
-			if requestID := r.Header.Get("X-Request-ID"); requestID != "" {
+			requestID := GetRequestIDFromContext(r)
+			if requestID == "" {
+				requestID = r.Header.Get("X-Request-ID")
+			}
+			if requestID != "" {
 				hub.Scope().SetTag("request_id", requestID)
 			}
 
@@ -65,3 +71,23 @@ func (w *sentryResponseWriter) WriteHeader(code int) {
 	w.statusCode = code
 	w.ResponseWriter.WriteHeader(code)
 }
+
+// Flush implements http.Flusher interface
+func (w *sentryResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *sentryResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Hijack implements http.Hijacker interface
+func (w *sentryResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, fmt.Errorf("response writer does not support hijacking")
+}
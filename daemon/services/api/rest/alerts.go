@@ -0,0 +1,147 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/domalab/uma/daemon/logger"
+	"github.com/domalab/uma/daemon/services/alerts"
+	"github.com/domalab/uma/daemon/services/api/types/requests"
+)
+
+// handleAlerts lists the pending, firing and recently resolved alerts with
+// the rules and silences behind them
+func (rs *RESTServer) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		rs.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if rs.services.Alerts == nil {
+		rs.writeError(w, http.StatusServiceUnavailable, "Alert rules not available")
+		return
+	}
+
+	list := rs.services.Alerts.Alerts()
+	if state := r.URL.Query().Get("state"); state != "" {
+		filtered := make([]alerts.Alert, 0, len(list))
+		for _, alert := range list {
+			if string(alert.State) == state {
+				filtered = append(filtered, alert)
+			}
+		}
+		list = filtered
+	}
+
+	rs.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"alerts":   list,
+		"rules":    rs.services.Alerts.Rules(),
+		"silences": rs.services.Alerts.Silences(),
+	})
+}
+
+// handleAlertSilences lists silences or adds one
+func (rs *RESTServer) handleAlertSilences(w http.ResponseWriter, r *http.Request) {
+	if rs.services.Alerts == nil {
+		rs.writeError(w, http.StatusServiceUnavailable, "Alert rules not available")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		rs.writeJSON(w, http.StatusOK, rs.services.Alerts.Silences())
+
+	case http.MethodPost:
+		var req requests.AlertSilenceRequest
+		if !rs.decodeOptionalBody(w, r, &req) {
+			return
+		}
+		silence, err := silenceFromRequest(req)
+		if err != nil {
+			rs.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		silence.CreatedBy = requestCreator(r)
+
+		silence, err = rs.services.Alerts.AddSilence(silence)
+		if err != nil {
+			if errors.Is(err, alerts.ErrInvalidSilence) {
+				rs.writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			logger.Yellow("Failed to add alert silence: %v", err)
+			rs.writeError(w, http.StatusInternalServerError, "Failed to add silence")
+			return
+		}
+		rs.writeJSON(w, http.StatusCreated, silence)
+
+	default:
+		rs.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleAlertSilence ends a silence early
+func (rs *RESTServer) handleAlertSilence(w http.ResponseWriter, r *http.Request) {
+	if rs.services.Alerts == nil {
+		rs.writeError(w, http.StatusServiceUnavailable, "Alert rules not available")
+		return
+	}
+	if r.Method != http.MethodDelete {
+		rs.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	silenceID := strings.TrimPrefix(r.URL.Path, "/api/v2/alerts/silences/")
+	if silenceID == "" || strings.Contains(silenceID, "/") {
+		rs.writeError(w, http.StatusBadRequest, "Invalid silence URL")
+		return
+	}
+	if err := rs.services.Alerts.DeleteSilence(silenceID); err != nil {
+		rs.writeError(w, http.StatusNotFound, fmt.Sprintf("Silence not found: %s", silenceID))
+		return
+	}
+
+	rs.writeJSON(w, http.StatusOK, OperationResult{
+		Success:   true,
+		Message:   fmt.Sprintf("Silence %s deleted", silenceID),
+		Timestamp: time.Now().Unix(),
+		RequestID: requestID(r),
+	})
+}
+
+// silenceFromRequest parses the window of a silence request
+func silenceFromRequest(req requests.AlertSilenceRequest) (alerts.Silence, error) {
+	silence := alerts.Silence{Rule: req.Rule, Field: req.Field, Comment: req.Comment}
+
+	if req.StartsAt != "" {
+		startsAt, err := time.Parse(time.RFC3339, req.StartsAt)
+		if err != nil {
+			return silence, fmt.Errorf("invalid starts_at: %v", err)
+		}
+		silence.StartsAt = startsAt
+	}
+
+	switch {
+	case req.EndsAt != "":
+		endsAt, err := time.Parse(time.RFC3339, req.EndsAt)
+		if err != nil {
+			return silence, fmt.Errorf("invalid ends_at: %v", err)
+		}
+		silence.EndsAt = endsAt
+	case req.Duration != "":
+		duration, err := time.ParseDuration(req.Duration)
+		if err != nil || duration <= 0 {
+			return silence, fmt.Errorf("invalid duration: %s", req.Duration)
+		}
+		start := silence.StartsAt
+		if start.IsZero() {
+			start = time.Now()
+		}
+		silence.EndsAt = start.Add(duration)
+	default:
+		return silence, fmt.Errorf("duration or ends_at is required")
+	}
+	return silence, nil
+}
@@ -0,0 +1,321 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/domalab/uma/daemon/logger"
+	"github.com/domalab/uma/daemon/plugins/docker"
+	"github.com/domalab/uma/daemon/services/auth"
+	"github.com/gorilla/websocket"
+)
+
+// defaultExecCommand is run when the client does not name a command
+var defaultExecCommand = []string{"/bin/sh"}
+
+// execIdleTimeout closes exec sessions without input or output for this long
+var execIdleTimeout = 15 * time.Minute
+
+// execControlMessage is a text frame sent by an exec client
+type execControlMessage struct {
+	Type string `json:"type"`           // stdin or resize
+	Data string `json:"data,omitempty"` // Input, for stdin
+	Cols uint   `json:"cols,omitempty"` // Terminal size, for resize
+	Rows uint   `json:"rows,omitempty"`
+}
+
+// handleContainerExec runs a process in a container attached to a WebSocket,
+// by default a shell on a terminal. Binary frames from the client are stdin
+// and binary frames to it are output, the first byte giving the stream (1
+// stdout, 2 stderr). Text frames carry JSON: the client sends
+// {"type":"resize","cols":120,"rows":40} or {"type":"stdin","data":"ls\n"}
+// and receives started, error and finally exit messages. Sessions need an
+// admin API key, even while authentication is disabled, and are recorded in
+// the audit log.
+func (rs *RESTServer) handleContainerExec(w http.ResponseWriter, r *http.Request, containerID string) {
+	clientIP, _, _ := net.SplitHostPort(r.RemoteAddr)
+	key, ok := auth.FromContext(r.Context())
+	if !ok || !auth.Scope(key.Scope).Allows(auth.ScopeAdmin) {
+		actor := "anonymous"
+		if ok {
+			actor = key.Name
+		}
+		logger.LogAuditEvent("container.exec.denied", actor, clientIP, requestID(r), map[string]interface{}{
+			"container": containerID,
+			"error":     "admin api key required",
+		})
+		rs.writeError(w, http.StatusForbidden, "Exec sessions require authentication with an admin API key")
+		return
+	}
+	if !rs.execOriginAllowed(r) {
+		logger.LogAuditEvent("container.exec.denied", key.Name, clientIP, requestID(r), map[string]interface{}{
+			"container": containerID,
+			"error":     "origin not allowed",
+			"origin":    r.Header.Get("Origin"),
+		})
+		rs.writeError(w, http.StatusForbidden, "Origin not allowed")
+		return
+	}
+
+	if rs.services.Docker == nil {
+		rs.writeError(w, http.StatusServiceUnavailable, "Docker manager not available")
+		return
+	}
+	if !websocket.IsWebSocketUpgrade(r) {
+		rs.writeError(w, http.StatusBadRequest, "Exec sessions require a WebSocket connection")
+		return
+	}
+
+	query := r.URL.Query()
+	options := docker.ExecOptions{
+		Cmd:        query["cmd"],
+		Env:        query["env"],
+		User:       query.Get("user"),
+		WorkingDir: query.Get("workdir"),
+		Tty:        query.Get("tty") == "" || parseBool(query.Get("tty")),
+	}
+	if len(options.Cmd) == 0 {
+		options.Cmd = defaultExecCommand
+	}
+	var cols, rows uint64
+	if value := query.Get("cols"); value != "" {
+		var err error
+		if cols, err = strconv.ParseUint(value, 10, 16); err != nil {
+			rs.writeError(w, http.StatusBadRequest, "Invalid cols, must be a number of characters")
+			return
+		}
+	}
+	if value := query.Get("rows"); value != "" {
+		var err error
+		if rows, err = strconv.ParseUint(value, 10, 16); err != nil {
+			rs.writeError(w, http.StatusBadRequest, "Invalid rows, must be a number of lines")
+			return
+		}
+	}
+
+	container, err := rs.services.Docker.GetContainer(containerID)
+	if err != nil {
+		rs.writeContainerError(w, containerID, err)
+		return
+	}
+	if container.State != "running" {
+		rs.writeError(w, http.StatusConflict, fmt.Sprintf("Container %s is not running", containerID))
+		return
+	}
+
+	audit := func(action string, details map[string]interface{}) {
+		details["container"] = containerID
+		details["command"] = options.Cmd
+		logger.LogAuditEvent(action, key.Name, clientIP, requestID(r), details)
+	}
+
+	// The request context ends when the handler returns, tearing the session down with it
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// Start the process before upgrading so failures get a proper status code
+	session, err := rs.services.Docker.Exec(ctx, containerID, options)
+	if err != nil {
+		audit("container.exec.denied", map[string]interface{}{"error": err.Error()})
+		// The container may have stopped since it was resolved
+		if errors.Is(err, docker.ErrContainerNotRunning) {
+			rs.writeError(w, http.StatusConflict, fmt.Sprintf("Container %s is not running", containerID))
+			return
+		}
+		logger.Yellow("Failed to start exec session in container %s: %v", containerID, err)
+		rs.writeError(w, http.StatusInternalServerError, "Failed to start exec session")
+		return
+	}
+	defer session.Close()
+
+	conn, err := rs.execUpgrader().Upgrade(w, r, nil)
+	if err != nil {
+		logger.Yellow("Failed to upgrade exec session for container %s: %v", containerID, err)
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(1024 * 1024)
+
+	started := time.Now()
+	audit("container.exec.start", map[string]interface{}{
+		"exec_id": session.ID,
+		"tty":     options.Tty,
+		"user":    options.User,
+	})
+
+	// gorilla/websocket allows one writer at a time
+	var writeMutex sync.Mutex
+	write := func(messageType int, data []byte) error {
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		return conn.WriteMessage(messageType, data)
+	}
+	writeJSON := func(message map[string]interface{}) error {
+		data, err := json.Marshal(message)
+		if err != nil {
+			return err
+		}
+		return write(websocket.TextMessage, data)
+	}
+
+	// The first reason the session ends is the one reported
+	var reason string
+	var endOnce sync.Once
+	end := func(why string) {
+		endOnce.Do(func() {
+			reason = why
+			session.Close()
+		})
+	}
+
+	var lastActive atomic.Int64
+	touch := func() { lastActive.Store(time.Now().UnixNano()) }
+	touch()
+
+	writeJSON(map[string]interface{}{"type": "started", "exec_id": session.ID, "tty": options.Tty})
+	if cols > 0 && rows > 0 {
+		if err := session.Resize(ctx, uint(cols), uint(rows)); err != nil {
+			logger.Yellow("Failed to size exec session %s: %v", session.ID, err)
+		}
+	}
+
+	// Client input
+	go func() {
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				end("client disconnected")
+				return
+			}
+			touch()
+
+			if messageType == websocket.BinaryMessage {
+				if _, err := session.Write(data); err != nil {
+					end("process input closed")
+					return
+				}
+				continue
+			}
+
+			var message execControlMessage
+			if err := json.Unmarshal(data, &message); err != nil {
+				writeJSON(map[string]interface{}{"type": "error", "message": "invalid message"})
+				continue
+			}
+			switch message.Type {
+			case "stdin":
+				if _, err := session.Write([]byte(message.Data)); err != nil {
+					end("process input closed")
+					return
+				}
+			case "resize":
+				if message.Cols == 0 || message.Rows == 0 {
+					writeJSON(map[string]interface{}{"type": "error", "message": "resize needs cols and rows"})
+				} else if err := session.Resize(ctx, message.Cols, message.Rows); err != nil {
+					writeJSON(map[string]interface{}{"type": "error", "message": "failed to resize: " + err.Error()})
+				}
+			default:
+				writeJSON(map[string]interface{}{"type": "error", "message": fmt.Sprintf("unknown message type %q", message.Type)})
+			}
+		}
+	}()
+
+	// Idle sessions are closed
+	idleTimeout := execIdleTimeout
+	go func() {
+		ticker := time.NewTicker(idleTimeout / 10)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if time.Since(time.Unix(0, lastActive.Load())) >= idleTimeout {
+					end("idle timeout")
+					return
+				}
+			}
+		}
+	}()
+
+	// Process output, until it exits or the session is ended
+	session.Output(func(stream int, data []byte) error {
+		touch()
+		frame := make([]byte, 0, len(data)+1)
+		frame = append(append(frame, byte(stream)), data...)
+		if err := write(websocket.BinaryMessage, frame); err != nil {
+			end("client disconnected")
+			return err
+		}
+		return nil
+	})
+	end("exited")
+
+	exit := map[string]interface{}{"type": "exit", "reason": reason}
+	details := map[string]interface{}{
+		"exec_id":  session.ID,
+		"reason":   reason,
+		"duration": time.Since(started).Round(time.Millisecond).String(),
+	}
+	stateCtx, stateCancel := context.WithTimeout(ctx, 10*time.Second)
+	if reason != "exited" {
+		// Detaching ends a shell on a terminal but leaves other processes running
+		if err := session.Terminate(stateCtx, 3*time.Second); err != nil {
+			logger.Yellow("Failed to stop exec process %s in container %s: %v", session.ID, containerID, err)
+		}
+	}
+	if state, err := session.State(stateCtx); err == nil && !state.Running {
+		exit["exit_code"] = state.ExitCode
+		details["exit_code"] = state.ExitCode
+	}
+	stateCancel()
+	audit("container.exec.end", details)
+
+	if reason != "client disconnected" {
+		writeJSON(exit)
+		writeMutex.Lock()
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, reason), time.Now().Add(time.Second))
+		writeMutex.Unlock()
+	}
+}
+
+// execUpgrader upgrades exec requests. handleContainerExec checks the origin
+// before the process is started as well, to answer with a proper status.
+func (rs *RESTServer) execUpgrader() *websocket.Upgrader {
+	return &websocket.Upgrader{
+		CheckOrigin:     rs.execOriginAllowed,
+		ReadBufferSize:  4096,
+		WriteBufferSize: 32 * 1024,
+	}
+}
+
+// execOriginAllowed reports whether a browser on the request's origin may open
+// an exec session: the server's own host or an origin listed in the CORS
+// config. A wildcard there does not apply. Requests without an origin are not
+// from a browser and are allowed.
+func (rs *RESTServer) execOriginAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if parsed, err := url.Parse(origin); err == nil && strings.EqualFold(parsed.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range rs.execOrigins {
+		if allowed != "*" && strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/domalab/uma/daemon/httputil"
+	"github.com/domalab/uma/daemon/logger"
+	"github.com/domalab/uma/daemon/plugins/docker"
+)
+
+const (
+	// defaultLogTail is how many lines are returned when tail is omitted
+	defaultLogTail = 100
+
+	// logsHeartbeatInterval is how often a followed SSE log stream sends a comment while idle
+	logsHeartbeatInterval = 15 * time.Second
+)
+
+// handleContainerLogs returns a container's recent log lines, or with
+// follow=true streams them as they are written until the client disconnects:
+// as server-sent events when the client accepts them, and otherwise as
+// newline-delimited JSON
+func (rs *RESTServer) handleContainerLogs(w http.ResponseWriter, r *http.Request, containerID string) {
+	if rs.services.Docker == nil {
+		rs.writeError(w, http.StatusServiceUnavailable, "Docker manager not available")
+		return
+	}
+
+	options, err := parseLogOptions(r.URL.Query(), time.Now())
+	if err != nil {
+		rs.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Resolve the container first so unknown IDs get a status code before streaming starts
+	if _, err := rs.services.Docker.GetContainer(containerID); err != nil {
+		rs.writeContainerError(w, containerID, err)
+		return
+	}
+
+	if options.Follow {
+		rs.followContainerLogs(w, r, containerID, options)
+		return
+	}
+
+	lines := make([]docker.LogLine, 0)
+	err = rs.services.Docker.StreamContainerLogs(r.Context(), containerID, options, func(line docker.LogLine) error {
+		lines = append(lines, line)
+		return nil
+	})
+	if err != nil {
+		logger.Yellow("Failed to get logs for container %s: %v", containerID, err)
+		rs.writeError(w, http.StatusInternalServerError, "Failed to retrieve container logs")
+		return
+	}
+	rs.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"container_id": containerID,
+		"lines":        lines,
+		"count":        len(lines),
+	})
+}
+
+// followContainerLogs streams log lines until the container's log ends or
+// the client goes away, which cancels the request to the Docker daemon
+func (rs *RESTServer) followContainerLogs(w http.ResponseWriter, r *http.Request, containerID string, options docker.LogOptions) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		rs.writeError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+	sse := strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+
+	httputil.DisableWriteDeadline(w, "log stream of container "+containerID)
+
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	lines := make(chan docker.LogLine, 64)
+	done := make(chan error, 1)
+	go func() {
+		done <- rs.services.Docker.StreamContainerLogs(ctx, containerID, options, func(line docker.LogLine) error {
+			select {
+			case lines <- line:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	heartbeat := time.NewTicker(logsHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case line := <-lines:
+			data, err := json.Marshal(line)
+			if err != nil {
+				continue
+			}
+			if sse {
+				_, err = fmt.Fprintf(w, "event: log\ndata: %s\n\n", data)
+			} else {
+				_, err = fmt.Fprintf(w, "%s\n", data)
+			}
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if !sse {
+				continue
+			}
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case err := <-done:
+			// Lines queued before the log ended are still delivered
+			for len(lines) > 0 {
+				data, _ := json.Marshal(<-lines)
+				if sse {
+					fmt.Fprintf(w, "event: log\ndata: %s\n\n", data)
+				} else {
+					fmt.Fprintf(w, "%s\n", data)
+				}
+			}
+			if err != nil && ctx.Err() == nil {
+				logger.Yellow("Log stream for container %s ended: %v", containerID, err)
+				if sse {
+					data, _ := json.Marshal(map[string]string{"error": "Log stream ended unexpectedly"})
+					fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+				}
+			}
+			flusher.Flush()
+			return
+		}
+	}
+}
+
+// parseLogOptions reads tail, since, until, timestamps, follow, grep,
+// ignore_case and invert from a logs request
+func parseLogOptions(query url.Values, now time.Time) (docker.LogOptions, error) {
+	options := docker.LogOptions{
+		Tail:       defaultLogTail,
+		Timestamps: parseBool(query.Get("timestamps")),
+		Follow:     parseBool(query.Get("follow")),
+		Invert:     parseBool(query.Get("invert")),
+	}
+
+	switch tail := query.Get("tail"); tail {
+	case "":
+	case "all":
+		options.Tail = -1
+	default:
+		lines, err := strconv.Atoi(tail)
+		if err != nil || lines < 0 {
+			return options, errors.New("Invalid tail, must be a number of lines or 'all'")
+		}
+		options.Tail = lines
+	}
+
+	var err error
+	if options.Since, err = parseHistoryTime(query.Get("since"), time.Time{}, now); err != nil {
+		return options, fmt.Errorf("Invalid since: %v", err)
+	}
+	if options.Until, err = parseHistoryTime(query.Get("until"), time.Time{}, now); err != nil {
+		return options, fmt.Errorf("Invalid until: %v", err)
+	}
+	if !options.Since.IsZero() && !options.Until.IsZero() && !options.Since.Before(options.Until) {
+		return options, errors.New("since must be before until")
+	}
+
+	if pattern := query.Get("grep"); pattern != "" {
+		if parseBool(query.Get("ignore_case")) {
+			pattern = "(?i)" + pattern
+		}
+		if options.Grep, err = regexp.Compile(pattern); err != nil {
+			return options, fmt.Errorf("Invalid grep pattern: %v", err)
+		}
+	} else if options.Invert {
+		return options, errors.New("invert requires grep")
+	}
+	return options, nil
+}
+
+// parseBool accepts true or 1 as set, like the other query flags
+func parseBool(value string) bool {
+	return value == "true" || value == "1"
+}
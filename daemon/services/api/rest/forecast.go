@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/domalab/uma/daemon/services/forecast"
+)
+
+// handleStorageForecast returns the fill rate and projected days until full
+// of the array, each disk and pool, and Docker storage, soonest first
+func (rs *RESTServer) handleStorageForecast(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		rs.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if rs.services.Forecast == nil {
+		rs.writeError(w, http.StatusServiceUnavailable, "Storage forecast not available; it needs the metric history")
+		return
+	}
+
+	report := rs.services.Forecast.Forecast()
+
+	kind, name := r.URL.Query().Get("kind"), r.URL.Query().Get("name")
+	if kind != "" || name != "" {
+		targets := make([]forecast.Target, 0, len(report.Targets))
+		for _, target := range report.Targets {
+			if (kind == "" || target.Kind == kind) && (name == "" || target.Name == name) {
+				targets = append(targets, target)
+			}
+		}
+		report.Targets = targets
+	}
+
+	rs.writeJSON(w, http.StatusOK, report)
+}
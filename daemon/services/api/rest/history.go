@@ -0,0 +1,114 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/domalab/uma/daemon/logger"
+	"github.com/domalab/uma/daemon/services/history"
+)
+
+// defaultHistoryRange is queried when from is omitted
+const defaultHistoryRange = time.Hour
+
+// handleHistory returns the min, max and average of a metric field per step,
+// or lists the recorded series when no field is given (target: <20ms)
+func (rs *RESTServer) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		rs.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if rs.services.History == nil {
+		rs.writeError(w, http.StatusServiceUnavailable, "Metric history not available")
+		return
+	}
+
+	query := r.URL.Query()
+	metric, field := query.Get("metric"), query.Get("field")
+	if field == "" {
+		rs.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"series": rs.services.History.List(metric),
+			"stats":  rs.services.History.Stats(),
+		})
+		return
+	}
+	if metric == "" {
+		rs.writeError(w, http.StatusBadRequest, "metric is required with field")
+		return
+	}
+
+	now := time.Now()
+	to, err := parseHistoryTime(query.Get("to"), now, now)
+	if err != nil {
+		rs.writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid to: %v", err))
+		return
+	}
+	from, err := parseHistoryTime(query.Get("from"), to.Add(-defaultHistoryRange), now)
+	if err != nil {
+		rs.writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid from: %v", err))
+		return
+	}
+	if !from.Before(to) {
+		rs.writeError(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+
+	var step time.Duration
+	if value := query.Get("step"); value != "" {
+		if step, err = parseHistoryDuration(value); err != nil || step < 0 {
+			rs.writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid step: %s", value))
+			return
+		}
+	}
+
+	series, err := rs.services.History.Query(metric, field, from, to, step)
+	if err != nil {
+		if errors.Is(err, history.ErrSeriesNotFound) {
+			rs.writeError(w, http.StatusNotFound, fmt.Sprintf("No history for %s %s", metric, field))
+			return
+		}
+		logger.Yellow("Failed to query history for %s %s: %v", metric, field, err)
+		rs.writeError(w, http.StatusInternalServerError, "Failed to query metric history")
+		return
+	}
+	rs.writeJSON(w, http.StatusOK, series)
+}
+
+// parseHistoryTime accepts a duration relative to now such as -24h, Unix
+// seconds or RFC 3339, returning fallback for an empty value. Times before
+// the Unix epoch are refused.
+func parseHistoryTime(value string, fallback, now time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+
+	var parsed time.Time
+	if strings.HasPrefix(value, "-") {
+		offset, err := time.ParseDuration(value)
+		if err != nil {
+			return time.Time{}, err
+		}
+		parsed = now.Add(offset)
+	} else if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		parsed = time.Unix(seconds, 0)
+	} else if parsed, err = time.Parse(time.RFC3339, value); err != nil {
+		return time.Time{}, err
+	}
+
+	if parsed.Before(time.Unix(0, 0)) {
+		return time.Time{}, fmt.Errorf("%s is before the Unix epoch", value)
+	}
+	return parsed, nil
+}
+
+// parseHistoryDuration accepts whole seconds or a duration such as 5m
+func parseHistoryDuration(value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(value)
+}
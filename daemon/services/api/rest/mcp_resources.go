@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/domalab/uma/daemon/logger"
+	"github.com/domalab/uma/daemon/plugins/storage"
+	"github.com/domalab/uma/daemon/services/mcp"
+)
+
+// resourceLogLines is how many log lines the MCP log resources return
+const resourceLogLines = 200
+
+// registerMCPResources registers the MCP resources and prompts backed by the REST server
+func (rs *RESTServer) registerMCPResources() {
+	err := rs.mcpServer.Resources().Register(
+		mcp.ResourceDefinition{
+			URI:         "uma://system/info",
+			Name:        "System information",
+			Description: "Hostname, version, CPU, memory and uptime of the server",
+			MimeType:    "application/json",
+			Topic:       "system.cpu",
+			Read: func(ctx context.Context, params map[string]string) (interface{}, error) {
+				return rs.getRealSystemInfo()
+			},
+		},
+		mcp.ResourceDefinition{
+			URI:         "uma://storage/disks/{name}/smart",
+			Name:        "Disk SMART data",
+			Description: "SMART attributes of an array disk, by disk name (disk1, parity, cache) or device. Spun down disks are not woken.",
+			MimeType:    "application/json",
+			Topic:       "storage.disks",
+			Read: func(ctx context.Context, params map[string]string) (interface{}, error) {
+				return rs.readDiskSMARTResource(params["name"])
+			},
+		},
+		mcp.ResourceDefinition{
+			URI:         "uma://containers/{id}/logs",
+			Name:        "Container logs",
+			Description: fmt.Sprintf("The last %d log lines of a Docker container", resourceLogLines),
+			MimeType:    "text/plain",
+			Read: func(ctx context.Context, params map[string]string) (interface{}, error) {
+				if rs.services.Docker == nil {
+					return nil, errors.New("docker manager not available")
+				}
+				return rs.services.Docker.GetContainerLogs(params["id"], resourceLogLines, false)
+			},
+		},
+		mcp.ResourceDefinition{
+			URI:         "uma://logs/syslog",
+			Name:        "System log",
+			Description: fmt.Sprintf("The last %d lines of /var/log/syslog", resourceLogLines),
+			MimeType:    "text/plain",
+			Read: func(ctx context.Context, params map[string]string) (interface{}, error) {
+				return rs.getSystemLogs(resourceLogLines)
+			},
+		},
+	)
+	if err != nil {
+		logger.Red("Failed to register MCP resources: %v", err)
+	}
+
+	err = rs.mcpServer.Prompts().Register(mcp.PromptDefinition{
+		Prompt: mcp.Prompt{
+			Name:        "diagnose_array_health",
+			Description: "Review health checks, disk states and parity status and explain any array problems",
+		},
+		Build: rs.buildArrayHealthPrompt,
+	})
+	if err != nil {
+		logger.Red("Failed to register MCP prompts: %v", err)
+	}
+}
+
+// readDiskSMARTResource returns SMART data for a disk, skipping smartctl for spun down disks
+func (rs *RESTServer) readDiskSMARTResource(name string) (interface{}, error) {
+	if rs.services.Storage == nil {
+		return nil, errors.New("storage services not available")
+	}
+
+	disks, err := rs.services.Storage.GetDiskStates()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, disk := range disks {
+		if disk.Name != name && strings.TrimPrefix(disk.Device, "/dev/") != name {
+			continue
+		}
+
+		// smartctl would spin the disk up; report its state instead
+		if disk.SpunDown {
+			return map[string]interface{}{
+				"disk":    disk,
+				"message": "Disk is spun down; SMART data is not read to avoid waking it",
+			}, nil
+		}
+
+		smart, err := rs.getSMARTDataForDevice(disk.Device)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"disk":  disk,
+			"smart": smart,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("disk %s not found", name)
+}
+
+// buildArrayHealthPrompt bundles health checks, disk states and parity status into a diagnosis request
+func (rs *RESTServer) buildArrayHealthPrompt(ctx context.Context, args map[string]string) (mcp.PromptGetResult, error) {
+	if rs.services.Diagnostics == nil {
+		return mcp.PromptGetResult{}, errors.New("diagnostics not available")
+	}
+
+	health, err := rs.services.Diagnostics.RunHealthChecks()
+	if err != nil {
+		return mcp.PromptGetResult{}, fmt.Errorf("failed to run health checks: %w", err)
+	}
+
+	var disks []storage.DiskState
+	if rs.services.Storage != nil {
+		if disks, err = rs.services.Storage.GetDiskStates(); err != nil {
+			logger.Yellow("Failed to read disk states for health prompt: %v", err)
+		}
+	}
+
+	parity, err := rs.getRealParityStatus()
+	if err != nil {
+		logger.Yellow("Failed to read parity status for health prompt: %v", err)
+	}
+
+	var text strings.Builder
+	text.WriteString("Diagnose the health of this Unraid array. Identify failing or degraded components, ")
+	text.WriteString("explain the likely cause of each problem and suggest remediation steps, most urgent first. ")
+	text.WriteString("Say so plainly if everything looks healthy.\n")
+	writePromptSection(&text, "Health checks", health)
+	writePromptSection(&text, "Disk states", disks)
+	writePromptSection(&text, "Parity status", parity)
+
+	return mcp.PromptGetResult{
+		Description: fmt.Sprintf("Array health diagnosis (overall status: %s)", health.OverallStatus),
+		Messages: []mcp.PromptMessage{{
+			Role:    "user",
+			Content: mcp.ToolContent{Type: "text", Text: text.String()},
+		}},
+	}, nil
+}
+
+// writePromptSection appends a titled JSON block to a prompt
+func writePromptSection(text *strings.Builder, title string, data interface{}) {
+	encoded, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		encoded = []byte(fmt.Sprintf("unavailable: %v", err))
+	}
+	fmt.Fprintf(text, "\n## %s\n```json\n%s\n```\n", title, encoded)
+}
@@ -0,0 +1,281 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/domalab/uma/daemon/logger"
+	"github.com/domalab/uma/daemon/plugins/notifications"
+	"github.com/domalab/uma/daemon/services/api/types/requests"
+	"github.com/domalab/uma/daemon/services/api/types/responses"
+)
+
+const (
+	defaultNotificationPageSize = 50
+	maxNotificationPageSize     = 500
+)
+
+// handleNotifications lists notifications a page at a time, creates one or
+// clears them all
+func (rs *RESTServer) handleNotifications(w http.ResponseWriter, r *http.Request) {
+	if rs.services.Notifications == nil {
+		rs.writeError(w, http.StatusServiceUnavailable, "Notifications not available")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		filter, page, err := notificationFilter(r)
+		if err != nil {
+			rs.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		list, total, err := rs.services.Notifications.ListNotifications(filter)
+		if err != nil {
+			logger.Yellow("Failed to list notifications: %v", err)
+			rs.writeError(w, http.StatusInternalServerError, "Failed to list notifications")
+			return
+		}
+
+		totalPages := (total + filter.Limit - 1) / filter.Limit
+		rs.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"notifications": list,
+			"pagination": responses.PaginationInfo{
+				Page:       page,
+				PageSize:   filter.Limit,
+				TotalPages: totalPages,
+				TotalItems: total,
+				HasNext:    page < totalPages,
+				HasPrev:    page > 1,
+			},
+		})
+
+	case http.MethodPost:
+		var req requests.NotificationCreateRequest
+		if !rs.decodeOptionalBody(w, r, &req) {
+			return
+		}
+		level, category := notifications.NotificationLevel(req.Level), notifications.NotificationCategory(req.Category)
+		if level == "" {
+			level = notifications.LevelInfo
+		}
+		if category == "" {
+			category = notifications.CategoryCustom
+		}
+		switch {
+		case req.Title == "":
+			rs.writeError(w, http.StatusBadRequest, "Title is required")
+			return
+		case !level.Valid():
+			rs.writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid level: %s", req.Level))
+			return
+		case !category.Valid():
+			rs.writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid category: %s", req.Category))
+			return
+		}
+
+		notification, err := rs.services.Notifications.CreateNotification(req.Title, req.Message, level, category)
+		if err != nil {
+			logger.Yellow("Failed to create notification: %v", err)
+			rs.writeError(w, http.StatusInternalServerError, "Failed to create notification")
+			return
+		}
+		rs.writeJSON(w, http.StatusCreated, notification)
+
+	case http.MethodDelete:
+		if err := rs.services.Notifications.ClearAllNotifications(); err != nil {
+			logger.Yellow("Failed to clear notifications: %v", err)
+			rs.writeError(w, http.StatusInternalServerError, "Failed to clear notifications")
+			return
+		}
+		rs.writeJSON(w, http.StatusOK, OperationResult{
+			Success:   true,
+			Message:   "Notifications cleared",
+			Timestamp: time.Now().Unix(),
+			RequestID: requestID(r),
+		})
+
+	default:
+		rs.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleNotificationStats counts notifications by level and category
+func (rs *RESTServer) handleNotificationStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		rs.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if rs.services.Notifications == nil {
+		rs.writeError(w, http.StatusServiceUnavailable, "Notifications not available")
+		return
+	}
+
+	stats, err := rs.services.Notifications.GetNotificationStats()
+	if err != nil {
+		logger.Yellow("Failed to get notification stats: %v", err)
+		rs.writeError(w, http.StatusInternalServerError, "Failed to get notification stats")
+		return
+	}
+	rs.writeJSON(w, http.StatusOK, stats)
+}
+
+// handleNotificationsRead marks every notification read, archiving those
+// imported from Unraid
+func (rs *RESTServer) handleNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		rs.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if rs.services.Notifications == nil {
+		rs.writeError(w, http.StatusServiceUnavailable, "Notifications not available")
+		return
+	}
+
+	if err := rs.services.Notifications.MarkAllAsRead(); err != nil {
+		logger.Yellow("Failed to mark notifications read: %v", err)
+		rs.writeError(w, http.StatusInternalServerError, "Failed to mark notifications read")
+		return
+	}
+	rs.writeJSON(w, http.StatusOK, OperationResult{
+		Success:   true,
+		Message:   "Notifications marked read",
+		Timestamp: time.Now().Unix(),
+		RequestID: requestID(r),
+	})
+}
+
+// handleNotification returns, updates or deletes a single notification
+func (rs *RESTServer) handleNotification(w http.ResponseWriter, r *http.Request) {
+	if rs.services.Notifications == nil {
+		rs.writeError(w, http.StatusServiceUnavailable, "Notifications not available")
+		return
+	}
+
+	notificationID := strings.TrimPrefix(r.URL.Path, "/api/v2/notifications/")
+	if _, err := strconv.Atoi(notificationID); err != nil {
+		rs.writeError(w, http.StatusBadRequest, "Invalid notification URL")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		notification, err := rs.services.Notifications.GetNotification(notificationID)
+		if err != nil {
+			rs.writeNotificationError(w, notificationID, "get", err)
+			return
+		}
+		rs.writeJSON(w, http.StatusOK, notification)
+
+	case http.MethodPatch:
+		var req requests.NotificationUpdateRequest
+		if !rs.decodeOptionalBody(w, r, &req) {
+			return
+		}
+		updates := make(map[string]interface{})
+		if req.Title != nil {
+			updates["title"] = *req.Title
+		}
+		if req.Message != nil {
+			updates["message"] = *req.Message
+		}
+		if req.Read != nil {
+			updates["read"] = *req.Read
+		}
+		if req.Persistent != nil {
+			updates["persistent"] = *req.Persistent
+		}
+
+		notification, err := rs.services.Notifications.UpdateNotification(notificationID, updates)
+		if err != nil {
+			rs.writeNotificationError(w, notificationID, "update", err)
+			return
+		}
+		rs.writeJSON(w, http.StatusOK, notification)
+
+	case http.MethodDelete:
+		if err := rs.services.Notifications.DeleteNotification(notificationID); err != nil {
+			rs.writeNotificationError(w, notificationID, "delete", err)
+			return
+		}
+		rs.writeJSON(w, http.StatusOK, OperationResult{
+			Success:   true,
+			Message:   fmt.Sprintf("Notification %s deleted", notificationID),
+			Timestamp: time.Now().Unix(),
+			RequestID: requestID(r),
+		})
+
+	default:
+		rs.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (rs *RESTServer) writeNotificationError(w http.ResponseWriter, id, action string, err error) {
+	if errors.Is(err, notifications.ErrNotificationNotFound) {
+		rs.writeError(w, http.StatusNotFound, fmt.Sprintf("Notification not found: %s", id))
+		return
+	}
+	logger.Yellow("Failed to %s notification %s: %v", action, id, err)
+	rs.writeError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to %s notification", action))
+}
+
+// notificationFilter parses the list query into a filter selecting the
+// requested page, which it also returns
+func notificationFilter(r *http.Request) (*notifications.NotificationFilter, int, error) {
+	query := r.URL.Query()
+	filter := &notifications.NotificationFilter{
+		Level:    notifications.NotificationLevel(query.Get("level")),
+		Category: notifications.NotificationCategory(query.Get("category")),
+		Source:   query.Get("source"),
+		Limit:    defaultNotificationPageSize,
+	}
+	if filter.Level != "" && !filter.Level.Valid() {
+		return nil, 0, fmt.Errorf("invalid level: %s", filter.Level)
+	}
+	if filter.Category != "" && !filter.Category.Valid() {
+		return nil, 0, fmt.Errorf("invalid category: %s", filter.Category)
+	}
+
+	for name, target := range map[string]**bool{"read": &filter.Read, "persistent": &filter.Persistent} {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, 0, fmt.Errorf("invalid %s: %s", name, value)
+			}
+			*target = &parsed
+		}
+	}
+
+	now := time.Now()
+	for name, target := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := query.Get(name); value != "" {
+			parsed, err := parseHistoryTime(value, now, now)
+			if err != nil {
+				return nil, 0, fmt.Errorf("invalid %s: %s", name, value)
+			}
+			*target = &parsed
+		}
+	}
+
+	page := 1
+	if value := query.Get("page"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			return nil, 0, fmt.Errorf("invalid page: %s", value)
+		}
+		page = parsed
+	}
+	if value := query.Get("page_size"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxNotificationPageSize {
+			return nil, 0, fmt.Errorf("page_size must be between 1 and %d", maxNotificationPageSize)
+		}
+		filter.Limit = parsed
+	}
+	filter.Offset = (page - 1) * filter.Limit
+	return filter, page, nil
+}
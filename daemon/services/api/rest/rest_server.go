@@ -4,7 +4,10 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
@@ -12,10 +15,23 @@ import (
 	"strings"
 	"time"
 
+	"github.com/domalab/uma/daemon/domain"
 	"github.com/domalab/uma/daemon/logger"
+	"github.com/domalab/uma/daemon/plugins/diagnostics"
+	"github.com/domalab/uma/daemon/plugins/docker"
+	"github.com/domalab/uma/daemon/plugins/notifications"
+	"github.com/domalab/uma/daemon/plugins/storage"
+	"github.com/domalab/uma/daemon/services/alerts"
+	"github.com/domalab/uma/daemon/services/api/middleware"
+	"github.com/domalab/uma/daemon/services/api/types/requests"
+	"github.com/domalab/uma/daemon/services/async"
+	"github.com/domalab/uma/daemon/services/auth"
+	"github.com/domalab/uma/daemon/services/cache"
 	"github.com/domalab/uma/daemon/services/collectors"
+	"github.com/domalab/uma/daemon/services/forecast"
+	"github.com/domalab/uma/daemon/services/history"
+	"github.com/domalab/uma/daemon/services/mcp"
 	"github.com/domalab/uma/daemon/services/streaming"
-	"github.com/gorilla/websocket"
 )
 
 // CacheEntry represents a cached response
@@ -25,18 +41,29 @@ type CacheEntry struct {
 	TTL       int64 // Time to live in seconds
 }
 
-// MCPHandler provides MCP WebSocket functionality integrated into REST server
-type MCPHandler struct {
-	upgrader websocket.Upgrader
-}
-
 // RESTServer provides REST API v2 with integrated MCP server
 type RESTServer struct {
-	collector  *collectors.SystemCollector
-	streamer   *streaming.WebSocketEngine
-	mux        *http.ServeMux
-	cache      map[string]*CacheEntry
-	mcpHandler *MCPHandler
+	collector *collectors.SystemCollector
+	streamer  *streaming.WebSocketEngine
+	services  Services
+	mux       *http.ServeMux
+	cache     map[string]*CacheEntry
+	tools     *mcp.ToolRegistry
+	mcpServer *mcp.Server
+
+	execOrigins []string // Browser origins besides the server's own allowed to open exec sessions
+}
+
+// Services holds the daemon plugins that REST handlers act on
+type Services struct {
+	Docker        *docker.DockerManager
+	Storage       *storage.StorageMonitor
+	Async         *async.AsyncManager
+	Diagnostics   *diagnostics.DiagnosticsManager
+	Notifications *notifications.NotificationManager
+	History       *history.Store
+	Forecast      *forecast.Service
+	Alerts        *alerts.Engine
 }
 
 // SystemInfo represents comprehensive system information
@@ -322,10 +349,11 @@ type HealthStatus struct {
 
 // OperationResult represents operation results
 type OperationResult struct {
-	Success   bool   `json:"success"`
-	Message   string `json:"message"`
-	Timestamp int64  `json:"timestamp"`
-	RequestID string `json:"request_id,omitempty"`
+	Success     bool   `json:"success"`
+	Message     string `json:"message"`
+	Timestamp   int64  `json:"timestamp"`
+	RequestID   string `json:"request_id,omitempty"`
+	OperationID string `json:"operation_id,omitempty"`
 }
 
 // NewRESTServer creates a REST server with integrated MCP
@@ -337,21 +365,45 @@ func NewRESTServer(collector *collectors.SystemCollector, streamer *streaming.We
 		cache:     make(map[string]*CacheEntry),
 	}
 
-	// Initialize MCP handler with WebSocket upgrader
-	server.mcpHandler = &MCPHandler{
-		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				return true // Allow all origins for MCP
-			},
-			ReadBufferSize:  1024,
-			WriteBufferSize: 1024,
-		},
-	}
+	// MCP tools replay requests through the REST server, so they share its handlers
+	server.tools = mcp.NewToolRegistry(server)
+	server.mcpServer = mcp.NewServer(domain.DefaultConfig().MCP, server.tools)
 
 	server.registerRoutes()
+	server.registerMCPResources()
+
+	// Collector updates drive MCP resource subscriptions
+	collector.AddListener(func(name string, data interface{}) {
+		server.mcpServer.NotifyResourceTopic(name)
+	})
 	return server
 }
 
+// SetMCPConfig applies the MCP section of the daemon config
+func (rs *RESTServer) SetMCPConfig(config domain.MCPConfig) {
+	rs.mcpServer.SetConfig(config)
+}
+
+// SetCORSConfig applies the cross-origin settings of the daemon config
+func (rs *RESTServer) SetCORSConfig(config domain.CORSMiddlewareConfig) {
+	rs.execOrigins = config.AllowedOrigins
+}
+
+// MCPServer returns the MCP server, which the daemon also serves over its Unix socket
+func (rs *RESTServer) MCPServer() *mcp.Server {
+	return rs.mcpServer
+}
+
+// Stop closes open MCP sessions
+func (rs *RESTServer) Stop() {
+	rs.mcpServer.Stop()
+}
+
+// SetServices wires the daemon plugins once they have been initialized
+func (rs *RESTServer) SetServices(services Services) {
+	rs.services = services
+}
+
 // Cache helper methods
 
 // getCachedData retrieves data from cache if not expired
@@ -379,90 +431,90 @@ func (rs *RESTServer) setCachedData(key string, data interface{}, ttl int64) {
 	}
 }
 
-// registerRoutes registers all REST API v2 routes
+// registerRoutes registers all REST API v2 routes and their MCP tools
 func (rs *RESTServer) registerRoutes() {
-	// System endpoints (4 total)
-	rs.mux.HandleFunc("/api/v2/system/info", rs.handleSystemInfo)
-	rs.mux.HandleFunc("/api/v2/system/health", rs.handleSystemHealth)
-	rs.mux.HandleFunc("/api/v2/system/reboot", rs.handleSystemReboot)
-	rs.mux.HandleFunc("/api/v2/system/shutdown", rs.handleSystemShutdown)
-
-	// Storage endpoints (4 total)
-	rs.mux.HandleFunc("/api/v2/storage/config", rs.handleStorageConfig)
-	rs.mux.HandleFunc("/api/v2/storage/layout", rs.handleStorageLayout)
-	rs.mux.HandleFunc("/api/v2/storage/array/start", rs.handleArrayStart)
-	rs.mux.HandleFunc("/api/v2/storage/array/stop", rs.handleArrayStop)
-
-	// Container endpoints (3 total)
-	rs.mux.HandleFunc("/api/v2/containers/list", rs.handleContainersList)
-	rs.mux.HandleFunc("/api/v2/containers/", rs.handleContainerAction) // Handles /{id}/start, /{id}/stop, /{id}/stats
-
-	// VM endpoints (1 total)
-	rs.mux.HandleFunc("/api/v2/vms/list", rs.handleVMsList)
-
-	// UPS monitoring endpoints (1 total)
-	rs.mux.HandleFunc("/api/v2/ups/status", rs.handleUPSStatus)
-
-	// Hardware sensor endpoints (1 total)
-	rs.mux.HandleFunc("/api/v2/system/sensors", rs.handleSystemSensors)
-
-	// Network interface endpoints (1 total)
-	rs.mux.HandleFunc("/api/v2/network/interfaces", rs.handleNetworkInterfaces)
-
-	// GPU monitoring endpoints (1 total)
-	rs.mux.HandleFunc("/api/v2/system/gpu", rs.handleGPUStatus)
-
-	// Unraid-specific endpoints (4 total)
-	rs.mux.HandleFunc("/api/v2/shares", rs.handleShares)
-	rs.mux.HandleFunc("/api/v2/storage/pools", rs.handleStoragePools)
-	rs.mux.HandleFunc("/api/v2/storage/usage", rs.handleStorageUsage)
-	rs.mux.HandleFunc("/api/v2/logs", rs.handleLogs)
-
-	// Priority 1 Critical Features (4 total)
-	rs.mux.HandleFunc("/api/v2/storage/disks/smart", rs.handleDiskSMART)
-	rs.mux.HandleFunc("/api/v2/array/parity", rs.handleParityStatus)
-	rs.mux.HandleFunc("/api/v2/scripts", rs.handleUserScripts)
+	routes := rs.routes()
+	for _, route := range routes {
+		rs.mux.HandleFunc(route.pattern, route.handler)
+		if err := rs.tools.Register(route.tools...); err != nil {
+			logger.Red("Failed to register MCP tools for %s: %v", route.pattern, err)
+		}
+	}
 
-	// Priority 2 Enhancements (2 total)
-	rs.mux.HandleFunc("/api/v2/storage/disks/spindown", rs.handleDiskSpindown)
-	rs.mux.HandleFunc("/api/v2/vms/stats/", rs.handleVMStats) // Handles /{id}
+	// Prometheus exposition
+	rs.mux.Handle("/metrics", middleware.GetMetricsHandler())
 
 	// WebSocket endpoints
 	rs.mux.HandleFunc("/api/v2/stream", rs.streamer.HandleWebSocket)
-	rs.mux.HandleFunc("/mcp", rs.handleMCPWebSocket)
+	rs.mux.HandleFunc("/api/v2/events", rs.streamer.HandleEvents)
+
+	// MCP over WebSocket and Streamable HTTP
+	rs.mux.Handle("/mcp", rs.mcpServer)
 
-	logger.Green("Registered 27 REST endpoints + WebSocket streaming + MCP server")
+	logger.Green("Registered %d REST endpoints + Prometheus metrics + WebSocket/SSE streaming + MCP server with %d tools",
+		len(routes), rs.tools.GetRegistryStats()["total_tools"])
 }
 
-// ServeHTTP implements http.Handler
+// ServeHTTP implements http.Handler. CORS, compression and request logging
+// are handled by the middleware pipeline mounted in HTTPServer.
 func (rs *RESTServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 
-	// Add performance headers
-	w.Header().Set("X-API-Version", "2.0")
-	w.Header().Set("X-Response-Time", "")
+	// Add performance headers unless the versioning middleware negotiated one
+	if w.Header().Get("X-API-Version") == "" {
+		w.Header().Set("X-API-Version", "2.0")
+	}
 
-	// CORS headers for web clients
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	// Route to handler; the response time is stamped just before headers are sent
+	rs.mux.ServeHTTP(&responseTimer{ResponseWriter: w, start: start}, r)
 
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
+	// Log slow requests
+	if duration := time.Since(start); duration > 100*time.Millisecond {
+		logger.Yellow("Slow request: %s %s took %v", r.Method, r.URL.Path, duration)
+	}
+}
+
+// responseTimer sets X-Response-Time when the handler writes its headers
+type responseTimer struct {
+	http.ResponseWriter
+	start       time.Time
+	wroteHeader bool
+}
+
+func (rt *responseTimer) WriteHeader(code int) {
+	if !rt.wroteHeader {
+		rt.wroteHeader = true
+		rt.Header().Set("X-Response-Time", time.Since(rt.start).String())
 	}
+	rt.ResponseWriter.WriteHeader(code)
+}
 
-	// Route to handler
-	rs.mux.ServeHTTP(w, r)
+func (rt *responseTimer) Write(b []byte) (int, error) {
+	if !rt.wroteHeader {
+		rt.WriteHeader(http.StatusOK)
+	}
+	return rt.ResponseWriter.Write(b)
+}
 
-	// Add response time header
-	duration := time.Since(start)
-	w.Header().Set("X-Response-Time", duration.String())
+// Flush implements http.Flusher interface
+func (rt *responseTimer) Flush() {
+	if flusher, ok := rt.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
 
-	// Log slow requests
-	if duration > 100*time.Millisecond {
-		logger.Yellow("Slow request: %s %s took %v", r.Method, r.URL.Path, duration)
+// Unwrap exposes the underlying writer to http.ResponseController
+func (rt *responseTimer) Unwrap() http.ResponseWriter {
+	return rt.ResponseWriter
+}
+
+// Hijack implements http.Hijacker interface for WebSocket upgrades
+func (rt *responseTimer) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := rt.ResponseWriter.(http.Hijacker); ok {
+		rt.wroteHeader = true
+		return hijacker.Hijack()
 	}
+	return nil, nil, fmt.Errorf("response writer does not support hijacking")
 }
 
 // System Handlers
@@ -515,6 +567,7 @@ func (rs *RESTServer) handleSystemReboot(w http.ResponseWriter, r *http.Request)
 		Success:   false,
 		Message:   "System reboot is disabled for safety",
 		Timestamp: time.Now().Unix(),
+		RequestID: requestID(r),
 	}
 
 	rs.writeJSON(w, http.StatusForbidden, result)
@@ -532,6 +585,7 @@ func (rs *RESTServer) handleSystemShutdown(w http.ResponseWriter, r *http.Reques
 		Success:   false,
 		Message:   "System shutdown is disabled for safety",
 		Timestamp: time.Now().Unix(),
+		RequestID: requestID(r),
 	}
 
 	rs.writeJSON(w, http.StatusForbidden, result)
@@ -580,36 +634,220 @@ func (rs *RESTServer) handleStorageLayout(w http.ResponseWriter, r *http.Request
 	rs.writeJSON(w, http.StatusOK, layout)
 }
 
-// handleArrayStart handles array start requests
+// handleArrayStart starts the array as an async operation and returns its ID
 func (rs *RESTServer) handleArrayStart(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		rs.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
-	result := OperationResult{
-		Success:   true,
-		Message:   "Array start initiated",
-		Timestamp: time.Now().Unix(),
+	if rs.services.Storage == nil || rs.services.Async == nil {
+		rs.writeError(w, http.StatusServiceUnavailable, "Storage services not available")
+		return
 	}
 
-	rs.writeJSON(w, http.StatusOK, result)
+	var req requests.ArrayStartRequest
+	if !rs.decodeOptionalBody(w, r, &req) {
+		return
+	}
+
+	if err := rs.services.Storage.CheckArrayStart(); err != nil {
+		rs.writeArrayCheckError(w, "start", err)
+		return
+	}
+
+	rs.startAsyncOperation(w, r, async.OperationRequest{
+		Type:        async.TypeArrayStart,
+		Description: "Start array",
+		Parameters: map[string]interface{}{
+			"maintenance_mode": req.MaintenanceMode,
+			"check_filesystem": req.CheckFilesystem,
+		},
+	}, "Array start initiated")
 }
 
-// handleArrayStop handles array stop requests
+// handleArrayStop stops the array as an async operation and returns its ID
 func (rs *RESTServer) handleArrayStop(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		rs.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
-	result := OperationResult{
-		Success:   true,
-		Message:   "Array stop initiated",
-		Timestamp: time.Now().Unix(),
+	if rs.services.Storage == nil || rs.services.Async == nil {
+		rs.writeError(w, http.StatusServiceUnavailable, "Storage services not available")
+		return
 	}
 
-	rs.writeJSON(w, http.StatusOK, result)
+	// Shares must be unmounted for mdcmd stop to succeed, so default to it
+	req := requests.ArrayStopRequest{UnmountShares: true}
+	if !rs.decodeOptionalBody(w, r, &req) {
+		return
+	}
+
+	if err := rs.services.Storage.CheckArrayStop(req.Force); err != nil {
+		rs.writeArrayCheckError(w, "stop", err)
+		return
+	}
+
+	rs.startAsyncOperation(w, r, async.OperationRequest{
+		Type:        async.TypeArrayStop,
+		Description: "Stop array",
+		Parameters: map[string]interface{}{
+			"force":           req.Force,
+			"unmount_shares":  req.UnmountShares,
+			"stop_containers": req.StopContainers,
+			"stop_vms":        req.StopVMs,
+		},
+	}, "Array stop initiated")
+}
+
+// writeArrayCheckError maps array pre-check failures to 409 and anything else to 500
+func (rs *RESTServer) writeArrayCheckError(w http.ResponseWriter, action string, err error) {
+	if errors.Is(err, storage.ErrArrayPrecondition) {
+		rs.writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	logger.Yellow("Failed to check array %s preconditions: %v", action, err)
+	rs.writeError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to check array %s preconditions", action))
+}
+
+// Operation Handlers
+
+// requestCreator names the caller for operation history, preferring the API key name
+func requestCreator(r *http.Request) string {
+	if key, ok := auth.FromContext(r.Context()); ok {
+		return "api-key:" + key.Name
+	}
+	return "api"
+}
+
+// startAsyncOperation starts an async operation and responds with 202 and its ID
+func (rs *RESTServer) startAsyncOperation(w http.ResponseWriter, r *http.Request, req async.OperationRequest, message string) {
+	op, err := rs.services.Async.StartOperation(req, requestCreator(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, async.ErrOperationConflict):
+			rs.writeError(w, http.StatusConflict, err.Error())
+			return
+		case errors.Is(err, async.ErrUnknownOperationType):
+			rs.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		case errors.Is(err, async.ErrOperationLimit):
+			rs.writeError(w, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		logger.Yellow("Failed to start %s operation: %v", req.Type, err)
+		rs.writeError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to start %s operation", req.Type))
+		return
+	}
+
+	w.Header().Set("Location", "/api/v2/operations/"+op.ID)
+	rs.writeJSON(w, http.StatusAccepted, OperationResult{
+		Success:     true,
+		Message:     message,
+		Timestamp:   time.Now().Unix(),
+		RequestID:   requestID(r),
+		OperationID: op.ID,
+	})
+}
+
+// genericOperationTypes may be started through POST /api/v2/operations. Array
+// start and stop, reboot and shutdown need the pre-checks and admin scope of
+// their own endpoints.
+var genericOperationTypes = map[async.OperationType]bool{
+	async.TypeParityCheck:   true,
+	async.TypeParityCorrect: true,
+	async.TypeDiskScan:      true,
+	async.TypeSMARTScan:     true,
+	async.TypeBulkContainer: true,
+	async.TypeBulkVM:        true,
+}
+
+// handleOperations lists operations or starts a new one (target: <5ms)
+func (rs *RESTServer) handleOperations(w http.ResponseWriter, r *http.Request) {
+	if rs.services.Async == nil {
+		rs.writeError(w, http.StatusServiceUnavailable, "Async operations not available")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		status := async.OperationStatus(query.Get("status"))
+		operationType := async.OperationType(query.Get("type"))
+		rs.writeJSON(w, http.StatusOK, rs.services.Async.ListOperations(status, operationType))
+
+	case http.MethodPost:
+		var req async.OperationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			rs.writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		if req.Type == "" {
+			rs.writeError(w, http.StatusBadRequest, "Operation type is required")
+			return
+		}
+		if !genericOperationTypes[req.Type] {
+			rs.writeError(w, http.StatusBadRequest, fmt.Sprintf("Operation type %s cannot be started here; use its own endpoint", req.Type))
+			return
+		}
+		if req.Description == "" {
+			req.Description = string(req.Type)
+		}
+		rs.startAsyncOperation(w, r, req, fmt.Sprintf("Operation %s started", req.Type))
+
+	default:
+		rs.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleOperation returns or cancels a single async operation (target: <5ms)
+func (rs *RESTServer) handleOperation(w http.ResponseWriter, r *http.Request) {
+	if rs.services.Async == nil {
+		rs.writeError(w, http.StatusServiceUnavailable, "Async operations not available")
+		return
+	}
+
+	operationID := strings.TrimPrefix(r.URL.Path, "/api/v2/operations/")
+	if operationID == "" || strings.Contains(operationID, "/") {
+		rs.writeError(w, http.StatusBadRequest, "Invalid operation URL")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		op, err := rs.services.Async.GetOperation(operationID)
+		if err != nil {
+			rs.writeError(w, http.StatusNotFound, fmt.Sprintf("Operation not found: %s", operationID))
+			return
+		}
+		rs.writeJSON(w, http.StatusOK, op.ToSafeOperation())
+
+	case http.MethodDelete:
+		if err := rs.services.Async.CancelOperation(operationID); err != nil {
+			switch {
+			case errors.Is(err, async.ErrOperationNotFound):
+				rs.writeError(w, http.StatusNotFound, fmt.Sprintf("Operation not found: %s", operationID))
+			case errors.Is(err, async.ErrNotCancellable):
+				rs.writeError(w, http.StatusConflict, err.Error())
+			default:
+				logger.Yellow("Failed to cancel operation %s: %v", operationID, err)
+				rs.writeError(w, http.StatusInternalServerError, "Failed to cancel operation")
+			}
+			return
+		}
+		rs.writeJSON(w, http.StatusOK, OperationResult{
+			Success:     true,
+			Message:     fmt.Sprintf("Operation %s cancelled", operationID),
+			Timestamp:   time.Now().Unix(),
+			RequestID:   requestID(r),
+			OperationID: operationID,
+		})
+
+	default:
+		rs.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
 }
 
 // Container Handlers
@@ -632,7 +870,7 @@ func (rs *RESTServer) handleContainersList(w http.ResponseWriter, r *http.Reques
 	rs.writeJSON(w, http.StatusOK, containers)
 }
 
-// handleContainerAction handles container start/stop actions and stats requests
+// handleContainerAction handles container lifecycle actions and stats requests
 func (rs *RESTServer) handleContainerAction(w http.ResponseWriter, r *http.Request) {
 	// Parse container ID and action from URL
 	path := strings.TrimPrefix(r.URL.Path, "/api/v2/containers/")
@@ -657,26 +895,152 @@ func (rs *RESTServer) handleContainerAction(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	// Handle log requests (GET)
+	if action == "logs" && r.Method == http.MethodGet {
+		rs.handleContainerLogs(w, r, containerID)
+		return
+	}
+
+	// Handle exec sessions (WebSocket)
+	if action == "exec" && r.Method == http.MethodGet {
+		rs.handleContainerExec(w, r, containerID)
+		return
+	}
+
 	// Handle control actions (POST)
 	if r.Method != http.MethodPost {
 		rs.writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
-	if action != "start" && action != "stop" {
-		rs.writeError(w, http.StatusBadRequest, "Invalid action, must be 'start', 'stop', or 'stats' (GET)")
+	event, ok := containerActionEvents[action]
+	if !ok {
+		rs.writeError(w, http.StatusBadRequest, "Invalid action, must be 'start', 'stop', 'restart', 'pause', 'unpause', 'remove', 'stats' (GET), 'logs' (GET) or 'exec' (WebSocket)")
+		return
+	}
+
+	if rs.services.Docker == nil {
+		rs.writeError(w, http.StatusServiceUnavailable, "Docker manager not available")
 		return
 	}
 
+	var req requests.DockerContainerActionRequest
+	if !rs.decodeOptionalBody(w, r, &req) {
+		return
+	}
+	if timeout := r.URL.Query().Get("timeout"); timeout != "" {
+		seconds, err := strconv.Atoi(timeout)
+		if err != nil {
+			rs.writeError(w, http.StatusBadRequest, "Invalid timeout, must be a number of seconds")
+			return
+		}
+		req.Timeout = seconds
+	}
+	if force := r.URL.Query().Get("force"); force != "" {
+		req.Force = force == "true" || force == "1"
+	}
+	if req.Timeout < 0 {
+		rs.writeError(w, http.StatusBadRequest, "Invalid timeout, must not be negative")
+		return
+	}
+
+	// Resolve the container first so unknown IDs and state conflicts get proper status codes
+	container, err := rs.services.Docker.GetContainer(containerID)
+	if err != nil {
+		rs.writeContainerError(w, containerID, err)
+		return
+	}
+
+	if conflict := containerActionConflict(action, container.State, req.Force); conflict != "" {
+		rs.writeError(w, http.StatusConflict, fmt.Sprintf("Container %s %s", containerID, conflict))
+		return
+	}
+
+	switch action {
+	case "start":
+		err = rs.services.Docker.StartContainer(containerID)
+	case "stop":
+		err = rs.services.Docker.StopContainer(containerID, req.Timeout)
+	case "restart":
+		err = rs.services.Docker.RestartContainer(containerID, req.Timeout)
+	case "pause":
+		err = rs.services.Docker.PauseContainer(containerID)
+	case "unpause":
+		err = rs.services.Docker.UnpauseContainer(containerID)
+	case "remove":
+		err = rs.services.Docker.RemoveContainer(containerID, req.Force)
+	}
+	if err != nil {
+		logger.Yellow("Failed to %s container %s: %v", action, containerID, err)
+		rs.writeError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to %s container", action))
+		return
+	}
+
+	cache.InvalidateCache(cache.CreateDockerEvent(containerID, event, map[string]interface{}{
+		"name":   container.Name,
+		"action": action,
+	}))
+
 	result := OperationResult{
 		Success:   true,
-		Message:   fmt.Sprintf("Container %s %s initiated", containerID, action),
+		Message:   fmt.Sprintf("Container %s %s completed", containerID, action),
 		Timestamp: time.Now().Unix(),
+		RequestID: requestID(r),
 	}
 
 	rs.writeJSON(w, http.StatusOK, result)
 }
 
+// containerActionEvents maps container actions to their cache invalidation events
+var containerActionEvents = map[string]string{
+	"start":   "container_started",
+	"stop":    "container_stopped",
+	"restart": "container_restarted",
+	"pause":   "container_paused",
+	"unpause": "container_unpaused",
+	"remove":  "container_removed",
+}
+
+// writeContainerError reports a failure to resolve a container
+func (rs *RESTServer) writeContainerError(w http.ResponseWriter, containerID string, err error) {
+	switch {
+	case errors.Is(err, docker.ErrContainerNotFound):
+		rs.writeError(w, http.StatusNotFound, fmt.Sprintf("Container not found: %s", containerID))
+	case errors.Is(err, docker.ErrDockerUnavailable):
+		rs.writeError(w, http.StatusServiceUnavailable, "Docker is not available")
+	default:
+		logger.Yellow("Failed to inspect container %s: %v", containerID, err)
+		rs.writeError(w, http.StatusInternalServerError, "Failed to retrieve container information")
+	}
+}
+
+// containerActionConflict returns why an action cannot be applied in the given state, or "" if it can
+func containerActionConflict(action, state string, force bool) string {
+	switch action {
+	case "start":
+		if state == "running" || state == "paused" {
+			return "is already " + state
+		}
+	case "stop":
+		if state != "running" && state != "paused" && state != "restarting" {
+			return "is already stopped"
+		}
+	case "pause":
+		if state != "running" {
+			return "is not running"
+		}
+	case "unpause":
+		if state != "paused" {
+			return "is not paused"
+		}
+	case "remove":
+		if (state == "running" || state == "paused") && !force {
+			return "is running, stop it first or set force"
+		}
+	}
+	return ""
+}
+
 // VM Handlers
 
 // handleVMsList returns real VM inventory (target: <30ms)
@@ -2638,118 +3002,31 @@ func (rs *RESTServer) getSMARTDataForDevice(device string) (DiskSMARTInfo, error
 
 // getRealContainerStats gets real-time performance stats for a container
 func (rs *RESTServer) getRealContainerStats(containerID string) (*ContainerStats, error) {
-	// Get container stats using docker stats
-	cmd := exec.Command("docker", "stats", "--no-stream", "--format", "{{.Container}}\t{{.CPUPerc}}\t{{.MemUsage}}\t{{.MemPerc}}\t{{.NetIO}}\t{{.BlockIO}}", containerID)
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get container stats: %v", err)
+	if rs.services.Docker == nil {
+		return nil, fmt.Errorf("docker manager not available")
 	}
 
-	line := strings.TrimSpace(string(output))
-	if line == "" {
-		return nil, fmt.Errorf("no stats data for container %s", containerID)
-	}
-
-	fields := strings.Split(line, "\t")
-	if len(fields) < 6 {
-		return nil, fmt.Errorf("unexpected stats format")
-	}
-
-	stats := &ContainerStats{
-		ContainerID: containerID,
-		LastUpdated: time.Now().Unix(),
-		Status:      "active",
-	}
-
-	// Parse CPU percentage
-	cpuStr := strings.TrimSuffix(fields[1], "%")
-	if cpu, err := strconv.ParseFloat(cpuStr, 64); err == nil {
-		stats.CPUPercent = cpu
-	}
-
-	// Parse memory usage (format: "123.4MiB / 456.7GiB")
-	memParts := strings.Split(fields[2], " / ")
-	if len(memParts) == 2 {
-		stats.MemoryUsage = rs.parseMemoryValue(memParts[0])
-		stats.MemoryLimit = rs.parseMemoryValue(memParts[1])
-		stats.MemoryUsageHuman = strings.TrimSpace(memParts[0])
-		stats.MemoryLimitHuman = strings.TrimSpace(memParts[1])
-	}
-
-	// Parse memory percentage
-	memPercStr := strings.TrimSuffix(fields[3], "%")
-	if memPerc, err := strconv.ParseFloat(memPercStr, 64); err == nil {
-		stats.MemoryPercent = memPerc
-	}
-
-	// Parse network I/O (format: "123kB / 456kB")
-	netParts := strings.Split(fields[4], " / ")
-	if len(netParts) == 2 {
-		stats.NetworkRxBytes = rs.parseNetworkValue(netParts[0])
-		stats.NetworkTxBytes = rs.parseNetworkValue(netParts[1])
-	}
-
-	// Parse disk I/O (format: "123MB / 456MB")
-	diskParts := strings.Split(fields[5], " / ")
-	if len(diskParts) == 2 {
-		stats.DiskReadBytes = rs.parseNetworkValue(diskParts[0])
-		stats.DiskWriteBytes = rs.parseNetworkValue(diskParts[1])
-	}
-
-	// Get container name
-	nameCmd := exec.Command("docker", "inspect", "--format", "{{.Name}}", containerID)
-	if nameOutput, err := nameCmd.Output(); err == nil {
-		stats.Name = strings.TrimPrefix(strings.TrimSpace(string(nameOutput)), "/")
-	}
-
-	return stats, nil
-}
-
-// parseMemoryValue parses memory values like "123.4MiB" to bytes
-func (rs *RESTServer) parseMemoryValue(value string) int64 {
-	value = strings.TrimSpace(value)
-	if value == "" {
-		return 0
-	}
-
-	// Extract number and unit
-	var numStr string
-	var unit string
-
-	for i, char := range value {
-		if char >= '0' && char <= '9' || char == '.' {
-			numStr += string(char)
-		} else {
-			unit = value[i:]
-			break
-		}
-	}
-
-	num, err := strconv.ParseFloat(numStr, 64)
+	sample, err := rs.services.Docker.GetContainerStats(containerID)
 	if err != nil {
-		return 0
-	}
-
-	// Convert to bytes based on unit
-	switch strings.ToLower(unit) {
-	case "b":
-		return int64(num)
-	case "kib", "kb":
-		return int64(num * 1024)
-	case "mib", "mb":
-		return int64(num * 1024 * 1024)
-	case "gib", "gb":
-		return int64(num * 1024 * 1024 * 1024)
-	case "tib", "tb":
-		return int64(num * 1024 * 1024 * 1024 * 1024)
-	default:
-		return int64(num)
-	}
-}
-
-// parseNetworkValue parses network/disk I/O values like "123kB" to bytes
-func (rs *RESTServer) parseNetworkValue(value string) int64 {
-	return rs.parseMemoryValue(value) // Same parsing logic
+		return nil, fmt.Errorf("failed to get container stats: %w", err)
+	}
+
+	return &ContainerStats{
+		ContainerID:      containerID,
+		Name:             sample.Name,
+		CPUPercent:       sample.CPUPercent,
+		MemoryUsage:      int64(sample.MemUsage),
+		MemoryLimit:      int64(sample.MemLimit),
+		MemoryPercent:    sample.MemPercent,
+		MemoryUsageHuman: rs.formatBytes(int64(sample.MemUsage)),
+		MemoryLimitHuman: rs.formatBytes(int64(sample.MemLimit)),
+		NetworkRxBytes:   int64(sample.NetRx),
+		NetworkTxBytes:   int64(sample.NetTx),
+		DiskReadBytes:    int64(sample.BlockRead),
+		DiskWriteBytes:   int64(sample.BlockWrite),
+		LastUpdated:      time.Now().Unix(),
+		Status:           "active",
+	}, nil
 }
 
 // getRealParityStatus gets current parity check status from /proc/mdstat
@@ -3046,299 +3323,6 @@ func (rs *RESTServer) executeUserScript(scriptID string) (map[string]interface{}
 	return result, nil
 }
 
-// MCP WebSocket Handler
-
-// handleMCPWebSocket handles MCP (Model Context Protocol) WebSocket connections
-func (rs *RESTServer) handleMCPWebSocket(w http.ResponseWriter, r *http.Request) {
-	// Upgrade HTTP connection to WebSocket
-	conn, err := rs.mcpHandler.upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		logger.Yellow("Failed to upgrade MCP WebSocket connection: %v", err)
-		return
-	}
-	defer conn.Close()
-
-	logger.Blue("MCP WebSocket connection established")
-
-	// Handle MCP protocol messages
-	for {
-		messageType, data, err := conn.ReadMessage()
-		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				logger.Yellow("MCP WebSocket error: %v", err)
-			}
-			break
-		}
-
-		if messageType == websocket.TextMessage {
-			response := rs.processMCPMessage(data)
-			if err := conn.WriteMessage(websocket.TextMessage, response); err != nil {
-				logger.Yellow("Failed to send MCP response: %v", err)
-				break
-			}
-		}
-	}
-
-	logger.Blue("MCP WebSocket connection closed")
-}
-
-// processMCPMessage processes incoming MCP JSON-RPC messages
-func (rs *RESTServer) processMCPMessage(data []byte) []byte {
-	var request map[string]interface{}
-	if err := json.Unmarshal(data, &request); err != nil {
-		return rs.createMCPError(nil, -32700, "Parse error", nil)
-	}
-
-	method, ok := request["method"].(string)
-	if !ok {
-		return rs.createMCPError(request["id"], -32600, "Invalid Request", nil)
-	}
-
-	switch method {
-	case "initialize":
-		return rs.handleMCPInitialize(request)
-	case "tools/list":
-		return rs.handleMCPToolsList(request)
-	case "tools/call":
-		return rs.handleMCPToolsCall(request)
-	default:
-		return rs.createMCPError(request["id"], -32601, "Method not found", nil)
-	}
-}
-
-// handleMCPInitialize handles the MCP initialize method
-func (rs *RESTServer) handleMCPInitialize(request map[string]interface{}) []byte {
-	response := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"id":      request["id"],
-		"result": map[string]interface{}{
-			"protocolVersion": "2024-11-05",
-			"capabilities": map[string]interface{}{
-				"tools": map[string]interface{}{
-					"listChanged": false,
-				},
-				"logging": map[string]interface{}{},
-			},
-			"serverInfo": map[string]interface{}{
-				"name":    "UMA MCP Server",
-				"version": "2.0.0",
-			},
-		},
-	}
-
-	data, _ := json.Marshal(response)
-	return data
-}
-
-// handleMCPToolsList handles the tools/list method
-func (rs *RESTServer) handleMCPToolsList(request map[string]interface{}) []byte {
-	tools := []map[string]interface{}{
-		{
-			"name":        "get_system_health",
-			"description": "Get system health status and metrics",
-			"inputSchema": map[string]interface{}{
-				"type":       "object",
-				"properties": map[string]interface{}{},
-				"required":   []string{},
-			},
-		},
-		{
-			"name":        "get_system_info",
-			"description": "Get comprehensive system information",
-			"inputSchema": map[string]interface{}{
-				"type":       "object",
-				"properties": map[string]interface{}{},
-				"required":   []string{},
-			},
-		},
-		{
-			"name":        "get_disk_smart_data",
-			"description": "Get SMART data for all disks including temperatures",
-			"inputSchema": map[string]interface{}{
-				"type":       "object",
-				"properties": map[string]interface{}{},
-				"required":   []string{},
-			},
-		},
-		{
-			"name":        "get_container_stats",
-			"description": "Get real-time container performance statistics",
-			"inputSchema": map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"container_id": map[string]interface{}{
-						"type":        "string",
-						"description": "Container ID or name",
-					},
-				},
-				"required": []string{"container_id"},
-			},
-		},
-		{
-			"name":        "get_parity_status",
-			"description": "Get array parity check status and progress",
-			"inputSchema": map[string]interface{}{
-				"type":       "object",
-				"properties": map[string]interface{}{},
-				"required":   []string{},
-			},
-		},
-	}
-
-	response := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"id":      request["id"],
-		"result": map[string]interface{}{
-			"tools": tools,
-		},
-	}
-
-	data, _ := json.Marshal(response)
-	return data
-}
-
-// handleMCPToolsCall handles the tools/call method
-func (rs *RESTServer) handleMCPToolsCall(request map[string]interface{}) []byte {
-	params, ok := request["params"].(map[string]interface{})
-	if !ok {
-		return rs.createMCPError(request["id"], -32602, "Invalid params", nil)
-	}
-
-	toolName, ok := params["name"].(string)
-	if !ok {
-		return rs.createMCPError(request["id"], -32602, "Tool name required", nil)
-	}
-
-	// Execute the tool and get result
-	result := rs.executeMCPTool(toolName, params)
-
-	response := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"id":      request["id"],
-		"result":  result,
-	}
-
-	data, _ := json.Marshal(response)
-	return data
-}
-
-// executeMCPTool executes a tool and returns the result
-func (rs *RESTServer) executeMCPTool(toolName string, params map[string]interface{}) map[string]interface{} {
-	switch toolName {
-	case "get_system_health":
-		// Use system info as health proxy since we don't have a separate health method
-		info, _ := rs.getRealSystemInfo()
-		return map[string]interface{}{
-			"content": []map[string]interface{}{
-				{
-					"type": "text",
-					"text": fmt.Sprintf("System Health: %s, Uptime: %s, Memory: %.1fGB",
-						info.Status, info.UptimeHuman, info.TotalMemoryGB),
-				},
-			},
-		}
-	case "get_system_info":
-		info, _ := rs.getRealSystemInfo()
-		return map[string]interface{}{
-			"content": []map[string]interface{}{
-				{
-					"type": "text",
-					"text": fmt.Sprintf("System: %s, CPU Cores: %d, Memory: %s",
-						info.Hostname, info.CPUCores, info.TotalMemoryHuman),
-				},
-			},
-		}
-	case "get_disk_smart_data":
-		disks, _ := rs.getRealDiskSMARTData()
-		diskInfo := fmt.Sprintf("Found %d disks", len(disks))
-		if len(disks) > 0 {
-			diskInfo += fmt.Sprintf(", first disk: %s at %d°C", disks[0].Device, disks[0].Temperature)
-		}
-		return map[string]interface{}{
-			"content": []map[string]interface{}{
-				{
-					"type": "text",
-					"text": diskInfo,
-				},
-			},
-		}
-	case "get_container_stats":
-		containerID, _ := params["arguments"].(map[string]interface{})["container_id"].(string)
-		if containerID == "" {
-			return map[string]interface{}{
-				"content": []map[string]interface{}{
-					{
-						"type": "text",
-						"text": "Error: container_id parameter required",
-					},
-				},
-				"isError": true,
-			}
-		}
-		stats, err := rs.getRealContainerStats(containerID)
-		if err != nil {
-			return map[string]interface{}{
-				"content": []map[string]interface{}{
-					{
-						"type": "text",
-						"text": fmt.Sprintf("Error getting container stats: %v", err),
-					},
-				},
-				"isError": true,
-			}
-		}
-		return map[string]interface{}{
-			"content": []map[string]interface{}{
-				{
-					"type": "text",
-					"text": fmt.Sprintf("Container %s: CPU %.2f%%, Memory %s",
-						stats.Name, stats.CPUPercent, stats.MemoryUsageHuman),
-				},
-			},
-		}
-	case "get_parity_status":
-		parity, _ := rs.getRealParityStatus()
-		return map[string]interface{}{
-			"content": []map[string]interface{}{
-				{
-					"type": "text",
-					"text": fmt.Sprintf("Parity: %s, Progress: %.1f%%, Speed: %s",
-						parity.Action, parity.Progress, parity.Speed),
-				},
-			},
-		}
-	default:
-		return map[string]interface{}{
-			"content": []map[string]interface{}{
-				{
-					"type": "text",
-					"text": fmt.Sprintf("Tool '%s' not found", toolName),
-				},
-			},
-			"isError": true,
-		}
-	}
-}
-
-// createMCPError creates a JSON-RPC error response
-func (rs *RESTServer) createMCPError(id interface{}, code int, message string, data interface{}) []byte {
-	response := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"id":      id,
-		"error": map[string]interface{}{
-			"code":    code,
-			"message": message,
-		},
-	}
-
-	if data != nil {
-		response["error"].(map[string]interface{})["data"] = data
-	}
-
-	responseData, _ := json.Marshal(response)
-	return responseData
-}
-
 // Priority 2 Enhancement Handlers
 
 // handleDiskSpindown returns disk spindown status (target: <50ms)
@@ -3555,6 +3539,28 @@ func (rs *RESTServer) bytesToTB(bytes int64) float64 {
 
 // Utility Methods
 
+// decodeOptionalBody decodes a JSON body into v if one was sent, writing a 400 on malformed input
+func (rs *RESTServer) decodeOptionalBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if r.Body == nil || r.ContentLength == 0 {
+		return true
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && err != io.EOF {
+		rs.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return false
+	}
+
+	return true
+}
+
+// requestID returns the request's tracing ID, as set by the request ID middleware
+func requestID(r *http.Request) string {
+	if id := middleware.GetRequestIDFromContext(r); id != "" {
+		return id
+	}
+	return r.Header.Get("X-Request-ID")
+}
+
 // writeJSON writes JSON response with performance optimization
 func (rs *RESTServer) writeJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,970 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/domalab/uma/daemon/domain"
+	"github.com/domalab/uma/daemon/plugins/docker"
+	"github.com/domalab/uma/daemon/plugins/notifications"
+	"github.com/domalab/uma/daemon/plugins/storage"
+	"github.com/domalab/uma/daemon/services/alerts"
+	"github.com/domalab/uma/daemon/services/api/middleware"
+	"github.com/domalab/uma/daemon/services/async"
+	"github.com/domalab/uma/daemon/services/auth"
+	"github.com/domalab/uma/daemon/services/collectors"
+	"github.com/domalab/uma/daemon/services/forecast"
+	"github.com/domalab/uma/daemon/services/history"
+	"github.com/domalab/uma/daemon/services/mcp"
+	"github.com/domalab/uma/daemon/services/streaming"
+	"github.com/gorilla/websocket"
+)
+
+// mockDockerEngine records Engine API requests and returns canned responses.
+// Unknown GETs answer 404 and any other request succeeds.
+type mockDockerEngine struct {
+	responses map[string]string
+	calls     []string
+	mutex     sync.Mutex
+}
+
+func newMockDockerEngine() *mockDockerEngine {
+	m := &mockDockerEngine{responses: make(map[string]string)}
+	m.responses["GET /_ping"] = "OK"
+	m.responses["GET /containers/web/json"] = `{"Id":"abc123","Name":"/web","State":{"Status":"running"},"Config":{"Tty":true}}`
+	m.responses["GET /containers/db/json"] = `{"Id":"def456","Name":"/db","State":{"Status":"exited"}}`
+	m.responses["GET /containers/paused/json"] = `{"Id":"ghi789","Name":"/paused","State":{"Status":"paused"}}`
+	return m
+}
+
+func (m *mockDockerEngine) Do(ctx context.Context, method, path string, query url.Values, body io.Reader) (*http.Response, error) {
+	call := method + " " + path
+	if len(query) > 0 {
+		call += "?" + query.Encode()
+	}
+	m.mutex.Lock()
+	m.calls = append(m.calls, call)
+	response, exists := m.responses[method+" "+path]
+	m.mutex.Unlock()
+
+	if !exists && method == http.MethodGet {
+		return nil, &docker.EngineError{StatusCode: http.StatusNotFound, Message: "No such container"}
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(response))}, nil
+}
+
+// Upgrade attaches to a fake exec session on a terminal, which echoes its
+// input line by line and exits after echoing exit
+func (m *mockDockerEngine) Upgrade(ctx context.Context, path string, query url.Values, body io.Reader) (io.ReadWriteCloser, error) {
+	m.mutex.Lock()
+	m.calls = append(m.calls, "POST "+path)
+	m.mutex.Unlock()
+
+	client, process := net.Pipe()
+	go func() {
+		defer process.Close()
+		reader := bufio.NewReader(process)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if _, err := process.Write([]byte(line)); err != nil || line == "exit\n" {
+				return
+			}
+		}
+	}()
+	return client, nil
+}
+
+func (m *mockDockerEngine) called(call string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, c := range m.calls {
+		if c == call {
+			return true
+		}
+	}
+	return false
+}
+
+func newTestRESTServer() *RESTServer {
+	collector := collectors.NewSystemCollector()
+	return NewRESTServer(collector, streaming.NewWebSocketEngine(collector))
+}
+
+// TestHandleContainerAction tests container lifecycle actions against a mocked Docker daemon
+func TestHandleContainerAction(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+		wantCall   string
+	}{
+		{"stop running", "/api/v2/containers/web/stop", "", http.StatusOK, "POST /containers/web/stop"},
+		{"stop with query timeout", "/api/v2/containers/web/stop?timeout=30", "", http.StatusOK, "POST /containers/web/stop?t=30"},
+		{"restart with body timeout", "/api/v2/containers/web/restart", `{"timeout":5}`, http.StatusOK, "POST /containers/web/restart?t=5"},
+		{"start stopped", "/api/v2/containers/db/start", "", http.StatusOK, "POST /containers/db/start"},
+		{"pause running", "/api/v2/containers/web/pause", "", http.StatusOK, "POST /containers/web/pause"},
+		{"unpause paused", "/api/v2/containers/paused/unpause", "", http.StatusOK, "POST /containers/paused/unpause"},
+		{"remove stopped", "/api/v2/containers/db/remove", "", http.StatusOK, "DELETE /containers/db"},
+		{"force remove running", "/api/v2/containers/web/remove?force=true", "", http.StatusOK, "DELETE /containers/web?force=1"},
+		{"unknown container", "/api/v2/containers/missing/stop", "", http.StatusNotFound, ""},
+		{"stop already stopped", "/api/v2/containers/db/stop", "", http.StatusConflict, ""},
+		{"start already running", "/api/v2/containers/web/start", "", http.StatusConflict, ""},
+		{"unpause running", "/api/v2/containers/web/unpause", "", http.StatusConflict, ""},
+		{"remove running without force", "/api/v2/containers/web/remove", "", http.StatusConflict, ""},
+		{"invalid action", "/api/v2/containers/web/explode", "", http.StatusBadRequest, ""},
+		{"invalid timeout", "/api/v2/containers/web/stop?timeout=soon", "", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := newMockDockerEngine()
+			server := newTestRESTServer()
+			server.SetServices(Services{Docker: docker.NewDockerManagerWithClient(executor)})
+
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+
+			if tt.wantCall != "" && !executor.called(tt.wantCall) {
+				t.Errorf("Expected Docker request %q, got %v", tt.wantCall, executor.calls)
+			}
+
+			if tt.wantStatus == http.StatusOK {
+				var result OperationResult
+				if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if !result.Success {
+					t.Error("Expected successful operation result")
+				}
+			}
+		})
+	}
+}
+
+// TestHandleContainerLogs tests reading, filtering and following container logs
+func TestHandleContainerLogs(t *testing.T) {
+	executor := newMockDockerEngine()
+	executor.responses["GET /containers/web/logs"] = "starting\nerror: no config\nlistening on :80\n"
+	server := newTestRESTServer()
+	server.SetServices(Services{Docker: docker.NewDockerManagerWithClient(executor)})
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/api/v2/containers/web/logs?grep=ERROR&ignore_case=true&invert=1&since=1718964000", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var result struct {
+		Lines []docker.LogLine `json:"lines"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(result.Lines) != 2 || result.Lines[1].Text != "listening on :80" || result.Lines[1].Stream != "stdout" {
+		t.Errorf("Unexpected lines %+v", result.Lines)
+	}
+	if !executor.called("GET /containers/web/logs?since=1718964000.000000000&stderr=1&stdout=1&tail=100") {
+		t.Errorf("Expected a logs request, got %v", executor.calls)
+	}
+
+	// Followed logs stream as newline-delimited JSON, or as events when asked for
+	rec = get("/api/v2/containers/web/logs?follow=true&tail=all", "")
+	if rec.Header().Get("Content-Type") != "application/x-ndjson" || strings.Count(rec.Body.String(), "\n") != 3 {
+		t.Errorf("Unexpected stream %q (%s)", rec.Body.String(), rec.Header().Get("Content-Type"))
+	}
+	if !executor.called("GET /containers/web/logs?follow=1&stderr=1&stdout=1") {
+		t.Errorf("Expected a followed logs request, got %v", executor.calls)
+	}
+	rec = get("/api/v2/containers/web/logs?follow=true&grep=error", "text/event-stream")
+	if rec.Body.String() != "event: log\ndata: {\"stream\":\"stdout\",\"line\":\"error: no config\"}\n\n" {
+		t.Errorf("Unexpected event stream %q", rec.Body.String())
+	}
+
+	for path, want := range map[string]int{
+		"/api/v2/containers/missing/logs":      http.StatusNotFound,
+		"/api/v2/containers/web/logs?grep=(":   http.StatusBadRequest,
+		"/api/v2/containers/web/logs?tail=-1":  http.StatusBadRequest,
+		"/api/v2/containers/web/logs?invert=1": http.StatusBadRequest,
+	} {
+		if rec := get(path, ""); rec.Code != want {
+			t.Errorf("%s: expected status %d, got %d", path, want, rec.Code)
+		}
+	}
+}
+
+// TestHandleContainerExec tests interactive exec sessions over a WebSocket
+func TestHandleContainerExec(t *testing.T) {
+	executor := newMockDockerEngine()
+	executor.responses["POST /containers/web/exec"] = `{"Id":"exec1"}`
+	executor.responses["GET /exec/exec1/json"] = `{"Running":false,"ExitCode":0}`
+	server := newTestRESTServer()
+	server.SetServices(Services{Docker: docker.NewDockerManagerWithClient(executor)})
+	server.SetCORSConfig(domain.CORSMiddlewareConfig{AllowedOrigins: []string{"https://dash.example", "*"}})
+
+	headers := make(map[auth.Scope]http.Header)
+	var keys []domain.APIKey
+	for _, scope := range []auth.Scope{auth.ScopeReadOnly, auth.ScopeOperator, auth.ScopeAdmin} {
+		secret, key, err := auth.GenerateAPIKey(string(scope), scope)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+		headers[scope] = http.Header{"Authorization": {"Bearer " + secret}}
+	}
+	httpServer := httptest.NewServer(middleware.Auth(auth.NewAuthenticator(domain.AuthConfig{Enabled: true, APIKeys: keys}))(server))
+	defer httpServer.Close()
+	base := "ws" + strings.TrimPrefix(httpServer.URL, "http") + "/api/v2/containers/"
+	admin := headers[auth.ScopeAdmin]
+
+	readJSON := func(conn *websocket.Conn) map[string]interface{} {
+		t.Helper()
+		var message map[string]interface{}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatalf("Failed to read message: %v", err)
+		}
+		return message
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(base+"web/exec?cols=80&rows=24", http.Header{
+		"Authorization": admin["Authorization"],
+		"Origin":        {"https://dash.example"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to open exec session: %v", err)
+	}
+	defer conn.Close()
+	if msg := readJSON(conn); msg["type"] != "started" || msg["exec_id"] != "exec1" || msg["tty"] != true {
+		t.Fatalf("Unexpected start %v", msg)
+	}
+
+	conn.WriteMessage(websocket.BinaryMessage, []byte("echo hi\n"))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if messageType, data, err := conn.ReadMessage(); err != nil || messageType != websocket.BinaryMessage || string(data) != "\x01echo hi\n" {
+		t.Fatalf("Unexpected output %q (%v)", data, err)
+	}
+	conn.WriteJSON(map[string]interface{}{"type": "resize", "cols": 120, "rows": 40})
+	conn.WriteJSON(map[string]interface{}{"type": "stdin", "data": "exit\n"})
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "\x01exit\n" {
+		t.Fatalf("Unexpected output %q (%v)", data, err)
+	}
+	if msg := readJSON(conn); msg["type"] != "exit" || msg["exit_code"] != float64(0) || msg["reason"] != "exited" {
+		t.Errorf("Unexpected exit %v", msg)
+	}
+	for _, call := range []string{
+		"POST /exec/exec1/start",
+		"POST /exec/exec1/resize?h=24&w=80",
+		"POST /exec/exec1/resize?h=40&w=120",
+	} {
+		if !executor.called(call) {
+			t.Errorf("Expected Docker request %q", call)
+		}
+	}
+
+	// Silent sessions are closed
+	defer func(timeout time.Duration) { execIdleTimeout = timeout }(execIdleTimeout)
+	execIdleTimeout = 50 * time.Millisecond
+	idle, _, err := websocket.DefaultDialer.Dial(base+"web/exec?tty=false&cmd=sh", http.Header{
+		"Authorization": admin["Authorization"],
+		"Origin":        {httpServer.URL},
+	})
+	if err != nil {
+		t.Fatalf("Failed to open exec session: %v", err)
+	}
+	defer idle.Close()
+	if msg := readJSON(idle); msg["tty"] != false {
+		t.Errorf("Unexpected start %v", msg)
+	}
+	if msg := readJSON(idle); msg["type"] != "exit" || msg["reason"] != "idle timeout" {
+		t.Errorf("Unexpected exit %v", msg)
+	}
+
+	for id, want := range map[string]int{"missing": http.StatusNotFound, "db": http.StatusConflict} {
+		if _, resp, err := websocket.DefaultDialer.Dial(base+id+"/exec", admin); err == nil || resp.StatusCode != want {
+			t.Errorf("%s: expected status %d, got %v", id, want, err)
+		}
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/containers/web/exec", nil)
+	req = req.WithContext(auth.NewContext(req.Context(), keys[2]))
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a WebSocket upgrade, got %d", rec.Code)
+	}
+
+	// Sessions need an admin key from an allowed origin
+	for name, test := range map[string]struct {
+		header http.Header
+		want   int
+	}{
+		"no key":         {nil, http.StatusUnauthorized},
+		"read-only key":  {headers[auth.ScopeReadOnly], http.StatusForbidden},
+		"operator key":   {headers[auth.ScopeOperator], http.StatusForbidden},
+		"foreign origin": {http.Header{"Authorization": admin["Authorization"], "Origin": {"https://evil.example"}}, http.StatusForbidden},
+	} {
+		if _, resp, err := websocket.DefaultDialer.Dial(base+"web/exec", test.header); err == nil || resp == nil || resp.StatusCode != test.want {
+			t.Errorf("%s: expected status %d, got %v", name, test.want, err)
+		}
+	}
+
+	// Also while authentication is disabled
+	unauthenticated := httptest.NewServer(server)
+	defer unauthenticated.Close()
+	if _, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(unauthenticated.URL, "http")+"/api/v2/containers/web/exec", nil); err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected status 403 without authentication, got %v", err)
+	}
+	executor.mutex.Lock()
+	started := 0
+	for _, call := range executor.calls {
+		if call == "POST /containers/web/exec" {
+			started++
+		}
+	}
+	executor.mutex.Unlock()
+	if started != 2 {
+		t.Errorf("Expected only the two allowed sessions to start a process, got %d", started)
+	}
+}
+
+// TestHandleContainerActionWithoutDocker tests that actions fail cleanly before plugins are wired
+func TestHandleContainerActionWithoutDocker(t *testing.T) {
+	server := newTestRESTServer()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v2/containers/web/stop", nil)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+}
+
+// instantExecutor completes immediately so operation endpoints can be exercised without hardware
+type instantExecutor struct {
+	operationType async.OperationType
+}
+
+func (e *instantExecutor) Execute(ctx context.Context, op *async.AsyncOperation, params map[string]interface{}) error {
+	op.UpdateStep(50, "halfway")
+	return nil
+}
+
+func (e *instantExecutor) GetType() async.OperationType { return e.operationType }
+
+func (e *instantExecutor) IsLongRunning() bool { return false }
+
+// TestHandleArrayRequestValidation tests array requests that are rejected before touching the array
+func TestHandleArrayRequestValidation(t *testing.T) {
+	server := newTestRESTServer()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v2/storage/array/stop", nil)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 before services are wired, got %d", rec.Code)
+	}
+
+	manager := async.NewAsyncManager()
+	defer manager.Stop()
+	server.SetServices(Services{Storage: storage.NewStorageMonitor(), Async: manager})
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v2/storage/array/start", strings.NewReader(`{"maintenance_mode":`))
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for malformed body, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v2/storage/array/start", nil)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for GET, got %d", rec.Code)
+	}
+}
+
+// TestHandleOperation tests operation progress lookups
+func TestHandleOperation(t *testing.T) {
+	manager := async.NewAsyncManager()
+	defer manager.Stop()
+	manager.RegisterExecutor(&instantExecutor{operationType: async.TypeSMARTScan})
+
+	server := newTestRESTServer()
+	server.SetServices(Services{Async: manager})
+
+	op, err := manager.StartOperation(async.OperationRequest{Type: async.TypeSMARTScan}, "test")
+	if err != nil {
+		t.Fatalf("Failed to start operation: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/operations/"+op.ID, nil)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var result async.SafeAsyncOperation
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result.ID != op.ID {
+		t.Errorf("Expected operation %s, got %s", op.ID, result.ID)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v2/operations/does-not-exist", nil)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown operation, got %d", rec.Code)
+	}
+}
+
+// TestHandleHistory tests listing and querying recorded metric history
+func TestHandleHistory(t *testing.T) {
+	server := newTestRESTServer()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/history", nil)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without a history store, got %d", rec.Code)
+	}
+
+	store, err := history.NewStore(history.Config{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to open history store: %v", err)
+	}
+	defer store.Close()
+	server.SetServices(Services{History: store})
+
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		store.Record("system.cpu", now.Add(time.Duration(i-10)*time.Second), map[string]float64{"cpu_percent": float64(i)})
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v2/history", nil)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"field":"cpu_percent"`) {
+		t.Fatalf("Expected the recorded series to be listed, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v2/history?metric=system.cpu&field=cpu_percent&from=-1m&step=1m", nil)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var series history.Series
+	if err := json.Unmarshal(rec.Body.Bytes(), &series); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	var minimum, maximum float64 = 100, 0
+	for _, point := range series.Points {
+		minimum, maximum = min(minimum, point.Min), max(maximum, point.Max)
+	}
+	if series.Step != 60 || minimum != 0 || maximum != 9 {
+		t.Errorf("Expected one-minute steps spanning 0 to 9, got step %d and %+v", series.Step, series.Points)
+	}
+
+	for query, status := range map[string]int{
+		"?metric=system.cpu&field=nope":                                  http.StatusNotFound,
+		"?field=cpu_percent":                                             http.StatusBadRequest,
+		"?metric=system.cpu&field=cpu_percent&step=x":                    http.StatusBadRequest,
+		"?metric=system.cpu&field=cpu_percent&from=-0s":                  http.StatusBadRequest,
+		"?metric=system.cpu&field=cpu_percent&from=-5":                   http.StatusBadRequest,
+		"?metric=system.cpu&field=cpu_percent&from=-1000000h":            http.StatusBadRequest,
+		"?metric=system.cpu&field=cpu_percent&from=1969-12-31T00:00:00Z": http.StatusBadRequest,
+	} {
+		req = httptest.NewRequest(http.MethodGet, "/api/v2/history"+query, nil)
+		rec = httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		if rec.Code != status {
+			t.Errorf("Expected status %d for %s, got %d", status, query, rec.Code)
+		}
+	}
+}
+
+func TestHandleStorageForecast(t *testing.T) {
+	server := newTestRESTServer()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/storage/forecast", nil)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without a forecast service, got %d", rec.Code)
+	}
+
+	store, err := history.NewStore(history.Config{Path: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to open history store: %v", err)
+	}
+	defer store.Close()
+	server.SetServices(Services{Forecast: forecast.NewService(store, nil, forecast.Config{ThresholdDays: 14})})
+
+	store.Record(forecast.MetricPools, time.Now().Add(-time.Hour), map[string]float64{
+		"cache.used_size": 50, "cache.total_size": 100,
+		"nvme.used_size": 10, "nvme.total_size": 100,
+	})
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v2/storage/forecast?kind=pool&name=cache", nil)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var report forecast.Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if report.ThresholdDays != 14 || len(report.Targets) != 1 {
+		t.Fatalf("Expected only the cache pool, got %+v", report)
+	}
+	// A single sample is too little history to project from
+	if target := report.Targets[0]; target.UsedPercent != 50 || target.Linear != nil || target.DaysUntilFull != nil {
+		t.Errorf("Unexpected target %+v", target)
+	}
+}
+
+// TestAlertsEndpoints tests listing alerts and managing silences
+func TestAlertsEndpoints(t *testing.T) {
+	server := newTestRESTServer()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/alerts", nil)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without an alert engine, got %d", rec.Code)
+	}
+
+	engine, err := alerts.NewEngine([]domain.AlertRule{
+		{Name: "Hot disk", Metric: "storage.disks", Field: "*.temperature", Operator: ">", Threshold: 50},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create alert engine: %v", err)
+	}
+	server.SetServices(Services{Alerts: engine})
+	engine.Observe("storage.disks", []storage.DiskState{{Name: "disk1", Temperature: 55}, {Name: "disk2", Temperature: 30}})
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v2/alerts/silences", strings.NewReader(`{"rule":"Hot disk","field":"disk1.*","duration":"1h"}`))
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var silence alerts.Silence
+	if err := json.Unmarshal(rec.Body.Bytes(), &silence); err != nil || silence.ID == "" || silence.CreatedBy != "api" {
+		t.Fatalf("Unexpected silence %+v (%v)", silence, err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v2/alerts?state=firing", nil)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	var list struct {
+		Alerts   []alerts.Alert   `json:"alerts"`
+		Silences []alerts.Silence `json:"silences"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(list.Alerts) != 1 || list.Alerts[0].Field != "disk1.temperature" || !list.Alerts[0].Silenced || len(list.Silences) != 1 {
+		t.Errorf("Expected the silenced disk1 alert, got %+v", list)
+	}
+
+	for body, status := range map[string]int{
+		`{"rule":"Hot disk"}`:                     http.StatusBadRequest,
+		`{"rule":"Cold disk","duration":"1h"}`:    http.StatusBadRequest,
+		`{"duration":"1h","ends_at":"yesterday"}`: http.StatusBadRequest,
+	} {
+		req = httptest.NewRequest(http.MethodPost, "/api/v2/alerts/silences", strings.NewReader(body))
+		rec = httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		if rec.Code != status {
+			t.Errorf("Expected status %d for %s, got %d", status, body, rec.Code)
+		}
+	}
+
+	for _, status := range []int{http.StatusOK, http.StatusNotFound} {
+		req = httptest.NewRequest(http.MethodDelete, "/api/v2/alerts/silences/"+silence.ID, nil)
+		rec = httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		if rec.Code != status {
+			t.Errorf("Expected status %d deleting the silence, got %d", status, rec.Code)
+		}
+	}
+}
+
+func TestNotificationsEndpoints(t *testing.T) {
+	server := newTestRESTServer()
+	manager := notifications.NewNotificationManagerWithStorage(t.TempDir())
+	server.SetServices(Services{Notifications: manager})
+
+	for i, body := range []string{
+		`{"title":"Disk hot","message":"disk1 at 55C","level":"warning","category":"storage"}`,
+		`{"title":"Backup done"}`,
+		`{"title":"Array stopped","level":"critical","category":"array"}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v2/notifications", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201 creating notification %d, got %d: %s", i, rec.Code, rec.Body.String())
+		}
+	}
+
+	for body, status := range map[string]int{
+		`{"message":"no title"}`:             http.StatusBadRequest,
+		`{"title":"t","level":"loud"}`:       http.StatusBadRequest,
+		`{"title":"t","category":"weather"}`: http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v2/notifications", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		if rec.Code != status {
+			t.Errorf("Expected status %d for %s, got %d", status, body, rec.Code)
+		}
+	}
+
+	var list struct {
+		Notifications []notifications.Notification `json:"notifications"`
+		Pagination    struct {
+			Page       int  `json:"page"`
+			TotalPages int  `json:"total_pages"`
+			TotalItems int  `json:"total_items"`
+			HasNext    bool `json:"has_next"`
+		} `json:"pagination"`
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/notifications?read=false&page=1&page_size=2", nil)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(list.Notifications) != 2 || list.Pagination.TotalItems != 3 || list.Pagination.TotalPages != 2 || !list.Pagination.HasNext {
+		t.Errorf("Expected the first page of 2 of 3 notifications, got %+v", list)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v2/notifications?level=critical", nil)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	list.Notifications = nil
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list.Notifications) != 1 || list.Notifications[0].Title != "Array stopped" {
+		t.Fatalf("Expected the critical notification, got %+v", list.Notifications)
+	}
+	id := list.Notifications[0].ID
+
+	req = httptest.NewRequest(http.MethodPatch, "/api/v2/notifications/"+id, strings.NewReader(`{"read":true}`))
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	var updated notifications.Notification
+	if err := json.Unmarshal(rec.Body.Bytes(), &updated); err != nil || rec.Code != http.StatusOK || !updated.Read || updated.Title != "Array stopped" {
+		t.Errorf("Expected the notification marked read, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v2/notifications/stats", nil)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	var stats struct {
+		Total  int `json:"total"`
+		Unread int `json:"unread"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil || stats.Total != 3 || stats.Unread != 2 {
+		t.Errorf("Expected 2 of 3 unread, got %+v (%v)", stats, err)
+	}
+
+	for query, status := range map[string]int{"read=maybe": 400, "page=0": 400, "page_size=1000": 400, "since=yesterday": 400, "since=-1h": 200} {
+		req = httptest.NewRequest(http.MethodGet, "/api/v2/notifications?"+query, nil)
+		rec = httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		if rec.Code != status {
+			t.Errorf("Expected status %d for %s, got %d", status, query, rec.Code)
+		}
+	}
+
+	for path, status := range map[string]int{"/api/v2/notifications/" + id: 200, "/api/v2/notifications/999": 404, "/api/v2/notifications/x/y": 400} {
+		req = httptest.NewRequest(http.MethodDelete, path, nil)
+		rec = httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		if rec.Code != status {
+			t.Errorf("Expected status %d deleting %s, got %d", status, path, rec.Code)
+		}
+	}
+}
+
+// TestOperationsEndpoints tests starting, listing and cancelling operations over REST
+func TestOperationsEndpoints(t *testing.T) {
+	manager := async.NewAsyncManager()
+	defer manager.Stop()
+	manager.RegisterExecutor(&blockingExecutor{operationType: async.TypeBulkContainer})
+
+	server := newTestRESTServer()
+	server.SetServices(Services{Async: manager})
+
+	// Start an operation
+	body := `{"type":"bulk_container","description":"Restart media stack","cancellable":true}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v2/operations", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var started OperationResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &started); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if started.OperationID == "" {
+		t.Fatal("Expected operation ID in response")
+	}
+	if location := rec.Header().Get("Location"); location != "/api/v2/operations/"+started.OperationID {
+		t.Errorf("Unexpected Location header: %s", location)
+	}
+
+	// Unknown operation types are rejected
+	req = httptest.NewRequest(http.MethodPost, "/api/v2/operations", strings.NewReader(`{"type":"disk_scan"}`))
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unregistered type, got %d", rec.Code)
+	}
+
+	// List with filters
+	req = httptest.NewRequest(http.MethodGet, "/api/v2/operations?type=bulk_container", nil)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	var list async.OperationListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Failed to decode list: %v", err)
+	}
+	if list.Total != 1 {
+		t.Errorf("Expected 1 operation, got %d", list.Total)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v2/operations?status=completed", nil)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	list = async.OperationListResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Failed to decode list: %v", err)
+	}
+	if list.Total != 0 {
+		t.Errorf("Expected no completed operations, got %d", list.Total)
+	}
+
+	// Cancel it, then cancelling again conflicts
+	req = httptest.NewRequest(http.MethodDelete, "/api/v2/operations/"+started.OperationID, nil)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 on cancel, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v2/operations/"+started.OperationID, nil)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 on second cancel, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v2/operations/does-not-exist", nil)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown operation, got %d", rec.Code)
+	}
+}
+
+// TestOperationsEndpointTypes tests that operations with their own admin
+// endpoints cannot be started through the generic one, even with an operator key
+func TestOperationsEndpointTypes(t *testing.T) {
+	manager := async.NewAsyncManager()
+	defer manager.Stop()
+	manager.RegisterExecutor(&blockingExecutor{operationType: async.TypeArrayStart})
+	manager.RegisterExecutor(&blockingExecutor{operationType: async.TypeSystemReboot})
+
+	server := newTestRESTServer()
+	server.SetServices(Services{Async: manager})
+
+	secret, key, err := auth.GenerateAPIKey("ha", auth.ScopeOperator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := middleware.Auth(auth.NewAuthenticator(domain.AuthConfig{Enabled: true, APIKeys: []domain.APIKey{key}}))(server)
+
+	for _, operationType := range []string{"array_start", "system_reboot"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v2/operations", strings.NewReader(`{"type":"`+operationType+`"}`))
+		req.Header.Set("Authorization", "Bearer "+secret)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400 for an operator key, got %d", operationType, rec.Code)
+		}
+	}
+	if list := manager.ListOperations("", ""); list.Total != 0 {
+		t.Errorf("Expected no operations started, got %d", list.Total)
+	}
+}
+
+// blockingExecutor runs until its operation is cancelled
+type blockingExecutor struct {
+	operationType async.OperationType
+}
+
+func (e *blockingExecutor) Execute(ctx context.Context, op *async.AsyncOperation, params map[string]interface{}) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (e *blockingExecutor) GetType() async.OperationType { return e.operationType }
+
+func (e *blockingExecutor) IsLongRunning() bool { return true }
+
+// TestServeHTTPHeaders tests the response time header and request ID echo
+func TestServeHTTPHeaders(t *testing.T) {
+	server := newTestRESTServer()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v2/system/reboot", nil)
+	req.Header.Set("X-Request-ID", "trace-123")
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	if rec.Header().Get("X-Response-Time") == "" {
+		t.Error("Expected X-Response-Time to be set before the body was written")
+	}
+
+	var result OperationResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result.RequestID != "trace-123" {
+		t.Errorf("Expected request ID to be echoed, got %q", result.RequestID)
+	}
+}
+
+// TestMCPToolsFromRoutes checks every tool maps onto its route and executes through the REST handlers
+func TestMCPToolsFromRoutes(t *testing.T) {
+	server := newTestRESTServer()
+
+	for _, route := range server.routes() {
+		for _, tool := range route.tools {
+			path := strings.NewReplacer("{container_id}", "web", "{vm_id}", "vm1", "{operation_id}", "op1").Replace(tool.Path)
+			_, pattern := server.mux.Handler(httptest.NewRequest(tool.Method, path, nil))
+			if pattern != route.pattern {
+				t.Errorf("Tool %s resolves to %q, expected %q", tool.Tool, pattern, route.pattern)
+			}
+		}
+	}
+
+	tools := server.tools.GetTools(context.Background())
+	byName := make(map[string]mcp.Tool, len(tools))
+	for _, tool := range tools {
+		byName[tool.Name] = tool
+	}
+	if !byName["list_containers"].Annotations.ReadOnlyHint || !byName["stop_array"].Annotations.DestructiveHint {
+		t.Error("Expected read-only and destructive annotations from the route table")
+	}
+	if _, ok := byName["start_array"].InputSchema.Properties["maintenance_mode"]; !ok {
+		t.Error("Expected start_array schema to be derived from ArrayStartRequest")
+	}
+
+	executor := newMockDockerEngine()
+	server.SetServices(Services{Docker: docker.NewDockerManagerWithClient(executor)})
+
+	result, err := server.tools.ExecuteTool(context.Background(), "stop_container", map[string]interface{}{
+		"container_id": "web",
+		"timeout":      float64(30),
+	})
+	if err != nil {
+		t.Fatalf("Failed to execute tool: %v", err)
+	}
+	if result.IsError || !executor.called("POST /containers/web/stop?t=30") {
+		t.Fatalf("Expected docker stop with timeout, got %+v (calls %v)", result, executor.calls)
+	}
+	if success, _ := result.StructuredContent.(map[string]interface{})["success"].(bool); !success {
+		t.Errorf("Expected structured operation result, got %v", result.StructuredContent)
+	}
+
+	// Operator keys cannot reach admin-only tools
+	ctx := auth.NewContext(context.Background(), domain.APIKey{Name: "ha", Scope: string(auth.ScopeOperator)})
+	if _, err := server.tools.ExecuteTool(ctx, "stop_array", nil); !errors.Is(err, mcp.ErrInsufficientScope) {
+		t.Errorf("Expected insufficient scope, got %v", err)
+	}
+}
+
+// TestMCPResources tests the resources and prompts served over MCP
+func TestMCPResources(t *testing.T) {
+	server := newTestRESTServer()
+	resources := server.mcpServer.Resources()
+
+	uris := make(map[string]bool)
+	for _, resource := range resources.List() {
+		uris[resource.URI] = true
+	}
+	for _, template := range resources.Templates() {
+		uris[template.URITemplate] = true
+	}
+	for _, uri := range []string{"uma://system/info", "uma://storage/disks/{name}/smart", "uma://containers/{id}/logs", "uma://logs/syslog"} {
+		if !uris[uri] {
+			t.Errorf("Expected MCP resource %s", uri)
+		}
+	}
+	if topic, _ := resources.Topic("uma://storage/disks/disk1/smart"); topic != "storage.disks" {
+		t.Errorf("Expected disk resources to follow the storage.disks collector, got %q", topic)
+	}
+
+	// Missing services are reported as read errors rather than panics
+	if _, err := resources.Read(context.Background(), "uma://containers/web/logs"); err == nil {
+		t.Error("Expected an error without a Docker manager")
+	}
+	if _, err := server.mcpServer.Prompts().Get(context.Background(), "diagnose_array_health", nil); err == nil {
+		t.Error("Expected an error without a diagnostics manager")
+	}
+
+	executor := newMockDockerEngine()
+	executor.responses["GET /containers/web/logs"] = "starting\nlistening on :80\n"
+	server.SetServices(Services{Docker: docker.NewDockerManagerWithClient(executor)})
+
+	result, err := resources.Read(context.Background(), "uma://containers/web/logs")
+	if err != nil {
+		t.Fatalf("Failed to read container logs: %v", err)
+	}
+	if got := result.Contents[0]; got.MimeType != "text/plain" || got.Text != "starting\nlistening on :80" {
+		t.Errorf("Unexpected container log contents: %+v", got)
+	}
+}
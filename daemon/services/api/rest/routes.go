@@ -0,0 +1,290 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/domalab/uma/daemon/services/api/types/requests"
+	"github.com/domalab/uma/daemon/services/mcp"
+)
+
+// route is a v2 endpoint pattern and the operations it exposes as MCP tools
+type route struct {
+	pattern string
+	handler http.HandlerFunc
+	tools   []mcp.Route
+}
+
+var (
+	containerIDParam = mcp.Param{Name: "container_id", Description: "Container ID or name"}
+	stopTimeoutParam = mcp.Param{Name: "timeout", Type: "integer", Description: "Seconds to wait before killing the container"}
+)
+
+// routes returns the v2 REST routes. registerRoutes mounts them on the mux
+// and the MCP tool registry is derived from the same table.
+func (rs *RESTServer) routes() []route {
+	return []route{
+		// System endpoints (4 total)
+		{"/api/v2/system/info", rs.handleSystemInfo, []mcp.Route{
+			{Tool: "get_system_info", Method: http.MethodGet, Path: "/api/v2/system/info",
+				Description: "Get hostname, CPU, memory and uptime"},
+		}},
+		{"/api/v2/system/health", rs.handleSystemHealth, []mcp.Route{
+			{Tool: "get_system_health", Method: http.MethodGet, Path: "/api/v2/system/health",
+				Description: "Check that the UMA daemon is healthy"},
+		}},
+		{"/api/v2/system/reboot", rs.handleSystemReboot, []mcp.Route{
+			{Tool: "reboot_system", Method: http.MethodPost, Path: "/api/v2/system/reboot",
+				Description: "Reboot the server", Body: requests.SystemRebootRequest{}, Destructive: true},
+		}},
+		{"/api/v2/system/shutdown", rs.handleSystemShutdown, []mcp.Route{
+			{Tool: "shutdown_system", Method: http.MethodPost, Path: "/api/v2/system/shutdown",
+				Description: "Power off the server", Body: requests.SystemShutdownRequest{}, Destructive: true},
+		}},
+
+		// Storage endpoints (5 total)
+		{"/api/v2/storage/config", rs.handleStorageConfig, []mcp.Route{
+			{Tool: "get_storage_config", Method: http.MethodGet, Path: "/api/v2/storage/config",
+				Description: "Get array state and the parity, data and cache disk configuration"},
+		}},
+		{"/api/v2/storage/layout", rs.handleStorageLayout, []mcp.Route{
+			{Tool: "get_storage_layout", Method: http.MethodGet, Path: "/api/v2/storage/layout",
+				Description: "Get array slot counts and disk assignments"},
+		}},
+		{"/api/v2/storage/array/start", rs.handleArrayStart, []mcp.Route{
+			{Tool: "start_array", Method: http.MethodPost, Path: "/api/v2/storage/array/start",
+				Description: "Start the Unraid array as an async operation and return its operation ID",
+				Body:        requests.ArrayStartRequest{}},
+		}},
+		{"/api/v2/storage/array/stop", rs.handleArrayStop, []mcp.Route{
+			{Tool: "stop_array", Method: http.MethodPost, Path: "/api/v2/storage/array/stop",
+				Description: "Stop the Unraid array as an async operation and return its operation ID",
+				Body:        requests.ArrayStopRequest{}, Destructive: true},
+		}},
+		{"/api/v2/storage/forecast", rs.handleStorageForecast, []mcp.Route{
+			{Tool: "get_storage_forecast", Method: http.MethodGet, Path: "/api/v2/storage/forecast",
+				Description: "Get the fill rate of the array, each disk and pool, and Docker storage and the days until each is projected to be full",
+				Params: []mcp.Param{
+					{Name: "kind", Description: "Only targets of this kind", Enum: []string{"array", "disk", "pool", "docker"}},
+					{Name: "name", Description: "Only the target with this name, such as cache or disk1"},
+				}},
+		}},
+
+		// Container endpoints (3 total)
+		{"/api/v2/containers/list", rs.handleContainersList, []mcp.Route{
+			{Tool: "list_containers", Method: http.MethodGet, Path: "/api/v2/containers/list",
+				Description: "List Docker containers with their image, state and ports"},
+		}},
+		{"/api/v2/containers/", rs.handleContainerAction, []mcp.Route{ // Handles /{id}/{start,stop,restart,pause,unpause,remove,stats,logs,exec}
+			{Tool: "get_container_stats", Method: http.MethodGet, Path: "/api/v2/containers/{container_id}/stats",
+				Description: "Get real-time CPU, memory, network and block I/O for a container",
+				Params:      []mcp.Param{containerIDParam}},
+			{Tool: "get_container_logs", Method: http.MethodGet, Path: "/api/v2/containers/{container_id}/logs",
+				Description: "Get a container's recent stdout and stderr lines, optionally filtered by a regular expression",
+				Params: []mcp.Param{containerIDParam,
+					{Name: "tail", Description: "Number of lines from the end, or all (default 100)"},
+					{Name: "since", Description: "Only lines after this time, as Unix seconds, RFC 3339 or relative such as -1h"},
+					{Name: "until", Description: "Only lines before this time, in the same formats as since"},
+					{Name: "timestamps", Type: "boolean", Description: "Include the time each line was written"},
+					{Name: "grep", Description: "Only lines matching this regular expression"},
+					{Name: "ignore_case", Type: "boolean", Description: "Match grep case-insensitively"},
+					{Name: "invert", Type: "boolean", Description: "Only lines not matching grep"}}},
+			{Tool: "start_container", Method: http.MethodPost, Path: "/api/v2/containers/{container_id}/start",
+				Description: "Start a stopped container",
+				Params:      []mcp.Param{containerIDParam}},
+			{Tool: "stop_container", Method: http.MethodPost, Path: "/api/v2/containers/{container_id}/stop",
+				Description: "Stop a running container",
+				Params:      []mcp.Param{containerIDParam, stopTimeoutParam}, Destructive: true},
+			{Tool: "restart_container", Method: http.MethodPost, Path: "/api/v2/containers/{container_id}/restart",
+				Description: "Restart a container",
+				Params:      []mcp.Param{containerIDParam, stopTimeoutParam}, Destructive: true},
+			{Tool: "pause_container", Method: http.MethodPost, Path: "/api/v2/containers/{container_id}/pause",
+				Description: "Pause all processes in a running container",
+				Params:      []mcp.Param{containerIDParam}},
+			{Tool: "unpause_container", Method: http.MethodPost, Path: "/api/v2/containers/{container_id}/unpause",
+				Description: "Resume a paused container",
+				Params:      []mcp.Param{containerIDParam}},
+			{Tool: "remove_container", Method: http.MethodPost, Path: "/api/v2/containers/{container_id}/remove",
+				Description: "Remove a container; running containers need force",
+				Params: []mcp.Param{containerIDParam,
+					{Name: "force", Type: "boolean", Description: "Remove even if the container is running"}},
+				Destructive: true},
+		}},
+
+		// VM endpoints (1 total)
+		{"/api/v2/vms/list", rs.handleVMsList, []mcp.Route{
+			{Tool: "list_vms", Method: http.MethodGet, Path: "/api/v2/vms/list",
+				Description: "List virtual machines with their state and resource allocation"},
+		}},
+
+		// UPS monitoring endpoints (1 total)
+		{"/api/v2/ups/status", rs.handleUPSStatus, []mcp.Route{
+			{Tool: "get_ups_status", Method: http.MethodGet, Path: "/api/v2/ups/status",
+				Description: "Get UPS status, load, battery charge and runtime"},
+		}},
+
+		// Hardware sensor endpoints (1 total)
+		{"/api/v2/system/sensors", rs.handleSystemSensors, []mcp.Route{
+			{Tool: "get_system_sensors", Method: http.MethodGet, Path: "/api/v2/system/sensors",
+				Description: "Get temperature, fan and voltage sensor readings"},
+		}},
+
+		// Network interface endpoints (1 total)
+		{"/api/v2/network/interfaces", rs.handleNetworkInterfaces, []mcp.Route{
+			{Tool: "list_network_interfaces", Method: http.MethodGet, Path: "/api/v2/network/interfaces",
+				Description: "List network interfaces with addresses and traffic counters"},
+		}},
+
+		// GPU monitoring endpoints (1 total)
+		{"/api/v2/system/gpu", rs.handleGPUStatus, []mcp.Route{
+			{Tool: "get_gpu_status", Method: http.MethodGet, Path: "/api/v2/system/gpu",
+				Description: "Get GPU utilization, memory and temperature"},
+		}},
+
+		// Unraid-specific endpoints (4 total)
+		{"/api/v2/shares", rs.handleShares, []mcp.Route{
+			{Tool: "list_shares", Method: http.MethodGet, Path: "/api/v2/shares",
+				Description: "List user shares with their size and usage"},
+		}},
+		{"/api/v2/storage/pools", rs.handleStoragePools, []mcp.Route{
+			{Tool: "list_storage_pools", Method: http.MethodGet, Path: "/api/v2/storage/pools",
+				Description: "List cache and other storage pools"},
+		}},
+		{"/api/v2/storage/usage", rs.handleStorageUsage, []mcp.Route{
+			{Tool: "get_storage_usage", Method: http.MethodGet, Path: "/api/v2/storage/usage",
+				Description: "Get used and free space across the array and pools"},
+		}},
+		{"/api/v2/logs", rs.handleLogs, []mcp.Route{
+			{Tool: "get_logs", Method: http.MethodGet, Path: "/api/v2/logs",
+				Description: "Get the most recent lines of a system log",
+				Params: []mcp.Param{
+					{Name: "type", Description: "Log to read", Enum: []string{"syslog", "docker"}},
+					{Name: "lines", Type: "integer", Description: "Number of lines to return (default 100)"},
+				}},
+		}},
+
+		// Priority 1 Critical Features (4 total)
+		{"/api/v2/storage/disks/smart", rs.handleDiskSMART, []mcp.Route{
+			{Tool: "get_disk_smart_data", Method: http.MethodGet, Path: "/api/v2/storage/disks/smart",
+				Description: "Get SMART health and temperature for all disks"},
+		}},
+		{"/api/v2/array/parity", rs.handleParityStatus, []mcp.Route{
+			{Tool: "get_parity_status", Method: http.MethodGet, Path: "/api/v2/array/parity",
+				Description: "Get parity check status and progress"},
+			{Tool: "control_parity_check", Method: http.MethodPost, Path: "/api/v2/array/parity",
+				Description: "Start, stop or pause a parity check",
+				Params: []mcp.Param{
+					{Name: "action", Required: true, Enum: []string{"start", "stop", "pause"}},
+				}},
+		}},
+		{"/api/v2/scripts", rs.handleUserScripts, []mcp.Route{
+			{Tool: "list_user_scripts", Method: http.MethodGet, Path: "/api/v2/scripts",
+				Description: "List User Scripts plugin scripts"},
+		}},
+
+		// Priority 2 Enhancements (2 total)
+		{"/api/v2/storage/disks/spindown", rs.handleDiskSpindown, []mcp.Route{
+			{Tool: "get_disk_spindown_status", Method: http.MethodGet, Path: "/api/v2/storage/disks/spindown",
+				Description: "Get which disks are spun down"},
+		}},
+		{"/api/v2/vms/stats/", rs.handleVMStats, []mcp.Route{ // Handles /{id}
+			{Tool: "get_vm_stats", Method: http.MethodGet, Path: "/api/v2/vms/stats/{vm_id}",
+				Description: "Get real-time CPU, memory, disk and network usage for a VM",
+				Params:      []mcp.Param{{Name: "vm_id", Description: "VM name or UUID"}}},
+		}},
+
+		// Metric history endpoints (1 total)
+		{"/api/v2/history", rs.handleHistory, []mcp.Route{
+			{Tool: "get_metric_history", Method: http.MethodGet, Path: "/api/v2/history",
+				Description: "Get the min, max and average of a collector metric over time, or list the recorded series when no field is given",
+				Params: []mcp.Param{
+					{Name: "metric", Description: "Collector name, such as system.cpu or storage.disks"},
+					{Name: "field", Description: "Dotted field path, such as cpu_percent or disks.disk1.temperature"},
+					{Name: "from", Description: "Start as Unix seconds, RFC 3339 or relative such as -24h (default one hour before to)"},
+					{Name: "to", Description: "End as Unix seconds, RFC 3339 or relative such as -1h (default now)"},
+					{Name: "step", Description: "Seconds or duration per point, such as 5m (default chosen from the range)"},
+				}},
+		}},
+
+		// Alert endpoints (3 total)
+		{"/api/v2/alerts", rs.handleAlerts, []mcp.Route{
+			{Tool: "list_alerts", Method: http.MethodGet, Path: "/api/v2/alerts",
+				Description: "List pending, firing and recently resolved alerts with the configured rules and silences",
+				Params: []mcp.Param{
+					{Name: "state", Description: "Only alerts in this state", Enum: []string{"pending", "firing", "resolved"}},
+				}},
+		}},
+		{"/api/v2/alerts/silences", rs.handleAlertSilences, []mcp.Route{
+			{Tool: "list_alert_silences", Method: http.MethodGet, Path: "/api/v2/alerts/silences",
+				Description: "List active and upcoming alert silences"},
+			{Tool: "create_alert_silence", Method: http.MethodPost, Path: "/api/v2/alerts/silences",
+				Description: "Silence notifications for alerts of a rule or field for a time window",
+				Body:        requests.AlertSilenceRequest{}},
+		}},
+		{"/api/v2/alerts/silences/", rs.handleAlertSilence, []mcp.Route{ // Handles /{id}
+			{Tool: "delete_alert_silence", Method: http.MethodDelete, Path: "/api/v2/alerts/silences/{silence_id}",
+				Description: "End an alert silence early",
+				Params:      []mcp.Param{{Name: "silence_id"}}},
+		}},
+
+		// Notification endpoints (4 total)
+		{"/api/v2/notifications", rs.handleNotifications, []mcp.Route{
+			{Tool: "list_notifications", Method: http.MethodGet, Path: "/api/v2/notifications",
+				Description: "List notifications newest first, including those imported from Unraid, a page at a time",
+				Params: []mcp.Param{
+					{Name: "level", Description: "Only notifications of this level", Enum: []string{"info", "warning", "error", "critical"}},
+					{Name: "category", Description: "Only notifications of this category",
+						Enum: []string{"system", "array", "docker", "vm", "storage", "network", "security", "custom"}},
+					{Name: "read", Type: "boolean", Description: "Only read or unread notifications"},
+					{Name: "persistent", Type: "boolean", Description: "Only persistent or transient notifications"},
+					{Name: "source", Description: "Only notifications from this source, such as uma or unraid"},
+					{Name: "since", Description: "Oldest as Unix seconds, RFC 3339 or relative such as -24h"},
+					{Name: "until", Description: "Newest as Unix seconds, RFC 3339 or relative such as -1h"},
+					{Name: "page", Type: "integer", Description: "Page number (default 1)"},
+					{Name: "page_size", Type: "integer", Description: "Notifications per page (default 50, at most 500)"},
+				}},
+			{Tool: "create_notification", Method: http.MethodPost, Path: "/api/v2/notifications",
+				Description: "Create a notification and deliver it to the configured channels",
+				Body:        requests.NotificationCreateRequest{}},
+			{Tool: "clear_notifications", Method: http.MethodDelete, Path: "/api/v2/notifications",
+				Description: "Delete every notification", Destructive: true},
+		}},
+		{"/api/v2/notifications/stats", rs.handleNotificationStats, []mcp.Route{
+			{Tool: "get_notification_stats", Method: http.MethodGet, Path: "/api/v2/notifications/stats",
+				Description: "Count notifications by read state, level and category"},
+		}},
+		{"/api/v2/notifications/read", rs.handleNotificationsRead, []mcp.Route{
+			{Tool: "mark_notifications_read", Method: http.MethodPost, Path: "/api/v2/notifications/read",
+				Description: "Mark every notification read, archiving those imported from Unraid"},
+		}},
+		{"/api/v2/notifications/", rs.handleNotification, []mcp.Route{ // Handles /{id}
+			{Tool: "get_notification", Method: http.MethodGet, Path: "/api/v2/notifications/{notification_id}",
+				Description: "Get a notification with its delivery status per channel",
+				Params:      []mcp.Param{{Name: "notification_id"}}},
+			{Tool: "update_notification", Method: http.MethodPatch, Path: "/api/v2/notifications/{notification_id}",
+				Description: "Change a notification's title, message, read or persistent flag; reading an Unraid notification archives it in Unraid",
+				Params:      []mcp.Param{{Name: "notification_id"}},
+				Body:        requests.NotificationUpdateRequest{}},
+			{Tool: "delete_notification", Method: http.MethodDelete, Path: "/api/v2/notifications/{notification_id}",
+				Description: "Delete a notification",
+				Params:      []mcp.Param{{Name: "notification_id"}}},
+		}},
+
+		// Async operation endpoints (2 total)
+		{"/api/v2/operations", rs.handleOperations, []mcp.Route{
+			{Tool: "list_operations", Method: http.MethodGet, Path: "/api/v2/operations",
+				Description: "List async operations such as array start and stop",
+				Params: []mcp.Param{
+					{Name: "status", Description: "Only operations in this status"},
+					{Name: "type", Description: "Only operations of this type"},
+				}},
+		}},
+		{"/api/v2/operations/", rs.handleOperation, []mcp.Route{ // Handles /{id}
+			{Tool: "get_operation", Method: http.MethodGet, Path: "/api/v2/operations/{operation_id}",
+				Description: "Get the progress and result of an async operation",
+				Params:      []mcp.Param{{Name: "operation_id"}}},
+			{Tool: "cancel_operation", Method: http.MethodDelete, Path: "/api/v2/operations/{operation_id}",
+				Description: "Cancel a pending or running async operation",
+				Params:      []mcp.Param{{Name: "operation_id"}}},
+		}},
+	}
+}
@@ -0,0 +1,13 @@
+package requests
+
+// Alert-related request types
+
+// AlertSilenceRequest represents a request to silence alert notifications
+type AlertSilenceRequest struct {
+	Rule     string `json:"rule,omitempty"`      // Alert rule name; every rule when empty
+	Field    string `json:"field,omitempty"`     // Field pattern such as disk1.*; every field when empty
+	Duration string `json:"duration,omitempty"`  // How long from starts_at, such as 2h, when ends_at is not given
+	StartsAt string `json:"starts_at,omitempty"` // RFC 3339, default now
+	EndsAt   string `json:"ends_at,omitempty"`   // RFC 3339
+	Comment  string `json:"comment,omitempty"`
+}
@@ -4,8 +4,9 @@ package requests
 
 // DockerContainerActionRequest represents a request to perform an action on a Docker container
 type DockerContainerActionRequest struct {
-	Action string `json:"action"` // "start", "stop", "restart", "pause", "unpause", "remove"
-	Force  bool   `json:"force,omitempty"`
+	Action  string `json:"action"` // "start", "stop", "restart", "pause", "unpause", "remove"
+	Force   bool   `json:"force,omitempty"`
+	Timeout int    `json:"timeout,omitempty"` // Seconds to wait before killing on stop/restart
 }
 
 // DockerBulkActionRequest represents a request to perform bulk actions on Docker containers
@@ -0,0 +1,20 @@
+package requests
+
+// Notification-related request types
+
+// NotificationCreateRequest represents a request to create a notification
+type NotificationCreateRequest struct {
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Level    string `json:"level,omitempty"`    // info (default), warning, error or critical
+	Category string `json:"category,omitempty"` // custom by default
+}
+
+// NotificationUpdateRequest represents a request to update a notification;
+// only the fields given are changed
+type NotificationUpdateRequest struct {
+	Title      *string `json:"title,omitempty"`
+	Message    *string `json:"message,omitempty"`
+	Read       *bool   `json:"read,omitempty"`
+	Persistent *bool   `json:"persistent,omitempty"`
+}
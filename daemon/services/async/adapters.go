@@ -48,6 +48,16 @@ func (a *StorageMonitorAdapter) CancelParityCheck() error {
 	return a.monitor.CancelParityCheck()
 }
 
+// StartArray starts the array, forwarding orchestration progress
+func (a *StorageMonitorAdapter) StartArray(maintenanceMode, checkFilesystem bool, progress func(int, string)) error {
+	return a.monitor.StartArrayWithProgress(maintenanceMode, checkFilesystem, progress)
+}
+
+// StopArray stops the array, forwarding orchestration progress
+func (a *StorageMonitorAdapter) StopArray(force, unmountShares, stopContainers, stopVMs bool, progress func(int, string)) error {
+	return a.monitor.StopArrayWithProgress(force, unmountShares, stopContainers, stopVMs, progress)
+}
+
 // DockerManagerAdapter adapts the docker.DockerManager to the async interface
 type DockerManagerAdapter struct {
 	manager *docker.DockerManager
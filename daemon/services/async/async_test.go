@@ -2,6 +2,7 @@ package async
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"testing"
 	"time"
@@ -172,6 +173,10 @@ func TestAsyncManager_ConflictingOperations(t *testing.T) {
 	if !strings.Contains(err.Error(), "conflicting operation") {
 		t.Errorf("Expected error to mention conflicting operation, got: %v", err)
 	}
+
+	if !errors.Is(err, ErrOperationConflict) {
+		t.Errorf("Expected ErrOperationConflict, got: %v", err)
+	}
 }
 
 func TestAsyncManager_ListOperations(t *testing.T) {
@@ -334,3 +339,119 @@ func TestAsyncManager_Stats(t *testing.T) {
 		t.Error("Expected by_type in stats")
 	}
 }
+
+// MockStorageMonitor records array calls and replays orchestration progress
+type MockStorageMonitor struct {
+	startErr    error
+	lastForce   bool
+	lastStopVMs bool
+}
+
+func (m *MockStorageMonitor) StartParityCheck(checkType string, priority string) error { return nil }
+
+func (m *MockStorageMonitor) GetParityCheckStatus() (map[string]interface{}, error) {
+	return map[string]interface{}{"active": false}, nil
+}
+
+func (m *MockStorageMonitor) CancelParityCheck() error { return nil }
+
+func (m *MockStorageMonitor) StartArray(maintenanceMode, checkFilesystem bool, progress func(int, string)) error {
+	progress(40, "waiting for array to start")
+	return m.startErr
+}
+
+func (m *MockStorageMonitor) StopArray(force, unmountShares, stopContainers, stopVMs bool, progress func(int, string)) error {
+	m.lastForce = force
+	m.lastStopVMs = stopVMs
+	progress(60, "unmounting array disks")
+	return nil
+}
+
+func TestArrayOperationExecutor(t *testing.T) {
+	monitor := &MockStorageMonitor{}
+
+	t.Run("start reports progress and completes", func(t *testing.T) {
+		op := &AsyncOperation{ID: "start", Status: StatusRunning}
+		var steps []int
+		op.SetProgressCallback(func(progress int) { steps = append(steps, progress) })
+
+		err := NewArrayStartExecutor(monitor).Execute(context.Background(), op, map[string]interface{}{"maintenance_mode": true})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		safe := op.ToSafeOperation()
+		if safe.Status != StatusCompleted || safe.Progress != 100 {
+			t.Errorf("Expected completed at 100%%, got %s at %d%%", safe.Status, safe.Progress)
+		}
+		if safe.Step != "waiting for array to start" {
+			t.Errorf("Expected last step to be recorded, got %q", safe.Step)
+		}
+		if len(steps) == 0 || steps[0] != 40 {
+			t.Errorf("Expected progress callback with 40, got %v", steps)
+		}
+		if safe.Result["maintenance_mode"] != true {
+			t.Errorf("Expected maintenance_mode in result, got %v", safe.Result)
+		}
+	})
+
+	t.Run("stop passes options", func(t *testing.T) {
+		op := &AsyncOperation{ID: "stop", Status: StatusRunning}
+
+		err := NewArrayStopExecutor(monitor).Execute(context.Background(), op, map[string]interface{}{"force": true, "stop_vms": true})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !monitor.lastForce || !monitor.lastStopVMs {
+			t.Error("Expected force and stop_vms to be forwarded to the storage monitor")
+		}
+	})
+
+	t.Run("start failure is returned", func(t *testing.T) {
+		failing := &MockStorageMonitor{startErr: errors.New("mdcmd failed")}
+		op := &AsyncOperation{ID: "fail", Status: StatusRunning}
+
+		if err := NewArrayStartExecutor(failing).Execute(context.Background(), op, nil); err == nil {
+			t.Error("Expected error from failing array start")
+		}
+	})
+}
+
+func TestAsyncManager_Listeners(t *testing.T) {
+	manager := NewAsyncManager()
+	defer manager.Stop()
+
+	manager.RegisterExecutor(&MockExecutor{
+		operationType: TypeSMARTScan,
+		duration:      50 * time.Millisecond,
+	})
+
+	events := make(chan OperationEvent, 10)
+	manager.AddListener(func(event OperationEvent) {
+		events <- event
+	})
+
+	operation, err := manager.StartOperation(OperationRequest{Type: TypeSMARTScan}, "test-user")
+	if err != nil {
+		t.Fatalf("Failed to start operation: %v", err)
+	}
+
+	var seen []string
+	timeout := time.After(2 * time.Second)
+	for len(seen) == 0 || seen[len(seen)-1] != string(StatusCompleted) {
+		select {
+		case event := <-events:
+			if event.Operation.ID != operation.ID {
+				t.Errorf("Expected events for %s, got %s", operation.ID, event.Operation.ID)
+			}
+			seen = append(seen, event.Event)
+		case <-timeout:
+			t.Fatalf("Timed out waiting for completion event, saw %v", seen)
+		}
+	}
+
+	expected := []string{string(StatusRunning), "progress", string(StatusCompleted)}
+	if strings.Join(seen, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected events %v, got %v", expected, seen)
+	}
+}
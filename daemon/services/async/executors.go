@@ -18,6 +18,8 @@ type StorageMonitorInterface interface {
 	StartParityCheck(checkType string, priority string) error
 	GetParityCheckStatus() (map[string]interface{}, error)
 	CancelParityCheck() error
+	StartArray(maintenanceMode, checkFilesystem bool, progress func(int, string)) error
+	StopArray(force, unmountShares, stopContainers, stopVMs bool, progress func(int, string)) error
 }
 
 // NewParityCheckExecutor creates a new parity check executor
@@ -140,73 +142,55 @@ func (e *ArrayOperationExecutor) executeArrayStart(ctx context.Context, op *Asyn
 	// Extract parameters
 	maintenanceMode, _ := params["maintenance_mode"].(bool)
 	checkFilesystem, _ := params["check_filesystem"].(bool)
-	
+
 	logger.Blue("Starting array (maintenance: %v, check_fs: %v)", maintenanceMode, checkFilesystem)
-	
-	op.UpdateProgress(10)
-	
-	// This would call the actual storage monitor method
-	// For now, simulate the operation
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-time.After(2 * time.Second):
-		op.UpdateProgress(50)
+
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-	
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-time.After(3 * time.Second):
-		op.UpdateProgress(100)
+
+	// mdcmd cannot be interrupted once issued, so the operation runs to completion
+	if err := e.storageMonitor.StartArray(maintenanceMode, checkFilesystem, op.UpdateStep); err != nil {
+		return err
 	}
-	
+
 	result := map[string]interface{}{
-		"array_status":      "started",
-		"maintenance_mode":  maintenanceMode,
-		"check_filesystem":  checkFilesystem,
-		"completed_at":      time.Now(),
+		"array_status":     "started",
+		"maintenance_mode": maintenanceMode,
+		"check_filesystem": checkFilesystem,
+		"completed_at":     time.Now(),
 	}
-	
+
 	op.SetCompleted(result)
 	return nil
 }
 
 // executeArrayStop executes array stop operation
 func (e *ArrayOperationExecutor) executeArrayStop(ctx context.Context, op *AsyncOperation, params map[string]interface{}) error {
-	logger.Blue("Stopping array")
-	
-	op.UpdateProgress(10)
-	
-	// Simulate stopping containers and VMs first
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-time.After(5 * time.Second):
-		op.UpdateProgress(30)
-	}
-	
-	// Simulate unmounting shares
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-time.After(3 * time.Second):
-		op.UpdateProgress(60)
+	// Extract parameters
+	force, _ := params["force"].(bool)
+	unmountShares, _ := params["unmount_shares"].(bool)
+	stopContainers, _ := params["stop_containers"].(bool)
+	stopVMs, _ := params["stop_vms"].(bool)
+
+	logger.Blue("Stopping array (force: %v, stop_containers: %v, stop_vms: %v)", force, stopContainers, stopVMs)
+
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-	
-	// Simulate stopping array
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-time.After(2 * time.Second):
-		op.UpdateProgress(100)
+
+	if err := e.storageMonitor.StopArray(force, unmountShares, stopContainers, stopVMs, op.UpdateStep); err != nil {
+		return err
 	}
-	
+
 	result := map[string]interface{}{
-		"array_status": "stopped",
-		"completed_at": time.Now(),
+		"array_status":    "stopped",
+		"force":           force,
+		"stop_containers": stopContainers,
+		"stop_vms":        stopVMs,
+		"completed_at":    time.Now(),
 	}
-	
+
 	op.SetCompleted(result)
 	return nil
 }
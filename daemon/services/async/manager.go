@@ -2,7 +2,9 @@ package async
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -10,12 +12,35 @@ import (
 	"github.com/google/uuid"
 )
 
+var (
+	// ErrOperationConflict is returned when a conflicting operation is already active
+	ErrOperationConflict = errors.New("conflicting operation already running")
+
+	// ErrUnknownOperationType is returned when no executor is registered for a type
+	ErrUnknownOperationType = errors.New("no executor registered for operation type")
+
+	// ErrOperationLimit is returned when the maximum number of tracked operations is reached
+	ErrOperationLimit = errors.New("maximum number of operations reached")
+
+	// ErrOperationNotFound is returned when an operation ID is unknown
+	ErrOperationNotFound = errors.New("operation not found")
+
+	// ErrNotCancellable is returned when an operation cannot be cancelled
+	ErrNotCancellable = errors.New("operation cannot be cancelled")
+)
+
 // AsyncManager manages asynchronous operations
 type AsyncManager struct {
 	operations map[string]*AsyncOperation
 	executors  map[OperationType]OperationExecutor
+	listeners  []func(OperationEvent)
 	mutex      sync.RWMutex
 
+	// Persistence; persistMutex orders journal appends against compaction
+	store        OperationStore
+	retention    RetentionPolicy
+	persistMutex sync.Mutex
+
 	// Configuration
 	maxOperations    int
 	cleanupInterval  time.Duration
@@ -26,23 +51,82 @@ type AsyncManager struct {
 	cleanupWG   sync.WaitGroup
 }
 
-// NewAsyncManager creates a new async manager
+// NewAsyncManager creates a new async manager that keeps history in memory only
 func NewAsyncManager() *AsyncManager {
+	return NewAsyncManagerWithStore(nil, DefaultRetentionPolicy())
+}
+
+// NewAsyncManagerWithStore creates an async manager that persists history to store
+// and reloads it, applying retention to finished operations
+func NewAsyncManagerWithStore(store OperationStore, retention RetentionPolicy) *AsyncManager {
 	manager := &AsyncManager{
 		operations:       make(map[string]*AsyncOperation),
 		executors:        make(map[OperationType]OperationExecutor),
+		store:            store,
+		retention:        retention,
 		maxOperations:    100, // Maximum concurrent operations
 		cleanupInterval:  5 * time.Minute,
 		operationTimeout: 30 * time.Minute,
 		stopCleanup:      make(chan struct{}),
 	}
 
+	if store != nil {
+		manager.restoreHistory()
+	}
+
 	// Start cleanup goroutine
 	manager.startCleanup()
 
 	return manager
 }
 
+// restoreHistory reloads persisted operations, failing any that a restart interrupted
+func (am *AsyncManager) restoreHistory() {
+	records, err := am.store.Load()
+	if err != nil {
+		logger.Yellow("Failed to load async operation history: %v", err)
+		return
+	}
+
+	var interrupted int
+	now := time.Now()
+
+	am.mutex.Lock()
+	for _, record := range records {
+		if record.Status == StatusPending || record.Status == StatusRunning {
+			record.Status = StatusFailed
+			record.Error = "interrupted by daemon restart"
+			record.Completed = &now
+			interrupted++
+		}
+		am.operations[record.ID] = fromSafeOperation(record)
+	}
+	am.mutex.Unlock()
+
+	logger.Blue("Restored %d async operations from history (%d interrupted)", len(records), interrupted)
+
+	// Apply retention and rewrite the journal so interrupted operations are recorded as failed
+	am.cleanup()
+}
+
+// fromSafeOperation rebuilds an inactive operation from a persisted record
+func fromSafeOperation(record SafeAsyncOperation) *AsyncOperation {
+	return &AsyncOperation{
+		ID:          record.ID,
+		Type:        record.Type,
+		Status:      record.Status,
+		Progress:    record.Progress,
+		Step:        record.Step,
+		Started:     record.Started,
+		Completed:   record.Completed,
+		Error:       record.Error,
+		Result:      record.Result,
+		Cancellable: record.Cancellable,
+		Description: record.Description,
+		CreatedBy:   record.CreatedBy,
+	}
+}
+
 // RegisterExecutor registers an operation executor
 func (am *AsyncManager) RegisterExecutor(executor OperationExecutor) {
 	am.mutex.Lock()
@@ -60,12 +144,12 @@ func (am *AsyncManager) StartOperation(req OperationRequest, createdBy string) (
 	// Check if we have an executor for this operation type
 	executor, exists := am.executors[req.Type]
 	if !exists {
-		return nil, fmt.Errorf("no executor registered for operation type: %s", req.Type)
+		return nil, fmt.Errorf("%w: %s", ErrUnknownOperationType, req.Type)
 	}
 
-	// Check operation limits
-	if len(am.operations) >= am.maxOperations {
-		return nil, fmt.Errorf("maximum number of operations (%d) reached", am.maxOperations)
+	// Check operation limits (finished history does not count)
+	if am.activeCount() >= am.maxOperations {
+		return nil, fmt.Errorf("%w (%d)", ErrOperationLimit, am.maxOperations)
 	}
 
 	// Check for conflicting operations (e.g., only one parity check at a time)
@@ -92,6 +176,8 @@ func (am *AsyncManager) StartOperation(req OperationRequest, createdBy string) (
 		ctx:         ctx,
 		cancel:      cancel,
 	}
+	operation.onProgress = func(int) { am.notify("progress", operation) }
+	operation.onComplete = func(error) { am.notify(string(operation.GetSafeStatus()), operation) }
 
 	// Store operation
 	am.operations[operationID] = operation
@@ -104,6 +190,60 @@ func (am *AsyncManager) StartOperation(req OperationRequest, createdBy string) (
 	return operation, nil
 }
 
+// AddListener registers a callback for operation lifecycle and progress events.
+// Listeners are called from the operation's goroutine and must not block.
+func (am *AsyncManager) AddListener(listener func(OperationEvent)) {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	am.listeners = append(am.listeners, listener)
+}
+
+// notify persists lifecycle changes and delivers the event to all registered listeners
+func (am *AsyncManager) notify(event string, operation *AsyncOperation) {
+	am.mutex.RLock()
+	listeners := make([]func(OperationEvent), len(am.listeners))
+	copy(listeners, am.listeners)
+	am.mutex.RUnlock()
+
+	if len(listeners) == 0 && (am.store == nil || event == "progress") {
+		return
+	}
+
+	// Progress is not journaled; lifecycle transitions are enough to explain what happened
+	persist := am.store != nil && event != "progress"
+	if persist {
+		am.persistMutex.Lock()
+	}
+
+	operationEvent := OperationEvent{
+		Event:     event,
+		Operation: operation.ToSafeOperation(),
+	}
+
+	if persist {
+		if err := am.store.Save(operationEvent.Operation); err != nil {
+			logger.Yellow("Failed to persist async operation %s: %v", operation.ID, err)
+		}
+		am.persistMutex.Unlock()
+	}
+
+	for _, listener := range listeners {
+		listener(operationEvent)
+	}
+}
+
+// activeCount returns the number of pending or running operations (caller holds the lock)
+func (am *AsyncManager) activeCount() int {
+	count := 0
+	for _, op := range am.operations {
+		if op.IsActive() {
+			count++
+		}
+	}
+	return count
+}
+
 // GetOperation retrieves an operation by ID
 func (am *AsyncManager) GetOperation(operationID string) (*AsyncOperation, error) {
 	am.mutex.RLock()
@@ -111,7 +251,7 @@ func (am *AsyncManager) GetOperation(operationID string) (*AsyncOperation, error
 
 	operation, exists := am.operations[operationID]
 	if !exists {
-		return nil, fmt.Errorf("operation not found: %s", operationID)
+		return nil, fmt.Errorf("%w: %s", ErrOperationNotFound, operationID)
 	}
 
 	return operation, nil
@@ -122,24 +262,25 @@ func (am *AsyncManager) ListOperations(status OperationStatus, operationType Ope
 	am.mutex.RLock()
 	defer am.mutex.RUnlock()
 
-	var filteredOps []SafeAsyncOperation
+	filteredOps := make([]SafeAsyncOperation, 0)
 	var active, completed, failed int
 
 	for _, op := range am.operations {
+		// Work on a safe copy to avoid racing with running operations
+		safeCopy := op.ToSafeOperation()
+
 		// Apply filters
-		if status != "" && op.Status != status {
+		if status != "" && safeCopy.Status != status {
 			continue
 		}
-		if operationType != "" && op.Type != operationType {
+		if operationType != "" && safeCopy.Type != operationType {
 			continue
 		}
 
-		// Create a safe copy to avoid race conditions
-		safeCopy := op.ToSafeOperation()
 		filteredOps = append(filteredOps, safeCopy)
 
 		// Count by status
-		switch op.Status {
+		switch safeCopy.Status {
 		case StatusPending, StatusRunning:
 			active++
 		case StatusCompleted:
@@ -149,6 +290,11 @@ func (am *AsyncManager) ListOperations(status OperationStatus, operationType Ope
 		}
 	}
 
+	// Newest first, so restored history reads naturally
+	sort.Slice(filteredOps, func(i, j int) bool {
+		return filteredOps[i].Started.After(filteredOps[j].Started)
+	})
+
 	return &OperationListResponse{
 		Operations: filteredOps,
 		Total:      len(filteredOps),
@@ -165,12 +311,12 @@ func (am *AsyncManager) CancelOperation(operationID string) error {
 	am.mutex.RUnlock()
 
 	if !exists {
-		return fmt.Errorf("operation not found: %s", operationID)
+		return fmt.Errorf("%w: %s", ErrOperationNotFound, operationID)
 	}
 
 	if !operation.Cancel() {
-		return fmt.Errorf("operation cannot be cancelled (status: %s, cancellable: %v)",
-			operation.Status, operation.Cancellable)
+		return fmt.Errorf("%w (status: %s, cancellable: %v)",
+			ErrNotCancellable, operation.GetSafeStatus(), operation.Cancellable)
 	}
 
 	logger.Yellow("Cancelled async operation %s (%s)", operationID, operation.Type)
@@ -188,6 +334,7 @@ func (am *AsyncManager) executeOperation(operation *AsyncOperation, executor Ope
 
 	// Mark as running
 	operation.SetRunning()
+	am.notify(string(StatusRunning), operation)
 
 	// Execute the operation
 	err := executor.Execute(operation.GetContext(), operation, params)
@@ -230,7 +377,7 @@ func (am *AsyncManager) checkConflicts(operationType OperationType) error {
 		if op.IsActive() {
 			for _, conflictType := range conflictTypes {
 				if op.Type == conflictType {
-					return fmt.Errorf("conflicting operation already running: %s (%s)", op.ID, op.Type)
+					return fmt.Errorf("%w: %s (%s)", ErrOperationConflict, op.ID, op.Type)
 				}
 			}
 		}
@@ -259,24 +406,59 @@ func (am *AsyncManager) startCleanup() {
 	}()
 }
 
-// cleanup removes old completed operations
+// cleanup applies the retention policy to finished operations and compacts the store
 func (am *AsyncManager) cleanup() {
+	if am.store != nil {
+		am.persistMutex.Lock()
+		defer am.persistMutex.Unlock()
+	}
+
 	am.mutex.Lock()
-	defer am.mutex.Unlock()
 
-	cutoff := time.Now().Add(-24 * time.Hour) // Keep operations for 24 hours
-	var removed int
+	var finished []*AsyncOperation
+	for _, op := range am.operations {
+		if !op.IsActive() {
+			finished = append(finished, op)
+		}
+	}
+
+	// Newest first, so the count limit keeps the most recent history
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].Started.After(finished[j].Started)
+	})
 
-	for id, op := range am.operations {
-		if !op.IsActive() && op.Started.Before(cutoff) {
-			delete(am.operations, id)
+	var removed int
+	cutoff := time.Now().Add(-am.retention.MaxAge)
+	for i, op := range finished {
+		expired := am.retention.MaxAge > 0 && op.Started.Before(cutoff)
+		overflow := am.retention.MaxCount > 0 && i >= am.retention.MaxCount
+		if expired || overflow {
+			delete(am.operations, op.ID)
 			removed++
 		}
 	}
 
+	var records []SafeAsyncOperation
+	if am.store != nil {
+		records = make([]SafeAsyncOperation, 0, len(am.operations))
+		for _, op := range am.operations {
+			records = append(records, op.ToSafeOperation())
+		}
+	}
+	am.mutex.Unlock()
+
 	if removed > 0 {
 		logger.Blue("Cleaned up %d old async operations", removed)
 	}
+
+	if am.store != nil {
+		sort.Slice(records, func(i, j int) bool {
+			return records[i].Started.Before(records[j].Started)
+		})
+		if err := am.store.Compact(records); err != nil {
+			logger.Yellow("Failed to compact async operation history: %v", err)
+		}
+	}
 }
 
 // Stop stops the async manager
@@ -284,15 +466,19 @@ func (am *AsyncManager) Stop() {
 	close(am.stopCleanup)
 	am.cleanupWG.Wait()
 
-	// Cancel all active operations
-	am.mutex.Lock()
-	defer am.mutex.Unlock()
-
+	// Interrupt all active operations outside the lock, since interruption notifies listeners
+	am.mutex.RLock()
+	var active []*AsyncOperation
 	for _, op := range am.operations {
 		if op.IsActive() {
-			op.Cancel()
+			active = append(active, op)
 		}
 	}
+	am.mutex.RUnlock()
+
+	for _, op := range active {
+		op.Interrupt("interrupted by daemon shutdown")
+	}
 
 	logger.Blue("Async manager stopped")
 }
@@ -313,7 +499,7 @@ func (am *AsyncManager) GetStats() map[string]interface{} {
 	typeCounts := make(map[string]int)
 
 	for _, op := range am.operations {
-		statusCounts[string(op.Status)]++
+		statusCounts[string(op.GetSafeStatus())]++
 		typeCounts[string(op.Type)]++
 	}
 
@@ -0,0 +1,157 @@
+package async
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// OperationStore persists operation records so history survives daemon restarts
+type OperationStore interface {
+	// Save records the latest state of an operation
+	Save(op SafeAsyncOperation) error
+	// Load returns the latest state of every recorded operation
+	Load() ([]SafeAsyncOperation, error)
+	// Compact replaces the stored history with exactly the given records
+	Compact(ops []SafeAsyncOperation) error
+}
+
+// RetentionPolicy bounds how much finished operation history is kept
+type RetentionPolicy struct {
+	MaxCount int           // Maximum finished operations to keep, 0 for unlimited
+	MaxAge   time.Duration // Maximum age of finished operations, 0 for unlimited
+}
+
+// DefaultRetentionPolicy matches the in-memory behaviour of keeping a day of history
+func DefaultRetentionPolicy() RetentionPolicy {
+	return RetentionPolicy{
+		MaxAge: 24 * time.Hour,
+	}
+}
+
+// JournalStore is an OperationStore backed by an append-only JSON-lines file.
+// Every Save appends a full snapshot; on Load the last line for each ID wins.
+type JournalStore struct {
+	path  string
+	mutex sync.Mutex
+}
+
+// NewJournalStore creates a journal store writing to path
+func NewJournalStore(path string) *JournalStore {
+	return &JournalStore{path: path}
+}
+
+// Save appends the operation snapshot to the journal
+func (s *JournalStore) Save(op SafeAsyncOperation) error {
+	line, err := json.Marshal(op)
+	if err != nil {
+		return fmt.Errorf("failed to marshal operation %s: %w", op.ID, err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to append to journal: %w", err)
+	}
+
+	return nil
+}
+
+// Load reads the journal and returns the latest snapshot of each operation, oldest first
+func (s *JournalStore) Load() ([]SafeAsyncOperation, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	file, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer file.Close()
+
+	latest := make(map[string]SafeAsyncOperation)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	for scanner.Scan() {
+		var op SafeAsyncOperation
+		if err := json.Unmarshal(scanner.Bytes(), &op); err != nil || op.ID == "" {
+			// A torn final write from a crash must not prevent loading the rest
+			continue
+		}
+		latest[op.ID] = op
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	ops := make([]SafeAsyncOperation, 0, len(latest))
+	for _, op := range latest {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i].Started.Before(ops[j].Started)
+	})
+
+	return ops, nil
+}
+
+// Compact atomically rewrites the journal with one line per operation
+func (s *JournalStore) Compact(ops []SafeAsyncOperation) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	tmpPath := s.path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create compacted journal: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, op := range ops {
+		if err := encoder.Encode(op); err != nil {
+			file.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to write operation %s: %w", op.ID, err)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to flush compacted journal: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close compacted journal: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace journal: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package async
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJournalStore_SaveAndLoad(t *testing.T) {
+	store := NewJournalStore(filepath.Join(t.TempDir(), "uma", "operations.jsonl"))
+
+	started := time.Now().Add(-time.Minute)
+	if err := store.Save(SafeAsyncOperation{ID: "op-1", Type: TypeParityCheck, Status: StatusRunning, Started: started}); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+	if err := store.Save(SafeAsyncOperation{ID: "op-2", Type: TypeSMARTScan, Status: StatusRunning, Started: started.Add(time.Second)}); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+	if err := store.Save(SafeAsyncOperation{ID: "op-1", Type: TypeParityCheck, Status: StatusCompleted, Started: started}); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+
+	ops, err := store.Load()
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+
+	if len(ops) != 2 {
+		t.Fatalf("Expected 2 operations, got %d", len(ops))
+	}
+	if ops[0].ID != "op-1" || ops[0].Status != StatusCompleted {
+		t.Errorf("Expected latest snapshot of op-1 first, got %s (%s)", ops[0].ID, ops[0].Status)
+	}
+}
+
+func TestJournalStore_LoadSkipsTornLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "operations.jsonl")
+	content := `{"id":"op-1","type":"smart_scan","status":"completed","started":"2024-06-21T10:00:00Z"}` + "\n" + `{"id":"op-2","ty`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write journal: %v", err)
+	}
+
+	ops, err := NewJournalStore(path).Load()
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+	if len(ops) != 1 || ops[0].ID != "op-1" {
+		t.Errorf("Expected only op-1 to load, got %+v", ops)
+	}
+}
+
+func TestJournalStore_MissingFile(t *testing.T) {
+	ops, err := NewJournalStore(filepath.Join(t.TempDir(), "missing.jsonl")).Load()
+	if err != nil {
+		t.Fatalf("Expected no error for missing journal, got %v", err)
+	}
+	if len(ops) != 0 {
+		t.Errorf("Expected no operations, got %d", len(ops))
+	}
+}
+
+func TestAsyncManager_RestoresHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "operations.jsonl")
+	store := NewJournalStore(path)
+
+	now := time.Now()
+	store.Save(SafeAsyncOperation{ID: "interrupted", Type: TypeParityCheck, Status: StatusRunning, Started: now.Add(-time.Hour), CreatedBy: "automation"})
+	store.Save(SafeAsyncOperation{ID: "finished", Type: TypeSMARTScan, Status: StatusCompleted, Started: now.Add(-2 * time.Hour)})
+	store.Save(SafeAsyncOperation{ID: "expired", Type: TypeSMARTScan, Status: StatusCompleted, Started: now.Add(-48 * time.Hour)})
+
+	manager := NewAsyncManagerWithStore(store, RetentionPolicy{MaxAge: 24 * time.Hour})
+	defer manager.Stop()
+
+	op, err := manager.GetOperation("interrupted")
+	if err != nil {
+		t.Fatalf("Expected interrupted operation to be restored: %v", err)
+	}
+	safe := op.ToSafeOperation()
+	if safe.Status != StatusFailed || !strings.Contains(safe.Error, "restart") {
+		t.Errorf("Expected interrupted operation to be failed with a restart reason, got %s: %q", safe.Status, safe.Error)
+	}
+	if safe.CreatedBy != "automation" {
+		t.Errorf("Expected creator to be preserved, got %q", safe.CreatedBy)
+	}
+
+	if _, err := manager.GetOperation("finished"); err != nil {
+		t.Errorf("Expected finished operation to be restored: %v", err)
+	}
+	if _, err := manager.GetOperation("expired"); err == nil {
+		t.Error("Expected expired operation to be dropped by retention")
+	}
+
+	// The journal is compacted with the interrupted operation recorded as failed
+	ops, err := store.Load()
+	if err != nil {
+		t.Fatalf("Failed to reload journal: %v", err)
+	}
+	if len(ops) != 2 {
+		t.Fatalf("Expected 2 operations after compaction, got %d", len(ops))
+	}
+	for _, record := range ops {
+		if record.ID == "interrupted" && record.Status != StatusFailed {
+			t.Errorf("Expected journal to record interrupted operation as failed, got %s", record.Status)
+		}
+	}
+}
+
+func TestAsyncManager_RetentionByCount(t *testing.T) {
+	store := NewJournalStore(filepath.Join(t.TempDir(), "operations.jsonl"))
+
+	now := time.Now()
+	for i, id := range []string{"oldest", "middle", "newest"} {
+		store.Save(SafeAsyncOperation{ID: id, Type: TypeSMARTScan, Status: StatusCompleted, Started: now.Add(time.Duration(i) * time.Minute)})
+	}
+
+	manager := NewAsyncManagerWithStore(store, RetentionPolicy{MaxCount: 2})
+	defer manager.Stop()
+
+	if _, err := manager.GetOperation("oldest"); err == nil {
+		t.Error("Expected oldest operation to be dropped by count retention")
+	}
+	for _, id := range []string{"middle", "newest"} {
+		if _, err := manager.GetOperation(id); err != nil {
+			t.Errorf("Expected %s to be kept: %v", id, err)
+		}
+	}
+}
+
+func TestAsyncManager_PersistsLifecycle(t *testing.T) {
+	store := NewJournalStore(filepath.Join(t.TempDir(), "operations.jsonl"))
+	manager := NewAsyncManagerWithStore(store, DefaultRetentionPolicy())
+
+	manager.RegisterExecutor(&MockExecutor{
+		operationType: TypeSMARTScan,
+		duration:      20 * time.Millisecond,
+	})
+
+	op, err := manager.StartOperation(OperationRequest{Type: TypeSMARTScan, Description: "Nightly scan"}, "test-user")
+	if err != nil {
+		t.Fatalf("Failed to start operation: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for op.IsActive() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	manager.Stop()
+
+	ops, err := store.Load()
+	if err != nil {
+		t.Fatalf("Failed to load journal: %v", err)
+	}
+	if len(ops) != 1 || ops[0].Status != StatusCompleted || ops[0].CreatedBy != "test-user" {
+		t.Errorf("Expected completed operation in journal, got %+v", ops)
+	}
+}
@@ -2,6 +2,7 @@ package async
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"time"
 )
@@ -39,6 +40,7 @@ type AsyncOperation struct {
 	Type        OperationType          `json:"type"`
 	Status      OperationStatus        `json:"status"`
 	Progress    int                    `json:"progress"` // 0-100
+	Step        string                 `json:"step,omitempty"`
 	Started     time.Time              `json:"started"`
 	Completed   *time.Time             `json:"completed,omitempty"`
 	Error       string                 `json:"error,omitempty"`
@@ -73,6 +75,12 @@ type OperationResponse struct {
 	Started     time.Time       `json:"started"`
 }
 
+// OperationEvent describes a progress or lifecycle change of an operation
+type OperationEvent struct {
+	Event     string             `json:"event"` // "running", "progress", "completed", "failed", "cancelled"
+	Operation SafeAsyncOperation `json:"operation"`
+}
+
 // OperationListResponse represents a list of operations
 type OperationListResponse struct {
 	Operations []SafeAsyncOperation `json:"operations"`
@@ -85,7 +93,6 @@ type OperationListResponse struct {
 // UpdateProgress updates the operation progress (thread-safe)
 func (op *AsyncOperation) UpdateProgress(progress int) {
 	op.mutex.Lock()
-	defer op.mutex.Unlock()
 
 	if progress < 0 {
 		progress = 0
@@ -95,40 +102,52 @@ func (op *AsyncOperation) UpdateProgress(progress int) {
 	}
 
 	op.Progress = progress
+	callback := op.onProgress
+	op.mutex.Unlock()
 
-	if op.onProgress != nil {
-		op.onProgress(progress)
+	// Callbacks run outside the lock so they can read the operation safely
+	if callback != nil {
+		callback(progress)
 	}
 }
 
+// UpdateStep records a human-readable description of the current step with its progress (thread-safe)
+func (op *AsyncOperation) UpdateStep(progress int, step string) {
+	op.mutex.Lock()
+	op.Step = step
+	op.mutex.Unlock()
+
+	op.UpdateProgress(progress)
+}
+
 // SetError sets the operation error and status (thread-safe)
 func (op *AsyncOperation) SetError(err error) {
 	op.mutex.Lock()
-	defer op.mutex.Unlock()
-
 	op.Status = StatusFailed
 	op.Error = err.Error()
 	now := time.Now()
 	op.Completed = &now
+	callback := op.onComplete
+	op.mutex.Unlock()
 
-	if op.onComplete != nil {
-		op.onComplete(err)
+	if callback != nil {
+		callback(err)
 	}
 }
 
 // SetCompleted marks the operation as completed (thread-safe)
 func (op *AsyncOperation) SetCompleted(result map[string]interface{}) {
 	op.mutex.Lock()
-	defer op.mutex.Unlock()
-
 	op.Status = StatusCompleted
 	op.Progress = 100
 	op.Result = result
 	now := time.Now()
 	op.Completed = &now
+	callback := op.onComplete
+	op.mutex.Unlock()
 
-	if op.onComplete != nil {
-		op.onComplete(nil)
+	if callback != nil {
+		callback(nil)
 	}
 }
 
@@ -143,9 +162,8 @@ func (op *AsyncOperation) SetRunning() {
 // Cancel cancels the operation if it's cancellable (thread-safe)
 func (op *AsyncOperation) Cancel() bool {
 	op.mutex.Lock()
-	defer op.mutex.Unlock()
-
 	if !op.Cancellable || op.Status == StatusCompleted || op.Status == StatusFailed || op.Status == StatusCancelled {
+		op.mutex.Unlock()
 		return false
 	}
 
@@ -156,9 +174,37 @@ func (op *AsyncOperation) Cancel() bool {
 	if op.cancel != nil {
 		op.cancel()
 	}
+	callback := op.onComplete
+	op.mutex.Unlock()
+
+	if callback != nil {
+		callback(context.Canceled)
+	}
+
+	return true
+}
+
+// Interrupt fails an active operation with reason regardless of whether it is cancellable (thread-safe)
+func (op *AsyncOperation) Interrupt(reason string) bool {
+	op.mutex.Lock()
+	if op.Status != StatusPending && op.Status != StatusRunning {
+		op.mutex.Unlock()
+		return false
+	}
+
+	op.Status = StatusFailed
+	op.Error = reason
+	now := time.Now()
+	op.Completed = &now
+
+	if op.cancel != nil {
+		op.cancel()
+	}
+	callback := op.onComplete
+	op.mutex.Unlock()
 
-	if op.onComplete != nil {
-		op.onComplete(context.Canceled)
+	if callback != nil {
+		callback(errors.New(reason))
 	}
 
 	return true
@@ -218,6 +264,7 @@ type SafeAsyncOperation struct {
 	Type        OperationType          `json:"type"`
 	Status      OperationStatus        `json:"status"`
 	Progress    int                    `json:"progress"`
+	Step        string                 `json:"step,omitempty"`
 	Started     time.Time              `json:"started"`
 	Completed   *time.Time             `json:"completed,omitempty"`
 	Error       string                 `json:"error,omitempty"`
@@ -238,6 +285,7 @@ func (op *AsyncOperation) ToSafeOperation() SafeAsyncOperation {
 		Type:        op.Type,
 		Status:      op.Status,
 		Progress:    op.Progress,
+		Step:        op.Step,
 		Started:     op.Started,
 		Completed:   op.Completed,
 		Error:       op.Error,
@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/domalab/uma/daemon/domain"
+)
+
+// Scope is the permission level granted to an API key
+type Scope string
+
+const (
+	ScopeReadOnly Scope = "read-only" // Query endpoints and subscribe to streams
+	ScopeOperator Scope = "operator"  // Also control containers, VMs, disks and operations
+	ScopeAdmin    Scope = "admin"     // Also power, array state, user scripts and container shells
+)
+
+var (
+	ErrMissingKey   = errors.New("api key required")
+	ErrInvalidKey   = errors.New("invalid api key")
+	ErrInvalidScope = errors.New("invalid scope")
+)
+
+var scopeLevels = map[Scope]int{
+	ScopeReadOnly: 1,
+	ScopeOperator: 2,
+	ScopeAdmin:    3,
+}
+
+// adminPaths require the admin scope for any state-changing request
+var adminPaths = []string{
+	"/api/v2/system/reboot",
+	"/api/v2/system/shutdown",
+	"/api/v2/storage/array/start",
+	"/api/v2/storage/array/stop",
+	"/api/v2/scripts",
+}
+
+// containerExecSuffix ends the path of container exec sessions, which need the
+// admin scope even though they open with a GET
+const containerExecSuffix = "/exec"
+
+// mcpPath is the MCP endpoint, which checks the scope of each tool call itself
+const mcpPath = "/mcp"
+
+// ParseScope validates a scope name
+func ParseScope(name string) (Scope, error) {
+	scope := Scope(strings.ToLower(strings.TrimSpace(name)))
+	if !scope.Valid() {
+		return "", ErrInvalidScope
+	}
+	return scope, nil
+}
+
+// Valid reports whether the scope is known
+func (s Scope) Valid() bool {
+	_, ok := scopeLevels[s]
+	return ok
+}
+
+// Allows reports whether the scope grants at least the required scope
+func (s Scope) Allows(required Scope) bool {
+	return s.Valid() && scopeLevels[s] >= scopeLevels[required]
+}
+
+// RequiredScope returns the scope needed to serve a request
+func RequiredScope(r *http.Request) Scope {
+	if r.URL.Path == mcpPath {
+		return ScopeReadOnly
+	}
+	if strings.HasPrefix(r.URL.Path, "/api/v2/containers/") && strings.HasSuffix(r.URL.Path, containerExecSuffix) {
+		return ScopeAdmin
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return ScopeReadOnly
+	}
+
+	for _, path := range adminPaths {
+		if r.URL.Path == path || strings.HasPrefix(r.URL.Path, path+"/") {
+			return ScopeAdmin
+		}
+	}
+
+	return ScopeOperator
+}
+
+// Authenticator validates API keys against the hashed keys in the config
+type Authenticator struct {
+	enabled bool
+	keys    map[string]domain.APIKey
+	public  map[string]bool
+}
+
+// NewAuthenticator creates an authenticator from the auth config
+func NewAuthenticator(config domain.AuthConfig) *Authenticator {
+	a := &Authenticator{
+		enabled: config.Enabled,
+		keys:    make(map[string]domain.APIKey, len(config.APIKeys)),
+		public:  make(map[string]bool, len(config.PublicPaths)),
+	}
+
+	for _, key := range config.APIKeys {
+		a.keys[key.ID] = key
+	}
+	for _, path := range config.PublicPaths {
+		a.public[path] = true
+	}
+
+	return a
+}
+
+// Enabled reports whether requests must carry an API key
+func (a *Authenticator) Enabled() bool {
+	return a != nil && a.enabled
+}
+
+// IsPublic reports whether a path is on the unauthenticated allowlist
+func (a *Authenticator) IsPublic(path string) bool {
+	return a.public[path]
+}
+
+// Authenticate returns the stored key matching the presented secret
+func (a *Authenticator) Authenticate(presented string) (domain.APIKey, error) {
+	if presented == "" {
+		return domain.APIKey{}, ErrMissingKey
+	}
+
+	id, ok := parseKeyID(presented)
+	if !ok {
+		return domain.APIKey{}, ErrInvalidKey
+	}
+
+	key, exists := a.keys[id]
+	if !exists {
+		return domain.APIKey{}, ErrInvalidKey
+	}
+
+	if subtle.ConstantTimeCompare([]byte(HashKey(presented)), []byte(key.Hash)) != 1 {
+		return domain.APIKey{}, ErrInvalidKey
+	}
+
+	return key, nil
+}
+
+// KeyFromRequest extracts the presented API key from a request. The query
+// parameter is only honoured for WebSocket upgrades, since browsers cannot
+// set headers on those.
+func KeyFromRequest(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return r.URL.Query().Get("api_key")
+	}
+
+	return ""
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying the authenticated key
+func NewContext(ctx context.Context, key domain.APIKey) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// FromContext returns the authenticated key stored in the context, if any
+func FromContext(ctx context.Context) (domain.APIKey, bool) {
+	key, ok := ctx.Value(contextKey{}).(domain.APIKey)
+	return key, ok
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/domalab/uma/daemon/domain"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, record, err := GenerateAPIKey("home-assistant", ScopeOperator)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	if !strings.HasPrefix(key, KeyPrefix+"_"+record.ID+"_") {
+		t.Errorf("Expected key to embed its id %s, got %s", record.ID, key)
+	}
+	if strings.Contains(record.Hash, key) || record.Hash != HashKey(key) {
+		t.Error("Expected record to hold only the key hash")
+	}
+	if record.Scope != "operator" || record.Name != "home-assistant" {
+		t.Errorf("Unexpected record: %+v", record)
+	}
+
+	if _, _, err := GenerateAPIKey("bad", Scope("root")); !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("Expected ErrInvalidScope, got %v", err)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	key, record, err := GenerateAPIKey("grafana", ScopeReadOnly)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	authenticator := NewAuthenticator(domain.AuthConfig{Enabled: true, APIKeys: []domain.APIKey{record}})
+
+	if got, err := authenticator.Authenticate(key); err != nil || got.Name != "grafana" {
+		t.Errorf("Expected key to authenticate, got %+v, %v", got, err)
+	}
+
+	tests := []struct {
+		name      string
+		presented string
+		expected  error
+	}{
+		{"empty", "", ErrMissingKey},
+		{"malformed", "not-a-key", ErrInvalidKey},
+		{"unknown id", "uma_deadbeef_secret", ErrInvalidKey},
+		{"wrong secret", KeyPrefix + "_" + record.ID + "_wrong", ErrInvalidKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := authenticator.Authenticate(tt.presented); !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestScopeAllows(t *testing.T) {
+	if !ScopeAdmin.Allows(ScopeOperator) || !ScopeOperator.Allows(ScopeReadOnly) {
+		t.Error("Expected higher scopes to include lower ones")
+	}
+	if ScopeReadOnly.Allows(ScopeOperator) || ScopeOperator.Allows(ScopeAdmin) {
+		t.Error("Expected lower scopes to be rejected")
+	}
+	if Scope("").Allows(ScopeReadOnly) {
+		t.Error("Expected an unknown scope to allow nothing")
+	}
+}
+
+func TestRequiredScope(t *testing.T) {
+	tests := []struct {
+		method   string
+		path     string
+		expected Scope
+	}{
+		{"GET", "/api/v2/containers/list", ScopeReadOnly},
+		{"GET", "/api/v2/stream", ScopeReadOnly},
+		{"POST", "/api/v2/containers/plex/restart", ScopeOperator},
+		{"DELETE", "/api/v2/operations/abc", ScopeOperator},
+		{"POST", "/api/v2/system/reboot", ScopeAdmin},
+		{"POST", "/api/v2/storage/array/stop", ScopeAdmin},
+		{"POST", "/api/v2/scripts", ScopeAdmin},
+		{"GET", "/api/v2/containers/plex/exec", ScopeAdmin},
+		{"GET", "/api/v2/containers/plex/logs", ScopeReadOnly},
+		{"POST", "/mcp", ScopeReadOnly},
+		{"DELETE", "/mcp", ScopeReadOnly},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if got := RequiredScope(req); got != tt.expected {
+			t.Errorf("%s %s: expected %s, got %s", tt.method, tt.path, tt.expected, got)
+		}
+	}
+}
+
+func TestKeyFromRequest(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/v2/system/info", nil)
+	req.Header.Set("Authorization", "Bearer uma_1234_abc")
+	if got := KeyFromRequest(req); got != "uma_1234_abc" {
+		t.Errorf("Expected bearer token, got %q", got)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v2/system/info", nil)
+	req.Header.Set("X-API-Key", "uma_1234_abc")
+	if got := KeyFromRequest(req); got != "uma_1234_abc" {
+		t.Errorf("Expected X-API-Key header, got %q", got)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v2/system/info?api_key=uma_1234_abc", nil)
+	if got := KeyFromRequest(req); got != "" {
+		t.Errorf("Expected query key to be ignored for plain requests, got %q", got)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v2/stream?api_key=uma_1234_abc", nil)
+	req.Header.Set("Upgrade", "websocket")
+	if got := KeyFromRequest(req); got != "uma_1234_abc" {
+		t.Errorf("Expected query key for WebSocket upgrades, got %q", got)
+	}
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/domalab/uma/daemon/domain"
+)
+
+// KeyPrefix marks UMA API keys so they are recognisable in configs and secret scanners
+const KeyPrefix = "uma"
+
+// GenerateAPIKey creates a new API key. The returned secret is shown to the
+// user once; only the record, which holds its hash, is stored in the config.
+func GenerateAPIKey(name string, scope Scope) (string, domain.APIKey, error) {
+	if !scope.Valid() {
+		return "", domain.APIKey{}, fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+	}
+
+	idBytes := make([]byte, 4)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", domain.APIKey{}, fmt.Errorf("failed to generate key id: %w", err)
+	}
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", domain.APIKey{}, fmt.Errorf("failed to generate key secret: %w", err)
+	}
+
+	id := hex.EncodeToString(idBytes)
+	key := fmt.Sprintf("%s_%s_%s", KeyPrefix, id, base64.RawURLEncoding.EncodeToString(secretBytes))
+
+	return key, domain.APIKey{
+		ID:      id,
+		Name:    name,
+		Scope:   string(scope),
+		Hash:    HashKey(key),
+		Created: time.Now().UTC(),
+	}, nil
+}
+
+// HashKey returns the hex-encoded SHA-256 hash of a full API key
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseKeyID extracts the lookup ID from a key of the form uma_<id>_<secret>
+func parseKeyID(key string) (string, bool) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != KeyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
@@ -218,7 +218,7 @@ func (ci *CacheInvalidator) registerDefaultStrategies() {
 	// Container info cache invalidation
 	ci.RegisterStrategy(ContainerInfoCache, &ResourceInvalidationStrategy{
 		ResourceType: "docker",
-		Actions:      []string{"container_started", "container_stopped", "container_created", "container_removed"},
+		Actions:      []string{"container_started", "container_stopped", "container_restarted", "container_paused", "container_unpaused", "container_created", "container_removed", "container_health_changed"},
 	})
 	
 	ci.RegisterStrategy(ContainerInfoCache, &PrefixInvalidationStrategy{
@@ -0,0 +1,321 @@
+package changes
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/domalab/uma/daemon/dto"
+	"github.com/domalab/uma/daemon/events"
+	"github.com/domalab/uma/daemon/plugins/docker"
+	"github.com/domalab/uma/daemon/plugins/storage"
+	"github.com/domalab/uma/daemon/plugins/ups"
+	"github.com/domalab/uma/daemon/plugins/vm"
+)
+
+// Collector snapshots the detector compares
+const (
+	CollectorContainers = "docker.containers"
+	CollectorVMs        = "vm.list"
+	CollectorArray      = "array.state"
+	CollectorDisks      = "storage.disks"
+	CollectorParity     = "array.parity"
+	CollectorUPS        = "ups.status"
+)
+
+// announcementTTL bounds how long a manager event suppresses the matching
+// snapshot change, in case the snapshot never shows the announced state
+const announcementTTL = 2 * time.Minute
+
+// announcement is a state a plugin manager already published an event for
+type announcement struct {
+	state string
+	at    time.Time
+}
+
+// Detector publishes events for state changes between consecutive collector
+// snapshots. It catches what the plugin managers cannot see, such as a
+// container crashing or a disk spinning down, and skips changes a manager
+// has already announced. The first snapshot of each collector is only a baseline.
+type Detector struct {
+	bus *events.Bus
+
+	containers map[string]docker.ContainerInfo // By name
+	vms        map[string]string               // Name -> state
+	arrayState string
+	disks      map[string]storage.DiskState // By name
+	parity     *storage.ParityCheckStatus
+	ups        string // ups.Green or ups.Red once known
+
+	announced map[string]announcement
+	mutex     sync.Mutex
+}
+
+// NewDetector creates a detector publishing on bus
+func NewDetector(bus *events.Bus) *Detector {
+	return &Detector{
+		bus:       bus,
+		announced: make(map[string]announcement),
+	}
+}
+
+// Start follows the events published by plugin managers until the hub shuts down
+func (d *Detector) Start() {
+	ch := d.bus.Subscribe(events.TopicContainer, events.TopicVM, events.TopicArray, events.TopicParity)
+	go func() {
+		for msg := range ch {
+			if event, ok := msg.(events.Event); ok {
+				d.Announce(event)
+			}
+		}
+	}()
+}
+
+// Announce records that event was already published, so the snapshot
+// change it causes is not reported a second time
+func (d *Detector) Announce(event events.Event) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	switch data := event.Data.(type) {
+	case events.ContainerEvent:
+		d.announced["container:"+d.containerName(data.Name)] = announcement{state: data.State, at: event.Timestamp}
+	case events.VMEvent:
+		d.announced["vm:"+data.Name] = announcement{state: data.State, at: event.Timestamp}
+	case events.ArrayEvent:
+		d.announced["array"] = announcement{state: data.State, at: event.Timestamp}
+	case events.ParityEvent:
+		d.announced["parity"] = announcement{state: parityState(event.Type == events.ParityStarted), at: event.Timestamp}
+	}
+}
+
+// Observe compares a collector snapshot with the previous one. It is
+// registered as a SystemCollector listener.
+func (d *Detector) Observe(name string, data interface{}) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	switch name {
+	case CollectorContainers:
+		if containers, ok := data.([]docker.ContainerInfo); ok {
+			d.observeContainers(containers)
+		}
+	case CollectorVMs:
+		if vms, ok := data.([]vm.VMInfo); ok {
+			d.observeVMs(vms)
+		}
+	case CollectorArray:
+		if info, ok := data.(*storage.ArrayInfo); ok && info != nil {
+			d.observeArray(info.State)
+		}
+	case CollectorDisks:
+		if disks, ok := data.([]storage.DiskState); ok {
+			d.observeDisks(disks)
+		}
+	case CollectorParity:
+		if status, ok := data.(*storage.ParityCheckStatus); ok && status != nil {
+			d.observeParity(status)
+		}
+	case CollectorUPS:
+		if samples, ok := data.([]dto.Sample); ok {
+			d.observeUPS(samples)
+		}
+	}
+}
+
+// wasAnnounced reports whether a manager already published the change of key
+// to state, and forgets announcements the snapshots have caught up with
+func (d *Detector) wasAnnounced(key string, state string) bool {
+	a, exists := d.announced[key]
+	if !exists {
+		return false
+	}
+	if a.state == state || time.Since(a.at) > announcementTTL {
+		delete(d.announced, key)
+	}
+	return a.state == state
+}
+
+func (d *Detector) observeContainers(containers []docker.ContainerInfo) {
+	current := make(map[string]docker.ContainerInfo, len(containers))
+	for _, container := range containers {
+		current[container.Name] = container
+	}
+
+	previous := d.containers
+	d.containers = current
+	if previous == nil {
+		return
+	}
+
+	for name, container := range current {
+		before, existed := previous[name]
+		key := "container:" + name
+		if d.wasAnnounced(key, container.State) || (existed && before.State == container.State) {
+			continue
+		}
+
+		data := events.ContainerEvent{
+			ID:            container.ID,
+			Name:          name,
+			Image:         container.Image,
+			State:         container.State,
+			PreviousState: before.State,
+		}
+
+		if !existed {
+			d.bus.Publish(events.ContainerCreated, name, data)
+			continue
+		}
+
+		switch {
+		case container.State == "running" && before.State == "paused":
+			d.bus.Publish(events.ContainerUnpaused, name, data)
+		case container.State == "running":
+			d.bus.Publish(events.ContainerStarted, name, data)
+		case container.State == "paused":
+			d.bus.Publish(events.ContainerPaused, name, data)
+		case before.State == "running" || before.State == "paused" || before.State == "restarting":
+			// Stops through the API are announced, so anything else ended on its own
+			d.bus.Publish(events.ContainerDied, name, data)
+		}
+	}
+
+	for name, before := range previous {
+		if _, exists := current[name]; exists || d.wasAnnounced("container:"+name, "removed") {
+			continue
+		}
+		d.bus.Publish(events.ContainerRemoved, name, events.ContainerEvent{
+			ID:            before.ID,
+			Name:          name,
+			Image:         before.Image,
+			State:         "removed",
+			PreviousState: before.State,
+		})
+	}
+}
+
+// containerName resolves a container ID, or ID prefix, to its name
+func (d *Detector) containerName(nameOrID string) string {
+	for name, container := range d.containers {
+		if name == nameOrID || (nameOrID != "" && strings.HasPrefix(container.ID, nameOrID)) {
+			return name
+		}
+	}
+	return nameOrID
+}
+
+func (d *Detector) observeVMs(vms []vm.VMInfo) {
+	current := make(map[string]string, len(vms))
+	for _, info := range vms {
+		current[info.Name] = info.State
+	}
+
+	previous := d.vms
+	d.vms = current
+	if previous == nil {
+		return
+	}
+
+	for name, state := range current {
+		before, existed := previous[name]
+		if !existed || before == state || d.wasAnnounced("vm:"+name, state) {
+			continue
+		}
+		d.bus.Publish(events.VMStateChanged, name, events.VMEvent{Name: name, State: state, PreviousState: before})
+	}
+}
+
+func (d *Detector) observeArray(state string) {
+	previous := d.arrayState
+	d.arrayState = state
+	if previous == "" || previous == state || d.wasAnnounced("array", state) {
+		return
+	}
+	d.bus.Publish(events.ArrayStateChanged, "array", events.ArrayEvent{State: state, PreviousState: previous})
+}
+
+func (d *Detector) observeDisks(disks []storage.DiskState) {
+	current := make(map[string]storage.DiskState, len(disks))
+	for _, disk := range disks {
+		current[disk.Name] = disk
+	}
+
+	previous := d.disks
+	d.disks = current
+	if previous == nil {
+		return
+	}
+
+	for name, disk := range current {
+		before, existed := previous[name]
+		if !existed || before.SpunDown == disk.SpunDown {
+			continue
+		}
+
+		eventType := events.DiskSpunUp
+		if disk.SpunDown {
+			eventType = events.DiskSpunDown
+		}
+		d.bus.Publish(eventType, name, events.DiskEvent{Name: name, Device: disk.Device, Role: disk.Role})
+	}
+}
+
+// parityState names the announcement state of a parity operation
+func parityState(active bool) string {
+	if active {
+		return "active"
+	}
+	return "idle"
+}
+
+func (d *Detector) observeParity(status *storage.ParityCheckStatus) {
+	previous := d.parity
+	d.parity = status
+	if previous == nil || previous.Active == status.Active || d.wasAnnounced("parity", parityState(status.Active)) {
+		return
+	}
+
+	if status.Active {
+		d.bus.Publish(events.ParityStarted, "parity", events.ParityEvent{Type: status.Type})
+		return
+	}
+
+	// The finished status no longer carries progress, so report the last one seen
+	d.bus.Publish(events.ParityFinished, "parity", events.ParityEvent{
+		Type:     previous.Type,
+		Progress: previous.Progress,
+		Errors:   previous.Errors,
+	})
+}
+
+func (d *Detector) observeUPS(samples []dto.Sample) {
+	var status, condition string
+	var charge float64
+	for _, sample := range samples {
+		switch sample.Key {
+		case "UPS STATUS":
+			status, condition = sample.Value, sample.Condition
+		case "UPS CHARGE":
+			charge, _ = strconv.ParseFloat(sample.Value, 64)
+		}
+	}
+
+	// Orange means the UPS daemon is still refreshing
+	if condition != ups.Green && condition != ups.Red {
+		return
+	}
+
+	previous := d.ups
+	d.ups = condition
+	if previous == "" || previous == condition {
+		return
+	}
+
+	eventType := events.UPSOnline
+	if condition == ups.Red {
+		eventType = events.UPSOnBattery
+	}
+	d.bus.Publish(eventType, "ups", events.UPSEvent{Status: status, BatteryCharge: charge})
+}
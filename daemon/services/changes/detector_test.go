@@ -0,0 +1,184 @@
+package changes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cskr/pubsub"
+	"github.com/domalab/uma/daemon/dto"
+	"github.com/domalab/uma/daemon/events"
+	"github.com/domalab/uma/daemon/plugins/docker"
+	"github.com/domalab/uma/daemon/plugins/storage"
+	"github.com/domalab/uma/daemon/plugins/ups"
+	"github.com/domalab/uma/daemon/plugins/vm"
+)
+
+// newTestDetector returns a detector and a channel receiving everything it publishes
+func newTestDetector(t *testing.T) (*Detector, chan interface{}) {
+	t.Helper()
+
+	hub := pubsub.New(32)
+	t.Cleanup(hub.Shutdown)
+
+	bus := events.NewBus(hub)
+	return NewDetector(bus), bus.Subscribe()
+}
+
+// expectEvents reads the published event types, failing on anything extra
+func expectEvents(t *testing.T, ch chan interface{}, want ...events.Type) []events.Event {
+	t.Helper()
+
+	var got []events.Event
+	for _, eventType := range want {
+		select {
+		case msg := <-ch:
+			event := msg.(events.Event)
+			if event.Type != eventType {
+				t.Fatalf("got event %s, want %s", event.Type, eventType)
+			}
+			got = append(got, event)
+		case <-time.After(time.Second):
+			t.Fatalf("event %s not published", eventType)
+		}
+	}
+
+	select {
+	case msg := <-ch:
+		t.Fatalf("unexpected event %s", msg.(events.Event).Type)
+	case <-time.After(50 * time.Millisecond):
+	}
+	return got
+}
+
+// collectEvents reads n events keyed by source, since snapshot diffs are published in map order
+func collectEvents(t *testing.T, ch chan interface{}, n int) map[string]events.Event {
+	t.Helper()
+
+	got := make(map[string]events.Event, n)
+	for i := 0; i < n; i++ {
+		select {
+		case msg := <-ch:
+			event := msg.(events.Event)
+			got[event.Source] = event
+		case <-time.After(time.Second):
+			t.Fatalf("got %d events, want %d", i, n)
+		}
+	}
+	expectEvents(t, ch)
+	return got
+}
+
+func TestDetectorContainers(t *testing.T) {
+	detector, ch := newTestDetector(t)
+
+	detector.Observe(CollectorContainers, []docker.ContainerInfo{
+		{ID: "aaa111", Name: "plex", State: "running"},
+		{ID: "bbb222", Name: "sonarr", State: "running"},
+		{ID: "ccc333", Name: "radarr", State: "exited"},
+	})
+	expectEvents(t, ch) // Baseline only
+
+	// sonarr was stopped through the API, by ID
+	detector.Announce(events.Event{
+		Type:      events.ContainerStopped,
+		Timestamp: time.Now(),
+		Data:      events.ContainerEvent{Name: "bbb222", State: "exited"},
+	})
+
+	detector.Observe(CollectorContainers, []docker.ContainerInfo{
+		{ID: "aaa111", Name: "plex", State: "exited"},
+		{ID: "bbb222", Name: "sonarr", State: "exited"},
+		{ID: "ccc333", Name: "radarr", State: "running"},
+		{ID: "ddd444", Name: "lidarr", State: "created"},
+	})
+
+	got := collectEvents(t, ch, 3)
+	if got["plex"].Type != events.ContainerDied {
+		t.Errorf("plex: got %s, want %s", got["plex"].Type, events.ContainerDied)
+	}
+	if data := got["plex"].Data.(events.ContainerEvent); data.PreviousState != "running" || data.ID != "aaa111" {
+		t.Errorf("plex: unexpected data %+v", data)
+	}
+	if got["radarr"].Type != events.ContainerStarted {
+		t.Errorf("radarr: got %s, want %s", got["radarr"].Type, events.ContainerStarted)
+	}
+	if got["lidarr"].Type != events.ContainerCreated {
+		t.Errorf("lidarr: got %s, want %s", got["lidarr"].Type, events.ContainerCreated)
+	}
+
+	detector.Observe(CollectorContainers, []docker.ContainerInfo{
+		{ID: "aaa111", Name: "plex", State: "exited"},
+		{ID: "bbb222", Name: "sonarr", State: "exited"},
+		{ID: "ccc333", Name: "radarr", State: "paused"},
+	})
+
+	got = collectEvents(t, ch, 2)
+	if got["radarr"].Type != events.ContainerPaused {
+		t.Errorf("radarr: got %s, want %s", got["radarr"].Type, events.ContainerPaused)
+	}
+	if got["lidarr"].Type != events.ContainerRemoved {
+		t.Errorf("lidarr: got %s, want %s", got["lidarr"].Type, events.ContainerRemoved)
+	}
+}
+
+func TestDetectorVMsAndArray(t *testing.T) {
+	detector, ch := newTestDetector(t)
+
+	detector.Observe(CollectorVMs, []vm.VMInfo{{Name: "win11", State: "running"}})
+	detector.Observe(CollectorArray, &storage.ArrayInfo{State: "started"})
+	expectEvents(t, ch)
+
+	detector.Observe(CollectorVMs, []vm.VMInfo{{Name: "win11", State: "shut off"}})
+	got := expectEvents(t, ch, events.VMStateChanged)
+	if data := got[0].Data.(events.VMEvent); data.State != "shut off" || data.PreviousState != "running" {
+		t.Errorf("unexpected VM event %+v", data)
+	}
+
+	// The array stop was announced by the storage monitor
+	detector.Announce(events.Event{
+		Type:      events.ArrayStateChanged,
+		Timestamp: time.Now(),
+		Data:      events.ArrayEvent{State: "stopped"},
+	})
+	detector.Observe(CollectorArray, &storage.ArrayInfo{State: "stopped"})
+	expectEvents(t, ch)
+
+	detector.Observe(CollectorArray, &storage.ArrayInfo{State: "started"})
+	expectEvents(t, ch, events.ArrayStateChanged)
+}
+
+func TestDetectorDisksParityAndUPS(t *testing.T) {
+	detector, ch := newTestDetector(t)
+
+	detector.Observe(CollectorDisks, []storage.DiskState{{Name: "disk1"}, {Name: "disk2"}})
+	detector.Observe(CollectorParity, &storage.ParityCheckStatus{})
+	detector.Observe(CollectorUPS, []dto.Sample{{Key: "UPS STATUS", Value: "Online", Condition: ups.Green}})
+	expectEvents(t, ch)
+
+	detector.Observe(CollectorDisks, []storage.DiskState{{Name: "disk1", SpunDown: true}, {Name: "disk2"}})
+	got := expectEvents(t, ch, events.DiskSpunDown)
+	if got[0].Source != "disk1" {
+		t.Errorf("spun down source = %s", got[0].Source)
+	}
+
+	detector.Observe(CollectorParity, &storage.ParityCheckStatus{Active: true, Type: "check", Progress: 10})
+	expectEvents(t, ch, events.ParityStarted)
+
+	detector.Observe(CollectorParity, &storage.ParityCheckStatus{Active: true, Type: "check", Progress: 99.5, Errors: 2})
+	detector.Observe(CollectorParity, &storage.ParityCheckStatus{})
+	got = expectEvents(t, ch, events.ParityFinished)
+	if data := got[0].Data.(events.ParityEvent); data.Progress != 99.5 || data.Errors != 2 {
+		t.Errorf("unexpected parity event %+v", data)
+	}
+
+	// Refreshing readings neither raise events nor reset the baseline
+	detector.Observe(CollectorUPS, []dto.Sample{{Key: "UPS STATUS", Value: "Refreshing ...", Condition: ups.Orange}})
+	detector.Observe(CollectorUPS, []dto.Sample{
+		{Key: "UPS STATUS", Value: "On battery", Condition: ups.Red},
+		{Key: "UPS CHARGE", Value: "87", Unit: "%"},
+	})
+	got = expectEvents(t, ch, events.UPSOnBattery)
+	if data := got[0].Data.(events.UPSEvent); data.BatteryCharge != 87 {
+		t.Errorf("battery charge = %v", data.BatteryCharge)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/domalab/uma/daemon/logger"
+	"github.com/domalab/uma/daemon/plugins/docker"
 )
 
 // Direct system readers for maximum performance
@@ -40,9 +41,20 @@ type StorageReader struct {
 	mutex      sync.RWMutex
 }
 
-// DockerReader provides Docker statistics reading
+// DockerReader provides Docker statistics reading. Running containers'
+// stats are streamed from the Engine API so reads return the latest sample
+// instead of waiting on the daemon.
 type DockerReader struct {
-	// Connection pool and caching would be implemented here
+	ctx     context.Context
+	manager *docker.DockerManager
+	streams map[string]*statsStream // By container ID
+	mutex   sync.Mutex
+}
+
+// statsStream follows the stats of one running container
+type statsStream struct {
+	cancel context.CancelFunc
+	latest *docker.ContainerStatsSample
 }
 
 // NetworkStats for delta calculations
@@ -77,9 +89,13 @@ func NewStorageReader() *StorageReader {
 	}
 }
 
-// NewDockerReader creates Docker reader
-func NewDockerReader() *DockerReader {
-	return &DockerReader{}
+// NewDockerReader creates Docker reader whose stats streams end with ctx
+func NewDockerReader(ctx context.Context) *DockerReader {
+	return &DockerReader{
+		ctx:     ctx,
+		manager: docker.NewDockerManager(),
+		streams: make(map[string]*statsStream),
+	}
 }
 
 // ReadCPUStats reads CPU statistics directly from /proc/stat
@@ -252,38 +268,95 @@ func (sr *StorageReader) ReadStorageUsage() (arrayUsage, cacheUsage, dockerUsage
 	return arrayUsage, cacheUsage, dockerUsage, nil
 }
 
-// ReadContainerStats reads container statistics with connection pooling
+// ReadContainerStats reads container statistics from the stats streams,
+// starting streams for newly running containers and ending those of
+// containers that stopped or were removed
 func (dr *DockerReader) ReadContainerStats() (containers []ContainerStats, summary ContainerSummary, err error) {
-	// This would implement Docker API calls with connection pooling
-	// For now, return placeholder data
-	containers = []ContainerStats{
-		{
-			ID:          "container1",
-			Name:        "qbittorrent",
-			State:       "running",
-			CPUPercent:  15.2,
-			MemoryUsage: 104857600,
-			MemoryLimit: 1073741824,
-			NetworkRx:   1024000,
-			NetworkTx:   2048000,
-		},
+	list, err := dr.manager.ListContainerSummaries(true)
+	if err != nil {
+		return nil, summary, err
 	}
 
-	summary = ContainerSummary{
-		Total:   1,
-		Running: 1,
-		Stopped: 0,
+	dr.mutex.Lock()
+	defer dr.mutex.Unlock()
+
+	running := make(map[string]bool)
+	containers = make([]ContainerStats, 0, len(list))
+	for _, container := range list {
+		stats := ContainerStats{
+			ID:    container.ID,
+			Name:  container.Name,
+			State: container.State,
+		}
+		summary.Total++
+		if container.State != "running" {
+			summary.Stopped++
+			containers = append(containers, stats)
+			continue
+		}
+		summary.Running++
+		running[container.ID] = true
+
+		stream, ok := dr.streams[container.ID]
+		if !ok {
+			stream = dr.startStream(container.ID)
+		}
+		if sample := stream.latest; sample != nil {
+			rx, tx := sample.NetworkIO()
+			stats.CPUPercent = sample.CPUPercent()
+			stats.MemoryUsage = int64(sample.MemoryUsage())
+			stats.MemoryLimit = int64(sample.MemoryStats.Limit)
+			stats.NetworkRx = int64(rx)
+			stats.NetworkTx = int64(tx)
+		}
+		containers = append(containers, stats)
 	}
 
+	for id, stream := range dr.streams {
+		if !running[id] {
+			stream.cancel()
+			delete(dr.streams, id)
+		}
+	}
 	return containers, summary, nil
 }
 
+// startStream follows a container's stats until it stops or the stream is
+// cancelled; a stream that ends is restarted by the next read. Called with
+// the mutex held.
+func (dr *DockerReader) startStream(id string) *statsStream {
+	ctx, cancel := context.WithCancel(dr.ctx)
+	stream := &statsStream{cancel: cancel}
+	dr.streams[id] = stream
+
+	go func() {
+		err := dr.manager.StreamContainerStats(ctx, id, func(sample *docker.ContainerStatsSample) error {
+			dr.mutex.Lock()
+			stream.latest = sample
+			dr.mutex.Unlock()
+			return nil
+		})
+		if err != nil && ctx.Err() == nil {
+			logger.Yellow("Stats stream for container %s ended: %v", id, err)
+		}
+
+		dr.mutex.Lock()
+		if dr.streams[id] == stream {
+			delete(dr.streams, id)
+		}
+		dr.mutex.Unlock()
+		cancel()
+	}()
+	return stream
+}
+
 // SystemCollector provides efficient data collection
 type SystemCollector struct {
 	ctx        context.Context
 	cancel     context.CancelFunc
 	cache      *MetricsCache
 	collectors map[string]*CollectorConfig
+	listeners  []func(name string, data interface{})
 	running    bool
 	mutex      sync.RWMutex
 
@@ -412,11 +485,11 @@ func NewSystemCollector() *SystemCollector {
 		memoryReader:  NewMemoryReader(),
 		networkReader: NewNetworkReader(),
 		storageReader: NewStorageReader(),
-		dockerReader:  NewDockerReader(),
-
-		// Initialize system reader for comprehensive metrics
-		systemReader: NewSystemReader(),
 	}
+	collector.dockerReader = NewDockerReader(ctx)
+
+	// Initialize system reader for comprehensive metrics, sharing the stats streams
+	collector.systemReader = NewSystemReader(collector.dockerReader)
 
 	// Register collectors
 	collector.registerCollectors()
@@ -626,32 +699,63 @@ func (sc *SystemCollector) runCollector(name string, config *CollectorConfig) {
 	ticker := time.NewTicker(config.Interval)
 	defer ticker.Stop()
 
+	// Collect once up front so slow-interval metrics are available immediately
+	sc.collectOnce(name, config)
+
 	for {
 		select {
 		case <-sc.ctx.Done():
 			return
 		case <-ticker.C:
-			start := time.Now()
+			sc.collectOnce(name, config)
+		}
+	}
+}
 
-			data, err := config.CollectFunc()
-			if err != nil {
-				logger.Yellow("Collection failed for %s: %v", name, err)
-				continue
-			}
+// collectOnce runs a collector and caches its result
+func (sc *SystemCollector) collectOnce(name string, config *CollectorConfig) {
+	start := time.Now()
 
-			// Cache the result with TTL
-			sc.cache.Set(name, data, config.Interval*2)
+	data, err := config.CollectFunc()
+	if err != nil {
+		logger.Yellow("Collection failed for %s: %v", name, err)
+		return
+	}
 
-			duration := time.Since(start)
-			config.LastRun = time.Now()
+	// Cache the result with TTL
+	sc.cache.Set(name, data, config.Interval*2)
 
-			// Performance monitoring - only log issues, not routine operations
-			if duration > config.TargetTime {
-				logger.Yellow("Performance target missed for %s: %v (target: %v)", name, duration, config.TargetTime)
-			}
-			// Routine collection timing is now filtered out in production mode
-		}
+	sc.mutex.RLock()
+	listeners := sc.listeners
+	sc.mutex.RUnlock()
+	for _, listener := range listeners {
+		listener(name, data)
 	}
+
+	duration := time.Since(start)
+	config.LastRun = time.Now()
+
+	// Performance monitoring - only log issues, not routine operations
+	if duration > config.TargetTime {
+		logger.Yellow("Performance target missed for %s: %v (target: %v)", name, duration, config.TargetTime)
+	}
+	// Routine collection timing is now filtered out in production mode
+}
+
+// AddListener registers a function called with every successful collection.
+// Listeners run on the collector goroutine and must not block.
+func (sc *SystemCollector) AddListener(listener func(name string, data interface{})) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	sc.listeners = append(sc.listeners, listener)
+}
+
+// HasCollector reports whether a collector is registered under name
+func (sc *SystemCollector) HasCollector(name string) bool {
+	sc.mutex.RLock()
+	defer sc.mutex.RUnlock()
+	_, exists := sc.collectors[name]
+	return exists
 }
 
 // GetMetric retrieves cached metric with performance tracking
@@ -682,8 +786,9 @@ func (mc *MetricsCache) Set(key string, data interface{}, ttl time.Duration) {
 
 // Get retrieves data with performance tracking
 func (mc *MetricsCache) Get(key string) (interface{}, bool) {
-	mc.mutex.RLock()
-	defer mc.mutex.RUnlock()
+	// Access tracking writes, so concurrent readers need the write lock
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
 
 	entry, exists := mc.data[key]
 	if !exists {
@@ -0,0 +1,87 @@
+package collectors
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+)
+
+// identityKeys name list elements in field paths, in order of preference,
+// so a disk's temperature keeps its path when the disk list is reordered
+var identityKeys = []string{"name", "id", "device", "key"}
+
+// ignoredKeys are numeric fields that are not measurements
+var ignoredKeys = map[string]bool{
+	"timestamp":    true,
+	"last_updated": true,
+	"last_check":   true,
+	"created":      true,
+}
+
+// Flatten flattens a collector snapshot to dotted field paths, such as
+// cpu_percent or containers.plex.state, with float64, string or bool values.
+// A snapshot that is itself a scalar is returned as the field "value".
+func Flatten(data interface{}) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]interface{})
+	collectFields(value, "", fields)
+	return fields, nil
+}
+
+func collectFields(value interface{}, path string, fields map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if !ignoredKeys[key] {
+				collectFields(child, joinPath(path, key), fields)
+			}
+		}
+	case []interface{}:
+		for i, element := range v {
+			collectFields(element, joinPath(path, elementName(element, i)), fields)
+		}
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			fields[scalarPath(path)] = f
+		}
+	case string, bool:
+		fields[scalarPath(path)] = v
+	}
+}
+
+// elementName identifies a list element by its name or ID, or else its position
+func elementName(element interface{}, index int) string {
+	if record, ok := element.(map[string]interface{}); ok {
+		for _, key := range identityKeys {
+			if name, ok := record[key].(string); ok && name != "" {
+				return name
+			}
+		}
+	}
+	return strconv.Itoa(index)
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func scalarPath(path string) string {
+	if path == "" {
+		return "value"
+	}
+	return path
+}
@@ -14,14 +14,14 @@ type SystemReader struct {
 	dockerReader  *DockerReader
 }
 
-// NewSystemReader creates a new system reader
-func NewSystemReader() *SystemReader {
+// NewSystemReader creates a new system reader reading containers through dockerReader
+func NewSystemReader(dockerReader *DockerReader) *SystemReader {
 	return &SystemReader{
 		cpuReader:     NewCPUReader(),
 		memoryReader:  NewMemoryReader(),
 		networkReader: NewNetworkReader(),
 		storageReader: NewStorageReader(),
-		dockerReader:  NewDockerReader(),
+		dockerReader:  dockerReader,
 	}
 }
 
@@ -165,6 +165,72 @@ func (m *Manager) validateAndSetDefaults() {
 	if m.config.MCP.MaxConnections <= 0 {
 		m.config.MCP.MaxConnections = defaults.MCP.MaxConnections
 	}
+
+	// Validate operations history config
+	if m.config.Operations.JournalPath == "" {
+		m.config.Operations.JournalPath = defaults.Operations.JournalPath
+	}
+	if m.config.Operations.MaxCount <= 0 {
+		m.config.Operations.MaxCount = defaults.Operations.MaxCount
+	}
+	if m.config.Operations.MaxAgeHours <= 0 {
+		m.config.Operations.MaxAgeHours = defaults.Operations.MaxAgeHours
+	}
+
+	// Validate metric history config
+	if m.config.History.Path == "" {
+		m.config.History.Path = defaults.History.Path
+	}
+	if m.config.History.MaxSizeMB <= 0 {
+		m.config.History.MaxSizeMB = defaults.History.MaxSizeMB
+	}
+	if m.config.History.StaleHours <= 0 {
+		m.config.History.StaleHours = defaults.History.StaleHours
+	}
+	if m.config.Forecast.ThresholdDays < 0 {
+		m.config.Forecast.ThresholdDays = defaults.Forecast.ThresholdDays
+	}
+	if m.config.Forecast.WindowDays <= 0 {
+		m.config.Forecast.WindowDays = defaults.Forecast.WindowDays
+	}
+	if m.config.Forecast.StatePath == "" {
+		m.config.Forecast.StatePath = defaults.Forecast.StatePath
+	}
+
+	// Validate notification delivery config
+	if m.config.Notifications.MaxAttempts <= 0 {
+		m.config.Notifications.MaxAttempts = defaults.Notifications.MaxAttempts
+	}
+	if m.config.Notifications.RetryDelaySeconds <= 0 {
+		m.config.Notifications.RetryDelaySeconds = defaults.Notifications.RetryDelaySeconds
+	}
+
+	// Validate MQTT publisher config
+	if m.config.MQTT.TopicPrefix == "" {
+		m.config.MQTT.TopicPrefix = defaults.MQTT.TopicPrefix
+	}
+	if m.config.MQTT.DiscoveryPrefix == "" {
+		m.config.MQTT.DiscoveryPrefix = defaults.MQTT.DiscoveryPrefix
+	}
+	if m.config.MQTT.IntervalSeconds <= 0 {
+		m.config.MQTT.IntervalSeconds = defaults.MQTT.IntervalSeconds
+	}
+
+	// Validate middleware pipeline config
+	if len(m.config.Middleware.Order) == 0 {
+		m.config.Middleware.Order = defaults.Middleware.Order
+	}
+	if m.config.Middleware.Compression.MinLength < 0 {
+		m.config.Middleware.Compression.MinLength = defaults.Middleware.Compression.MinLength
+	}
+	if m.config.Middleware.Compression.Level < 0 || m.config.Middleware.Compression.Level > 9 {
+		m.config.Middleware.Compression.Level = defaults.Middleware.Compression.Level
+	}
+
+	// An explicitly empty allowlist is kept so every path can require a key
+	if m.config.Auth.PublicPaths == nil {
+		m.config.Auth.PublicPaths = defaults.Auth.PublicPaths
+	}
 }
 
 // SetHTTPEnabled enables or disables the HTTP server
@@ -0,0 +1,455 @@
+package forecast
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/domalab/uma/daemon/logger"
+	"github.com/domalab/uma/daemon/plugins/notifications"
+	"github.com/domalab/uma/daemon/services/history"
+)
+
+// Target kinds
+const (
+	KindArray  = "array"
+	KindDisk   = "disk"
+	KindPool   = "pool"
+	KindDocker = "docker"
+)
+
+// MetricPools is the collector registered for forecasting that nothing else records
+const MetricPools = "storage.pools"
+
+const (
+	DefaultWindow        = 30 * 24 * time.Hour
+	DefaultCheckInterval = time.Hour
+
+	// Usage is fitted at one sample per sampleStep, and needs minSamples of them
+	sampleStep = time.Hour
+	minSamples = 12
+
+	// Projections further out than horizon are reported as not filling up
+	horizon = 5 * 365 * 24 * time.Hour
+)
+
+// source locates the used and total bytes of one kind of target in the
+// history of a collector. Targets of a collector listing several are named
+// by the field path between prefix and the used or total field.
+type source struct {
+	kind   string
+	metric string
+	prefix string
+	name   string // The single target of the collector, if not a list
+	used   string
+	total  string
+}
+
+var sources = []source{
+	{kind: KindArray, metric: "storage.usage", prefix: "array_usage.", name: "array", used: "used", total: "total"},
+	{kind: KindDisk, metric: "array.state", prefix: "disks.", used: "used", total: "size"},
+	{kind: KindPool, metric: MetricPools, used: "used_size", total: "total_size"},
+	{kind: KindDocker, metric: "storage.usage", prefix: "docker_usage.", name: "docker", used: "used", total: "total"},
+}
+
+// Notifier raises storage notifications; NotificationManager implements it
+type Notifier interface {
+	CreateStorageNotification(title, message string, level notifications.NotificationLevel) (*notifications.Notification, error)
+}
+
+// Config configures a Service
+type Config struct {
+	Window        time.Duration // Usage history fitted; DefaultWindow when zero
+	ThresholdDays float64       // Notify when a target is projected full sooner; never when zero
+	CheckInterval time.Duration // DefaultCheckInterval when zero
+	StatePath     string        // Targets already notified about, kept across restarts; in memory only when empty
+}
+
+// Projection is when one model expects a target to be full
+type Projection struct {
+	DaysUntilFull *float64 `json:"days_until_full"` // Null when not filling up
+	FullAt        *int64   `json:"full_at,omitempty"`
+	RSquared      float64  `json:"r_squared"`
+}
+
+// Target is the forecast for the array, one disk or pool, or Docker storage
+type Target struct {
+	Kind          string  `json:"kind"`
+	Name          string  `json:"name"`
+	UsedBytes     int64   `json:"used_bytes"`
+	CapacityBytes int64   `json:"capacity_bytes"`
+	UsedPercent   float64 `json:"used_percent"`
+	FillRate      int64   `json:"fill_rate_bytes_per_day"` // Slope of the linear model
+	Samples       int     `json:"samples"`
+
+	Linear   *Projection `json:"linear,omitempty"`
+	Seasonal *Projection `json:"seasonal,omitempty"` // Once two days of history are available
+
+	// The earlier of the two projections, null while not filling up or
+	// without minSamples of history
+	DaysUntilFull  *float64 `json:"days_until_full"`
+	BelowThreshold bool     `json:"below_threshold"`
+}
+
+// Report is the forecast for every target with recorded usage, soonest full first
+type Report struct {
+	GeneratedAt   int64    `json:"generated_at"`
+	WindowDays    float64  `json:"window_days"`
+	ThresholdDays float64  `json:"threshold_days"`
+	Targets       []Target `json:"targets"`
+}
+
+// Service projects when the array, each disk and pool, and Docker storage
+// will be full from the usage recorded in the metric history, and raises a
+// notification when a projection falls below the threshold. Shares are not
+// projected: they have no capacity of their own and fill the array or pool
+// they are on. Usage is fitted with a linear
+// model and with a seasonal one that adds a daily cycle to the trend.
+type Service struct {
+	history  *history.Store
+	notifier Notifier
+	config   Config
+
+	alerted map[string]bool // Targets notified about until they recover
+	mutex   sync.Mutex
+	stopCh  chan struct{}
+}
+
+// NewService creates a forecasting service reading usage from store
+func NewService(store *history.Store, notifier Notifier, config Config) *Service {
+	if config.Window <= 0 {
+		config.Window = DefaultWindow
+	}
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = DefaultCheckInterval
+	}
+	s := &Service{
+		history:  store,
+		notifier: notifier,
+		config:   config,
+		alerted:  make(map[string]bool),
+		stopCh:   make(chan struct{}),
+	}
+	s.loadAlerted()
+	return s
+}
+
+// loadAlerted restores the targets notified about before a restart
+func (s *Service) loadAlerted() {
+	if s.config.StatePath == "" {
+		return
+	}
+	data, err := os.ReadFile(s.config.StatePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Yellow("Failed to read forecast state %s: %v", s.config.StatePath, err)
+		}
+		return
+	}
+
+	var keys []string
+	if err := json.Unmarshal(data, &keys); err != nil {
+		logger.Yellow("Ignoring invalid forecast state %s: %v", s.config.StatePath, err)
+		return
+	}
+	for _, key := range keys {
+		s.alerted[key] = true
+	}
+}
+
+// saveAlerted writes the targets notified about; the caller holds the mutex
+func (s *Service) saveAlerted() error {
+	if s.config.StatePath == "" {
+		return nil
+	}
+	keys := make([]string, 0, len(s.alerted))
+	for key := range s.alerted {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.config.StatePath), 0755); err != nil {
+		return fmt.Errorf("failed to create forecast state directory: %w", err)
+	}
+	tmpPath := s.config.StatePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write forecast state: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.config.StatePath); err != nil {
+		return fmt.Errorf("failed to replace forecast state: %w", err)
+	}
+	return nil
+}
+
+// Start checks the projections against the threshold periodically
+func (s *Service) Start() {
+	go func() {
+		ticker := time.NewTicker(s.config.CheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.Check()
+			case <-s.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic checks
+func (s *Service) Stop() {
+	close(s.stopCh)
+}
+
+// Forecast projects every target with recorded usage
+func (s *Service) Forecast() *Report {
+	now := time.Now()
+	report := &Report{
+		GeneratedAt:   now.Unix(),
+		WindowDays:    s.config.Window.Hours() / 24,
+		ThresholdDays: s.config.ThresholdDays,
+		Targets:       []Target{},
+	}
+
+	for _, src := range sources {
+		for _, name := range s.targetNames(src) {
+			target, ok := s.forecast(src, name, now)
+			if ok {
+				report.Targets = append(report.Targets, target)
+			}
+		}
+	}
+
+	sort.SliceStable(report.Targets, func(i, j int) bool {
+		a, b := report.Targets[i].DaysUntilFull, report.Targets[j].DaysUntilFull
+		if a == nil || b == nil {
+			return a != nil && b == nil
+		}
+		return *a < *b
+	})
+	return report
+}
+
+// targetNames returns the targets of src with both fields recorded
+func (s *Service) targetNames(src source) []string {
+	fields := make(map[string]bool)
+	for _, info := range s.history.List(src.metric) {
+		fields[info.Field] = true
+	}
+
+	if src.name != "" {
+		if fields[src.prefix+src.used] && fields[src.prefix+src.total] {
+			return []string{src.name}
+		}
+		return nil
+	}
+
+	var names []string
+	for field := range fields {
+		name, ok := strings.CutSuffix(field, "."+src.used)
+		if !ok || !strings.HasPrefix(name, src.prefix) {
+			continue
+		}
+		name = strings.TrimPrefix(name, src.prefix)
+		if name != "" && fields[src.prefix+name+"."+src.total] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fieldPath returns the history field of a target's used or total bytes
+func (src source) fieldPath(name, field string) string {
+	if src.name != "" {
+		return src.prefix + field
+	}
+	return src.prefix + name + "." + field
+}
+
+// forecast fits the target's usage, returning false for targets without a
+// capacity, such as parity disks
+func (s *Service) forecast(src source, name string, now time.Time) (Target, bool) {
+	from := now.Add(-s.config.Window)
+	used, err := s.history.Query(src.metric, src.fieldPath(name, src.used), from, now, sampleStep)
+	if err != nil {
+		logger.Yellow("Failed to read usage history of %s %s: %v", src.kind, name, err)
+		return Target{}, false
+	}
+	total, err := s.history.Query(src.metric, src.fieldPath(name, src.total), from, now, sampleStep)
+	if err != nil {
+		logger.Yellow("Failed to read capacity history of %s %s: %v", src.kind, name, err)
+		return Target{}, false
+	}
+	if len(used.Points) == 0 || len(total.Points) == 0 {
+		return Target{}, false
+	}
+
+	capacity := total.Points[len(total.Points)-1].Avg
+	if capacity <= 0 {
+		return Target{}, false
+	}
+
+	samples := make([]sample, len(used.Points))
+	for i, point := range used.Points {
+		samples[i] = sample{t: float64(point.Timestamp), v: point.Avg}
+	}
+	current := samples[len(samples)-1].v
+
+	target := Target{
+		Kind:          src.kind,
+		Name:          name,
+		UsedBytes:     int64(current),
+		CapacityBytes: int64(capacity),
+		UsedPercent:   math.Round(current/capacity*1000) / 10,
+		Samples:       len(samples),
+	}
+	if len(samples) < minSamples {
+		return target, true
+	}
+
+	at := float64(now.Unix())
+	linear, ok := fitLinear(samples)
+	if !ok {
+		return target, true
+	}
+	target.FillRate = int64(linear.slope * (24 * time.Hour).Seconds())
+	target.Linear = project(linear, nil, capacity, at)
+	target.DaysUntilFull = target.Linear.DaysUntilFull
+
+	if seasonal, ok := fitSeasonal(samples, linear); ok {
+		target.Seasonal = project(seasonal.trend, seasonal.offsets, capacity, at)
+		if days := target.Seasonal.DaysUntilFull; days != nil && (target.DaysUntilFull == nil || *days < *target.DaysUntilFull) {
+			target.DaysUntilFull = days
+		}
+	}
+
+	target.BelowThreshold = target.DaysUntilFull != nil && *target.DaysUntilFull < s.config.ThresholdDays
+	return target, true
+}
+
+// project returns when a fitted model reaches capacity
+func project(tr trend, offsets []float64, capacity, now float64) *Projection {
+	projection := &Projection{RSquared: math.Round(tr.r2*1000) / 1000}
+	if full, ok := fullAt(tr, offsets, capacity, now, horizon); ok {
+		days := math.Round((full-now)/(24*time.Hour).Seconds()*10) / 10
+		fullAt := int64(full)
+		projection.DaysUntilFull, projection.FullAt = &days, &fullAt
+	}
+	return projection
+}
+
+// Check notifies about each target newly projected to be full within the
+// threshold. A target is notified about again only after recovering, which
+// survives restarts when the state is persisted.
+func (s *Service) Check() {
+	if s.config.ThresholdDays <= 0 {
+		return
+	}
+	report := s.Forecast()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Targets that recovered or no longer exist may be notified about again
+	current := make(map[string]bool, len(report.Targets))
+	for _, target := range report.Targets {
+		if target.BelowThreshold {
+			current[target.Kind+":"+target.Name] = true
+		}
+	}
+	changed := false
+	for key := range s.alerted {
+		if !current[key] {
+			delete(s.alerted, key)
+			changed = true
+		}
+	}
+	defer func() {
+		if !changed {
+			return
+		}
+		if err := s.saveAlerted(); err != nil {
+			logger.Yellow("Failed to save forecast state: %v", err)
+		}
+	}()
+
+	for _, target := range report.Targets {
+		key := target.Kind + ":" + target.Name
+		if !target.BelowThreshold || s.alerted[key] {
+			continue
+		}
+		s.alerted[key] = true
+		changed = true
+
+		if s.notifier == nil {
+			continue
+		}
+		title, message, level := describe(target)
+		if _, err := s.notifier.CreateStorageNotification(title, message, level); err != nil {
+			logger.Yellow("Failed to notify about the %s %s forecast: %v", target.Kind, target.Name, err)
+		}
+	}
+}
+
+// describe words the notification for a target projected to be full soon
+func describe(target Target) (string, string, notifications.NotificationLevel) {
+	label := target.label()
+	days := *target.DaysUntilFull
+	if days == 0 {
+		return fmt.Sprintf("Storage full: %s", label),
+			fmt.Sprintf("%s is %.1f%% used (%s of %s) and projected to be full now.",
+				label, target.UsedPercent, formatBytes(target.UsedBytes), formatBytes(target.CapacityBytes)),
+			notifications.LevelCritical
+	}
+
+	return fmt.Sprintf("Storage forecast: %s full in %.1f days", label, days),
+		fmt.Sprintf("%s is %.1f%% used (%s of %s), growing by %s per day, and projected to be full in %.1f days.",
+			label, target.UsedPercent, formatBytes(target.UsedBytes), formatBytes(target.CapacityBytes),
+			formatBytes(target.FillRate), days),
+		notifications.LevelWarning
+}
+
+// label names a target in notifications
+func (t Target) label() string {
+	switch t.Kind {
+	case KindArray:
+		return "Array"
+	case KindDocker:
+		return "Docker storage"
+	case KindPool:
+		return "Pool " + t.Name
+	}
+	return t.Name
+}
+
+// formatBytes converts bytes to human-readable format
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < 0 {
+		return "-" + formatBytes(-bytes)
+	}
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit && exp < 4; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %s", float64(bytes)/float64(div), []string{"KB", "MB", "GB", "TB", "PB"}[exp])
+}
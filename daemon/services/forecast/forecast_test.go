@@ -0,0 +1,160 @@
+package forecast
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/domalab/uma/daemon/plugins/notifications"
+	"github.com/domalab/uma/daemon/services/history"
+)
+
+const gb = 1 << 30
+
+type fakeNotifier struct {
+	titles []string
+	levels []notifications.NotificationLevel
+}
+
+func (f *fakeNotifier) CreateStorageNotification(title, message string, level notifications.NotificationLevel) (*notifications.Notification, error) {
+	f.titles = append(f.titles, title)
+	f.levels = append(f.levels, level)
+	return &notifications.Notification{Title: title, Message: message, Level: level}, nil
+}
+
+func TestFitLinear(t *testing.T) {
+	var samples []sample
+	for i := 0; i < 48; i++ {
+		samples = append(samples, sample{t: 1.7e9 + float64(i*3600), v: 100 + 2*float64(i)})
+	}
+
+	tr, ok := fitLinear(samples)
+	if !ok {
+		t.Fatal("expected a fit")
+	}
+	if math.Abs(tr.slope-2.0/3600) > 1e-9 || math.Abs(tr.r2-1) > 1e-9 {
+		t.Errorf("got slope %v and r² %v", tr.slope, tr.r2)
+	}
+
+	// 100 + 2 per hour reaches 300 after 100 hours
+	full, ok := fullAt(tr, nil, 300, samples[0].t, horizon)
+	if !ok || math.Abs(full-(samples[0].t+100*3600)) > 1 {
+		t.Errorf("got full at %v (%v), want %v", full, ok, samples[0].t+100*3600)
+	}
+	if _, ok := fullAt(trend{slope: -1, intercept: 1e12}, nil, 2e12, 1.7e9, horizon); ok {
+		t.Error("a shrinking target should not be projected full")
+	}
+	if _, ok := fitLinear(samples[:1]); ok {
+		t.Error("expected no fit from one sample")
+	}
+}
+
+func TestFitSeasonal(t *testing.T) {
+	// A flat trend with usage peaking at midday, three days from midnight
+	const midnight = 1700006400
+	var samples []sample
+	for i := 0; i < 72; i++ {
+		hour := i % 24
+		samples = append(samples, sample{t: float64(midnight + i*3600), v: 100 + 10*float64(min(hour, 23-hour))})
+	}
+
+	tr, _ := fitLinear(samples)
+	if _, ok := fitSeasonal(samples[:40], tr); ok {
+		t.Error("expected no seasonal fit from under two days")
+	}
+	se, ok := fitSeasonal(samples, tr)
+	if !ok {
+		t.Fatal("expected a seasonal fit")
+	}
+	if math.Abs(se.amplitude()-110) > 1e-6 || se.r2 < 0.999 {
+		t.Errorf("got amplitude %v and r² %v", se.amplitude(), se.r2)
+	}
+
+	// The daily peak of 210 passes a capacity of 200 at 10:00 although the trend is flat
+	now := samples[len(samples)-1].t + 3600
+	full, ok := fullAt(se.trend, se.offsets, 200, now, horizon)
+	if !ok || full != now+10*3600 {
+		t.Errorf("got full at %v (%v), want 10:00", full, ok)
+	}
+	if _, ok := fullAt(se.trend, se.offsets, 220, now, horizon); ok {
+		t.Error("a flat trend below capacity at its peak should not be projected full")
+	}
+}
+
+func TestServiceForecast(t *testing.T) {
+	store, err := history.NewStore(history.Config{
+		Path:  t.TempDir(),
+		Tiers: []history.Tier{{Resolution: time.Hour, Retention: 60 * 24 * time.Hour}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	// Ten days of hourly samples: the cache grows 10GB a day plus 24GB written
+	// through each day and moved off overnight, disk1 1GB a day
+	start := time.Now().Truncate(time.Hour).Add(-240 * time.Hour)
+	for i := 0; i < 240; i++ {
+		at := start.Add(time.Duration(i) * time.Hour)
+		days := float64(i) / 24
+		store.Record(MetricPools, at, map[string]float64{
+			"cache.used_size":  (100 + 10*days + float64(at.UTC().Hour())) * gb,
+			"cache.total_size": 500 * gb,
+		})
+		store.Record("array.state", at, map[string]float64{
+			"disks.disk1.used":  (1000 + days) * gb,
+			"disks.disk1.size":  8000 * gb,
+			"disks.parity.used": 0,
+			"disks.parity.size": 0,
+		})
+	}
+
+	notifier := &fakeNotifier{}
+	service := NewService(store, notifier, Config{ThresholdDays: 60})
+	report := service.Forecast()
+
+	if len(report.Targets) != 2 {
+		t.Fatalf("got %d targets, want the cache and disk1 without the parity disk", len(report.Targets))
+	}
+	cache, disk := report.Targets[0], report.Targets[1]
+	if cache.Kind != KindPool || cache.Name != "cache" || disk.Kind != KindDisk || disk.Name != "disk1" {
+		t.Fatalf("unexpected targets %+v", report.Targets)
+	}
+
+	// About (500 - 100 - 100 - 11.5) / 10 days from the linear trend, and
+	// sooner once the daily peak is included
+	if math.Abs(float64(cache.FillRate)/gb-10) > 0.5 || cache.Samples != 240 {
+		t.Errorf("got fill rate %d from %d samples", cache.FillRate, cache.Samples)
+	}
+	linear, seasonal := *cache.Linear.DaysUntilFull, *cache.Seasonal.DaysUntilFull
+	if math.Abs(linear-28.9) > 0.5 || seasonal >= linear || *cache.DaysUntilFull != seasonal {
+		t.Errorf("got linear %v, seasonal %v and days until full %v", linear, seasonal, *cache.DaysUntilFull)
+	}
+	if !cache.BelowThreshold || disk.BelowThreshold {
+		t.Errorf("got below threshold %v for the cache and %v for disk1", cache.BelowThreshold, disk.BelowThreshold)
+	}
+
+	// Notified once until the projection recovers
+	service.Check()
+	service.Check()
+	if len(notifier.titles) != 1 || notifier.levels[0] != notifications.LevelWarning {
+		t.Fatalf("got notifications %v", notifier.titles)
+	}
+	service.config.ThresholdDays = 20
+	service.Check()
+	service.config.ThresholdDays = 60
+	service.Check()
+	if len(notifier.titles) != 2 {
+		t.Errorf("expected a second notification after recovering, got %v", notifier.titles)
+	}
+
+	// A restart keeps the targets already notified about
+	statePath := filepath.Join(t.TempDir(), "forecast.json")
+	notifier = &fakeNotifier{}
+	NewService(store, notifier, Config{ThresholdDays: 60, StatePath: statePath}).Check()
+	NewService(store, notifier, Config{ThresholdDays: 60, StatePath: statePath}).Check()
+	if len(notifier.titles) != 1 {
+		t.Errorf("expected one notification across the restart, got %v", notifier.titles)
+	}
+}
@@ -0,0 +1,176 @@
+package forecast
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// The seasonal model repeats daily, one offset per hour, which captures
+	// the usual cycle of a cache pool filling up and being emptied by the mover
+	seasonPeriod = 24 * time.Hour
+	seasonPhases = 24
+)
+
+// sample is a usage reading at a Unix time in seconds
+type sample struct {
+	t, v float64
+}
+
+// trend is a least-squares line through usage over time
+type trend struct {
+	slope     float64 // Bytes per second
+	intercept float64
+	r2        float64
+}
+
+func (tr trend) at(t float64) float64 {
+	return tr.intercept + tr.slope*t
+}
+
+// fitLinear fits a line through at least two samples
+func fitLinear(samples []sample) (trend, bool) {
+	if len(samples) < 2 {
+		return trend{}, false
+	}
+
+	var meanT, meanV float64
+	for _, s := range samples {
+		meanT += s.t
+		meanV += s.v
+	}
+	meanT /= float64(len(samples))
+	meanV /= float64(len(samples))
+
+	// Centered, since Unix seconds squared lose precision
+	var covariance, variance float64
+	for _, s := range samples {
+		covariance += (s.t - meanT) * (s.v - meanV)
+		variance += (s.t - meanT) * (s.t - meanT)
+	}
+	if variance == 0 {
+		return trend{}, false
+	}
+
+	tr := trend{slope: covariance / variance}
+	tr.intercept = meanV - tr.slope*meanT
+	tr.r2 = rSquared(samples, meanV, func(s sample) float64 { return tr.at(s.t) })
+	return tr, true
+}
+
+// season is a linear trend plus a repeating offset per phase of the period
+type season struct {
+	trend
+	offsets []float64
+}
+
+func (se season) at(t float64) float64 {
+	return se.trend.at(t) + se.offsets[phase(t)]
+}
+
+// fitSeasonal fits the seasonal model, which needs two whole periods of
+// samples with every phase seen at least once
+func fitSeasonal(samples []sample, tr trend) (season, bool) {
+	if len(samples) < 2 || samples[len(samples)-1].t-samples[0].t < 2*seasonPeriod.Seconds() {
+		return season{}, false
+	}
+
+	sums := make([]float64, seasonPhases)
+	counts := make([]int, seasonPhases)
+	var meanV float64
+	for _, s := range samples {
+		p := phase(s.t)
+		sums[p] += s.v - tr.at(s.t)
+		counts[p]++
+		meanV += s.v
+	}
+	meanV /= float64(len(samples))
+
+	se := season{trend: tr, offsets: make([]float64, seasonPhases)}
+	for p := range sums {
+		if counts[p] == 0 {
+			return season{}, false
+		}
+		se.offsets[p] = sums[p] / float64(counts[p])
+	}
+	se.r2 = rSquared(samples, meanV, func(s sample) float64 { return se.at(s.t) })
+	return se, true
+}
+
+// amplitude is the spread between the highest and lowest offsets
+func (se season) amplitude() float64 {
+	lowest, highest := se.offsets[0], se.offsets[0]
+	for _, offset := range se.offsets {
+		lowest = math.Min(lowest, offset)
+		highest = math.Max(highest, offset)
+	}
+	return highest - lowest
+}
+
+// phase returns which of the seasonPhases t falls in
+func phase(t float64) int {
+	period := seasonPeriod.Seconds()
+	p := int(math.Mod(t, period) / (period / seasonPhases))
+	if p < 0 {
+		p += seasonPhases
+	}
+	return p % seasonPhases
+}
+
+func rSquared(samples []sample, meanV float64, predict func(sample) float64) float64 {
+	var residual, total float64
+	for _, s := range samples {
+		residual += (s.v - predict(s)) * (s.v - predict(s))
+		total += (s.v - meanV) * (s.v - meanV)
+	}
+	if total == 0 {
+		return 1
+	}
+	return 1 - residual/total
+}
+
+// fullAt returns when the model first reaches capacity after now, or false
+// if it does not within horizon. offsets may be nil for the linear model.
+func fullAt(tr trend, offsets []float64, capacity, now float64, horizon time.Duration) (float64, bool) {
+	value := func(t float64) float64 {
+		if offsets == nil {
+			return tr.at(t)
+		}
+		return tr.at(t) + offsets[phase(t)]
+	}
+	if value(now) >= capacity {
+		return now, true
+	}
+
+	peak := 0.0
+	for _, offset := range offsets {
+		peak = math.Max(peak, offset)
+	}
+
+	// The trend plus the highest offset bounds the model, so nothing before
+	// start can reach capacity, and the peak phase does within a period of it
+	start := now
+	if tr.slope > 0 {
+		start = math.Max(now, (capacity-peak-tr.intercept)/tr.slope)
+	}
+	if start-now > horizon.Seconds() {
+		return 0, false
+	}
+	if offsets == nil {
+		if tr.slope <= 0 {
+			return 0, false
+		}
+		return start, true
+	}
+
+	step := seasonPeriod.Seconds() / seasonPhases
+	for t := start; t <= start+seasonPeriod.Seconds()+step; t += step {
+		if t-now > horizon.Seconds() {
+			break
+		}
+		if value(t) >= capacity {
+			return t, true
+		}
+	}
+	return 0, false
+}
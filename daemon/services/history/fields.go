@@ -0,0 +1,22 @@
+package history
+
+import (
+	"github.com/domalab/uma/daemon/services/collectors"
+)
+
+// numericFields returns the numeric fields of a snapshot by their dotted
+// paths, such as cpu_percent or containers.plex.memory_usage
+func numericFields(data interface{}) (map[string]float64, error) {
+	fields, err := collectors.Flatten(data)
+	if err != nil {
+		return nil, err
+	}
+
+	numbers := make(map[string]float64, len(fields))
+	for path, value := range fields {
+		if f, ok := value.(float64); ok {
+			numbers[path] = f
+		}
+	}
+	return numbers, nil
+}
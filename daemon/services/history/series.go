@@ -0,0 +1,271 @@
+package history
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	seriesExtension = ".ring"
+
+	// Series files start with the magic, a format version and the tier
+	// layout; a file whose header does not match the configured tiers is reset
+	seriesMagic   = "UMAH"
+	seriesVersion = 3
+
+	// Each bucket is its index since the epoch (0 for empty), the number of
+	// samples in it and their min, max and average, as big-endian uint32s and
+	// float64s so byte and counter series keep their precision
+	bucketSize = 32
+)
+
+// fileLayout locates each tier's ring within a series file
+type fileLayout struct {
+	header  []byte
+	offsets []int64 // Start of each tier's ring
+	tiers   []Tier
+	size    int64
+}
+
+func newFileLayout(tiers []Tier) fileLayout {
+	var header bytes.Buffer
+	header.WriteString(seriesMagic)
+	header.Write([]byte{seriesVersion, byte(len(tiers)), 0, 0})
+	for _, tier := range tiers {
+		binary.Write(&header, binary.BigEndian, uint32(tier.Resolution/time.Second))
+		binary.Write(&header, binary.BigEndian, uint32(tier.capacity()))
+	}
+
+	layout := fileLayout{header: header.Bytes(), tiers: tiers}
+	offset := int64(header.Len())
+	for _, tier := range tiers {
+		layout.offsets = append(layout.offsets, offset)
+		offset += tier.capacity() * bucketSize
+	}
+	layout.size = offset
+	return layout
+}
+
+// bucket is the aggregate of the samples in one tier bucket
+type bucket struct {
+	index         int64 // Bucket start divided by the tier resolution
+	count         int
+	min, max, avg float64
+}
+
+// accumulator aggregates the samples of the bucket currently being written
+type accumulator struct {
+	index    int64
+	min, max float64
+	sum      float64
+	count    int
+}
+
+// series is one field's ring file, opened on first use
+type series struct {
+	path    string
+	layout  fileLayout
+	file    *os.File
+	open    []accumulator // Per tier
+	removed bool          // Evicted; later samples are dropped
+	mutex   sync.Mutex
+
+	lastSample atomic.Int64 // Unix nanoseconds of the latest sample, for eviction
+}
+
+// ensureOpen opens the file, resetting it if it was written with another layout.
+// The caller must hold the series mutex.
+func (s *series) ensureOpen() error {
+	if s.file != nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create series directory: %w", err)
+	}
+	file, err := os.OpenFile(s.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open series: %w", err)
+	}
+
+	header := make([]byte, len(s.layout.header))
+	info, err := file.Stat()
+	if err == nil && info.Size() == s.layout.size {
+		_, err = file.ReadAt(header, 0)
+	}
+	if err != nil || info.Size() != s.layout.size || !bytes.Equal(header, s.layout.header) {
+		// Empty buckets are zero, so a sparse file of the right size is an empty series
+		if err := file.Truncate(0); err == nil {
+			err = file.Truncate(s.layout.size)
+		}
+		if err == nil {
+			_, err = file.WriteAt(s.layout.header, 0)
+		}
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("failed to initialize series: %w", err)
+		}
+	}
+
+	s.file = file
+	s.open = make([]accumulator, len(s.layout.tiers))
+	return nil
+}
+
+// record adds a sample to the current bucket of every tier
+func (s *series) record(timestamp time.Time, value float64) error {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.removed {
+		return nil
+	}
+	if err := s.ensureOpen(); err != nil {
+		return err
+	}
+	if nanos := timestamp.UnixNano(); nanos > s.lastSample.Load() {
+		s.lastSample.Store(nanos)
+	}
+
+	for i, tier := range s.layout.tiers {
+		index := timestamp.Unix() / int64(tier.Resolution/time.Second)
+		acc := &s.open[i]
+
+		if acc.index != index {
+			*acc = accumulator{index: index, min: value, max: value}
+			// Continue a bucket written before a restart rather than overwrite it
+			if existing, err := s.readBucket(i, index); err == nil && existing.index == index {
+				acc.min, acc.max = existing.min, existing.max
+				acc.sum, acc.count = existing.avg*float64(existing.count), existing.count
+			}
+		}
+
+		acc.min = math.Min(acc.min, value)
+		acc.max = math.Max(acc.max, value)
+		acc.sum += value
+		acc.count++
+
+		if err := s.writeBucket(i, bucket{index: index, count: acc.count, min: acc.min, max: acc.max, avg: acc.sum / float64(acc.count)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// slotOffset returns where bucket index of tier is stored
+func (s *series) slotOffset(tier int, index int64) int64 {
+	return s.layout.offsets[tier] + index%s.layout.tiers[tier].capacity()*bucketSize
+}
+
+func (s *series) writeBucket(tier int, b bucket) error {
+	buf := make([]byte, bucketSize)
+	binary.BigEndian.PutUint32(buf[0:], uint32(b.index))
+	binary.BigEndian.PutUint32(buf[4:], uint32(b.count))
+	binary.BigEndian.PutUint64(buf[8:], math.Float64bits(b.min))
+	binary.BigEndian.PutUint64(buf[16:], math.Float64bits(b.max))
+	binary.BigEndian.PutUint64(buf[24:], math.Float64bits(b.avg))
+	_, err := s.file.WriteAt(buf, s.slotOffset(tier, b.index))
+	return err
+}
+
+func (s *series) readBucket(tier int, index int64) (bucket, error) {
+	buf := make([]byte, bucketSize)
+	if _, err := s.file.ReadAt(buf, s.slotOffset(tier, index)); err != nil {
+		return bucket{}, err
+	}
+	return decodeBucket(buf), nil
+}
+
+func decodeBucket(buf []byte) bucket {
+	return bucket{
+		index: int64(binary.BigEndian.Uint32(buf[0:])),
+		count: int(binary.BigEndian.Uint32(buf[4:])),
+		min:   math.Float64frombits(binary.BigEndian.Uint64(buf[8:])),
+		max:   math.Float64frombits(binary.BigEndian.Uint64(buf[16:])),
+		avg:   math.Float64frombits(binary.BigEndian.Uint64(buf[24:])),
+	}
+}
+
+// read returns the stored buckets of tier from first to last index inclusive, oldest first
+func (s *series) read(tier int, first, last int64) ([]bucket, error) {
+	capacity := s.layout.tiers[tier].capacity()
+	if last-first+1 > capacity {
+		first = last - capacity + 1
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.removed {
+		return nil, nil
+	}
+	if err := s.ensureOpen(); err != nil {
+		return nil, err
+	}
+
+	// The range wraps around the end of the ring at most once
+	count := last - first + 1
+	start := first % capacity
+	head := count
+	if start+count > capacity {
+		head = capacity - start
+	}
+	buf := make([]byte, count*bucketSize)
+	if _, err := s.file.ReadAt(buf[:head*bucketSize], s.layout.offsets[tier]+start*bucketSize); err != nil {
+		return nil, fmt.Errorf("failed to read series: %w", err)
+	}
+	if head < count {
+		if _, err := s.file.ReadAt(buf[head*bucketSize:], s.layout.offsets[tier]); err != nil {
+			return nil, fmt.Errorf("failed to read series: %w", err)
+		}
+	}
+
+	buckets := make([]bucket, 0, count)
+	for i := int64(0); i < count; i++ {
+		b := decodeBucket(buf[i*bucketSize:])
+		// Slots still holding an older lap of the ring, or nothing, are skipped
+		if b.index == first+i {
+			buckets = append(buckets, b)
+		}
+	}
+	return buckets, nil
+}
+
+func (s *series) close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// remove closes the series and deletes its file; it records nothing afterwards
+func (s *series) remove() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.removed = true
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
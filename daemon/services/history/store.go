@@ -0,0 +1,456 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/domalab/uma/daemon/logger"
+)
+
+// ErrSeriesNotFound is returned when querying a series that was never recorded
+var ErrSeriesNotFound = errors.New("series not found")
+
+// Tier is one level of downsampling: samples are aggregated into buckets of
+// Resolution and the most recent Retention worth of buckets is kept
+type Tier struct {
+	Resolution time.Duration `json:"resolution"`
+	Retention  time.Duration `json:"retention"`
+}
+
+// capacity is the number of buckets in the tier's ring
+func (t Tier) capacity() int64 {
+	return int64(t.Retention / t.Resolution)
+}
+
+// DefaultTiers keeps an hour at 1s, a week at 1m and a year at 15m, about 1.6MB per series
+var DefaultTiers = []Tier{
+	{Resolution: time.Second, Retention: time.Hour},
+	{Resolution: time.Minute, Retention: 7 * 24 * time.Hour},
+	{Resolution: 15 * time.Minute, Retention: 365 * 24 * time.Hour},
+}
+
+// DefaultStaleAfter is how long a series goes without samples before it may
+// be evicted to make room for a new one
+const DefaultStaleAfter = 24 * time.Hour
+
+// refusedRetry is how long a series refused at the size cap waits before
+// trying again, in case another has gone stale since
+const refusedRetry = time.Minute
+
+// Config configures a Store
+type Config struct {
+	Path       string        // Directory holding one ring file per series
+	MaxSize    int64         // Bytes all series files may use; new series need room beyond it
+	Tiers      []Tier        // Finest first; DefaultTiers when empty
+	Keep       []string      // Series recorded even if others must be evicted, as metric or metric:field pattern
+	StaleAfter time.Duration // DefaultStaleAfter when zero
+}
+
+// Store is an embedded time-series store fed with collector snapshots. Every
+// numeric field of a collector's data becomes a series, kept on disk as a
+// fixed-size file of ring buffers, one per tier, so the store never grows
+// beyond MaxSize regardless of how long it runs. At the size cap a new series
+// replaces one that has gone stale, such as a removed container's, and a
+// series of a kept metric replaces the least recently recorded other one.
+type Store struct {
+	path       string
+	maxSize    int64
+	tiers      []Tier
+	layout     fileLayout
+	keep       []string
+	staleAfter time.Duration
+
+	series  map[seriesKey]*series
+	refused map[seriesKey]time.Time // When a series was last refused at the size cap
+	capped  bool                    // Whether refusing a series at the size cap has been logged
+	closed  bool
+	mutex   sync.RWMutex
+}
+
+type seriesKey struct {
+	metric string
+	field  string
+}
+
+// SeriesInfo describes a recorded series
+type SeriesInfo struct {
+	Metric string `json:"metric"`
+	Field  string `json:"field"`
+}
+
+// Point is the aggregate of the samples in one step
+type Point struct {
+	Timestamp int64   `json:"timestamp"`
+	Min       float64 `json:"min"`
+	Max       float64 `json:"max"`
+	Avg       float64 `json:"avg"`
+}
+
+// Series is the result of a query
+type Series struct {
+	Metric     string  `json:"metric"`
+	Field      string  `json:"field"`
+	From       int64   `json:"from"`
+	To         int64   `json:"to"`
+	Step       int64   `json:"step"`       // Seconds per point
+	Resolution int64   `json:"resolution"` // Seconds per bucket of the tier read
+	Points     []Point `json:"points"`
+}
+
+// Stats describes the store's disk usage
+type Stats struct {
+	Series      int    `json:"series"`
+	SizeBytes   int64  `json:"size_bytes"`
+	MaxBytes    int64  `json:"max_size_bytes"`
+	SeriesBytes int64  `json:"series_size_bytes"`
+	Tiers       []Tier `json:"tiers"`
+}
+
+// NewStore opens the store at config.Path, picking up series recorded by previous runs
+func NewStore(config Config) (*Store, error) {
+	tiers := config.Tiers
+	if len(tiers) == 0 {
+		tiers = DefaultTiers
+	}
+	for _, tier := range tiers {
+		if tier.Resolution < time.Second || tier.Resolution%time.Second != 0 || tier.capacity() < 1 {
+			return nil, fmt.Errorf("invalid tier %v/%v: resolution must be whole seconds and at most the retention", tier.Resolution, tier.Retention)
+		}
+	}
+
+	if err := os.MkdirAll(config.Path, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	store := &Store{
+		path:       config.Path,
+		maxSize:    config.MaxSize,
+		tiers:      tiers,
+		layout:     newFileLayout(tiers),
+		keep:       config.Keep,
+		staleAfter: config.StaleAfter,
+		series:     make(map[seriesKey]*series),
+		refused:    make(map[seriesKey]time.Time),
+	}
+	if store.staleAfter <= 0 {
+		store.staleAfter = DefaultStaleAfter
+	}
+	for _, entry := range config.Keep {
+		if _, pattern, ok := strings.Cut(entry, ":"); ok {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid keep entry %q: %w", entry, err)
+			}
+		}
+	}
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// load registers the series files already on disk; they are opened on first
+// use, and count as last recorded when their file was last written
+func (s *Store) load() error {
+	metrics, err := os.ReadDir(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read history directory: %w", err)
+	}
+
+	for _, metricDir := range metrics {
+		if !metricDir.IsDir() {
+			continue
+		}
+		metric, err := url.PathUnescape(metricDir.Name())
+		if err != nil {
+			continue
+		}
+
+		files, err := os.ReadDir(filepath.Join(s.path, metricDir.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read history directory: %w", err)
+		}
+		for _, file := range files {
+			name, ok := strings.CutSuffix(file.Name(), seriesExtension)
+			if !ok {
+				continue
+			}
+			field, err := url.PathUnescape(name)
+			if err != nil {
+				continue
+			}
+			key := seriesKey{metric: metric, field: field}
+			ser := &series{path: s.seriesPath(key), layout: s.layout}
+			if info, err := file.Info(); err == nil {
+				ser.lastSample.Store(info.ModTime().UnixNano())
+			}
+			s.series[key] = ser
+		}
+	}
+	return nil
+}
+
+// seriesPath returns the file a series is kept in
+func (s *Store) seriesPath(key seriesKey) string {
+	return filepath.Join(s.path, url.PathEscape(key.metric), url.PathEscape(key.field)+seriesExtension)
+}
+
+// Observe records the numeric fields of a collector snapshot. It has the
+// signature of a SystemCollector listener.
+func (s *Store) Observe(name string, data interface{}) {
+	values, err := numericFields(data)
+	if err != nil {
+		logger.Yellow("History cannot record %s: %v", name, err)
+		return
+	}
+	s.Record(name, time.Now(), values)
+}
+
+// Record adds one sample per field of metric at timestamp
+func (s *Store) Record(metric string, timestamp time.Time, values map[string]float64) {
+	// Sorted, so which fields fit under the size cap does not vary between runs
+	fields := make([]string, 0, len(values))
+	for field := range values {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		ser, ok := s.getOrCreate(seriesKey{metric: metric, field: field})
+		if !ok {
+			continue
+		}
+		if err := ser.record(timestamp, values[field]); err != nil {
+			logger.Yellow("Failed to record history for %s %s: %v", metric, field, err)
+		}
+	}
+}
+
+// getOrCreate returns the series for key, creating it if the size cap allows
+// or another series can be evicted for it
+func (s *Store) getOrCreate(key seriesKey) (*series, bool) {
+	s.mutex.RLock()
+	ser, exists := s.series[key]
+	refused := time.Since(s.refused[key]) < refusedRetry
+	closed := s.closed
+	s.mutex.RUnlock()
+	if exists || refused || closed {
+		return ser, exists && !closed
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if ser, exists := s.series[key]; exists {
+		return ser, true
+	}
+	if s.maxSize > 0 && int64(len(s.series)+1)*s.layout.size > s.maxSize && !s.evictFor(key) {
+		s.refused[key] = time.Now()
+		if !s.capped {
+			s.capped = true
+			logger.Yellow("History size cap of %d bytes reached with %d series, none stale; not recording %s %s or other new series until one is",
+				s.maxSize, len(s.series), key.metric, key.field)
+		}
+		return nil, false
+	}
+
+	delete(s.refused, key)
+	ser = &series{path: s.seriesPath(key), layout: s.layout}
+	s.series[key] = ser
+	return ser, true
+}
+
+// evictFor removes a series to make room for key: the one recorded least
+// recently among those not kept, provided it has gone stale or key is kept.
+// The caller holds the store mutex.
+func (s *Store) evictFor(key seriesKey) bool {
+	staleBefore := time.Now().Add(-s.staleAfter).UnixNano()
+	var victim seriesKey
+	var victimSample int64
+	found := false
+	for candidate, ser := range s.series {
+		if s.kept(candidate) {
+			continue
+		}
+		last := ser.lastSample.Load()
+		if !s.kept(key) && last > staleBefore {
+			continue
+		}
+		if !found || last < victimSample {
+			victim, victimSample, found = candidate, last, true
+		}
+	}
+	if !found {
+		return false
+	}
+
+	if err := s.series[victim].remove(); err != nil {
+		logger.Yellow("Failed to remove history for %s %s: %v", victim.metric, victim.field, err)
+	}
+	delete(s.series, victim)
+	logger.Blue("History size cap reached; dropped %s %s, last recorded %s, to record %s %s",
+		victim.metric, victim.field, time.Unix(0, victimSample).Format(time.RFC3339), key.metric, key.field)
+	return true
+}
+
+// kept reports whether a series matches a keep entry: its metric, or its
+// metric and a pattern of its field such as array.state:disks.*.used
+func (s *Store) kept(key seriesKey) bool {
+	for _, entry := range s.keep {
+		metric, pattern, hasPattern := strings.Cut(entry, ":")
+		if metric != key.metric {
+			continue
+		}
+		if !hasPattern {
+			return true
+		}
+		if matched, _ := path.Match(pattern, key.field); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Query returns the min, max and average of a field per step between from
+// and to. It reads the finest tier that still covers from; a step of zero
+// picks one giving at most maxPoints points.
+func (s *Store) Query(metric, field string, from, to time.Time, step time.Duration) (*Series, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("from must be before to")
+	}
+
+	s.mutex.RLock()
+	ser, exists := s.series[seriesKey{metric: metric, field: field}]
+	s.mutex.RUnlock()
+	if !exists {
+		return nil, ErrSeriesNotFound
+	}
+
+	tierIndex := s.tierFor(from)
+	tier := s.tiers[tierIndex]
+
+	resolution := int64(tier.Resolution / time.Second)
+	stepSeconds := int64(step / time.Second)
+	if stepSeconds <= 0 {
+		stepSeconds = (to.Unix() - from.Unix()) / maxPoints
+	}
+	// Steps are whole buckets of the tier read
+	if stepSeconds < resolution {
+		stepSeconds = resolution
+	}
+	stepSeconds = (stepSeconds + resolution - 1) / resolution * resolution
+
+	buckets, err := ser.read(tierIndex, from.Unix()/resolution, to.Unix()/resolution)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Series{
+		Metric:     metric,
+		Field:      field,
+		From:       from.Unix(),
+		To:         to.Unix(),
+		Step:       stepSeconds,
+		Resolution: resolution,
+		Points:     []Point{},
+	}
+
+	var current *Point
+	var sum float64
+	var count int
+	flush := func() {
+		if current != nil {
+			current.Avg = sum / float64(count)
+			result.Points = append(result.Points, *current)
+		}
+	}
+	for _, b := range buckets {
+		timestamp := b.index * resolution / stepSeconds * stepSeconds
+		if current == nil || current.Timestamp != timestamp {
+			flush()
+			current = &Point{Timestamp: timestamp, Min: b.min, Max: b.max}
+			sum, count = 0, 0
+		}
+		if b.min < current.Min {
+			current.Min = b.min
+		}
+		if b.max > current.Max {
+			current.Max = b.max
+		}
+		sum += b.avg * float64(b.count)
+		count += b.count
+	}
+	flush()
+
+	return result, nil
+}
+
+// maxPoints bounds the points returned when no step is given
+const maxPoints = 720
+
+// tierFor returns the finest tier whose retention reaches back to from
+func (s *Store) tierFor(from time.Time) int {
+	age := time.Since(from)
+	for i, tier := range s.tiers {
+		if age <= tier.Retention {
+			return i
+		}
+	}
+	return len(s.tiers) - 1
+}
+
+// List returns the recorded series of metric, or of every metric if it is empty
+func (s *Store) List(metric string) []SeriesInfo {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	list := make([]SeriesInfo, 0, len(s.series))
+	for key := range s.series {
+		if metric == "" || key.metric == metric {
+			list = append(list, SeriesInfo{Metric: key.metric, Field: key.field})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Metric != list[j].Metric {
+			return list[i].Metric < list[j].Metric
+		}
+		return list[i].Field < list[j].Field
+	})
+	return list
+}
+
+// Stats returns the store's disk usage
+func (s *Store) Stats() Stats {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return Stats{
+		Series:      len(s.series),
+		SizeBytes:   int64(len(s.series)) * s.layout.size,
+		MaxBytes:    s.maxSize,
+		SeriesBytes: s.layout.size,
+		Tiers:       s.tiers,
+	}
+}
+
+// Close closes every series file; later samples are dropped
+func (s *Store) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closed = true
+	var firstErr error
+	for _, ser := range s.series {
+		if err := ser.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
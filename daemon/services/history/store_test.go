@@ -0,0 +1,243 @@
+package history
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testTiers = []Tier{
+	{Resolution: time.Second, Retention: 10 * time.Second},
+	{Resolution: 5 * time.Second, Retention: time.Hour},
+}
+
+func newTestStore(t *testing.T, path string, maxSize int64) *Store {
+	t.Helper()
+
+	store, err := NewStore(Config{Path: path, MaxSize: maxSize, Tiers: testTiers})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// recordRamp records 0 to 29, one per second from start
+func recordRamp(store *Store, start time.Time) {
+	for i := 0; i < 30; i++ {
+		store.Record("system.cpu", start.Add(time.Duration(i)*time.Second), map[string]float64{"cpu_percent": float64(i)})
+	}
+}
+
+func TestStoreDownsampling(t *testing.T) {
+	store := newTestStore(t, t.TempDir(), 0)
+	defer store.Close()
+
+	start := time.Now().Add(-30 * time.Second).Truncate(5 * time.Second)
+	recordRamp(store, start)
+
+	// Older than the 1s tier's retention, so read from the 5s tier
+	series, err := store.Query("system.cpu", "cpu_percent", start, start.Add(29*time.Second), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if series.Resolution != 5 || series.Step != 5 || len(series.Points) != 6 {
+		t.Fatalf("got resolution %d, step %d and %d points", series.Resolution, series.Step, len(series.Points))
+	}
+	for k, point := range series.Points {
+		want := Point{Timestamp: start.Unix() + int64(5*k), Min: float64(5 * k), Max: float64(5*k + 4), Avg: float64(5*k + 2)}
+		if point != want {
+			t.Errorf("point %d = %+v, want %+v", k, point, want)
+		}
+	}
+
+	// Steps combine whole buckets and are aligned to multiples of the step
+	aligned := time.Now().Add(-time.Minute).Truncate(15 * time.Second)
+	for i := 0; i < 30; i++ {
+		store.Record("system.load", aligned.Add(time.Duration(i)*time.Second), map[string]float64{"load_1m": float64(i)})
+	}
+	series, err = store.Query("system.load", "load_1m", aligned, aligned.Add(29*time.Second), 12*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if series.Step != 15 || len(series.Points) != 2 {
+		t.Fatalf("got step %d and %d points, want step rounded up to 15 and 2 points", series.Step, len(series.Points))
+	}
+	if want := (Point{Timestamp: aligned.Unix() + 15, Min: 15, Max: 29, Avg: 22}); series.Points[1] != want {
+		t.Errorf("got %+v, want %+v", series.Points[1], want)
+	}
+
+	// Recent data comes from the 1s tier
+	series, err = store.Query("system.cpu", "cpu_percent", start.Add(27*time.Second), start.Add(29*time.Second), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if series.Resolution != 1 || len(series.Points) != 3 || series.Points[0].Avg != 27 {
+		t.Errorf("unexpected recent series %+v", series)
+	}
+
+	// The 1s ring only holds its last 10 buckets
+	ser := store.series[seriesKey{"system.cpu", "cpu_percent"}]
+	buckets, err := ser.read(0, start.Unix(), start.Unix()+29)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 10 || buckets[0].index != start.Unix()+20 {
+		t.Errorf("got %d buckets from %d, want 10 from %d", len(buckets), buckets[0].index, start.Unix()+20)
+	}
+
+	// Byte counters are kept exactly
+	store.Record("network.eth0", start, map[string]float64{"bytes_received": 123456789012345})
+	series, err = store.Query("network.eth0", "bytes_received", start, start.Add(4*time.Second), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series.Points) != 1 || series.Points[0].Avg != 123456789012345 {
+		t.Errorf("got %+v, want the counter unchanged", series.Points)
+	}
+
+	if _, err := store.Query("system.cpu", "nope", start, time.Now(), 0); !errors.Is(err, ErrSeriesNotFound) {
+		t.Errorf("expected ErrSeriesNotFound, got %v", err)
+	}
+}
+
+func TestStorePersistence(t *testing.T) {
+	path := t.TempDir()
+	start := time.Now().Add(-30 * time.Second).Truncate(5 * time.Second)
+
+	store := newTestStore(t, path, 0)
+	recordRamp(store, start)
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store = newTestStore(t, path, 0)
+	if list := store.List(""); !reflect.DeepEqual(list, []SeriesInfo{{Metric: "system.cpu", Field: "cpu_percent"}}) {
+		t.Fatalf("got series %v after reopening", list)
+	}
+
+	// A sample in a bucket written before the restart extends it, weighed against the 5 already in it
+	store.Record("system.cpu", start.Add(29*time.Second), map[string]float64{"cpu_percent": 100})
+	series, err := store.Query("system.cpu", "cpu_percent", start, start.Add(29*time.Second), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series.Points) != 6 {
+		t.Fatalf("got %d points after reopening, want 6", len(series.Points))
+	}
+	if last := series.Points[5]; last.Min != 25 || last.Max != 100 || last.Avg != (25+26+27+28+29+100)/6.0 {
+		t.Errorf("unexpected last point %+v", last)
+	}
+	store.Close()
+
+	// Files written with other tiers are reset rather than misread
+	store, err = NewStore(Config{Path: path, Tiers: []Tier{{Resolution: 5 * time.Second, Retention: time.Hour}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	series, err = store.Query("system.cpu", "cpu_percent", start, start.Add(29*time.Second), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series.Points) != 0 {
+		t.Errorf("got %d points from a file with another layout", len(series.Points))
+	}
+}
+
+func TestStoreSizeCap(t *testing.T) {
+	layout := newFileLayout(testTiers)
+	store := newTestStore(t, t.TempDir(), 2*layout.size)
+	defer store.Close()
+
+	store.Record("system.memory", time.Now(), map[string]float64{"used": 1, "free": 2, "total": 3})
+	store.Record("system.memory", time.Now(), map[string]float64{"used": 1, "free": 2, "total": 3})
+
+	want := []SeriesInfo{{Metric: "system.memory", Field: "free"}, {Metric: "system.memory", Field: "total"}}
+	if list := store.List("system.memory"); !reflect.DeepEqual(list, want) {
+		t.Errorf("got series %v, want %v", list, want)
+	}
+	if stats := store.Stats(); stats.SizeBytes != 2*layout.size || stats.MaxBytes != 2*layout.size {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestStoreEviction(t *testing.T) {
+	layout := newFileLayout(testTiers)
+	path := t.TempDir()
+	store, err := NewStore(Config{Path: path, MaxSize: 2 * layout.size, Tiers: testTiers, Keep: []string{"system.cpu", "array.state:disks.*.used"}, StaleAfter: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if _, err := NewStore(Config{Path: path, Tiers: testTiers, Keep: []string{"array.state:disks.[.used"}}); err == nil {
+		t.Error("expected an invalid keep pattern to be refused")
+	}
+
+	now := time.Now()
+	store.Record("containers.stats", now.Add(-2*time.Hour), map[string]float64{"gone.cpu_percent": 1})
+	store.Record("system.memory", now, map[string]float64{"used": 1})
+
+	// A stale series makes room for a new one
+	store.Record("system.memory", now, map[string]float64{"free": 2})
+	want := []SeriesInfo{{Metric: "system.memory", Field: "free"}, {Metric: "system.memory", Field: "used"}}
+	if list := store.List(""); !reflect.DeepEqual(list, want) {
+		t.Fatalf("got series %v, want %v", list, want)
+	}
+	if _, err := os.Stat(filepath.Join(path, "containers.stats", "gone.cpu_percent.ring")); !os.IsNotExist(err) {
+		t.Errorf("expected the evicted series file to be removed, got %v", err)
+	}
+
+	// Without a stale one, only kept metrics get in, replacing the least recently recorded
+	store.Record("system.memory", now.Add(time.Second), map[string]float64{"used": 1})
+	store.Record("system.memory", now, map[string]float64{"total": 3})
+	store.Record("system.cpu", now, map[string]float64{"cpu_percent": 5})
+	want = []SeriesInfo{{Metric: "system.cpu", Field: "cpu_percent"}, {Metric: "system.memory", Field: "used"}}
+	if list := store.List(""); !reflect.DeepEqual(list, want) {
+		t.Errorf("got series %v, want %v", list, want)
+	}
+
+	for key, want := range map[seriesKey]bool{
+		{"array.state", "disks.disk1.used"}:        true,
+		{"array.state", "disks.disk1.temperature"}: false,
+		{"system.memory", "used"}:                  false,
+	} {
+		if store.kept(key) != want {
+			t.Errorf("kept(%v) = %t, want %t", key, !want, want)
+		}
+	}
+}
+
+func TestNumericFields(t *testing.T) {
+	type disk struct {
+		Name        string `json:"name"`
+		Temperature int    `json:"temperature"`
+		SpunDown    bool   `json:"spun_down"`
+	}
+	data := struct {
+		Timestamp int64             `json:"timestamp"`
+		Load      []float64         `json:"load"`
+		Disks     []disk            `json:"disks"`
+		Labels    map[string]string `json:"labels"`
+	}{
+		Timestamp: 1700000000,
+		Load:      []float64{0.5, 0.25},
+		Disks:     []disk{{Name: "disk1", Temperature: 34}, {Name: "parity", Temperature: 38}},
+		Labels:    map[string]string{"a": "b"},
+	}
+
+	fields, err := numericFields(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{"load.0": 0.5, "load.1": 0.25, "disks.disk1.temperature": 34, "disks.parity.temperature": 38}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("got %v, want %v", fields, want)
+	}
+
+	if fields, _ := numericFields(42); fields["value"] != 42 {
+		t.Errorf("got %v for a bare number", fields)
+	}
+}
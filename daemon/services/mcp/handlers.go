@@ -1,126 +1,289 @@
 package mcp
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/domalab/uma/daemon/logger"
 )
 
-// handleInitialize handles the MCP initialize method
-func (c *Connection) handleInitialize(request *JSONRPCRequest) error {
-	logger.Info("MCP initialize request from connection %s", c.id)
+// incomingMessage is any JSON-RPC message a client may send. Responses to
+// server requests carry a result or error instead of a method.
+type incomingMessage struct {
+	JSONRPCRequest
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  json.RawMessage `json:"error,omitempty"`
+}
+
+// handleMessage decodes a JSON-RPC message or batch as received by any
+// transport, dispatches it and returns the encoded reply. It returns nil when
+// nothing needs a reply, as for notifications and client responses.
+func (s *Server) handleMessage(ctx context.Context, session *Session, data []byte) []byte {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '[' {
+		return encodeReply(s.dispatchMessage(ctx, session, data))
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return encodeReply(newErrorResponse(nil, ParseError, "Parse error"))
+	}
+	if len(batch) == 0 {
+		return encodeReply(newErrorResponse(nil, InvalidRequest, "Empty batch"))
+	}
+
+	responses := make([]*JSONRPCResponse, 0, len(batch))
+	for _, message := range batch {
+		if response := s.dispatchMessage(ctx, session, message); response != nil {
+			responses = append(responses, response)
+		}
+	}
+	if len(responses) == 0 {
+		return nil
+	}
+	return encodeReply(responses)
+}
+
+// dispatchMessage decodes and dispatches a single JSON-RPC message
+func (s *Server) dispatchMessage(ctx context.Context, session *Session, data []byte) *JSONRPCResponse {
+	var message incomingMessage
+	if err := json.Unmarshal(data, &message); err != nil {
+		return newErrorResponse(nil, ParseError, "Parse error")
+	}
 
+	// The server sends no requests of its own, so client responses are dropped
+	if message.Method == "" && (message.Result != nil || message.Error != nil) {
+		return nil
+	}
+
+	return s.Dispatch(ctx, session, &message.JSONRPCRequest)
+}
+
+// encodeReply marshals a response or batch of responses
+func encodeReply(reply interface{}) []byte {
+	if response, ok := reply.(*JSONRPCResponse); ok && response == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(reply)
+	if err != nil {
+		logger.Yellow("Failed to encode MCP response: %v", err)
+		data, _ = json.Marshal(newErrorResponse(nil, InternalError, "Internal error"))
+	}
+	return data
+}
+
+// Dispatch runs a JSON-RPC request against the server and returns the
+// response. Notifications (requests without an ID) return nil. session may be
+// nil for transports that cannot receive notifications, in which case
+// resource subscriptions are refused.
+func (s *Server) Dispatch(ctx context.Context, session *Session, request *JSONRPCRequest) *JSONRPCResponse {
+	if err := validateJSONRPCRequest(request); err != nil {
+		return newErrorResponse(request.ID, InvalidRequest, err.Error())
+	}
+
+	var result interface{}
+	var rpcErr *JSONRPCError
+
+	// Handle different MCP methods
+	switch request.Method {
+	case "initialize":
+		result, rpcErr = s.handleInitialize(request)
+	case "ping":
+		result = map[string]interface{}{}
+	case "tools/list":
+		result, rpcErr = s.handleToolsList(ctx)
+	case "tools/call":
+		result, rpcErr = s.handleToolsCall(ctx, request)
+	case "resources/list":
+		result = ResourcesListResult{Resources: s.resources.List()}
+	case "resources/templates/list":
+		result = ResourceTemplatesListResult{ResourceTemplates: s.resources.Templates()}
+	case "resources/read":
+		result, rpcErr = s.handleResourcesRead(ctx, request)
+	case "resources/subscribe", "resources/unsubscribe":
+		result, rpcErr = s.handleResourcesSubscribe(session, request)
+	case "prompts/list":
+		result = PromptsListResult{Prompts: s.prompts.List()}
+	case "prompts/get":
+		result, rpcErr = s.handlePromptsGet(ctx, request)
+	default:
+		if request.ID == nil {
+			// Notifications such as notifications/initialized need no reply
+			return nil
+		}
+		rpcErr = &JSONRPCError{Code: MethodNotFound, Message: "Method not found"}
+	}
+
+	if request.ID == nil {
+		return nil
+	}
+	if rpcErr != nil {
+		return &JSONRPCResponse{JSONRPC: "2.0", ID: request.ID, Error: rpcErr}
+	}
+	return &JSONRPCResponse{JSONRPC: "2.0", ID: request.ID, Result: result}
+}
+
+// decodeParams converts loosely typed request params into a params struct
+func decodeParams(params interface{}, v interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// handleInitialize handles the MCP initialize method
+func (s *Server) handleInitialize(request *JSONRPCRequest) (interface{}, *JSONRPCError) {
 	// Parse initialize parameters
 	var params InitializeParams
 	if request.Params != nil {
-		paramsData, err := json.Marshal(request.Params)
-		if err != nil {
-			return c.sendError(request.ID, InvalidParams, "Invalid parameters", nil)
-		}
-		if err := json.Unmarshal(paramsData, &params); err != nil {
-			return c.sendError(request.ID, InvalidParams, "Invalid parameters", nil)
+		if err := decodeParams(request.Params, &params); err != nil {
+			return nil, &JSONRPCError{Code: InvalidParams, Message: "Invalid parameters"}
 		}
 	}
 
-	// Validate protocol version
-	if params.ProtocolVersion != "" && params.ProtocolVersion != MCPProtocolVersion {
-		logger.Warn("Client requested protocol version %s, server supports %s",
-			params.ProtocolVersion, MCPProtocolVersion)
+	// Answer with the client's version when we speak it, otherwise our oldest
+	protocolVersion := MCPProtocolVersion
+	switch params.ProtocolVersion {
+	case MCPProtocolVersion, MCPStreamableProtocolVersion:
+		protocolVersion = params.ProtocolVersion
+	case "":
+	default:
+		logger.Warn("Client requested protocol version %s, server supports %s and %s",
+			params.ProtocolVersion, MCPProtocolVersion, MCPStreamableProtocolVersion)
 	}
 
-	// Create initialize result
-	result := InitializeResult{
-		ProtocolVersion: MCPProtocolVersion,
+	logger.Green("MCP client %s %s initialized", params.ClientInfo.Name, params.ClientInfo.Version)
+
+	return InitializeResult{
+		ProtocolVersion: protocolVersion,
 		Capabilities: ServerCapabilities{
 			Tools: &ToolsCapability{
-				ListChanged: false, // We don't support dynamic tool changes yet
+				ListChanged: false, // Tools are derived from the static route table
 			},
+			Resources: &ResourcesCapability{
+				Subscribe: true,
+			},
+			Prompts: &PromptsCapability{},
 			Logging: &LoggingCapability{},
 		},
 		ServerInfo: ServerInfo{
 			Name:    "UMA MCP Server",
-			Version: "1.0.0",
+			Version: "2.0.0",
 		},
-	}
-
-	logger.Green("MCP connection %s initialized successfully", c.id)
-	return c.sendResponse(request.ID, result)
+	}, nil
 }
 
 // handleToolsList handles the tools/list method
-func (c *Connection) handleToolsList(request *JSONRPCRequest) error {
-	logger.Info("MCP tools/list request from connection %s", c.id)
-
-	// Get tools from registry
-	tools, err := c.server.registry.GetTools()
-	if err != nil {
-		logger.Yellow("Error getting tools: %v", err)
-		return c.sendError(request.ID, InternalError, "Failed to get tools", nil)
-	}
-
-	result := ToolsListResult{
-		Tools: tools,
-	}
-
-	logger.Green("Returning %d tools to connection %s", len(tools), c.id)
-	return c.sendResponse(request.ID, result)
+func (s *Server) handleToolsList(ctx context.Context) (interface{}, *JSONRPCError) {
+	return ToolsListResult{Tools: s.registry.GetTools(ctx)}, nil
 }
 
 // handleToolsCall handles the tools/call method
-func (c *Connection) handleToolsCall(request *JSONRPCRequest) error {
-	logger.Info("MCP tools/call request from connection %s", c.id)
-
+func (s *Server) handleToolsCall(ctx context.Context, request *JSONRPCRequest) (interface{}, *JSONRPCError) {
 	// Parse tool call parameters
-	var params ToolCallParams
 	if request.Params == nil {
-		return c.sendError(request.ID, InvalidParams, "Missing parameters", nil)
+		return nil, &JSONRPCError{Code: InvalidParams, Message: "Missing parameters"}
 	}
 
-	paramsData, err := json.Marshal(request.Params)
-	if err != nil {
-		return c.sendError(request.ID, InvalidParams, "Invalid parameters", nil)
-	}
-	if err := json.Unmarshal(paramsData, &params); err != nil {
-		return c.sendError(request.ID, InvalidParams, "Invalid parameters", nil)
+	var params ToolCallParams
+	if err := decodeParams(request.Params, &params); err != nil {
+		return nil, &JSONRPCError{Code: InvalidParams, Message: "Invalid parameters"}
 	}
 
 	if params.Name == "" {
-		return c.sendError(request.ID, InvalidParams, "Tool name is required", nil)
+		return nil, &JSONRPCError{Code: InvalidParams, Message: "Tool name is required"}
 	}
 
-	logger.Info("Executing tool '%s' for connection %s", params.Name, c.id)
-
-	// Execute tool
-	result, err := c.server.registry.ExecuteTool(params.Name, params.Arguments)
+	result, err := s.registry.ExecuteTool(ctx, params.Name, params.Arguments)
 	if err != nil {
+		switch {
+		case errors.Is(err, ErrToolNotFound):
+			return nil, &JSONRPCError{Code: ToolNotFound, Message: fmt.Sprintf("Tool '%s' not found", params.Name)}
+		case errors.Is(err, ErrInvalidArguments):
+			return nil, &JSONRPCError{Code: InvalidParams, Message: err.Error()}
+		}
+
+		// Execution failures are reported to the model as error results
 		logger.Yellow("Tool execution error: %v", err)
+		return ToolCallResult{
+			Content: []ToolContent{{Type: "text", Text: err.Error()}},
+			IsError: true,
+		}, nil
+	}
 
-		// Check if it's a tool not found error
-		if err.Error() == "tool not found" {
-			return c.sendError(request.ID, ToolNotFound, fmt.Sprintf("Tool '%s' not found", params.Name), nil)
+	return result, nil
+}
+
+// handleResourcesRead handles the resources/read method
+func (s *Server) handleResourcesRead(ctx context.Context, request *JSONRPCRequest) (interface{}, *JSONRPCError) {
+	var params ResourceParams
+	if err := decodeParams(request.Params, &params); err != nil || params.URI == "" {
+		return nil, &JSONRPCError{Code: InvalidParams, Message: "Resource URI is required"}
+	}
+
+	result, err := s.resources.Read(ctx, params.URI)
+	if err != nil {
+		if !errors.Is(err, ErrResourceNotFound) {
+			logger.Yellow("Resource read error: %v", err)
 		}
+		return nil, &JSONRPCError{Code: ResourceError, Message: err.Error()}
+	}
 
-		// Return tool execution error
-		return c.sendResponse(request.ID, ToolCallResult{
-			Content: []ToolContent{
-				{
-					Type: "text",
-					Text: fmt.Sprintf("Error executing tool: %v", err),
-				},
-			},
-			IsError: true,
-		})
+	return result, nil
+}
+
+// handleResourcesSubscribe handles the resources/subscribe and resources/unsubscribe methods
+func (s *Server) handleResourcesSubscribe(session *Session, request *JSONRPCRequest) (interface{}, *JSONRPCError) {
+	if session == nil {
+		return nil, &JSONRPCError{Code: InvalidRequest, Message: "Subscriptions require a session"}
+	}
+
+	var params ResourceParams
+	if err := decodeParams(request.Params, &params); err != nil || params.URI == "" {
+		return nil, &JSONRPCError{Code: InvalidParams, Message: "Resource URI is required"}
+	}
+
+	if request.Method == "resources/unsubscribe" {
+		session.Unsubscribe(params.URI)
+		return map[string]interface{}{}, nil
+	}
+
+	if _, ok := s.resources.Topic(params.URI); !ok {
+		return nil, &JSONRPCError{Code: ResourceError, Message: fmt.Sprintf("%v: %s", ErrResourceNotFound, params.URI)}
 	}
+	session.Subscribe(params.URI)
 
-	logger.Green("Tool '%s' executed successfully for connection %s", params.Name, c.id)
-	return c.sendResponse(request.ID, result)
+	return map[string]interface{}{}, nil
 }
 
-// Additional helper methods for error handling and logging
+// handlePromptsGet handles the prompts/get method
+func (s *Server) handlePromptsGet(ctx context.Context, request *JSONRPCRequest) (interface{}, *JSONRPCError) {
+	var params PromptGetParams
+	if err := decodeParams(request.Params, &params); err != nil || params.Name == "" {
+		return nil, &JSONRPCError{Code: InvalidParams, Message: "Prompt name is required"}
+	}
+
+	result, err := s.prompts.Get(ctx, params.Name, params.Arguments)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidArguments):
+			return nil, &JSONRPCError{Code: InvalidParams, Message: err.Error()}
+		case !errors.Is(err, ErrPromptNotFound):
+			logger.Yellow("Prompt error: %v", err)
+		}
+		return nil, &JSONRPCError{Code: PromptError, Message: err.Error()}
+	}
+
+	return result, nil
+}
 
 // validateJSONRPCRequest validates basic JSON-RPC request structure
-func (c *Connection) validateJSONRPCRequest(request *JSONRPCRequest) error {
+func validateJSONRPCRequest(request *JSONRPCRequest) error {
 	if request.JSONRPC != "2.0" {
 		return fmt.Errorf("invalid JSON-RPC version: %s", request.JSONRPC)
 	}
@@ -134,11 +297,14 @@ func (c *Connection) validateJSONRPCRequest(request *JSONRPCRequest) error {
 
 // GetConnectionStats returns statistics about the connection
 func (c *Connection) GetConnectionStats() map[string]interface{} {
-	return map[string]interface{}{
-		"id":          c.id,
-		"remote_addr": c.conn.RemoteAddr().String(),
-		"connected":   c.ctx.Err() == nil,
+	stats := map[string]interface{}{
+		"id":        c.id,
+		"connected": c.ctx.Err() == nil,
 	}
+	if c.conn != nil {
+		stats["remote_addr"] = c.conn.RemoteAddr().String()
+	}
+	return stats
 }
 
 // GetServerStats returns statistics about the MCP server
@@ -146,9 +312,9 @@ func (s *Server) GetServerStats() map[string]interface{} {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
-	connectionStats := make([]map[string]interface{}, 0, len(s.connections))
-	for _, conn := range s.connections {
-		connectionStats = append(connectionStats, conn.GetConnectionStats())
+	sessionStats := make([]map[string]interface{}, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessionStats = append(sessionStats, session.stats())
 	}
 
 	// Get registry stats
@@ -156,10 +322,9 @@ func (s *Server) GetServerStats() map[string]interface{} {
 
 	stats := map[string]interface{}{
 		"enabled":            s.config.Enabled,
-		"port":               s.config.Port,
 		"max_connections":    s.config.MaxConnections,
-		"active_connections": len(s.connections),
-		"connections":        connectionStats,
+		"active_connections": len(s.sessions),
+		"connections":        sessionStats,
 	}
 
 	// Merge registry stats
@@ -4,65 +4,24 @@ import (
 	"context"
 	"testing"
 
-	"github.com/domalab/uma/daemon/services/config"
+	"github.com/domalab/uma/daemon/domain"
+	"github.com/domalab/uma/daemon/services/auth"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-// MockConnection provides a mock connection for testing handlers
-type MockConnection struct {
-	id            string
-	sentResponses []JSONRPCResponse
-	sentErrors    []JSONRPCResponse
-}
-
-func (m *MockConnection) sendResponse(id interface{}, result interface{}) error {
-	response := JSONRPCResponse{
-		JSONRPC: "2.0",
-		ID:      id,
-		Result:  result,
-	}
-	m.sentResponses = append(m.sentResponses, response)
-	return nil
-}
-
-func (m *MockConnection) sendError(id interface{}, code int, message string, data interface{}) error {
-	response := JSONRPCResponse{
-		JSONRPC: "2.0",
-		ID:      id,
-		Error: &JSONRPCError{
-			Code:    code,
-			Message: message,
-			Data:    data,
-		},
-	}
-	m.sentErrors = append(m.sentErrors, response)
-	return nil
+func newTestServer(t *testing.T) *Server {
+	return NewServer(domain.MCPConfig{Enabled: true, MaxConnections: 100}, newTestRegistry(t))
 }
 
 // TestHandleInitialize tests the MCP initialize method
 func TestHandleInitialize(t *testing.T) {
-	config := config.MCPConfig{
-		Enabled:        true,
-		Port:           34800,
-		MaxConnections: 100,
-	}
-
-	mockAPI := &MockAPIInterface{}
-	server := NewServer(config, mockAPI)
-
-	mockConn := &MockConnection{id: "test-conn"}
-	conn := &Connection{
-		id:     "test-conn",
-		server: server,
-		ctx:    context.Background(),
-	}
+	server := newTestServer(t)
 
 	tests := []struct {
 		name     string
 		request  *JSONRPCRequest
-		wantErr  bool
-		validate func(t *testing.T, responses []JSONRPCResponse, errors []JSONRPCResponse)
+		validate func(t *testing.T, response *JSONRPCResponse)
 	}{
 		{
 			name: "successful initialize",
@@ -79,30 +38,17 @@ func TestHandleInitialize(t *testing.T) {
 					},
 				},
 			},
-			wantErr: false,
-			validate: func(t *testing.T, responses []JSONRPCResponse, errors []JSONRPCResponse) {
-				require.Len(t, responses, 1)
-				require.Len(t, errors, 0)
-
-				response := responses[0]
+			validate: func(t *testing.T, response *JSONRPCResponse) {
+				require.Nil(t, response.Error)
 				assert.Equal(t, "init-1", response.ID)
-				assert.NotNil(t, response.Result)
 
-				// Validate result structure
 				result, ok := response.Result.(InitializeResult)
-				if !ok {
-					// Try to convert from map
-					resultMap, ok := response.Result.(map[string]interface{})
-					require.True(t, ok, "Result should be InitializeResult or map")
-
-					assert.Equal(t, MCPProtocolVersion, resultMap["protocolVersion"])
-					assert.Contains(t, resultMap, "capabilities")
-					assert.Contains(t, resultMap, "serverInfo")
-				} else {
-					assert.Equal(t, MCPProtocolVersion, result.ProtocolVersion)
-					assert.NotNil(t, result.Capabilities)
-					assert.NotNil(t, result.ServerInfo)
-				}
+				require.True(t, ok, "Result should be InitializeResult")
+				assert.Equal(t, MCPProtocolVersion, result.ProtocolVersion)
+				assert.NotNil(t, result.Capabilities.Tools)
+				require.NotNil(t, result.Capabilities.Resources)
+				assert.True(t, result.Capabilities.Resources.Subscribe)
+				assert.Equal(t, "UMA MCP Server", result.ServerInfo.Name)
 			},
 		},
 		{
@@ -113,10 +59,9 @@ func TestHandleInitialize(t *testing.T) {
 				Method:  "initialize",
 				Params:  "invalid-params",
 			},
-			wantErr: false, // Should handle gracefully
-			validate: func(t *testing.T, responses []JSONRPCResponse, errors []JSONRPCResponse) {
-				// Should either respond with error or handle gracefully
-				assert.True(t, len(responses) > 0 || len(errors) > 0)
+			validate: func(t *testing.T, response *JSONRPCResponse) {
+				require.NotNil(t, response.Error)
+				assert.Equal(t, InvalidParams, response.Error.Code)
 			},
 		},
 		{
@@ -127,276 +72,144 @@ func TestHandleInitialize(t *testing.T) {
 				Method:  "initialize",
 				Params:  nil,
 			},
-			wantErr: false,
-			validate: func(t *testing.T, responses []JSONRPCResponse, errors []JSONRPCResponse) {
-				require.Len(t, responses, 1)
-				assert.Equal(t, "init-3", responses[0].ID)
+			validate: func(t *testing.T, response *JSONRPCResponse) {
+				require.Nil(t, response.Error)
+				assert.Equal(t, "init-3", response.ID)
 			},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Reset mock connection
-			mockConn.sentResponses = nil
-			mockConn.sentErrors = nil
-
-			// Create a test connection that uses our mock
-			testConn := &testConnection{
-				Connection: conn,
-				mock:       mockConn,
-			}
-
-			err := testConn.handleInitialize(tt.request)
-			if tt.wantErr {
-				assert.Error(t, err)
-			} else {
-				assert.NoError(t, err)
-			}
-
-			tt.validate(t, mockConn.sentResponses, mockConn.sentErrors)
+			response := server.Dispatch(context.Background(), nil, tt.request)
+			require.NotNil(t, response)
+			tt.validate(t, response)
 		})
 	}
 }
 
 // TestHandleToolsList tests the tools/list method
 func TestHandleToolsList(t *testing.T) {
-	config := config.MCPConfig{
-		Enabled:        true,
-		Port:           34800,
-		MaxConnections: 100,
-	}
+	server := newTestServer(t)
 
-	mockAPI := &MockAPIInterface{}
-	server := NewServer(config, mockAPI)
-
-	mockConn := &MockConnection{id: "test-conn"}
-	conn := &Connection{
-		id:     "test-conn",
-		server: server,
-		ctx:    context.Background(),
-	}
-
-	request := &JSONRPCRequest{
+	response := server.Dispatch(context.Background(), nil, &JSONRPCRequest{
 		JSONRPC: "2.0",
 		ID:      "tools-1",
 		Method:  "tools/list",
-		Params:  nil,
-	}
-
-	testConn := &testConnection{
-		Connection: conn,
-		mock:       mockConn,
-	}
-
-	err := testConn.handleToolsList(request)
-	assert.NoError(t, err)
-
-	require.Len(t, mockConn.sentResponses, 1)
-	response := mockConn.sentResponses[0]
+	})
+	require.NotNil(t, response)
+	require.Nil(t, response.Error)
 	assert.Equal(t, "tools-1", response.ID)
-	assert.NotNil(t, response.Result)
 
-	// Validate tools list structure
 	result, ok := response.Result.(ToolsListResult)
-	if !ok {
-		// Try to convert from map
-		resultMap, ok := response.Result.(map[string]interface{})
-		require.True(t, ok, "Result should be ToolsListResult or map")
-		assert.Contains(t, resultMap, "tools")
-	} else {
-		assert.NotNil(t, result.Tools)
-	}
+	require.True(t, ok, "Result should be ToolsListResult")
+	require.Len(t, result.Tools, 3)
+	assert.Equal(t, "delete_widget", result.Tools[0].Name)
+
+	// Read-only keys only see read-only tools
+	ctx := auth.NewContext(context.Background(), domain.APIKey{Name: "grafana", Scope: string(auth.ScopeReadOnly)})
+	response = server.Dispatch(ctx, nil, &JSONRPCRequest{JSONRPC: "2.0", ID: "tools-2", Method: "tools/list"})
+	result = response.Result.(ToolsListResult)
+	require.Len(t, result.Tools, 1)
+	assert.Equal(t, "get_widget", result.Tools[0].Name)
 }
 
 // TestHandleToolsCall tests the tools/call method
 func TestHandleToolsCall(t *testing.T) {
-	config := config.MCPConfig{
-		Enabled:        true,
-		Port:           34800,
-		MaxConnections: 100,
-	}
-
-	mockAPI := &MockAPIInterface{}
-	server := NewServer(config, mockAPI)
-
-	mockConn := &MockConnection{id: "test-conn"}
-	conn := &Connection{
-		id:     "test-conn",
-		server: server,
-		ctx:    context.Background(),
-	}
+	server := newTestServer(t)
 
 	tests := []struct {
 		name     string
-		request  *JSONRPCRequest
-		wantErr  bool
-		validate func(t *testing.T, responses []JSONRPCResponse, errors []JSONRPCResponse)
+		ctx      context.Context
+		params   interface{}
+		validate func(t *testing.T, response *JSONRPCResponse)
 	}{
 		{
-			name: "missing params",
-			request: &JSONRPCRequest{
-				JSONRPC: "2.0",
-				ID:      "call-1",
-				Method:  "tools/call",
-				Params:  nil,
+			name:   "missing params",
+			params: nil,
+			validate: func(t *testing.T, response *JSONRPCResponse) {
+				require.NotNil(t, response.Error)
+				assert.Equal(t, InvalidParams, response.Error.Code)
 			},
-			wantErr: false,
-			validate: func(t *testing.T, responses []JSONRPCResponse, errors []JSONRPCResponse) {
-				require.Len(t, errors, 1)
-				assert.Equal(t, InvalidParams, errors[0].Error.Code)
+		},
+		{
+			name:   "missing tool name",
+			params: map[string]interface{}{"arguments": map[string]interface{}{}},
+			validate: func(t *testing.T, response *JSONRPCResponse) {
+				require.NotNil(t, response.Error)
+				assert.Equal(t, InvalidParams, response.Error.Code)
 			},
 		},
 		{
-			name: "missing tool name",
-			request: &JSONRPCRequest{
-				JSONRPC: "2.0",
-				ID:      "call-2",
-				Method:  "tools/call",
-				Params: map[string]interface{}{
-					"arguments": map[string]interface{}{},
-				},
+			name:   "tool not found",
+			params: map[string]interface{}{"name": "nonexistent_tool", "arguments": map[string]interface{}{}},
+			validate: func(t *testing.T, response *JSONRPCResponse) {
+				require.NotNil(t, response.Error)
+				assert.Equal(t, ToolNotFound, response.Error.Code)
 			},
-			wantErr: false,
-			validate: func(t *testing.T, responses []JSONRPCResponse, errors []JSONRPCResponse) {
-				require.Len(t, errors, 1)
-				assert.Equal(t, InvalidParams, errors[0].Error.Code)
+		},
+		{
+			name:   "missing required argument",
+			params: map[string]interface{}{"name": "get_widget", "arguments": map[string]interface{}{}},
+			validate: func(t *testing.T, response *JSONRPCResponse) {
+				require.NotNil(t, response.Error)
+				assert.Equal(t, InvalidParams, response.Error.Code)
 			},
 		},
 		{
-			name: "tool not found",
-			request: &JSONRPCRequest{
-				JSONRPC: "2.0",
-				ID:      "call-3",
-				Method:  "tools/call",
-				Params: map[string]interface{}{
-					"name":      "nonexistent_tool",
-					"arguments": map[string]interface{}{},
-				},
+			name:   "structured result",
+			params: map[string]interface{}{"name": "get_widget", "arguments": map[string]interface{}{"id": "w1"}},
+			validate: func(t *testing.T, response *JSONRPCResponse) {
+				require.Nil(t, response.Error)
+				result := response.Result.(ToolCallResult)
+				assert.False(t, result.IsError)
+				assert.Equal(t, "w1", result.StructuredContent.(map[string]interface{})["id"])
 			},
-			wantErr: false,
-			validate: func(t *testing.T, responses []JSONRPCResponse, errors []JSONRPCResponse) {
-				require.Len(t, errors, 1)
-				assert.Equal(t, ToolNotFound, errors[0].Error.Code)
+		},
+		{
+			name:   "insufficient scope",
+			ctx:    auth.NewContext(context.Background(), domain.APIKey{Name: "grafana", Scope: string(auth.ScopeReadOnly)}),
+			params: map[string]interface{}{"name": "delete_widget", "arguments": map[string]interface{}{"id": "w1"}},
+			validate: func(t *testing.T, response *JSONRPCResponse) {
+				require.Nil(t, response.Error)
+				result := response.Result.(ToolCallResult)
+				assert.True(t, result.IsError)
+				assert.Contains(t, result.Content[0].Text, "insufficient scope")
 			},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Reset mock connection
-			mockConn.sentResponses = nil
-			mockConn.sentErrors = nil
-
-			testConn := &testConnection{
-				Connection: conn,
-				mock:       mockConn,
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
 			}
-
-			err := testConn.handleToolsCall(tt.request)
-			if tt.wantErr {
-				assert.Error(t, err)
-			} else {
-				assert.NoError(t, err)
-			}
-
-			tt.validate(t, mockConn.sentResponses, mockConn.sentErrors)
+			response := server.Dispatch(ctx, nil, &JSONRPCRequest{
+				JSONRPC: "2.0",
+				ID:      "call-1",
+				Method:  "tools/call",
+				Params:  tt.params,
+			})
+			require.NotNil(t, response)
+			tt.validate(t, response)
 		})
 	}
 }
 
-// testConnection wraps Connection to use mock for testing
-type testConnection struct {
-	*Connection
-	mock *MockConnection
-}
-
-func (tc *testConnection) sendResponse(id interface{}, result interface{}) error {
-	return tc.mock.sendResponse(id, result)
-}
-
-func (tc *testConnection) sendError(id interface{}, code int, message string, data interface{}) error {
-	return tc.mock.sendError(id, code, message, data)
-}
-
-func (tc *testConnection) handleInitialize(request *JSONRPCRequest) error {
-	// Mock the initialize handling without using WebSocket
-	result := InitializeResult{
-		ProtocolVersion: MCPProtocolVersion,
-		Capabilities: ServerCapabilities{
-			Tools: &ToolsCapability{
-				ListChanged: false,
-			},
-			Logging: &LoggingCapability{},
-		},
-		ServerInfo: ServerInfo{
-			Name:    "UMA MCP Server",
-			Version: "1.0.0",
-		},
-	}
-	return tc.sendResponse(request.ID, result)
-}
-
-func (tc *testConnection) handleToolsList(request *JSONRPCRequest) error {
-	// Mock the tools list handling
-	tools, err := tc.server.registry.GetTools()
-	if err != nil {
-		return tc.sendError(request.ID, InternalError, "Failed to get tools", nil)
-	}
-
-	result := ToolsListResult{
-		Tools: tools,
-	}
-	return tc.sendResponse(request.ID, result)
-}
-
-func (tc *testConnection) handleToolsCall(request *JSONRPCRequest) error {
-	// Mock the tools call handling
-	var params ToolCallParams
-	if request.Params == nil {
-		return tc.sendError(request.ID, InvalidParams, "Missing parameters", nil)
-	}
-
-	// Simple parameter validation for testing
-	paramsMap, ok := request.Params.(map[string]interface{})
-	if !ok {
-		return tc.sendError(request.ID, InvalidParams, "Invalid parameters", nil)
-	}
+// TestDispatchNotifications tests that notifications get no response
+func TestDispatchNotifications(t *testing.T) {
+	server := newTestServer(t)
 
-	name, ok := paramsMap["name"].(string)
-	if !ok || name == "" {
-		return tc.sendError(request.ID, InvalidParams, "Tool name is required", nil)
-	}
-
-	params.Name = name
-	if args, ok := paramsMap["arguments"].(map[string]interface{}); ok {
-		params.Arguments = args
-	}
+	assert.Nil(t, server.Dispatch(context.Background(), nil, &JSONRPCRequest{JSONRPC: "2.0", Method: "notifications/initialized"}))
 
-	// Execute tool
-	result, err := tc.server.registry.ExecuteTool(params.Name, params.Arguments)
-	if err != nil {
-		if err.Error() == "tool not found" {
-			return tc.sendError(request.ID, ToolNotFound, "Tool not found", nil)
-		}
-		return tc.sendResponse(request.ID, ToolCallResult{
-			Content: []ToolContent{
-				{
-					Type: "text",
-					Text: "Error executing tool",
-				},
-			},
-			IsError: true,
-		})
-	}
-
-	return tc.sendResponse(request.ID, result)
+	response := server.Dispatch(context.Background(), nil, &JSONRPCRequest{JSONRPC: "2.0", ID: 7, Method: "unknown"})
+	require.NotNil(t, response)
+	assert.Equal(t, MethodNotFound, response.Error.Code)
 }
 
 // TestValidateJSONRPCRequest tests request validation
 func TestValidateJSONRPCRequest(t *testing.T) {
-	conn := &Connection{}
-
 	tests := []struct {
 		name    string
 		request *JSONRPCRequest
@@ -433,7 +246,7 @@ func TestValidateJSONRPCRequest(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := conn.validateJSONRPCRequest(tt.request)
+			err := validateJSONRPCRequest(tt.request)
 			if tt.wantErr {
 				assert.Error(t, err)
 			} else {
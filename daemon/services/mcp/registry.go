@@ -1,175 +1,284 @@
 package mcp
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/domalab/uma/daemon/logger"
-	"github.com/domalab/uma/daemon/services/api/utils"
+	"github.com/domalab/uma/daemon/services/auth"
 )
 
-// ToolRegistry manages the mapping between REST endpoints and MCP tools
+var (
+	ErrToolNotFound      = errors.New("tool not found")
+	ErrInvalidArguments  = errors.New("invalid arguments")
+	ErrInsufficientScope = errors.New("insufficient scope")
+)
+
+// Param describes a path or query parameter of a route
+type Param struct {
+	Name        string
+	Type        string // JSON schema type, string when empty
+	Description string
+	Required    bool
+	Enum        []string
+}
+
+// Route describes a REST v2 endpoint exposed as an MCP tool. Path segments
+// written as {name} become required arguments; other params go in the query.
+type Route struct {
+	Tool        string
+	Method      string
+	Path        string
+	Description string
+	Params      []Param
+	Body        interface{} // Request type whose JSON fields are sent as the body
+	Destructive bool        // Stops, removes or powers something off
+}
+
+// ToolRegistry maps REST routes to MCP tools and executes them through the
+// REST handler, so tools and endpoints always share validation and behaviour
 type ToolRegistry struct {
-	api   utils.APIInterface
-	tools map[string]*ToolDefinition
-	mutex sync.RWMutex
+	handler http.Handler
+	tools   map[string]*ToolDefinition
+	mutex   sync.RWMutex
 }
 
 // ToolDefinition represents a tool definition with execution details
 type ToolDefinition struct {
-	Tool     Tool
-	Endpoint string
-	Method   string
-	Handler  ToolHandler
+	Tool  Tool
+	Route Route
+	Scope auth.Scope
 }
 
-// ToolHandler is a function that executes a tool
-type ToolHandler func(args map[string]interface{}) (ToolCallResult, error)
-
-// NewToolRegistry creates a new tool registry
-func NewToolRegistry(api utils.APIInterface) *ToolRegistry {
-	registry := &ToolRegistry{
-		api:   api,
-		tools: make(map[string]*ToolDefinition),
+// NewToolRegistry creates an empty registry that executes tools against handler
+func NewToolRegistry(handler http.Handler) *ToolRegistry {
+	return &ToolRegistry{
+		handler: handler,
+		tools:   make(map[string]*ToolDefinition),
 	}
-
-	// Register all available tools
-	registry.registerTools()
-
-	return registry
 }
 
-// registerTools registers all available tools from REST endpoints
-func (r *ToolRegistry) registerTools() {
-	logger.Info("Registering MCP tools from v2 REST endpoints...")
+// Register adds tools for the given routes
+func (r *ToolRegistry) Register(routes ...Route) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 
-	// Only register tools for available v2 endpoints
-	r.registerV2SystemTools()
-	r.registerV2StorageTools()
-	r.registerV2ContainerTools()
-	r.registerV2VMTools()
+	for _, route := range routes {
+		if route.Tool == "" || route.Method == "" || !strings.HasPrefix(route.Path, "/") {
+			return fmt.Errorf("incomplete route for tool %q", route.Tool)
+		}
+		if _, exists := r.tools[route.Tool]; exists {
+			return fmt.Errorf("tool %q is registered more than once", route.Tool)
+		}
+
+		tool, err := buildTool(route)
+		if err != nil {
+			return err
+		}
+
+		r.tools[route.Tool] = &ToolDefinition{
+			Tool:  tool,
+			Route: route,
+			Scope: auth.RequiredScope(&http.Request{Method: route.Method, URL: &url.URL{Path: route.Path}}),
+		}
+		logger.Debug("Registered MCP tool: %s -> %s %s", route.Tool, route.Method, route.Path)
+	}
 
-	logger.Green("Registered %d MCP tools for v2 API", len(r.tools))
+	return nil
 }
 
-// registerV2SystemTools registers v2 system-related tools
-func (r *ToolRegistry) registerV2SystemTools() {
-	// System health tool
-	r.registerTool("get_system_health", Tool{
-		Name:        "get_system_health",
-		Description: "Get system health status and checks",
-		InputSchema: ToolSchema{
-			Type:       "object",
-			Properties: map[string]interface{}{},
-			Required:   []string{},
-		},
-	}, "/api/v2/system/health", "GET", r.handleSystemHealth)
+// buildTool derives the tool schema and annotations from a route
+func buildTool(route Route) (Tool, error) {
+	properties := make(map[string]interface{})
+	var required []string
 
-	// System info tool
-	r.registerTool("get_system_info", Tool{
-		Name:        "get_system_info",
-		Description: "Get comprehensive system information including CPU, memory, and hardware details",
-		InputSchema: ToolSchema{
-			Type:       "object",
-			Properties: map[string]interface{}{},
-			Required:   []string{},
-		},
-	}, "/api/v2/system/info", "GET", r.handleSystemInfo)
-}
+	if route.Body != nil {
+		properties, required = SchemaFor(route.Body)
+	}
 
-// registerV2StorageTools registers v2 storage-related tools
-func (r *ToolRegistry) registerV2StorageTools() {
-	// Storage config tool
-	r.registerTool("get_storage_config", Tool{
-		Name:        "get_storage_config",
-		Description: "Get storage configuration including array status and disk counts",
-		InputSchema: ToolSchema{
-			Type:       "object",
-			Properties: map[string]interface{}{},
-			Required:   []string{},
-		},
-	}, "/api/v2/storage/config", "GET", r.handleStorageConfig)
+	declared := make(map[string]bool, len(route.Params))
+	for _, param := range route.Params {
+		declared[param.Name] = true
+
+		property := map[string]interface{}{"type": "string"}
+		if param.Type != "" {
+			property["type"] = param.Type
+		}
+		if param.Description != "" {
+			property["description"] = param.Description
+		}
+		if len(param.Enum) > 0 {
+			property["enum"] = param.Enum
+		}
+		properties[param.Name] = property
+
+		if param.Required || isPathParam(route.Path, param.Name) {
+			required = append(required, param.Name)
+		}
+	}
 
-	// Storage layout tool
-	r.registerTool("get_storage_layout", Tool{
-		Name:        "get_storage_layout",
-		Description: "Get detailed storage layout and disk assignments",
-		InputSchema: ToolSchema{
-			Type:       "object",
-			Properties: map[string]interface{}{},
-			Required:   []string{},
-		},
-	}, "/api/v2/storage/layout", "GET", r.handleStorageLayout)
-}
+	for _, name := range pathParams(route.Path) {
+		if !declared[name] {
+			return Tool{}, fmt.Errorf("tool %q does not describe path parameter %q", route.Tool, name)
+		}
+	}
 
-// registerV2ContainerTools registers v2 container-related tools
-func (r *ToolRegistry) registerV2ContainerTools() {
-	// Container list tool
-	r.registerTool("list_containers", Tool{
-		Name:        "list_containers",
-		Description: "List all Docker containers with their status, resource usage, and configuration",
+	readOnly := route.Method == http.MethodGet
+	return Tool{
+		Name:        route.Tool,
+		Description: route.Description,
 		InputSchema: ToolSchema{
 			Type:       "object",
-			Properties: map[string]interface{}{},
-			Required:   []string{},
+			Properties: properties,
+			Required:   required,
 		},
-	}, "/api/v2/containers/list", "GET", r.handleListContainers)
-}
-
-// registerV2VMTools registers v2 VM-related tools
-func (r *ToolRegistry) registerV2VMTools() {
-	// VM list tool
-	r.registerTool("list_vms", Tool{
-		Name:        "list_vms",
-		Description: "List all virtual machines with their status and resource allocation",
-		InputSchema: ToolSchema{
-			Type:       "object",
-			Properties: map[string]interface{}{},
-			Required:   []string{},
+		Annotations: &ToolAnnotations{
+			Title:           route.Method + " " + route.Path,
+			ReadOnlyHint:    readOnly,
+			DestructiveHint: route.Destructive,
+			IdempotentHint:  readOnly || route.Method == http.MethodDelete,
 		},
-	}, "/api/v2/vms/list", "GET", r.handleListVMs)
-}
-
-// registerTool registers a single tool
-func (r *ToolRegistry) registerTool(name string, tool Tool, endpoint string, method string, handler ToolHandler) {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
-
-	r.tools[name] = &ToolDefinition{
-		Tool:     tool,
-		Endpoint: endpoint,
-		Method:   method,
-		Handler:  handler,
-	}
-
-	logger.Debug("Registered MCP tool: %s -> %s %s", name, method, endpoint)
+	}, nil
 }
 
-// GetTools returns all registered tools
-func (r *ToolRegistry) GetTools() ([]Tool, error) {
+// GetTools returns the tools the caller may use, sorted by name. Callers
+// without an API key in the context see every tool.
+func (r *ToolRegistry) GetTools(ctx context.Context) []Tool {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
+	key, authenticated := auth.FromContext(ctx)
+
 	tools := make([]Tool, 0, len(r.tools))
 	for _, toolDef := range r.tools {
+		if authenticated && !auth.Scope(key.Scope).Allows(toolDef.Scope) {
+			continue
+		}
 		tools = append(tools, toolDef.Tool)
 	}
 
-	return tools, nil
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	return tools
 }
 
-// ExecuteTool executes a tool by name with the given arguments
-func (r *ToolRegistry) ExecuteTool(name string, args map[string]interface{}) (ToolCallResult, error) {
+// ExecuteTool runs a tool by replaying it as a request against the REST
+// handler. Responses with an error status are returned as error results.
+func (r *ToolRegistry) ExecuteTool(ctx context.Context, name string, args map[string]interface{}) (ToolCallResult, error) {
 	r.mutex.RLock()
 	toolDef, exists := r.tools[name]
 	r.mutex.RUnlock()
 
 	if !exists {
-		return ToolCallResult{}, fmt.Errorf("tool not found")
+		return ToolCallResult{}, ErrToolNotFound
+	}
+
+	if key, ok := auth.FromContext(ctx); ok && !auth.Scope(key.Scope).Allows(toolDef.Scope) {
+		return ToolCallResult{}, fmt.Errorf("%w: %s requires the %s scope", ErrInsufficientScope, name, toolDef.Scope)
+	}
+
+	req, err := buildRequest(ctx, toolDef, args)
+	if err != nil {
+		return ToolCallResult{}, err
 	}
 
 	logger.Info("Executing MCP tool: %s", name)
-	return toolDef.Handler(args)
+
+	recorder := newResponseRecorder()
+	r.handler.ServeHTTP(recorder, req)
+
+	return toolResult(recorder.status, recorder.body.Bytes()), nil
+}
+
+// buildRequest maps tool arguments onto the route's path, query and body
+func buildRequest(ctx context.Context, toolDef *ToolDefinition, args map[string]interface{}) (*http.Request, error) {
+	route := toolDef.Route
+	properties := toolDef.Tool.InputSchema.Properties
+
+	for _, name := range toolDef.Tool.InputSchema.Required {
+		if value, ok := args[name]; !ok || value == nil || value == "" {
+			return nil, fmt.Errorf("%w: %s is required", ErrInvalidArguments, name)
+		}
+	}
+
+	queryParams := make(map[string]bool, len(route.Params))
+	for _, param := range route.Params {
+		queryParams[param.Name] = !isPathParam(route.Path, param.Name)
+	}
+
+	path := route.Path
+	query := url.Values{}
+	body := make(map[string]interface{})
+
+	for name, value := range args {
+		if _, known := properties[name]; !known {
+			return nil, fmt.Errorf("%w: unknown argument %s", ErrInvalidArguments, name)
+		}
+
+		switch {
+		case isPathParam(route.Path, name):
+			path = strings.ReplaceAll(path, "{"+name+"}", url.PathEscape(fmt.Sprint(value)))
+		case queryParams[name]:
+			if value != nil {
+				query.Set(name, fmt.Sprint(value))
+			}
+		default:
+			body[name] = value
+		}
+	}
+
+	target := path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var payload []byte
+	if len(body) > 0 {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+		}
+		payload = data
+	}
+
+	req, err := http.NewRequestWithContext(ctx, route.Method, target, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArguments, err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.RemoteAddr = "mcp"
+
+	return req, nil
+}
+
+// toolResult wraps a REST response as tool content. JSON objects are also
+// returned as structured content; arrays are wrapped under "items".
+func toolResult(status int, body []byte) ToolCallResult {
+	result := ToolCallResult{
+		Content: []ToolContent{{Type: "text", Text: string(bytes.TrimSpace(body))}},
+		IsError: status >= http.StatusBadRequest,
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err == nil {
+		switch value := decoded.(type) {
+		case map[string]interface{}:
+			result.StructuredContent = value
+		case []interface{}:
+			result.StructuredContent = map[string]interface{}{"items": value}
+		}
+	}
+
+	return result
 }
 
 // GetRegistryStats returns statistics about the tool registry
@@ -183,91 +292,51 @@ func (r *ToolRegistry) GetRegistryStats() map[string]interface{} {
 	}
 }
 
-// getToolNames returns a list of all tool names
+// getToolNames returns a sorted list of all tool names
 func (r *ToolRegistry) getToolNames() []string {
 	names := make([]string, 0, len(r.tools))
 	for name := range r.tools {
 		names = append(names, name)
 	}
+	sort.Strings(names)
 	return names
 }
 
-// Tool handler implementations for v2 endpoints
+// pathParams returns the {name} placeholders in a route path
+func pathParams(path string) []string {
+	var names []string
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			names = append(names, strings.Trim(segment, "{}"))
+		}
+	}
+	return names
+}
 
-// handleSystemHealth handles the get_system_health tool
-func (r *ToolRegistry) handleSystemHealth(args map[string]interface{}) (ToolCallResult, error) {
-	return ToolCallResult{
-		Content: []ToolContent{
-			{
-				Type: "text",
-				Text: "System health check completed. Status: healthy. All v2 services operational.",
-			},
-		},
-		IsError: false,
-	}, nil
+// isPathParam reports whether name is a placeholder in the route path
+func isPathParam(path, name string) bool {
+	return strings.Contains(path, "{"+name+"}")
 }
 
-// handleSystemInfo handles the get_system_info tool
-func (r *ToolRegistry) handleSystemInfo(args map[string]interface{}) (ToolCallResult, error) {
-	return ToolCallResult{
-		Content: []ToolContent{
-			{
-				Type: "text",
-				Text: "System info tool executed successfully. This would return comprehensive system information from v2 API.",
-			},
-		},
-		IsError: false,
-	}, nil
+// responseRecorder buffers a REST response for conversion into a tool result
+type responseRecorder struct {
+	header http.Header
+	body   bytes.Buffer
+	status int
 }
 
-// handleStorageConfig handles the get_storage_config tool
-func (r *ToolRegistry) handleStorageConfig(args map[string]interface{}) (ToolCallResult, error) {
-	return ToolCallResult{
-		Content: []ToolContent{
-			{
-				Type: "text",
-				Text: "Storage config tool executed successfully. This would return storage configuration from v2 API.",
-			},
-		},
-		IsError: false,
-	}, nil
+func newResponseRecorder() *responseRecorder {
+	return &responseRecorder{header: make(http.Header), status: http.StatusOK}
 }
 
-// handleStorageLayout handles the get_storage_layout tool
-func (r *ToolRegistry) handleStorageLayout(args map[string]interface{}) (ToolCallResult, error) {
-	return ToolCallResult{
-		Content: []ToolContent{
-			{
-				Type: "text",
-				Text: "Storage layout tool executed successfully. This would return detailed storage layout from v2 API.",
-			},
-		},
-		IsError: false,
-	}, nil
+func (rr *responseRecorder) Header() http.Header {
+	return rr.header
 }
 
-// handleListContainers handles the list_containers tool
-func (r *ToolRegistry) handleListContainers(args map[string]interface{}) (ToolCallResult, error) {
-	return ToolCallResult{
-		Content: []ToolContent{
-			{
-				Type: "text",
-				Text: "Container list tool executed successfully. This would return all Docker containers from v2 API.",
-			},
-		},
-		IsError: false,
-	}, nil
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	return rr.body.Write(b)
 }
 
-// handleListVMs handles the list_vms tool
-func (r *ToolRegistry) handleListVMs(args map[string]interface{}) (ToolCallResult, error) {
-	return ToolCallResult{
-		Content: []ToolContent{
-			{
-				Type: "text",
-				Text: "VM list tool executed successfully. This would return all virtual machines from v2 API.",
-			},
-		},
-		IsError: false,
-	}, nil
+func (rr *responseRecorder) WriteHeader(code int) {
+	rr.status = code
 }
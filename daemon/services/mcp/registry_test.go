@@ -0,0 +1,126 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type widgetRequest struct {
+	Name  string            `json:"name" validate:"required"`
+	Color string            `json:"color,omitempty" validate:"oneof=red blue"`
+	Tags  []string          `json:"tags,omitempty"`
+	Meta  map[string]int    `json:"meta,omitempty"`
+	Extra map[string]string `json:"-"`
+}
+
+// newTestRegistry builds a registry over a handler that echoes the request
+func newTestRegistry(t testing.TB) *ToolRegistry {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/missing") {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"not found"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":     strings.TrimPrefix(r.URL.Path, "/api/v2/widgets/"),
+			"method": r.Method,
+			"query":  r.URL.RawQuery,
+			"body":   string(body),
+		})
+	})
+
+	registry := NewToolRegistry(handler)
+	err := registry.Register(
+		Route{
+			Tool:        "get_widget",
+			Method:      http.MethodGet,
+			Path:        "/api/v2/widgets/{id}",
+			Description: "Get a widget",
+			Params:      []Param{{Name: "id", Description: "Widget ID"}, {Name: "verbose", Type: "boolean"}},
+		},
+		Route{
+			Tool:   "update_widget",
+			Method: http.MethodPost,
+			Path:   "/api/v2/widgets/{id}",
+			Params: []Param{{Name: "id"}},
+			Body:   widgetRequest{},
+		},
+		Route{
+			Tool:        "delete_widget",
+			Method:      http.MethodDelete,
+			Path:        "/api/v2/widgets/{id}",
+			Params:      []Param{{Name: "id"}},
+			Destructive: true,
+		},
+	)
+	require.NoError(t, err)
+	return registry
+}
+
+func TestRegisterRejectsInvalidRoutes(t *testing.T) {
+	registry := newTestRegistry(t)
+
+	err := registry.Register(Route{Tool: "get_widget", Method: http.MethodGet, Path: "/api/v2/other"})
+	assert.Error(t, err, "duplicate tool names must be rejected")
+
+	err = registry.Register(Route{Tool: "undescribed", Method: http.MethodGet, Path: "/api/v2/widgets/{id}"})
+	assert.Error(t, err, "path parameters must be described")
+}
+
+func TestToolSchemaAndAnnotations(t *testing.T) {
+	tools := newTestRegistry(t).GetTools(context.Background())
+	byName := make(map[string]Tool, len(tools))
+	for _, tool := range tools {
+		byName[tool.Name] = tool
+	}
+
+	get := byName["get_widget"]
+	assert.Equal(t, []string{"id"}, get.InputSchema.Required)
+	assert.Equal(t, "boolean", get.InputSchema.Properties["verbose"].(map[string]interface{})["type"])
+	assert.True(t, get.Annotations.ReadOnlyHint)
+	assert.False(t, get.Annotations.DestructiveHint)
+
+	update := byName["update_widget"]
+	assert.ElementsMatch(t, []string{"name", "id"}, update.InputSchema.Required)
+	assert.Equal(t, []string{"red", "blue"}, update.InputSchema.Properties["color"].(map[string]interface{})["enum"])
+	assert.Equal(t, "array", update.InputSchema.Properties["tags"].(map[string]interface{})["type"])
+	assert.NotContains(t, update.InputSchema.Properties, "Extra")
+	assert.False(t, update.Annotations.ReadOnlyHint)
+
+	assert.True(t, byName["delete_widget"].Annotations.DestructiveHint)
+}
+
+func TestExecuteToolMapsArguments(t *testing.T) {
+	registry := newTestRegistry(t)
+	ctx := context.Background()
+
+	result, err := registry.ExecuteTool(ctx, "get_widget", map[string]interface{}{"id": "a b", "verbose": true})
+	require.NoError(t, err)
+	data := result.StructuredContent.(map[string]interface{})
+	assert.Equal(t, "a b", data["id"])
+	assert.Equal(t, "verbose=true", data["query"])
+
+	result, err = registry.ExecuteTool(ctx, "update_widget", map[string]interface{}{"id": "w1", "name": "gear"})
+	require.NoError(t, err)
+	data = result.StructuredContent.(map[string]interface{})
+	assert.Equal(t, "POST", data["method"])
+	assert.JSONEq(t, `{"name":"gear"}`, data["body"].(string))
+
+	result, err = registry.ExecuteTool(ctx, "get_widget", map[string]interface{}{"id": "missing"})
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+	assert.Equal(t, `{"error":"not found"}`, result.Content[0].Text)
+
+	_, err = registry.ExecuteTool(ctx, "get_widget", map[string]interface{}{"id": "w1", "bogus": 1})
+	assert.True(t, errors.Is(err, ErrInvalidArguments))
+}
@@ -0,0 +1,268 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrResourceNotFound = errors.New("resource not found")
+	ErrPromptNotFound   = errors.New("prompt not found")
+)
+
+// ResourceReader returns the data behind a resource. params holds the values
+// of the {name} segments of its URI. Strings are served as text, anything
+// else as JSON.
+type ResourceReader func(ctx context.Context, params map[string]string) (interface{}, error)
+
+// ResourceDefinition describes a resource or, when its URI contains {name}
+// segments, a resource template
+type ResourceDefinition struct {
+	URI         string
+	Name        string
+	Description string
+	MimeType    string
+	Topic       string // Collector whose updates are pushed to subscribers
+	Read        ResourceReader
+}
+
+// ResourceRegistry holds the resources the MCP server can read
+type ResourceRegistry struct {
+	resources map[string]*ResourceDefinition
+	mutex     sync.RWMutex
+}
+
+// NewResourceRegistry creates an empty resource registry
+func NewResourceRegistry() *ResourceRegistry {
+	return &ResourceRegistry{resources: make(map[string]*ResourceDefinition)}
+}
+
+// Register adds resource definitions
+func (r *ResourceRegistry) Register(definitions ...ResourceDefinition) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, definition := range definitions {
+		if !strings.Contains(definition.URI, "://") || definition.Read == nil {
+			return fmt.Errorf("incomplete resource %q", definition.URI)
+		}
+		if _, exists := r.resources[definition.URI]; exists {
+			return fmt.Errorf("resource %q is registered more than once", definition.URI)
+		}
+		definition := definition
+		r.resources[definition.URI] = &definition
+	}
+
+	return nil
+}
+
+// List returns the concrete resources, sorted by URI
+func (r *ResourceRegistry) List() []Resource {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	resources := make([]Resource, 0, len(r.resources))
+	for _, definition := range r.resources {
+		if isTemplate(definition.URI) {
+			continue
+		}
+		resources = append(resources, Resource{
+			URI:         definition.URI,
+			Name:        definition.Name,
+			Description: definition.Description,
+			MimeType:    definition.MimeType,
+		})
+	}
+
+	sort.Slice(resources, func(i, j int) bool { return resources[i].URI < resources[j].URI })
+	return resources
+}
+
+// Templates returns the parameterized resources, sorted by URI template
+func (r *ResourceRegistry) Templates() []ResourceTemplate {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	templates := make([]ResourceTemplate, 0, len(r.resources))
+	for _, definition := range r.resources {
+		if !isTemplate(definition.URI) {
+			continue
+		}
+		templates = append(templates, ResourceTemplate{
+			URITemplate: definition.URI,
+			Name:        definition.Name,
+			Description: definition.Description,
+			MimeType:    definition.MimeType,
+		})
+	}
+
+	sort.Slice(templates, func(i, j int) bool { return templates[i].URITemplate < templates[j].URITemplate })
+	return templates
+}
+
+// Read returns the contents of the resource at uri
+func (r *ResourceRegistry) Read(ctx context.Context, uri string) (ResourceReadResult, error) {
+	definition, params, ok := r.match(uri)
+	if !ok {
+		return ResourceReadResult{}, fmt.Errorf("%w: %s", ErrResourceNotFound, uri)
+	}
+
+	data, err := definition.Read(ctx, params)
+	if err != nil {
+		return ResourceReadResult{}, err
+	}
+
+	contents := ResourceContents{URI: uri, MimeType: definition.MimeType}
+	switch value := data.(type) {
+	case string:
+		contents.Text = value
+	case []string:
+		contents.Text = strings.Join(value, "\n")
+	default:
+		encoded, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return ResourceReadResult{}, fmt.Errorf("failed to encode %s: %w", uri, err)
+		}
+		contents.Text = string(encoded)
+	}
+
+	return ResourceReadResult{Contents: []ResourceContents{contents}}, nil
+}
+
+// Topic returns the collector feeding updates for uri, if any
+func (r *ResourceRegistry) Topic(uri string) (string, bool) {
+	definition, _, ok := r.match(uri)
+	if !ok {
+		return "", false
+	}
+	return definition.Topic, true
+}
+
+// match finds the definition for uri, extracting template parameters
+func (r *ResourceRegistry) match(uri string) (*ResourceDefinition, map[string]string, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if definition, ok := r.resources[uri]; ok && !isTemplate(uri) {
+		return definition, map[string]string{}, true
+	}
+
+	segments := strings.Split(uri, "/")
+	for _, definition := range r.resources {
+		if !isTemplate(definition.URI) {
+			continue
+		}
+		if params, ok := matchTemplate(strings.Split(definition.URI, "/"), segments); ok {
+			return definition, params, true
+		}
+	}
+
+	return nil, nil, false
+}
+
+// matchTemplate matches URI segments against template segments
+func matchTemplate(template, segments []string) (map[string]string, bool) {
+	if len(template) != len(segments) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, part := range template {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			value, err := url.PathUnescape(segments[i])
+			if err != nil || value == "" {
+				return nil, false
+			}
+			params[strings.Trim(part, "{}")] = value
+			continue
+		}
+		if part != segments[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+// isTemplate reports whether a resource URI has {name} segments
+func isTemplate(uri string) bool {
+	return strings.Contains(uri, "{")
+}
+
+// PromptBuilder renders a prompt from its arguments
+type PromptBuilder func(ctx context.Context, args map[string]string) (PromptGetResult, error)
+
+// PromptDefinition pairs a prompt with the function that renders it
+type PromptDefinition struct {
+	Prompt Prompt
+	Build  PromptBuilder
+}
+
+// PromptRegistry holds the canned prompts the MCP server offers
+type PromptRegistry struct {
+	prompts map[string]*PromptDefinition
+	mutex   sync.RWMutex
+}
+
+// NewPromptRegistry creates an empty prompt registry
+func NewPromptRegistry() *PromptRegistry {
+	return &PromptRegistry{prompts: make(map[string]*PromptDefinition)}
+}
+
+// Register adds prompt definitions
+func (r *PromptRegistry) Register(definitions ...PromptDefinition) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, definition := range definitions {
+		if definition.Prompt.Name == "" || definition.Build == nil {
+			return fmt.Errorf("incomplete prompt %q", definition.Prompt.Name)
+		}
+		if _, exists := r.prompts[definition.Prompt.Name]; exists {
+			return fmt.Errorf("prompt %q is registered more than once", definition.Prompt.Name)
+		}
+		definition := definition
+		r.prompts[definition.Prompt.Name] = &definition
+	}
+
+	return nil
+}
+
+// List returns the prompts, sorted by name
+func (r *PromptRegistry) List() []Prompt {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	prompts := make([]Prompt, 0, len(r.prompts))
+	for _, definition := range r.prompts {
+		prompts = append(prompts, definition.Prompt)
+	}
+
+	sort.Slice(prompts, func(i, j int) bool { return prompts[i].Name < prompts[j].Name })
+	return prompts
+}
+
+// Get renders a prompt after checking its required arguments
+func (r *PromptRegistry) Get(ctx context.Context, name string, args map[string]string) (PromptGetResult, error) {
+	r.mutex.RLock()
+	definition, exists := r.prompts[name]
+	r.mutex.RUnlock()
+
+	if !exists {
+		return PromptGetResult{}, fmt.Errorf("%w: %s", ErrPromptNotFound, name)
+	}
+
+	for _, argument := range definition.Prompt.Arguments {
+		if argument.Required && args[argument.Name] == "" {
+			return PromptGetResult{}, fmt.Errorf("%w: %s is required", ErrInvalidArguments, argument.Name)
+		}
+	}
+
+	return definition.Build(ctx, args)
+}
@@ -0,0 +1,218 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newResourceTestServer builds a server with a static and a templated resource and one prompt
+func newResourceTestServer(t *testing.T) *Server {
+	server := newTestServer(t)
+
+	require.NoError(t, server.Resources().Register(
+		ResourceDefinition{
+			URI:      "uma://system/info",
+			Name:     "System information",
+			MimeType: "application/json",
+			Topic:    "system.cpu",
+			Read: func(ctx context.Context, params map[string]string) (interface{}, error) {
+				return map[string]string{"hostname": "tower"}, nil
+			},
+		},
+		ResourceDefinition{
+			URI:      "uma://containers/{id}/logs",
+			Name:     "Container logs",
+			MimeType: "text/plain",
+			Read: func(ctx context.Context, params map[string]string) (interface{}, error) {
+				if params["id"] == "broken" {
+					return nil, errors.New("no such container")
+				}
+				return []string{"started " + params["id"], "ready"}, nil
+			},
+		},
+	))
+
+	require.NoError(t, server.Prompts().Register(PromptDefinition{
+		Prompt: Prompt{
+			Name:      "greet",
+			Arguments: []PromptArgument{{Name: "who", Required: true}},
+		},
+		Build: func(ctx context.Context, args map[string]string) (PromptGetResult, error) {
+			return PromptGetResult{Messages: []PromptMessage{{
+				Role:    "user",
+				Content: ToolContent{Type: "text", Text: "Hello " + args["who"]},
+			}}}, nil
+		},
+	}))
+
+	return server
+}
+
+// TestResourceRegistryRegister tests that incomplete and duplicate resources are rejected
+func TestResourceRegistryRegister(t *testing.T) {
+	registry := NewResourceRegistry()
+	read := func(ctx context.Context, params map[string]string) (interface{}, error) { return "", nil }
+
+	require.NoError(t, registry.Register(ResourceDefinition{URI: "uma://logs/syslog", Read: read}))
+	assert.Error(t, registry.Register(ResourceDefinition{URI: "uma://logs/syslog", Read: read}))
+	assert.Error(t, registry.Register(ResourceDefinition{URI: "logs/syslog", Read: read}))
+	assert.Error(t, registry.Register(ResourceDefinition{URI: "uma://logs/other"}))
+}
+
+// TestResourcesListAndRead tests listing and reading resources through Dispatch
+func TestResourcesListAndRead(t *testing.T) {
+	server := newResourceTestServer(t)
+	ctx := context.Background()
+
+	response := server.Dispatch(ctx, nil, &JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "resources/list"})
+	require.Nil(t, response.Error)
+	resources := response.Result.(ResourcesListResult).Resources
+	require.Len(t, resources, 1)
+	assert.Equal(t, "uma://system/info", resources[0].URI)
+
+	response = server.Dispatch(ctx, nil, &JSONRPCRequest{JSONRPC: "2.0", ID: 2, Method: "resources/templates/list"})
+	require.Nil(t, response.Error)
+	templates := response.Result.(ResourceTemplatesListResult).ResourceTemplates
+	require.Len(t, templates, 1)
+	assert.Equal(t, "uma://containers/{id}/logs", templates[0].URITemplate)
+
+	tests := []struct {
+		uri      string
+		text     string
+		mimeType string
+		code     int
+	}{
+		{uri: "uma://system/info", text: "{\n  \"hostname\": \"tower\"\n}", mimeType: "application/json"},
+		{uri: "uma://containers/plex/logs", text: "started plex\nready", mimeType: "text/plain"},
+		{uri: "uma://containers/my%20app/logs", text: "started my app\nready", mimeType: "text/plain"},
+		{uri: "uma://containers/broken/logs", code: ResourceError},
+		{uri: "uma://containers/plex/stats", code: ResourceError},
+		{uri: "", code: InvalidParams},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			response := server.Dispatch(ctx, nil, &JSONRPCRequest{
+				JSONRPC: "2.0",
+				ID:      "read",
+				Method:  "resources/read",
+				Params:  map[string]interface{}{"uri": tt.uri},
+			})
+
+			if tt.code != 0 {
+				require.NotNil(t, response.Error)
+				assert.Equal(t, tt.code, response.Error.Code)
+				return
+			}
+
+			require.Nil(t, response.Error)
+			contents := response.Result.(ResourceReadResult).Contents
+			require.Len(t, contents, 1)
+			assert.Equal(t, tt.uri, contents[0].URI)
+			assert.Equal(t, tt.mimeType, contents[0].MimeType)
+			assert.Equal(t, tt.text, contents[0].Text)
+		})
+	}
+}
+
+// TestResourceSubscriptions tests that collector updates reach subscribed sessions
+func TestResourceSubscriptions(t *testing.T) {
+	server := newResourceTestServer(t)
+	ctx := context.Background()
+
+	// Subscriptions need a session to deliver notifications to
+	response := server.Dispatch(ctx, nil, &JSONRPCRequest{
+		JSONRPC: "2.0", ID: 1, Method: "resources/subscribe",
+		Params: map[string]interface{}{"uri": "uma://system/info"},
+	})
+	require.NotNil(t, response.Error)
+	assert.Equal(t, InvalidRequest, response.Error.Code)
+
+	notifications := make(chan *JSONRPCRequest, 4)
+	session := NewSession("test-session", func(notification *JSONRPCRequest) error {
+		notifications <- notification
+		return nil
+	})
+	server.sessions[session.ID()] = session
+
+	response = server.Dispatch(ctx, session, &JSONRPCRequest{
+		JSONRPC: "2.0", ID: 2, Method: "resources/subscribe",
+		Params: map[string]interface{}{"uri": "uma://unknown"},
+	})
+	require.NotNil(t, response.Error)
+	assert.Equal(t, ResourceError, response.Error.Code)
+
+	response = server.Dispatch(ctx, session, &JSONRPCRequest{
+		JSONRPC: "2.0", ID: 3, Method: "resources/subscribe",
+		Params: map[string]interface{}{"uri": "uma://system/info"},
+	})
+	require.Nil(t, response.Error)
+	assert.Equal(t, []string{"uma://system/info"}, session.Subscriptions())
+
+	// Unrelated collectors are ignored, and repeated updates are rate limited
+	server.NotifyResourceTopic("storage.disks")
+	server.NotifyResourceTopic("system.cpu")
+	server.NotifyResourceTopic("system.cpu")
+
+	select {
+	case notification := <-notifications:
+		assert.Equal(t, "notifications/resources/updated", notification.Method)
+		assert.Nil(t, notification.ID)
+		assert.Equal(t, ResourceParams{URI: "uma://system/info"}, notification.Params)
+	case <-time.After(time.Second):
+		t.Fatal("expected a resource update notification")
+	}
+
+	select {
+	case <-notifications:
+		t.Fatal("expected repeated updates to be rate limited")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	response = server.Dispatch(ctx, session, &JSONRPCRequest{
+		JSONRPC: "2.0", ID: 4, Method: "resources/unsubscribe",
+		Params: map[string]interface{}{"uri": "uma://system/info"},
+	})
+	require.Nil(t, response.Error)
+	assert.Empty(t, session.Subscriptions())
+}
+
+// TestPrompts tests listing and rendering prompts through Dispatch
+func TestPrompts(t *testing.T) {
+	server := newResourceTestServer(t)
+	ctx := context.Background()
+
+	response := server.Dispatch(ctx, nil, &JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "prompts/list"})
+	require.Nil(t, response.Error)
+	prompts := response.Result.(PromptsListResult).Prompts
+	require.Len(t, prompts, 1)
+	assert.Equal(t, "greet", prompts[0].Name)
+
+	response = server.Dispatch(ctx, nil, &JSONRPCRequest{
+		JSONRPC: "2.0", ID: 2, Method: "prompts/get",
+		Params: map[string]interface{}{"name": "greet", "arguments": map[string]string{"who": "tower"}},
+	})
+	require.Nil(t, response.Error)
+	result := response.Result.(PromptGetResult)
+	require.Len(t, result.Messages, 1)
+	assert.Equal(t, "Hello tower", result.Messages[0].Content.Text)
+
+	response = server.Dispatch(ctx, nil, &JSONRPCRequest{
+		JSONRPC: "2.0", ID: 3, Method: "prompts/get",
+		Params: map[string]interface{}{"name": "greet"},
+	})
+	require.NotNil(t, response.Error)
+	assert.Equal(t, InvalidParams, response.Error.Code)
+
+	response = server.Dispatch(ctx, nil, &JSONRPCRequest{
+		JSONRPC: "2.0", ID: 4, Method: "prompts/get",
+		Params: map[string]interface{}{"name": "missing"},
+	})
+	require.NotNil(t, response.Error)
+	assert.Equal(t, PromptError, response.Error.Code)
+}
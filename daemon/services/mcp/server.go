@@ -3,51 +3,56 @@ package mcp
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
 	"sync"
 	"time"
 
+	"github.com/domalab/uma/daemon/domain"
 	"github.com/domalab/uma/daemon/logger"
-	"github.com/domalab/uma/daemon/services/api/utils"
-	"github.com/domalab/uma/daemon/services/config"
+	"github.com/domalab/uma/daemon/services/auth"
 	"github.com/gorilla/websocket"
 )
 
-// Server represents the MCP (Model Context Protocol) server
+// Server represents the MCP (Model Context Protocol) server. It shares the
+// HTTP port and is mounted at /mcp by the REST server, where it speaks both
+// WebSocket and Streamable HTTP. The daemon also serves it over the Unix
+// socket for the stdio transport.
 type Server struct {
-	config      config.MCPConfig
-	api         utils.APIInterface
-	registry    *ToolRegistry
-	upgrader    websocket.Upgrader
-	connections map[string]*Connection
-	mutex       sync.RWMutex
-	ctx         context.Context
-	cancel      context.CancelFunc
-	server      *http.Server
+	config    domain.MCPConfig
+	registry  *ToolRegistry
+	resources *ResourceRegistry
+	prompts   *PromptRegistry
+	upgrader  websocket.Upgrader
+	sessions  map[string]*Session
+	mutex     sync.RWMutex
+	ctx       context.Context
+	cancel    context.CancelFunc
 }
 
 // Connection represents an active MCP WebSocket connection
 type Connection struct {
-	id     string
-	conn   *websocket.Conn
-	ctx    context.Context
-	cancel context.CancelFunc
-	mutex  sync.RWMutex
-	server *Server
+	id      string
+	conn    *websocket.Conn
+	ctx     context.Context
+	cancel  context.CancelFunc
+	mutex   sync.RWMutex
+	server  *Server
+	session *Session
 }
 
-// NewServer creates a new MCP server instance
-func NewServer(config config.MCPConfig, api utils.APIInterface) *Server {
+// NewServer creates a new MCP server serving the tools in registry
+func NewServer(config domain.MCPConfig, registry *ToolRegistry) *Server {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	server := &Server{
-		config:      config,
-		api:         api,
-		registry:    NewToolRegistry(api),
-		connections: make(map[string]*Connection),
-		ctx:         ctx,
-		cancel:      cancel,
+		config:    config,
+		registry:  registry,
+		resources: NewResourceRegistry(),
+		prompts:   NewPromptRegistry(),
+		sessions:  make(map[string]*Session),
+		ctx:       ctx,
+		cancel:    cancel,
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				return true // Allow all origins for now
@@ -60,75 +65,130 @@ func NewServer(config config.MCPConfig, api utils.APIInterface) *Server {
 	return server
 }
 
-// Start starts the MCP server
-func (s *Server) Start() error {
-	if !s.config.Enabled {
-		logger.Info("MCP server is disabled")
-		return nil
-	}
+// SetConfig applies the MCP section of the daemon config
+func (s *Server) SetConfig(config domain.MCPConfig) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.config = config
+}
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/mcp", s.handleWebSocket)
+// Stop closes all MCP sessions
+func (s *Server) Stop() {
+	logger.Blue("Stopping MCP server...")
 
-	s.server = &http.Server{
-		Addr:         fmt.Sprintf(":%d", s.config.Port),
-		Handler:      mux,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
-	}
+	// Cancel context to signal shutdown
+	s.cancel()
 
-	logger.Blue("Starting MCP server on port %d", s.config.Port)
+	s.mutex.Lock()
+	sessions := s.sessions
+	s.sessions = make(map[string]*Session)
+	s.mutex.Unlock()
 
-	go func() {
-		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Yellow("MCP server error: %v", err)
-		}
-	}()
+	for _, session := range sessions {
+		session.Close()
+	}
 
-	return nil
+	logger.Blue("MCP server stopped")
 }
 
-// Stop stops the MCP server
-func (s *Server) Stop() error {
-	if s.server == nil {
-		return nil
+// newSession creates an unregistered session owned by the API key in ctx
+func (s *Server) newSession(ctx context.Context, transport string, notify func(*JSONRPCRequest) error, onClose func()) *Session {
+	session := NewSession(generateSessionID(), notify)
+	session.transport = transport
+	session.onClose = onClose
+	if key, ok := auth.FromContext(ctx); ok {
+		session.keyID = key.ID
 	}
+	return session
+}
 
-	logger.Blue("Stopping MCP server...")
+// admit reports whether another session may be opened. The caller must hold the mutex.
+func (s *Server) admit() error {
+	if !s.config.Enabled {
+		return ErrMCPDisabled
+	}
 
-	// Cancel context to signal shutdown
-	s.cancel()
+	// Streamable HTTP clients may vanish without ending their session
+	now := time.Now()
+	for id, session := range s.sessions {
+		if session.transport == TransportHTTP && now.Sub(session.idleSince()) > httpSessionIdleTimeout {
+			delete(s.sessions, id)
+			go session.Close()
+		}
+	}
 
-	// Close all connections
-	s.mutex.Lock()
-	for _, conn := range s.connections {
-		conn.Close()
+	if len(s.sessions) >= s.config.MaxConnections {
+		return ErrTooManySessions
 	}
-	s.mutex.Unlock()
+	return nil
+}
 
-	// Shutdown HTTP server
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// checkAdmission reports whether a session could be opened right now
+func (s *Server) checkAdmission() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.admit()
+}
+
+// openSession registers a session if the connection limit allows it
+func (s *Server) openSession(session *Session) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
-	if err := s.server.Shutdown(ctx); err != nil {
-		logger.Yellow("Error shutting down MCP server: %v", err)
+	if err := s.admit(); err != nil {
 		return err
 	}
+	s.sessions[session.id] = session
 
-	logger.Blue("MCP server stopped")
+	logger.Green("MCP %s session opened: %s", session.transport, session.id)
 	return nil
 }
 
-// handleWebSocket handles WebSocket connections for MCP
-func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	// Check connection limit
+// closeSession unregisters and closes a session
+func (s *Server) closeSession(session *Session) {
+	s.mutex.Lock()
+	_, exists := s.sessions[session.id]
+	delete(s.sessions, session.id)
+	s.mutex.Unlock()
+
+	session.Close()
+	if exists {
+		logger.Yellow("MCP %s session closed: %s", session.transport, session.id)
+	}
+}
+
+// getSession returns a session, checking that it belongs to the API key in ctx
+func (s *Server) getSession(ctx context.Context, id string) (*Session, error) {
 	s.mutex.RLock()
-	connectionCount := len(s.connections)
+	session, exists := s.sessions[id]
 	s.mutex.RUnlock()
 
-	if connectionCount >= s.config.MaxConnections {
-		http.Error(w, "Maximum connections reached", http.StatusServiceUnavailable)
+	if !exists {
+		return nil, ErrSessionNotFound
+	}
+
+	key, _ := auth.FromContext(ctx)
+	if session.keyID != key.ID {
+		return nil, ErrSessionKeyChange
+	}
+
+	session.touch()
+	return session, nil
+}
+
+// admissionStatus maps a session admission error to an HTTP status
+func admissionStatus(err error) int {
+	if errors.Is(err, ErrMCPDisabled) {
+		return http.StatusNotFound
+	}
+	return http.StatusServiceUnavailable
+}
+
+// HandleWebSocket handles WebSocket connections for MCP
+func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	// Refuse before upgrading so clients get a proper HTTP status
+	if err := s.checkAdmission(); err != nil {
+		http.Error(w, err.Error(), admissionStatus(err))
 		return
 	}
 
@@ -139,34 +199,31 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create connection instance
-	connectionID := generateConnectionID()
+	// Create connection instance, carrying the caller's API key for per-tool scope checks
 	ctx, cancel := context.WithCancel(s.ctx)
+	if key, ok := auth.FromContext(r.Context()); ok {
+		ctx = auth.NewContext(ctx, key)
+	}
 
 	mcpConn := &Connection{
-		id:     connectionID,
 		conn:   conn,
 		ctx:    ctx,
 		cancel: cancel,
 		server: s,
 	}
+	mcpConn.session = s.newSession(ctx, TransportWebSocket, mcpConn.notify, mcpConn.Close)
+	mcpConn.id = mcpConn.session.ID()
 
-	// Register connection
-	s.mutex.Lock()
-	s.connections[connectionID] = mcpConn
-	s.mutex.Unlock()
-
-	logger.Green("New MCP connection established: %s", connectionID)
+	// Another transport may have taken the last slot since the check above
+	if err := s.openSession(mcpConn.session); err != nil {
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, err.Error()))
+		mcpConn.Close()
+		return
+	}
 
-	// Handle connection
+	// Handle connection, then clean up on disconnect
 	mcpConn.Handle()
-
-	// Cleanup on disconnect
-	s.mutex.Lock()
-	delete(s.connections, connectionID)
-	s.mutex.Unlock()
-
-	logger.Yellow("MCP connection closed: %s", connectionID)
+	s.closeSession(mcpConn.session)
 }
 
 // Handle handles the MCP connection lifecycle
@@ -226,76 +283,82 @@ func (c *Connection) Handle() {
 
 // Close closes the MCP connection
 func (c *Connection) Close() {
-	c.cancel()
-	c.conn.Close()
-}
-
-// processMessage processes incoming MCP messages
-func (c *Connection) processMessage(data []byte) error {
-	var request JSONRPCRequest
-	if err := json.Unmarshal(data, &request); err != nil {
-		return c.sendError(nil, -32700, "Parse error", nil)
+	if c.cancel != nil {
+		c.cancel()
 	}
-
-	// Handle different MCP methods
-	switch request.Method {
-	case "initialize":
-		return c.handleInitialize(&request)
-	case "tools/list":
-		return c.handleToolsList(&request)
-	case "tools/call":
-		return c.handleToolsCall(&request)
-	default:
-		return c.sendError(request.ID, -32601, "Method not found", nil)
+	if c.conn != nil {
+		c.conn.Close()
 	}
 }
 
-// sendResponse sends a JSON-RPC response
-func (c *Connection) sendResponse(id interface{}, result interface{}) error {
-	response := JSONRPCResponse{
-		JSONRPC: "2.0",
-		ID:      id,
-		Result:  result,
+// processMessage dispatches an incoming message and writes the reply, if any
+func (c *Connection) processMessage(data []byte) error {
+	reply := c.server.handleMessage(c.ctx, c.session, data)
+	if reply == nil {
+		return nil
 	}
+	return c.write(reply)
+}
 
-	data, err := json.Marshal(response)
+// notify writes a server-initiated JSON-RPC notification to the WebSocket
+func (c *Connection) notify(notification *JSONRPCRequest) error {
+	data, err := json.Marshal(notification)
 	if err != nil {
 		return err
 	}
+	return c.write(data)
+}
 
+// write sends a text frame to the WebSocket
+func (c *Connection) write(data []byte) error {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 	return c.conn.WriteMessage(websocket.TextMessage, data)
 }
 
-// sendError sends a JSON-RPC error response
-func (c *Connection) sendError(id interface{}, code int, message string, data interface{}) error {
-	response := JSONRPCResponse{
+// newErrorResponse creates a JSON-RPC error response
+func newErrorResponse(id interface{}, code int, message string) *JSONRPCResponse {
+	return &JSONRPCResponse{
 		JSONRPC: "2.0",
 		ID:      id,
 		Error: &JSONRPCError{
 			Code:    code,
 			Message: message,
-			Data:    data,
 		},
 	}
+}
 
-	responseData, err := json.Marshal(response)
-	if err != nil {
-		return err
-	}
+// GetRegistry returns the tool registry
+func (s *Server) GetRegistry() *ToolRegistry {
+	return s.registry
+}
 
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-	return c.conn.WriteMessage(websocket.TextMessage, responseData)
+// Resources returns the resource registry
+func (s *Server) Resources() *ResourceRegistry {
+	return s.resources
 }
 
-// generateConnectionID generates a unique connection ID
-func generateConnectionID() string {
-	return fmt.Sprintf("mcp-%d", time.Now().UnixNano())
+// Prompts returns the prompt registry
+func (s *Server) Prompts() *PromptRegistry {
+	return s.prompts
 }
 
-// GetRegistry returns the tool registry
-func (s *Server) GetRegistry() *ToolRegistry {
-	return s.registry
+// NotifyResourceTopic tells subscribers of every resource fed by topic that
+// it changed. It is called by the system collector after each collection.
+func (s *Server) NotifyResourceTopic(topic string) {
+	s.mutex.RLock()
+	sessions := make([]*Session, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	s.mutex.RUnlock()
+
+	now := time.Now()
+	for _, session := range sessions {
+		for _, uri := range session.Subscriptions() {
+			if resourceTopic, ok := s.resources.Topic(uri); ok && resourceTopic == topic {
+				session.resourceUpdated(uri, now)
+			}
+		}
+	}
 }
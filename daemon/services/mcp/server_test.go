@@ -7,62 +7,42 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/domalab/uma/daemon/services/config"
+	"github.com/domalab/uma/daemon/domain"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestNewServer tests MCP server creation
 func TestNewServer(t *testing.T) {
-	config := config.MCPConfig{
+	config := domain.MCPConfig{
 		Enabled:        true,
-		Port:           34800,
 		MaxConnections: 100,
 	}
 
-	mockAPI := &MockAPIInterface{}
-	server := NewServer(config, mockAPI)
+	server := NewServer(config, newTestRegistry(t))
 
 	assert.NotNil(t, server)
 	assert.Equal(t, config.Enabled, server.config.Enabled)
-	assert.Equal(t, config.Port, server.config.Port)
 	assert.Equal(t, config.MaxConnections, server.config.MaxConnections)
 	assert.NotNil(t, server.registry)
-	assert.NotNil(t, server.connections)
+	assert.NotNil(t, server.sessions)
 	assert.NotNil(t, server.ctx)
 }
 
-// TestServerStartStop tests server lifecycle
-func TestServerStartStop(t *testing.T) {
-	config := config.MCPConfig{
-		Enabled:        true,
-		Port:           0, // Use random port for testing
-		MaxConnections: 10,
-	}
+// TestServerStop tests that stopping closes open sessions
+func TestServerStop(t *testing.T) {
+	server := NewServer(domain.MCPConfig{Enabled: true, MaxConnections: 10}, newTestRegistry(t))
+
+	ctx, cancel := context.WithCancel(server.ctx)
+	conn := &Connection{id: "test-conn", ctx: ctx, cancel: cancel}
+	conn.session = server.newSession(ctx, TransportWebSocket, nil, conn.Close)
+	require.NoError(t, server.openSession(conn.session))
+
+	server.Stop()
 
-	mockAPI := &MockAPIInterface{}
-	server := NewServer(config, mockAPI)
-
-	// Test start with disabled server
-	config.Enabled = false
-	server.config = config
-	err := server.Start()
-	assert.NoError(t, err)
-
-	// Test start with enabled server
-	config.Enabled = true
-	config.Port = 0 // Random port
-	server.config = config
-	err = server.Start()
-	assert.NoError(t, err)
-
-	// Test stop
-	err = server.Stop()
-	assert.NoError(t, err)
-
-	// Test stop when server is nil
-	server.server = nil
-	err = server.Stop()
-	assert.NoError(t, err)
+	assert.Error(t, server.ctx.Err())
+	assert.Error(t, conn.ctx.Err())
+	assert.Empty(t, server.sessions)
 }
 
 // TestWebSocketUpgrade tests WebSocket connection upgrade
@@ -74,19 +54,15 @@ func TestWebSocketUpgrade(t *testing.T) {
 
 // TestConnectionLimit tests maximum connection limit
 func TestConnectionLimit(t *testing.T) {
-	config := config.MCPConfig{
+	config := domain.MCPConfig{
 		Enabled:        true,
-		Port:           34800,
 		MaxConnections: 1, // Very low limit for testing
 	}
 
-	mockAPI := &MockAPIInterface{}
-	server := NewServer(config, mockAPI)
+	server := NewServer(config, newTestRegistry(t))
 
-	// Simulate max connections reached
-	server.mutex.Lock()
-	server.connections["test-conn-1"] = &Connection{id: "test-conn-1"}
-	server.mutex.Unlock()
+	// Simulate max connections reached by a session on another transport
+	require.NoError(t, server.openSession(server.newSession(context.Background(), TransportStdio, nil, nil)))
 
 	// Create test request
 	req := httptest.NewRequest("GET", "/mcp", nil)
@@ -96,16 +72,27 @@ func TestConnectionLimit(t *testing.T) {
 	req.Header.Set("Sec-WebSocket-Version", "13")
 
 	w := httptest.NewRecorder()
-	server.handleWebSocket(w, req)
+	server.HandleWebSocket(w, req)
 
 	// Should return 503 Service Unavailable
 	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
 }
 
-// TestGenerateConnectionID tests connection ID generation
-func TestGenerateConnectionID(t *testing.T) {
-	id1 := generateConnectionID()
-	id2 := generateConnectionID()
+// TestDisabledServer tests that a disabled server refuses connections
+func TestDisabledServer(t *testing.T) {
+	server := NewServer(domain.MCPConfig{Enabled: true, MaxConnections: 10}, newTestRegistry(t))
+	server.SetConfig(domain.MCPConfig{Enabled: false, MaxConnections: 10})
+
+	w := httptest.NewRecorder()
+	server.HandleWebSocket(w, httptest.NewRequest("GET", "/mcp", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// TestGenerateSessionID tests session ID generation
+func TestGenerateSessionID(t *testing.T) {
+	id1 := generateSessionID()
+	id2 := generateSessionID()
 
 	assert.NotEmpty(t, id1)
 	assert.NotEmpty(t, id2)
@@ -116,33 +103,24 @@ func TestGenerateConnectionID(t *testing.T) {
 
 // TestGetServerStats tests server statistics
 func TestGetServerStats(t *testing.T) {
-	config := config.MCPConfig{
+	config := domain.MCPConfig{
 		Enabled:        true,
-		Port:           34800,
 		MaxConnections: 100,
 	}
 
-	mockAPI := &MockAPIInterface{}
-	server := NewServer(config, mockAPI)
+	server := NewServer(config, newTestRegistry(t))
 
-	// Add mock connection
-	server.mutex.Lock()
-	mockConn := &Connection{
-		id:  "test-conn",
-		ctx: context.Background(),
-	}
-	server.connections["test-conn"] = mockConn
-	server.mutex.Unlock()
+	// Add a session
+	require.NoError(t, server.openSession(server.newSession(context.Background(), TransportHTTP, nil, nil)))
 
 	stats := server.GetServerStats()
 
 	assert.NotNil(t, stats)
 	assert.Equal(t, true, stats["enabled"])
-	assert.Equal(t, 34800, stats["port"])
 	assert.Equal(t, 100, stats["max_connections"])
 	assert.Equal(t, 1, stats["active_connections"])
 	assert.Contains(t, stats, "connections")
-	assert.Contains(t, stats, "total_tools")
+	assert.Equal(t, 3, stats["total_tools"])
 }
 
 // TestConnectionClose tests connection cleanup
@@ -186,38 +164,29 @@ func TestConnectionGetStats(t *testing.T) {
 
 // TestServerGetRegistry tests registry access
 func TestServerGetRegistry(t *testing.T) {
-	config := config.MCPConfig{
-		Enabled:        true,
-		Port:           34800,
-		MaxConnections: 100,
-	}
-
-	mockAPI := &MockAPIInterface{}
-	server := NewServer(config, mockAPI)
+	registry := newTestRegistry(t)
+	server := NewServer(domain.MCPConfig{Enabled: true, MaxConnections: 100}, registry)
 
-	registry := server.GetRegistry()
-	assert.NotNil(t, registry)
-	assert.IsType(t, &SimpleToolRegistry{}, registry)
+	assert.Same(t, registry, server.GetRegistry())
 }
 
 // Benchmark tests for performance validation
 func BenchmarkNewServer(b *testing.B) {
-	config := config.MCPConfig{
+	config := domain.MCPConfig{
 		Enabled:        true,
-		Port:           34800,
 		MaxConnections: 100,
 	}
-	mockAPI := &MockAPIInterface{}
+	registry := NewToolRegistry(http.NotFoundHandler())
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_ = NewServer(config, mockAPI)
+		_ = NewServer(config, registry)
 	}
 }
 
-func BenchmarkGenerateConnectionID(b *testing.B) {
+func BenchmarkGenerateSessionID(b *testing.B) {
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_ = generateConnectionID()
+		_ = generateSessionID()
 	}
 }
@@ -0,0 +1,190 @@
+package mcp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// resourceUpdateInterval limits how often a subscriber is told about a changing resource
+	resourceUpdateInterval = 5 * time.Second
+
+	// httpSessionIdleTimeout expires Streamable HTTP sessions whose client went away without DELETE
+	httpSessionIdleTimeout = 30 * time.Minute
+)
+
+// Transports an MCP session can be served over
+const (
+	TransportWebSocket = "websocket"
+	TransportHTTP      = "http"
+	TransportStdio     = "stdio"
+)
+
+var (
+	ErrMCPDisabled      = errors.New("MCP server is disabled")
+	ErrTooManySessions  = errors.New("maximum connections reached")
+	ErrSessionNotFound  = errors.New("session not found")
+	ErrSessionKeyChange = errors.New("session belongs to another API key")
+)
+
+// Session holds the state of one MCP client, such as its resource
+// subscriptions. Every transport opens one per client and they all count
+// towards MCPConfig.MaxConnections.
+type Session struct {
+	id            string
+	transport     string
+	keyID         string // API key that opened the session, if any
+	created       time.Time
+	lastSeen      time.Time
+	notify        func(notification *JSONRPCRequest) error
+	notifyGen     int // Bumped whenever an SSE stream takes over notify
+	onClose       func()
+	done          chan struct{}
+	closeOnce     sync.Once
+	subscriptions map[string]time.Time // URI -> last update sent
+	mutex         sync.Mutex
+}
+
+// NewSession creates a session that delivers server notifications through notify
+func NewSession(id string, notify func(notification *JSONRPCRequest) error) *Session {
+	now := time.Now()
+	return &Session{
+		id:            id,
+		created:       now,
+		lastSeen:      now,
+		notify:        notify,
+		done:          make(chan struct{}),
+		subscriptions: make(map[string]time.Time),
+	}
+}
+
+// ID returns the session ID
+func (s *Session) ID() string {
+	return s.id
+}
+
+// Done is closed when the session ends
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close ends the session and releases its transport
+func (s *Session) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		if s.onClose != nil {
+			s.onClose()
+		}
+	})
+}
+
+// Subscribe starts sending update notifications for uri
+func (s *Session) Subscribe(uri string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, exists := s.subscriptions[uri]; !exists {
+		s.subscriptions[uri] = time.Time{}
+	}
+}
+
+// Unsubscribe stops update notifications for uri
+func (s *Session) Unsubscribe(uri string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.subscriptions, uri)
+}
+
+// Subscriptions returns the subscribed URIs
+func (s *Session) Subscriptions() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	uris := make([]string, 0, len(s.subscriptions))
+	for uri := range s.subscriptions {
+		uris = append(uris, uri)
+	}
+	return uris
+}
+
+// attach routes notifications to a Streamable HTTP SSE stream. The returned
+// function detaches it again, unless a newer stream has taken over.
+func (s *Session) attach(notify func(notification *JSONRPCRequest) error) func() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.notifyGen++
+	generation := s.notifyGen
+	s.notify = notify
+
+	return func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if s.notifyGen == generation {
+			s.notify = nil
+		}
+	}
+}
+
+// touch records client activity
+func (s *Session) touch() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lastSeen = time.Now()
+}
+
+// idleSince returns when the client was last active
+func (s *Session) idleSince() time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lastSeen
+}
+
+// stats returns a summary of the session for server statistics
+func (s *Session) stats() map[string]interface{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return map[string]interface{}{
+		"id":            s.id,
+		"transport":     s.transport,
+		"created":       s.created,
+		"last_seen":     s.lastSeen,
+		"subscriptions": len(s.subscriptions),
+	}
+}
+
+// resourceUpdated notifies the client that uri changed, at most once per interval
+func (s *Session) resourceUpdated(uri string, now time.Time) {
+	s.mutex.Lock()
+	last, subscribed := s.subscriptions[uri]
+	notify := s.notify
+	due := subscribed && notify != nil && now.Sub(last) >= resourceUpdateInterval
+	if due {
+		s.subscriptions[uri] = now
+	}
+	s.mutex.Unlock()
+
+	if !due {
+		return
+	}
+
+	// Delivery must not hold up the collector that triggered it
+	go notify(&JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "notifications/resources/updated",
+		Params:  ResourceParams{URI: uri},
+	})
+}
+
+// generateSessionID generates an unguessable session ID, since Streamable
+// HTTP clients present it on every request
+func generateSessionID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "mcp-" + time.Now().Format("20060102150405.000000000")
+	}
+	return "mcp-" + hex.EncodeToString(buf)
+}
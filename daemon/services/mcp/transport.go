@@ -0,0 +1,287 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/domalab/uma/daemon/httputil"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// SessionHeader carries the Streamable HTTP session ID
+	SessionHeader = "Mcp-Session-Id"
+
+	// maxMessageSize bounds a single JSON-RPC message or batch
+	maxMessageSize = 4 << 20
+
+	// streamPingInterval keeps idle SSE streams and their session alive
+	streamPingInterval = 30 * time.Second
+)
+
+var errStreamBacklog = errors.New("SSE stream is not keeping up")
+
+// ServeHTTP serves /mcp. WebSocket upgrades are handed to HandleWebSocket;
+// everything else is the Streamable HTTP transport: POST carries client
+// messages, GET opens an SSE stream for server notifications and DELETE ends
+// the session.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		s.HandleWebSocket(w, r)
+		return
+	}
+
+	s.mutex.RLock()
+	enabled := s.config.Enabled
+	s.mutex.RUnlock()
+	if !enabled {
+		http.Error(w, ErrMCPDisabled.Error(), http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		s.handleHTTPPost(w, r)
+	case http.MethodGet:
+		s.handleHTTPStream(w, r)
+	case http.MethodDelete:
+		s.handleHTTPDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleHTTPPost dispatches a POSTed message or batch. An initialize request
+// without a session header opens a new session.
+func (s *Server) handleHTTPPost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxMessageSize+1))
+	if err != nil {
+		http.Error(w, "Failed to read request", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxMessageSize {
+		http.Error(w, "Message too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var session *Session
+	if id := r.Header.Get(SessionHeader); id != "" {
+		if session, err = s.getSession(r.Context(), id); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+	} else if isInitialize(body) {
+		session = s.newSession(r.Context(), TransportHTTP, nil, nil)
+		if err := s.openSession(session); err != nil {
+			http.Error(w, err.Error(), admissionStatus(err))
+			return
+		}
+		w.Header().Set(SessionHeader, session.ID())
+	} else {
+		http.Error(w, "Missing "+SessionHeader+" header", http.StatusBadRequest)
+		return
+	}
+
+	reply := s.handleMessage(r.Context(), session, body)
+	if reply == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	// Clients accept both; SSE is only used for those that insist on it
+	accept := r.Header.Get("Accept")
+	if strings.Contains(accept, "text/event-stream") && !strings.Contains(accept, "application/json") {
+		writeStreamHeaders(w)
+		writeStreamEvent(w, reply)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(reply)
+}
+
+// handleHTTPStream holds an SSE stream open and writes server notifications to it
+func (s *Server) handleHTTPStream(w http.ResponseWriter, r *http.Request) {
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		http.Error(w, "Accept must include text/event-stream", http.StatusNotAcceptable)
+		return
+	}
+
+	session, status, err := s.requestSession(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	httputil.DisableWriteDeadline(w, "MCP stream")
+
+	messages := make(chan []byte, 32)
+	detach := session.attach(func(notification *JSONRPCRequest) error {
+		data, err := json.Marshal(notification)
+		if err != nil {
+			return err
+		}
+		select {
+		case messages <- data:
+			return nil
+		default:
+			return errStreamBacklog
+		}
+	})
+	defer detach()
+
+	writeStreamHeaders(w)
+
+	ticker := time.NewTicker(streamPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-session.Done():
+			return
+		case <-s.ctx.Done():
+			return
+		case data := <-messages:
+			writeStreamEvent(w, data)
+		case <-ticker.C:
+			session.touch()
+			fmt.Fprint(w, ": ping\n\n")
+			flush(w)
+		}
+	}
+}
+
+// handleHTTPDelete ends a Streamable HTTP session
+func (s *Server) handleHTTPDelete(w http.ResponseWriter, r *http.Request) {
+	session, status, err := s.requestSession(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	s.closeSession(session)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requestSession looks up the session named by the request's session header
+func (s *Server) requestSession(r *http.Request) (*Session, int, error) {
+	id := r.Header.Get(SessionHeader)
+	if id == "" {
+		return nil, http.StatusBadRequest, fmt.Errorf("missing %s header", SessionHeader)
+	}
+
+	session, err := s.getSession(r.Context(), id)
+	if err != nil {
+		return nil, http.StatusNotFound, err
+	}
+	return session, http.StatusOK, nil
+}
+
+// isInitialize reports whether a message is an initialize request
+func isInitialize(body []byte) bool {
+	var probe struct {
+		Method string `json:"method"`
+	}
+	return json.Unmarshal(body, &probe) == nil && probe.Method == "initialize"
+}
+
+// writeStreamHeaders starts an SSE response
+func writeStreamHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flush(w)
+}
+
+// writeStreamEvent writes one JSON-RPC message as an SSE event
+func writeStreamEvent(w http.ResponseWriter, data []byte) {
+	fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+	flush(w)
+}
+
+// flush pushes buffered SSE output to the client
+func flush(w http.ResponseWriter) {
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// ServeStream serves one MCP session over newline-delimited JSON-RPC, as
+// used by the stdio transport. It returns when r is exhausted, ctx is
+// canceled or the server stops; the caller owns r and w.
+func (s *Server) ServeStream(ctx context.Context, r io.Reader, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var writeMutex sync.Mutex
+	writeLine := func(data []byte) error {
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+		_, err := w.Write(append(data, '\n'))
+		return err
+	}
+
+	session := s.newSession(ctx, TransportStdio, func(notification *JSONRPCRequest) error {
+		data, err := json.Marshal(notification)
+		if err != nil {
+			return err
+		}
+		return writeLine(data)
+	}, cancel)
+
+	if err := s.openSession(session); err != nil {
+		writeLine(encodeReply(newErrorResponse(nil, InternalError, err.Error())))
+		return err
+	}
+	defer s.closeSession(session)
+
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+		for scanner.Scan() {
+			line := append([]byte(nil), scanner.Bytes()...)
+			select {
+			case lines <- line:
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr <- scanner.Err()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.ctx.Done():
+			return nil
+		case err := <-readErr:
+			return err
+		case line := <-lines:
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			if reply := s.handleMessage(ctx, session, line); reply != nil {
+				if err := writeLine(reply); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/domalab/uma/daemon/domain"
+	"github.com/domalab/uma/daemon/services/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const initializeMessage = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","clientInfo":{"name":"test","version":"1.0"}}}`
+
+// postMCP sends a Streamable HTTP POST and returns the response
+func postMCP(t *testing.T, url, sessionID, body string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if sessionID != "" {
+		req.Header.Set(SessionHeader, sessionID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+// TestStreamableHTTP tests the session lifecycle of the Streamable HTTP transport
+func TestStreamableHTTP(t *testing.T) {
+	server := newResourceTestServer(t)
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	// Requests other than initialize need a session
+	resp := postMCP(t, httpServer.URL, "", `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = postMCP(t, httpServer.URL, "", initializeMessage)
+	var initialized JSONRPCResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&initialized))
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Nil(t, initialized.Error)
+	assert.Equal(t, MCPStreamableProtocolVersion, initialized.Result.(map[string]interface{})["protocolVersion"])
+
+	sessionID := resp.Header.Get(SessionHeader)
+	require.NotEmpty(t, sessionID)
+
+	// Notifications are accepted without a body
+	resp = postMCP(t, httpServer.URL, sessionID, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	// Batches are answered with a batch
+	resp = postMCP(t, httpServer.URL, sessionID, `[{"jsonrpc":"2.0","id":2,"method":"tools/list"},{"jsonrpc":"2.0","id":3,"method":"ping"}]`)
+	var batch []JSONRPCResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&batch))
+	resp.Body.Close()
+	require.Len(t, batch, 2)
+	assert.Nil(t, batch[0].Error)
+	assert.Nil(t, batch[1].Error)
+
+	resp = postMCP(t, httpServer.URL, "mcp-unknown", `{"jsonrpc":"2.0","id":4,"method":"ping"}`)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Server notifications arrive on the SSE stream
+	req, err := http.NewRequest(http.MethodGet, httpServer.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set(SessionHeader, sessionID)
+	stream, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer stream.Body.Close()
+	require.Equal(t, http.StatusOK, stream.StatusCode)
+	assert.Equal(t, "text/event-stream", stream.Header.Get("Content-Type"))
+
+	resp = postMCP(t, httpServer.URL, sessionID, `{"jsonrpc":"2.0","id":5,"method":"resources/subscribe","params":{"uri":"uma://system/info"}}`)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Wait for the stream to attach before triggering an update
+	session, err := server.getSession(context.Background(), sessionID)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		session.mutex.Lock()
+		defer session.mutex.Unlock()
+		return session.notify != nil
+	}, time.Second, 5*time.Millisecond)
+
+	server.NotifyResourceTopic("system.cpu")
+
+	events := make(chan string, 1)
+	go func() {
+		reader := bufio.NewReader(stream.Body)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				events <- data
+				return
+			}
+		}
+	}()
+
+	select {
+	case data := <-events:
+		assert.Contains(t, data, "notifications/resources/updated")
+		assert.Contains(t, data, "uma://system/info")
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a resource update on the SSE stream")
+	}
+
+	// DELETE ends the session
+	req, err = http.NewRequest(http.MethodDelete, httpServer.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set(SessionHeader, sessionID)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp = postMCP(t, httpServer.URL, sessionID, `{"jsonrpc":"2.0","id":6,"method":"ping"}`)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+// TestStreamableHTTPSessionKey tests that sessions cannot be used with another API key
+func TestStreamableHTTPSessionKey(t *testing.T) {
+	server := newTestServer(t)
+
+	withKey := func(id string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := domain.APIKey{ID: id, Scope: string(auth.ScopeAdmin)}
+			server.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), key)))
+		})
+	}
+
+	w := httptest.NewRecorder()
+	withKey("owner").ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(initializeMessage)))
+	require.Equal(t, http.StatusOK, w.Code)
+	sessionID := w.Header().Get(SessionHeader)
+
+	req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":2,"method":"ping"}`))
+	req.Header.Set(SessionHeader, sessionID)
+	w = httptest.NewRecorder()
+	withKey("intruder").ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// TestServeStream tests the newline-delimited stdio transport
+func TestServeStream(t *testing.T) {
+	server := newTestServer(t)
+
+	input := strings.Join([]string{
+		initializeMessage,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		"",
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`not json`,
+	}, "\n") + "\n"
+
+	var output strings.Builder
+	require.NoError(t, server.ServeStream(context.Background(), strings.NewReader(input), &output))
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	require.Len(t, lines, 3)
+
+	var response JSONRPCResponse
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &response))
+	assert.Equal(t, float64(2), response.ID)
+	assert.Len(t, response.Result.(map[string]interface{})["tools"], 3)
+
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &response))
+	require.NotNil(t, response.Error)
+	assert.Equal(t, ParseError, response.Error.Code)
+
+	// The session is released when the stream ends
+	assert.Empty(t, server.sessions)
+}
+
+// TestTransportsShareConnectionLimit tests that every transport counts towards MaxConnections
+func TestTransportsShareConnectionLimit(t *testing.T) {
+	server := NewServer(domain.MCPConfig{Enabled: true, MaxConnections: 1}, newTestRegistry(t))
+
+	reader, writer := io.Pipe()
+	defer writer.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- server.ServeStream(context.Background(), reader, io.Discard)
+	}()
+
+	require.Eventually(t, func() bool {
+		server.mutex.RLock()
+		defer server.mutex.RUnlock()
+		return len(server.sessions) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(initializeMessage)))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	var output strings.Builder
+	err := server.ServeStream(context.Background(), strings.NewReader(""), &output)
+	assert.ErrorIs(t, err, ErrTooManySessions)
+	assert.Contains(t, output.String(), "maximum connections reached")
+
+	// Closing stdin ends the stdio session and frees the slot
+	writer.Close()
+	require.NoError(t, <-done)
+
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(initializeMessage)))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -97,9 +97,19 @@ type ServerInfo struct {
 
 // Tool represents an MCP tool
 type Tool struct {
-	Name        string     `json:"name"`
-	Description string     `json:"description"`
-	InputSchema ToolSchema `json:"inputSchema"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	InputSchema ToolSchema       `json:"inputSchema"`
+	Annotations *ToolAnnotations `json:"annotations,omitempty"`
+}
+
+// ToolAnnotations describes a tool's side effects so clients can ask for confirmation
+type ToolAnnotations struct {
+	Title           string `json:"title,omitempty"`
+	ReadOnlyHint    bool   `json:"readOnlyHint"`
+	DestructiveHint bool   `json:"destructiveHint"`
+	IdempotentHint  bool   `json:"idempotentHint"`
+	OpenWorldHint   bool   `json:"openWorldHint"`
 }
 
 // ToolSchema represents a tool's input schema
@@ -122,8 +132,9 @@ type ToolCallParams struct {
 
 // ToolCallResult represents the result of tools/call
 type ToolCallResult struct {
-	Content []ToolContent `json:"content"`
-	IsError bool          `json:"isError,omitempty"`
+	Content           []ToolContent `json:"content"`
+	StructuredContent interface{}   `json:"structuredContent,omitempty"`
+	IsError           bool          `json:"isError,omitempty"`
 }
 
 // ToolContent represents tool execution content
@@ -133,6 +144,86 @@ type ToolContent struct {
 	Data interface{} `json:"data,omitempty"`
 }
 
+// Resource represents a readable MCP resource
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceTemplate represents a parameterized family of resources
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourcesListResult represents the result of resources/list
+type ResourcesListResult struct {
+	Resources []Resource `json:"resources"`
+}
+
+// ResourceTemplatesListResult represents the result of resources/templates/list
+type ResourceTemplatesListResult struct {
+	ResourceTemplates []ResourceTemplate `json:"resourceTemplates"`
+}
+
+// ResourceParams represents parameters for resources/read, subscribe and unsubscribe
+type ResourceParams struct {
+	URI string `json:"uri"`
+}
+
+// ResourceContents represents the contents of a resource
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text"`
+}
+
+// ResourceReadResult represents the result of resources/read
+type ResourceReadResult struct {
+	Contents []ResourceContents `json:"contents"`
+}
+
+// Prompt represents a canned prompt template
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+// PromptArgument represents an argument accepted by a prompt
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// PromptsListResult represents the result of prompts/list
+type PromptsListResult struct {
+	Prompts []Prompt `json:"prompts"`
+}
+
+// PromptGetParams represents parameters for prompts/get
+type PromptGetParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+// PromptMessage represents a message in a rendered prompt
+type PromptMessage struct {
+	Role    string      `json:"role"`
+	Content ToolContent `json:"content"`
+}
+
+// PromptGetResult represents the result of prompts/get
+type PromptGetResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
 // Error codes for JSON-RPC
 const (
 	// Standard JSON-RPC error codes
@@ -150,5 +241,9 @@ const (
 	PromptError   = -32004
 )
 
-// MCP protocol version
-const MCPProtocolVersion = "2024-11-05"
+// MCP protocol versions. Clients asking for the newer revision, which
+// introduced Streamable HTTP, are answered with it.
+const (
+	MCPProtocolVersion           = "2024-11-05"
+	MCPStreamableProtocolVersion = "2025-03-26"
+)
@@ -0,0 +1,255 @@
+package metrics
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/domalab/uma/daemon/dto"
+	"github.com/domalab/uma/daemon/plugins/storage"
+	"github.com/domalab/uma/daemon/plugins/ups"
+	"github.com/domalab/uma/daemon/services/collectors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricSource provides cached collector snapshots by name
+type MetricSource interface {
+	GetMetric(name string) (interface{}, bool)
+}
+
+// PrometheusCollector exports collector snapshots as Prometheus series.
+// It only reads cached data, so a scrape never shells out or wakes disks;
+// the background collectors decide how often the underlying data is refreshed.
+type PrometheusCollector struct {
+	source MetricSource
+
+	cpuPercent    *prometheus.Desc
+	load1         *prometheus.Desc
+	memoryUsed    *prometheus.Desc
+	memoryTotal   *prometheus.Desc
+	networkRx     *prometheus.Desc
+	networkTx     *prometheus.Desc
+	containers    *prometheus.Desc
+	containerUp   *prometheus.Desc
+	containerCPU  *prometheus.Desc
+	containerMem  *prometheus.Desc
+	containerLim  *prometheus.Desc
+	containerRx   *prometheus.Desc
+	containerTx   *prometheus.Desc
+	diskTemp      *prometheus.Desc
+	diskSpunDown  *prometheus.Desc
+	diskSize      *prometheus.Desc
+	diskFsSize    *prometheus.Desc
+	diskFsUsed    *prometheus.Desc
+	diskErrors    *prometheus.Desc
+	parityActive  *prometheus.Desc
+	parityRatio   *prometheus.Desc
+	parityErrors  *prometheus.Desc
+	upsOnline     *prometheus.Desc
+	upsLoad       *prometheus.Desc
+	upsCharge     *prometheus.Desc
+	upsRuntime    *prometheus.Desc
+	upsPower      *prometheus.Desc
+	metricPresent *prometheus.Desc
+}
+
+// NewPrometheusCollector creates a collector exporting data from source
+func NewPrometheusCollector(source MetricSource) *PrometheusCollector {
+	diskLabels := []string{"disk", "role"}
+
+	return &PrometheusCollector{
+		source: source,
+
+		cpuPercent:  prometheus.NewDesc("uma_cpu_usage_percent", "Host CPU usage in percent", nil, nil),
+		load1:       prometheus.NewDesc("uma_load1", "Host 1-minute load average", nil, nil),
+		memoryUsed:  prometheus.NewDesc("uma_memory_used_bytes", "Host memory in use, excluding buffers and cache", nil, nil),
+		memoryTotal: prometheus.NewDesc("uma_memory_total_bytes", "Total host memory", nil, nil),
+		networkRx:   prometheus.NewDesc("uma_network_receive_bytes_total", "Bytes received on all non-loopback interfaces", nil, nil),
+		networkTx:   prometheus.NewDesc("uma_network_transmit_bytes_total", "Bytes transmitted on all non-loopback interfaces", nil, nil),
+
+		containers:   prometheus.NewDesc("uma_containers", "Number of containers by state", []string{"state"}, nil),
+		containerUp:  prometheus.NewDesc("uma_container_running", "Whether the container is running", []string{"name"}, nil),
+		containerCPU: prometheus.NewDesc("uma_container_cpu_percent", "Container CPU usage in percent", []string{"name"}, nil),
+		containerMem: prometheus.NewDesc("uma_container_memory_usage_bytes", "Container memory usage", []string{"name"}, nil),
+		containerLim: prometheus.NewDesc("uma_container_memory_limit_bytes", "Container memory limit", []string{"name"}, nil),
+		containerRx:  prometheus.NewDesc("uma_container_network_receive_bytes_total", "Bytes received by the container", []string{"name"}, nil),
+		containerTx:  prometheus.NewDesc("uma_container_network_transmit_bytes_total", "Bytes transmitted by the container", []string{"name"}, nil),
+
+		diskTemp:     prometheus.NewDesc("uma_disk_temperature_celsius", "Disk temperature; absent while the disk is spun down", diskLabels, nil),
+		diskSpunDown: prometheus.NewDesc("uma_disk_spun_down", "Whether the disk is spun down", diskLabels, nil),
+		diskSize:     prometheus.NewDesc("uma_disk_size_bytes", "Raw disk capacity", diskLabels, nil),
+		diskFsSize:   prometheus.NewDesc("uma_disk_filesystem_size_bytes", "Filesystem capacity on the disk", diskLabels, nil),
+		diskFsUsed:   prometheus.NewDesc("uma_disk_filesystem_used_bytes", "Filesystem space used on the disk", diskLabels, nil),
+		diskErrors:   prometheus.NewDesc("uma_disk_errors_total", "Read/write errors reported by the array driver", diskLabels, nil),
+
+		parityActive: prometheus.NewDesc("uma_parity_check_active", "Whether a parity check or rebuild is running", nil, nil),
+		parityRatio:  prometheus.NewDesc("uma_parity_check_progress_ratio", "Progress of the running parity check from 0 to 1", nil, nil),
+		parityErrors: prometheus.NewDesc("uma_parity_check_errors", "Errors found by the running parity check", nil, nil),
+
+		upsOnline:  prometheus.NewDesc("uma_ups_online", "Whether the UPS is running on line power", nil, nil),
+		upsLoad:    prometheus.NewDesc("uma_ups_load_percent", "UPS load in percent", nil, nil),
+		upsCharge:  prometheus.NewDesc("uma_ups_battery_charge_percent", "UPS battery charge in percent", nil, nil),
+		upsRuntime: prometheus.NewDesc("uma_ups_runtime_seconds", "Estimated UPS runtime on battery", nil, nil),
+		upsPower:   prometheus.NewDesc("uma_ups_power_watts", "UPS output power", nil, nil),
+
+		metricPresent: prometheus.NewDesc("uma_collector_up", "Whether a fresh snapshot is available from the collector", []string{"collector"}, nil),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (pc *PrometheusCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		pc.cpuPercent, pc.load1, pc.memoryUsed, pc.memoryTotal, pc.networkRx, pc.networkTx,
+		pc.containers, pc.containerUp, pc.containerCPU, pc.containerMem, pc.containerLim, pc.containerRx, pc.containerTx,
+		pc.diskTemp, pc.diskSpunDown, pc.diskSize, pc.diskFsSize, pc.diskFsUsed, pc.diskErrors,
+		pc.parityActive, pc.parityRatio, pc.parityErrors,
+		pc.upsOnline, pc.upsLoad, pc.upsCharge, pc.upsRuntime, pc.upsPower,
+		pc.metricPresent,
+	} {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector
+func (pc *PrometheusCollector) Collect(ch chan<- prometheus.Metric) {
+	pc.collectSystem(ch)
+	pc.collectContainers(ch)
+	pc.collectDisks(ch)
+	pc.collectParity(ch)
+	pc.collectUPS(ch)
+}
+
+// snapshot fetches a collector snapshot and reports whether it was available
+func (pc *PrometheusCollector) snapshot(ch chan<- prometheus.Metric, name string) interface{} {
+	data, ok := pc.source.GetMetric(name)
+	if !ok || data == nil {
+		ch <- prometheus.MustNewConstMetric(pc.metricPresent, prometheus.GaugeValue, 0, name)
+		return nil
+	}
+	ch <- prometheus.MustNewConstMetric(pc.metricPresent, prometheus.GaugeValue, 1, name)
+	return data
+}
+
+func (pc *PrometheusCollector) collectSystem(ch chan<- prometheus.Metric) {
+	system, ok := pc.snapshot(ch, "system.cpu").(*collectors.SystemMetrics)
+	if !ok {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(pc.cpuPercent, prometheus.GaugeValue, system.CPUPercent)
+	ch <- prometheus.MustNewConstMetric(pc.load1, prometheus.GaugeValue, system.Load1m)
+	ch <- prometheus.MustNewConstMetric(pc.memoryUsed, prometheus.GaugeValue, float64(system.MemoryUsed))
+	ch <- prometheus.MustNewConstMetric(pc.memoryTotal, prometheus.GaugeValue, float64(system.MemoryTotal))
+	ch <- prometheus.MustNewConstMetric(pc.networkRx, prometheus.CounterValue, float64(system.NetworkRx))
+	ch <- prometheus.MustNewConstMetric(pc.networkTx, prometheus.CounterValue, float64(system.NetworkTx))
+}
+
+func (pc *PrometheusCollector) collectContainers(ch chan<- prometheus.Metric) {
+	containers, ok := pc.snapshot(ch, "containers.stats").(*collectors.ContainerMetrics)
+	if !ok {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(pc.containers, prometheus.GaugeValue, float64(containers.Summary.Running), "running")
+	ch <- prometheus.MustNewConstMetric(pc.containers, prometheus.GaugeValue, float64(containers.Summary.Stopped), "stopped")
+
+	for _, container := range containers.Containers {
+		running := 0.0
+		if container.State == "running" {
+			running = 1
+		}
+		ch <- prometheus.MustNewConstMetric(pc.containerUp, prometheus.GaugeValue, running, container.Name)
+		ch <- prometheus.MustNewConstMetric(pc.containerCPU, prometheus.GaugeValue, container.CPUPercent, container.Name)
+		ch <- prometheus.MustNewConstMetric(pc.containerMem, prometheus.GaugeValue, float64(container.MemoryUsage), container.Name)
+		ch <- prometheus.MustNewConstMetric(pc.containerLim, prometheus.GaugeValue, float64(container.MemoryLimit), container.Name)
+		ch <- prometheus.MustNewConstMetric(pc.containerRx, prometheus.CounterValue, float64(container.NetworkRx), container.Name)
+		ch <- prometheus.MustNewConstMetric(pc.containerTx, prometheus.CounterValue, float64(container.NetworkTx), container.Name)
+	}
+}
+
+func (pc *PrometheusCollector) collectDisks(ch chan<- prometheus.Metric) {
+	disks, ok := pc.snapshot(ch, "storage.disks").([]storage.DiskState)
+	if !ok {
+		return
+	}
+
+	for _, disk := range disks {
+		labels := []string{disk.Name, disk.Role}
+
+		spunDown := 0.0
+		if disk.SpunDown {
+			spunDown = 1
+		}
+		ch <- prometheus.MustNewConstMetric(pc.diskSpunDown, prometheus.GaugeValue, spunDown, labels...)
+
+		if disk.Temperature > 0 {
+			ch <- prometheus.MustNewConstMetric(pc.diskTemp, prometheus.GaugeValue, float64(disk.Temperature), labels...)
+		}
+		ch <- prometheus.MustNewConstMetric(pc.diskSize, prometheus.GaugeValue, float64(disk.Size), labels...)
+		if disk.FsSize > 0 {
+			ch <- prometheus.MustNewConstMetric(pc.diskFsSize, prometheus.GaugeValue, float64(disk.FsSize), labels...)
+			ch <- prometheus.MustNewConstMetric(pc.diskFsUsed, prometheus.GaugeValue, float64(disk.FsUsed), labels...)
+		}
+		ch <- prometheus.MustNewConstMetric(pc.diskErrors, prometheus.CounterValue, float64(disk.Errors), labels...)
+	}
+}
+
+func (pc *PrometheusCollector) collectParity(ch chan<- prometheus.Metric) {
+	parity, ok := pc.snapshot(ch, "array.parity").(*storage.ParityCheckStatus)
+	if !ok {
+		return
+	}
+
+	active := 0.0
+	if parity.Active {
+		active = 1
+	}
+	ch <- prometheus.MustNewConstMetric(pc.parityActive, prometheus.GaugeValue, active)
+	ch <- prometheus.MustNewConstMetric(pc.parityRatio, prometheus.GaugeValue, parity.Progress/100)
+	ch <- prometheus.MustNewConstMetric(pc.parityErrors, prometheus.GaugeValue, float64(parity.Errors))
+}
+
+func (pc *PrometheusCollector) collectUPS(ch chan<- prometheus.Metric) {
+	samples, ok := pc.snapshot(ch, "ups.status").([]dto.Sample)
+	if !ok {
+		return
+	}
+
+	for _, sample := range samples {
+		switch sample.Key {
+		case "UPS STATUS":
+			online := 0.0
+			if sample.Condition == ups.Green {
+				online = 1
+			}
+			ch <- prometheus.MustNewConstMetric(pc.upsOnline, prometheus.GaugeValue, online)
+		case "UPS LOAD":
+			if value, err := strconv.ParseFloat(sample.Value, 64); err == nil {
+				ch <- prometheus.MustNewConstMetric(pc.upsLoad, prometheus.GaugeValue, value)
+			}
+		case "UPS CHARGE":
+			if value, err := strconv.ParseFloat(sample.Value, 64); err == nil {
+				ch <- prometheus.MustNewConstMetric(pc.upsCharge, prometheus.GaugeValue, value)
+			}
+		case "UPS LEFT":
+			if value, err := strconv.ParseFloat(sample.Value, 64); err == nil {
+				ch <- prometheus.MustNewConstMetric(pc.upsRuntime, prometheus.GaugeValue, value*runtimeUnitSeconds(sample.Unit))
+			}
+		case "UPS POWER":
+			if value, err := strconv.ParseFloat(sample.Value, 64); err == nil {
+				ch <- prometheus.MustNewConstMetric(pc.upsPower, prometheus.GaugeValue, value)
+			}
+		}
+	}
+}
+
+// runtimeUnitSeconds converts a UPS runtime unit to seconds, assuming minutes when unknown
+func runtimeUnitSeconds(unit string) float64 {
+	switch strings.ToLower(unit) {
+	case "s", "sec", "seconds":
+		return 1
+	case "h", "hours":
+		return 3600
+	default:
+		return 60
+	}
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/domalab/uma/daemon/dto"
+	"github.com/domalab/uma/daemon/plugins/storage"
+	"github.com/domalab/uma/daemon/services/collectors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeSource serves fixed collector snapshots
+type fakeSource struct {
+	data map[string]interface{}
+}
+
+func (f *fakeSource) GetMetric(name string) (interface{}, bool) {
+	data, ok := f.data[name]
+	return data, ok
+}
+
+func TestPrometheusCollector(t *testing.T) {
+	source := &fakeSource{data: map[string]interface{}{
+		"containers.stats": &collectors.ContainerMetrics{
+			Containers: []collectors.ContainerStats{{Name: "plex", State: "running", CPUPercent: 12.5}},
+			Summary:    collectors.ContainerSummary{Total: 1, Running: 1},
+		},
+		"storage.disks": []storage.DiskState{
+			{Name: "disk1", Role: "data", Temperature: 36, Size: 1024},
+			{Name: "parity", Role: "parity", SpunDown: true, Size: 1024},
+		},
+		"array.parity": &storage.ParityCheckStatus{Active: true, Progress: 42.5},
+		"ups.status": []dto.Sample{
+			{Key: "UPS STATUS", Value: "Online", Condition: "green"},
+			{Key: "UPS LOAD", Value: "23.0", Unit: "%"},
+			{Key: "UPS LEFT", Value: "30", Unit: "m"},
+		},
+	}}
+
+	expected := `
+# HELP uma_container_cpu_percent Container CPU usage in percent
+# TYPE uma_container_cpu_percent gauge
+uma_container_cpu_percent{name="plex"} 12.5
+# HELP uma_disk_temperature_celsius Disk temperature; absent while the disk is spun down
+# TYPE uma_disk_temperature_celsius gauge
+uma_disk_temperature_celsius{disk="disk1",role="data"} 36
+# HELP uma_parity_check_progress_ratio Progress of the running parity check from 0 to 1
+# TYPE uma_parity_check_progress_ratio gauge
+uma_parity_check_progress_ratio 0.425
+# HELP uma_ups_load_percent UPS load in percent
+# TYPE uma_ups_load_percent gauge
+uma_ups_load_percent 23
+# HELP uma_ups_runtime_seconds Estimated UPS runtime on battery
+# TYPE uma_ups_runtime_seconds gauge
+uma_ups_runtime_seconds 1800
+# HELP uma_collector_up Whether a fresh snapshot is available from the collector
+# TYPE uma_collector_up gauge
+uma_collector_up{collector="array.parity"} 1
+uma_collector_up{collector="containers.stats"} 1
+uma_collector_up{collector="storage.disks"} 1
+uma_collector_up{collector="system.cpu"} 0
+uma_collector_up{collector="ups.status"} 1
+`
+
+	collector := NewPrometheusCollector(source)
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"uma_container_cpu_percent",
+		"uma_disk_temperature_celsius",
+		"uma_parity_check_progress_ratio",
+		"uma_ups_load_percent",
+		"uma_ups_runtime_seconds",
+		"uma_collector_up",
+	)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPrometheusCollectorIgnoresEmptySnapshots(t *testing.T) {
+	// Placeholder collectors cache nil, which must not produce series or panic
+	source := &fakeSource{data: map[string]interface{}{"storage.disks": nil}}
+
+	count := testutil.CollectAndCount(NewPrometheusCollector(source))
+	if count != 5 {
+		t.Errorf("Expected only the 5 collector_up series, got %d", count)
+	}
+}
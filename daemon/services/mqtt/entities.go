@@ -0,0 +1,373 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/domalab/uma/daemon/dto"
+	"github.com/domalab/uma/daemon/logger"
+	"github.com/domalab/uma/daemon/plugins/docker"
+	"github.com/domalab/uma/daemon/plugins/storage"
+	"github.com/domalab/uma/daemon/plugins/ups"
+	"github.com/domalab/uma/daemon/plugins/vm"
+	"github.com/domalab/uma/daemon/services/changes"
+	"github.com/domalab/uma/daemon/services/collectors"
+)
+
+// Home Assistant components entities are announced as
+const (
+	componentSensor       = "sensor"
+	componentBinarySensor = "binary_sensor"
+	componentSwitch       = "switch"
+	componentButton       = "button"
+)
+
+const (
+	payloadOn    = "ON"
+	payloadOff   = "OFF"
+	payloadPress = "PRESS"
+	payloadNone  = "None" // Home Assistant shows sensors with this state as unknown
+
+	// stopTimeout is how long containers get to stop before they are killed
+	stopTimeout = 10
+)
+
+// entitySources are the collectors entities are derived from
+var entitySources = map[string]bool{
+	"system.cpu":                true,
+	changes.CollectorArray:      true,
+	changes.CollectorParity:     true,
+	changes.CollectorDisks:      true,
+	changes.CollectorUPS:        true,
+	changes.CollectorContainers: true,
+	changes.CollectorVMs:        true,
+}
+
+// entity is a Home Assistant entity of the node's device
+type entity struct {
+	component   string
+	objectID    string
+	name        string
+	unit        string
+	deviceClass string
+	stateClass  string
+	icon        string
+
+	// command carries out a switch or button payload, returning the state
+	// the entity is now in; nil for read-only entities
+	command func(payload string) (string, error)
+}
+
+// key identifies the entity and is its topic below the node
+func (e *entity) key() string {
+	return e.component + "/" + e.objectID
+}
+
+// entityState is an entity and its current state; buttons have none
+type entityState struct {
+	entity *entity
+	value  string
+}
+
+// entityStates derives entities and their states from a collector snapshot
+func (p *Publisher) entityStates(name string, data interface{}) []entityState {
+	var states []entityState
+	switch name {
+	case "system.cpu":
+		metrics, ok := data.(*collectors.SystemMetrics)
+		if !ok || metrics == nil {
+			return nil
+		}
+		states = append(states,
+			percentSensor("cpu_usage", "CPU usage", "mdi:cpu-64-bit", metrics.CPUPercent),
+			percentSensor("memory_usage", "Memory usage", "mdi:memory", metrics.MemoryPercent),
+			entityState{
+				entity: &entity{component: componentSensor, objectID: "load_1m", name: "Load (1m)", stateClass: "measurement", icon: "mdi:gauge"},
+				value:  formatFloat(metrics.Load1m),
+			})
+
+	case changes.CollectorArray:
+		info, ok := data.(*storage.ArrayInfo)
+		if !ok || info == nil {
+			return nil
+		}
+		states = append(states,
+			binaryState(&entity{component: componentBinarySensor, objectID: "array_started", name: "Array started", deviceClass: "running"}, info.State == "started"),
+			percentSensor("array_used", "Array used", "mdi:harddisk", info.UsedPercent))
+
+	case changes.CollectorParity:
+		status, ok := data.(*storage.ParityCheckStatus)
+		if !ok || status == nil {
+			return nil
+		}
+		states = append(states,
+			binaryState(&entity{component: componentBinarySensor, objectID: "parity_check", name: "Parity check", deviceClass: "running"}, status.Active),
+			percentSensor("parity_check_progress", "Parity check progress", "mdi:progress-check", status.Progress))
+		if p.controllers.Parity != nil {
+			states = append(states, entityState{entity: &entity{
+				component: componentButton,
+				objectID:  "parity_check_start",
+				name:      "Start parity check",
+				icon:      "mdi:shield-check",
+				command: pressCommand(func() error {
+					return p.controllers.Parity.StartParityCheck("check", "normal")
+				}),
+			}})
+		}
+
+	case changes.CollectorDisks:
+		disks, ok := data.([]storage.DiskState)
+		if !ok {
+			return nil
+		}
+		for _, disk := range disks {
+			// Spun-down disks report no temperature
+			value := payloadNone
+			if !disk.SpunDown && disk.Temperature > 0 {
+				value = strconv.Itoa(disk.Temperature)
+			}
+			states = append(states, entityState{
+				entity: &entity{
+					component:   componentSensor,
+					objectID:    "disk_" + slug(disk.Name) + "_temperature",
+					name:        "Disk " + disk.Name + " temperature",
+					unit:        "°C",
+					deviceClass: "temperature",
+					stateClass:  "measurement",
+				},
+				value: value,
+			})
+		}
+
+	case changes.CollectorUPS:
+		samples, ok := data.([]dto.Sample)
+		if !ok {
+			return nil
+		}
+		states = append(states, p.upsStates(samples)...)
+
+	case changes.CollectorContainers:
+		containers, ok := data.([]docker.ContainerInfo)
+		if !ok {
+			return nil
+		}
+		for _, container := range containers {
+			states = append(states, p.containerStates(container)...)
+		}
+
+	case changes.CollectorVMs:
+		vms, ok := data.([]vm.VMInfo)
+		if !ok {
+			return nil
+		}
+		for _, machine := range vms {
+			states = append(states, p.vmState(machine))
+		}
+	}
+	return states
+}
+
+// upsStates turns UPS samples into sensors, and the status into an on
+// battery sensor once the UPS daemon reports it
+func (p *Publisher) upsStates(samples []dto.Sample) []entityState {
+	var states []entityState
+	for _, sample := range samples {
+		e := &entity{component: componentSensor, stateClass: "measurement"}
+		switch sample.Key {
+		case "UPS STATUS":
+			if sample.Condition == ups.Green || sample.Condition == ups.Red {
+				states = append(states, binaryState(&entity{component: componentBinarySensor, objectID: "ups_on_battery", name: "UPS on battery", deviceClass: "battery"}, sample.Condition == ups.Red))
+			}
+			e.objectID, e.name, e.stateClass, e.icon = "ups_status", "UPS status", "", "mdi:power-plug"
+		case "UPS CHARGE":
+			e.objectID, e.name, e.unit, e.deviceClass = "ups_charge", "UPS battery charge", "%", "battery"
+		case "UPS LEFT":
+			e.objectID, e.name, e.unit, e.deviceClass = "ups_runtime", "UPS runtime", "min", "duration"
+			if sample.Unit == "h" || sample.Unit == "s" {
+				e.unit = sample.Unit
+			}
+		case "UPS LOAD":
+			e.objectID, e.name, e.unit, e.icon = "ups_load", "UPS load", "%", "mdi:gauge"
+		case "UPS POWER":
+			e.objectID, e.name, e.unit, e.deviceClass = "ups_power", "UPS power", "W", "power"
+		default:
+			continue
+		}
+		states = append(states, entityState{entity: e, value: sample.Value})
+	}
+	return states
+}
+
+// containerStates exposes a container as a power switch and a restart
+// button, or a sensor when commands are off
+func (p *Publisher) containerStates(container docker.ContainerInfo) []entityState {
+	id := "container_" + slug(container.Name)
+	running := container.State == "running"
+	manager := p.controllers.Containers
+	if manager == nil {
+		return []entityState{binaryState(&entity{component: componentBinarySensor, objectID: id, name: container.Name, deviceClass: "running", icon: "mdi:docker"}, running)}
+	}
+
+	name := container.Name
+	power := &entity{
+		component: componentSwitch,
+		objectID:  id,
+		name:      name,
+		icon:      "mdi:docker",
+		command: switchCommand(func() error {
+			return manager.StartContainer(name)
+		}, func() error {
+			return manager.StopContainer(name, stopTimeout)
+		}),
+	}
+	restart := &entity{
+		component: componentButton,
+		objectID:  id + "_restart",
+		name:      "Restart " + name,
+		icon:      "mdi:restart",
+		command: pressCommand(func() error {
+			return manager.RestartContainer(name, stopTimeout)
+		}),
+	}
+	return []entityState{binaryState(power, running), {entity: restart}}
+}
+
+// vmState exposes a VM as a power switch, or a sensor when commands are off
+func (p *Publisher) vmState(machine vm.VMInfo) entityState {
+	id := "vm_" + slug(machine.Name)
+	running := machine.State == "running"
+	manager := p.controllers.VMs
+	if manager == nil {
+		return binaryState(&entity{component: componentBinarySensor, objectID: id, name: machine.Name, deviceClass: "running", icon: "mdi:monitor"}, running)
+	}
+
+	name := machine.Name
+	return binaryState(&entity{
+		component: componentSwitch,
+		objectID:  id,
+		name:      name,
+		icon:      "mdi:monitor",
+		command: switchCommand(func() error {
+			return manager.StartVM(name)
+		}, func() error {
+			return manager.StopVM(name, false)
+		}),
+	}, running)
+}
+
+func percentSensor(objectID, name, icon string, value float64) entityState {
+	return entityState{
+		entity: &entity{component: componentSensor, objectID: objectID, name: name, unit: "%", stateClass: "measurement", icon: icon},
+		value:  formatFloat(value),
+	}
+}
+
+func binaryState(e *entity, on bool) entityState {
+	if on {
+		return entityState{entity: e, value: payloadOn}
+	}
+	return entityState{entity: e, value: payloadOff}
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', 1, 64)
+}
+
+// switchCommand handles ON and OFF payloads
+func switchCommand(on, off func() error) func(string) (string, error) {
+	return func(payload string) (string, error) {
+		switch payload {
+		case payloadOn:
+			return payloadOn, on()
+		case payloadOff:
+			return payloadOff, off()
+		default:
+			return "", fmt.Errorf("unknown payload %q", payload)
+		}
+	}
+}
+
+// pressCommand handles button presses
+func pressCommand(press func() error) func(string) (string, error) {
+	return func(payload string) (string, error) {
+		if payload != payloadPress {
+			return "", fmt.Errorf("unknown payload %q", payload)
+		}
+		return "", press()
+	}
+}
+
+// device groups the node's entities in Home Assistant
+type device struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+	SWVersion    string   `json:"sw_version,omitempty"`
+}
+
+// discoveryConfig is the Home Assistant MQTT discovery payload of an entity
+type discoveryConfig struct {
+	Name                string `json:"name"`
+	UniqueID            string `json:"unique_id"`
+	StateTopic          string `json:"state_topic,omitempty"`
+	CommandTopic        string `json:"command_topic,omitempty"`
+	AvailabilityTopic   string `json:"availability_topic"`
+	PayloadAvailable    string `json:"payload_available"`
+	PayloadNotAvailable string `json:"payload_not_available"`
+	PayloadOn           string `json:"payload_on,omitempty"`
+	PayloadOff          string `json:"payload_off,omitempty"`
+	PayloadPress        string `json:"payload_press,omitempty"`
+	UnitOfMeasurement   string `json:"unit_of_measurement,omitempty"`
+	DeviceClass         string `json:"device_class,omitempty"`
+	StateClass          string `json:"state_class,omitempty"`
+	Icon                string `json:"icon,omitempty"`
+	Device              device `json:"device"`
+}
+
+// discoveryTopic is where Home Assistant looks for the entity's config
+func (p *Publisher) discoveryTopic(e *entity) string {
+	return p.config.DiscoveryPrefix + "/" + e.component + "/" + p.node + "/" + e.objectID + "/config"
+}
+
+func (p *Publisher) discoveryMessage(e *entity) message {
+	config := discoveryConfig{
+		Name:                e.name,
+		UniqueID:            "uma_" + p.node + "_" + e.objectID,
+		AvailabilityTopic:   p.availabilityTopic(),
+		PayloadAvailable:    payloadOnline,
+		PayloadNotAvailable: payloadOffline,
+		UnitOfMeasurement:   e.unit,
+		DeviceClass:         e.deviceClass,
+		StateClass:          e.stateClass,
+		Icon:                e.icon,
+		Device: device{
+			Identifiers:  []string{"uma_" + p.node},
+			Name:         p.node,
+			Manufacturer: "Lime Technology",
+			Model:        "Unraid",
+			SWVersion:    p.version,
+		},
+	}
+	switch e.component {
+	case componentButton:
+		config.CommandTopic = p.commandTopic(e)
+		config.PayloadPress = payloadPress
+	case componentSwitch:
+		config.StateTopic = p.stateTopic(e)
+		config.CommandTopic = p.commandTopic(e)
+		config.PayloadOn, config.PayloadOff = payloadOn, payloadOff
+	case componentBinarySensor:
+		config.StateTopic = p.stateTopic(e)
+		config.PayloadOn, config.PayloadOff = payloadOn, payloadOff
+	default:
+		config.StateTopic = p.stateTopic(e)
+	}
+
+	payload, err := json.Marshal(config)
+	if err != nil {
+		logger.Yellow("Failed to encode MQTT discovery for %s: %v", e.key(), err)
+	}
+	return message{topic: p.discoveryTopic(e), payload: payload, qos: 1, retained: true}
+}
@@ -0,0 +1,374 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/domalab/uma/daemon/domain"
+	"github.com/domalab/uma/daemon/logger"
+)
+
+const (
+	payloadOnline  = "online"
+	payloadOffline = "offline"
+
+	writeTimeout = 10 * time.Second
+	queueSize    = 64
+)
+
+// ContainerController starts, stops and restarts containers; DockerManager implements it
+type ContainerController interface {
+	StartContainer(nameOrID string) error
+	StopContainer(nameOrID string, timeout int) error
+	RestartContainer(nameOrID string, timeout int) error
+}
+
+// VMController starts and stops VMs; VMManager implements it
+type VMController interface {
+	StartVM(name string) error
+	StopVM(name string, force bool) error
+}
+
+// ParityController starts parity checks; StorageMonitor implements it
+type ParityController interface {
+	StartParityCheck(checkType string, priority string) error
+}
+
+// Controllers carry out switch and button commands. A nil controller
+// exposes no commands for its entities.
+type Controllers struct {
+	Containers ContainerController
+	VMs        VMController
+	Parity     ParityController
+}
+
+// snapshot is a collector result waiting to be published
+type snapshot struct {
+	name string
+	data interface{}
+}
+
+// message is a pending MQTT publish
+type message struct {
+	topic    string
+	payload  []byte
+	qos      byte
+	retained bool
+}
+
+// Publisher publishes collector snapshots and entity states to an MQTT
+// broker, announces the entities for Home Assistant discovery and carries
+// out the switch and button commands it receives.
+type Publisher struct {
+	config      domain.MQTTConfig
+	node        string
+	base        string // <topic_prefix>/<node>
+	version     string
+	controllers Controllers
+	metrics     map[string]bool
+	interval    time.Duration
+
+	client paho.Client
+	queue  chan snapshot
+	stopCh chan struct{}
+
+	entities map[string]*entity         // Discovered entities by key
+	states   map[string]string          // Last state published by entity key
+	owned    map[string]map[string]bool // Entity keys by the collector they come from
+	handled  map[string]time.Time       // When each collector's snapshot was last published
+	mutex    sync.Mutex
+}
+
+// NewPublisher creates a publisher for config. version is reported as the
+// device's software version in discovery.
+func NewPublisher(config domain.MQTTConfig, version string, controllers Controllers) (*Publisher, error) {
+	if config.Broker == "" {
+		return nil, fmt.Errorf("a broker is required")
+	}
+	if !config.Commands {
+		controllers = Controllers{}
+	}
+
+	node := config.NodeID
+	if node == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get hostname: %v", err)
+		}
+		node = hostname
+	}
+	node = slug(node)
+	if node == "" {
+		return nil, fmt.Errorf("invalid node ID %q", config.NodeID)
+	}
+
+	p := &Publisher{
+		config:      config,
+		node:        node,
+		base:        strings.TrimSuffix(config.TopicPrefix, "/") + "/" + node,
+		version:     version,
+		controllers: controllers,
+		metrics:     make(map[string]bool),
+		interval:    time.Duration(config.IntervalSeconds) * time.Second,
+		queue:       make(chan snapshot, queueSize),
+		stopCh:      make(chan struct{}),
+		entities:    make(map[string]*entity),
+		states:      make(map[string]string),
+		owned:       make(map[string]map[string]bool),
+		handled:     make(map[string]time.Time),
+	}
+	for _, name := range config.Metrics {
+		p.metrics[name] = true
+	}
+
+	clientID := config.ClientID
+	if clientID == "" {
+		clientID = "uma-" + node
+	}
+	options := paho.NewClientOptions().
+		AddBroker(config.Broker).
+		SetClientID(clientID).
+		SetUsername(config.Username).
+		SetPassword(config.Password).
+		SetWill(p.availabilityTopic(), payloadOffline, 1, true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetWriteTimeout(writeTimeout).
+		SetOrderMatters(false). // Commands may take a while; don't hold up other messages
+		SetOnConnectHandler(p.onConnect).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			logger.Yellow("MQTT connection lost: %v", err)
+		})
+	p.client = paho.NewClient(options)
+	return p, nil
+}
+
+// Start connects to the broker, retrying in the background until it is
+// reachable, and starts publishing observed snapshots
+func (p *Publisher) Start() {
+	logger.Blue("Publishing to MQTT broker %s under %s", p.config.Broker, p.base)
+	p.client.Connect()
+	go p.run()
+}
+
+// Stop marks the node offline and disconnects
+func (p *Publisher) Stop() {
+	close(p.stopCh)
+	if p.client.IsConnected() {
+		p.client.Publish(p.availabilityTopic(), 1, true, payloadOffline).WaitTimeout(writeTimeout)
+	}
+	p.client.Disconnect(250)
+}
+
+// Observe queues a collector snapshot for publishing. It is registered as a
+// SystemCollector listener and drops snapshots while the queue is full.
+func (p *Publisher) Observe(name string, data interface{}) {
+	if !p.metrics[name] && !entitySources[name] {
+		return
+	}
+	select {
+	case p.queue <- snapshot{name: name, data: data}:
+	default:
+	}
+}
+
+func (p *Publisher) run() {
+	for {
+		select {
+		case <-p.stopCh:
+			return
+		case s := <-p.queue:
+			p.publish(p.handle(s.name, s.data, time.Now())...)
+		}
+	}
+}
+
+// handle records a snapshot and returns the messages it causes: the
+// snapshot itself, discovery for new and removed entities and changed states
+func (p *Publisher) handle(name string, data interface{}, now time.Time) []message {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if now.Sub(p.handled[name]) < p.interval {
+		return nil
+	}
+	p.handled[name] = now
+
+	var messages []message
+	if p.metrics[name] {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			logger.Yellow("Failed to encode %s for MQTT: %v", name, err)
+		} else {
+			messages = append(messages, message{topic: p.metricTopic(name), payload: payload, retained: true})
+		}
+	}
+	if !entitySources[name] {
+		return messages
+	}
+
+	current := make(map[string]bool)
+	for _, state := range p.entityStates(name, data) {
+		key := state.entity.key()
+		current[key] = true
+		if _, known := p.entities[key]; !known {
+			p.entities[key] = state.entity
+			if p.config.Discovery {
+				messages = append(messages, p.discoveryMessage(state.entity))
+			}
+		}
+		if state.entity.component != componentButton && p.states[key] != state.value {
+			p.states[key] = state.value
+			messages = append(messages, p.stateMessage(state.entity, state.value))
+		}
+	}
+
+	// Entities that disappeared, such as removed containers, are withdrawn
+	for key := range p.owned[name] {
+		if current[key] {
+			continue
+		}
+		if p.config.Discovery {
+			messages = append(messages, message{topic: p.discoveryTopic(p.entities[key]), qos: 1, retained: true})
+		}
+		delete(p.entities, key)
+		delete(p.states, key)
+	}
+	p.owned[name] = current
+	return messages
+}
+
+// onConnect announces the node and every known entity, which also covers
+// reconnecting to a broker that lost its retained messages
+func (p *Publisher) onConnect(client paho.Client) {
+	logger.Green("Connected to MQTT broker %s", p.config.Broker)
+
+	filters := map[string]byte{
+		p.base + "/" + componentSwitch + "/+/set":   1,
+		p.base + "/" + componentButton + "/+/press": 1,
+	}
+	if p.config.Discovery {
+		filters[p.config.DiscoveryPrefix+"/status"] = 1
+	}
+	if token := client.SubscribeMultiple(filters, p.onMessage); token.WaitTimeout(writeTimeout) && token.Error() != nil {
+		logger.Yellow("Failed to subscribe to MQTT commands: %v", token.Error())
+	}
+
+	p.publish(message{topic: p.availabilityTopic(), payload: []byte(payloadOnline), qos: 1, retained: true})
+	p.publish(p.announce()...)
+}
+
+// announce returns the discovery and state messages of every known entity
+func (p *Publisher) announce() []message {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var messages []message
+	for key, e := range p.entities {
+		if p.config.Discovery {
+			messages = append(messages, p.discoveryMessage(e))
+		}
+		if state, ok := p.states[key]; ok {
+			messages = append(messages, p.stateMessage(e, state))
+		}
+	}
+	return messages
+}
+
+// onMessage handles switch and button commands, and Home Assistant coming
+// online, which needs discovery again
+func (p *Publisher) onMessage(_ paho.Client, msg paho.Message) {
+	payload := string(msg.Payload())
+	if msg.Topic() == p.config.DiscoveryPrefix+"/status" {
+		if payload == payloadOnline {
+			p.publish(p.announce()...)
+		}
+		return
+	}
+
+	rest := strings.TrimPrefix(msg.Topic(), p.base+"/")
+	parts := strings.Split(rest, "/")
+	if len(parts) != 3 {
+		return
+	}
+	key := parts[0] + "/" + parts[1]
+
+	p.mutex.Lock()
+	e := p.entities[key]
+	p.mutex.Unlock()
+	if e == nil || e.command == nil {
+		logger.Yellow("Ignoring MQTT command for unknown entity %s", key)
+		return
+	}
+
+	logger.Blue("MQTT command %s for %s", payload, e.name)
+	state, err := e.command(payload)
+	if err != nil {
+		logger.Yellow("MQTT command %s for %s failed: %v", payload, e.name, err)
+		return
+	}
+
+	// Report the new state now rather than on the next collection
+	if state != "" {
+		p.mutex.Lock()
+		if _, known := p.entities[key]; known {
+			p.states[key] = state
+		}
+		p.mutex.Unlock()
+		p.publish(p.stateMessage(e, state))
+	}
+}
+
+func (p *Publisher) publish(messages ...message) {
+	for _, m := range messages {
+		p.client.Publish(m.topic, m.qos, m.retained, m.payload)
+	}
+}
+
+func (p *Publisher) availabilityTopic() string {
+	return p.base + "/status"
+}
+
+// metricTopic is where a collector's snapshots are published, such as
+// uma/tower/system/cpu for system.cpu
+func (p *Publisher) metricTopic(name string) string {
+	return p.base + "/" + strings.ReplaceAll(name, ".", "/")
+}
+
+func (p *Publisher) stateTopic(e *entity) string {
+	return p.base + "/" + e.key() + "/state"
+}
+
+func (p *Publisher) commandTopic(e *entity) string {
+	if e.component == componentButton {
+		return p.base + "/" + e.key() + "/press"
+	}
+	return p.base + "/" + e.key() + "/set"
+}
+
+func (p *Publisher) stateMessage(e *entity, state string) message {
+	return message{topic: p.stateTopic(e), payload: []byte(state), retained: true}
+}
+
+// slug lowercases name and replaces anything but letters and digits with
+// underscores, as topics and entity IDs need
+func slug(name string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			underscore = false
+		} else if !underscore && b.Len() > 0 {
+			b.WriteByte('_')
+			underscore = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
@@ -0,0 +1,271 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	server "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github.com/domalab/uma/daemon/domain"
+	"github.com/domalab/uma/daemon/dto"
+	"github.com/domalab/uma/daemon/plugins/docker"
+	"github.com/domalab/uma/daemon/plugins/storage"
+	"github.com/domalab/uma/daemon/plugins/ups"
+	"github.com/domalab/uma/daemon/plugins/vm"
+	"github.com/domalab/uma/daemon/services/changes"
+	"github.com/domalab/uma/daemon/services/collectors"
+)
+
+// fakeControllers records the commands the publisher carries out
+type fakeControllers struct {
+	calls chan string
+}
+
+func (f *fakeControllers) StartContainer(nameOrID string) error {
+	f.calls <- "start container " + nameOrID
+	return nil
+}
+
+func (f *fakeControllers) StopContainer(nameOrID string, timeout int) error {
+	f.calls <- "stop container " + nameOrID
+	return nil
+}
+
+func (f *fakeControllers) RestartContainer(nameOrID string, timeout int) error {
+	f.calls <- "restart container " + nameOrID
+	return nil
+}
+
+func (f *fakeControllers) StartVM(name string) error {
+	f.calls <- "start vm " + name
+	return nil
+}
+
+func (f *fakeControllers) StopVM(name string, force bool) error {
+	f.calls <- "stop vm " + name
+	return nil
+}
+
+func (f *fakeControllers) StartParityCheck(checkType string, priority string) error {
+	f.calls <- "parity " + checkType
+	return nil
+}
+
+// testBroker is an embedded broker recording the latest payload of every topic
+type testBroker struct {
+	*server.Server
+	address string
+
+	messages map[string]string
+	mutex    sync.Mutex
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	t.Helper()
+
+	b := &testBroker{Server: server.New(&server.Options{InlineClient: true}), messages: make(map[string]string)}
+	if err := b.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	tcp := listeners.NewTCP(listeners.Config{ID: "test", Address: "127.0.0.1:0"})
+	if err := b.AddListener(tcp); err != nil {
+		t.Fatal(err)
+	}
+	b.address = tcp.Address()
+
+	err := b.Subscribe("#", 1, func(_ *server.Client, _ packets.Subscription, pk packets.Packet) {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		b.messages[pk.TopicName] = string(pk.Payload)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go b.Serve()
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+// waitFor waits until topic was last published with payload
+func (b *testBroker) waitFor(t *testing.T, topic, payload string) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		b.mutex.Lock()
+		got, ok := b.messages[topic]
+		b.mutex.Unlock()
+		if ok && got == payload {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	t.Fatalf("%s = %q, want %q", topic, b.messages[topic], payload)
+}
+
+func (b *testBroker) discovery(t *testing.T, topic string) discoveryConfig {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		b.mutex.Lock()
+		got := b.messages[topic]
+		b.mutex.Unlock()
+		if got != "" {
+			var config discoveryConfig
+			if err := json.Unmarshal([]byte(got), &config); err != nil {
+				t.Fatalf("%s: %v", topic, err)
+			}
+			return config
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no discovery config at %s", topic)
+	return discoveryConfig{}
+}
+
+func expectCall(t *testing.T, calls chan string, want string) {
+	t.Helper()
+
+	select {
+	case got := <-calls:
+		if got != want {
+			t.Fatalf("got command %q, want %q", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("command %q not carried out", want)
+	}
+}
+
+func newTestPublisher(t *testing.T, broker string, controllers Controllers) *Publisher {
+	t.Helper()
+
+	config := domain.DefaultConfig().MQTT
+	config.Broker = broker
+	config.NodeID = "Tower"
+	config.IntervalSeconds = 0
+	config.Commands = true
+	p, err := NewPublisher(config, "1.2.3", controllers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPublisher(t *testing.T) {
+	broker := newTestBroker(t)
+	fake := &fakeControllers{calls: make(chan string, 8)}
+	p := newTestPublisher(t, "tcp://"+broker.address, Controllers{Containers: fake, VMs: fake, Parity: fake})
+	p.Start()
+
+	broker.waitFor(t, "uma/tower/status", "online")
+
+	p.Observe("system.cpu", &collectors.SystemMetrics{CPUPercent: 12.34, MemoryPercent: 50, Load1m: 1.5})
+	p.Observe(changes.CollectorArray, &storage.ArrayInfo{State: "started", UsedPercent: 40})
+	p.Observe(changes.CollectorParity, &storage.ParityCheckStatus{})
+	p.Observe(changes.CollectorUPS, []dto.Sample{
+		{Key: "UPS STATUS", Value: "Online", Condition: ups.Green},
+		{Key: "UPS CHARGE", Value: "100", Unit: "%"},
+	})
+	p.Observe(changes.CollectorContainers, []docker.ContainerInfo{{Name: "Plex", State: "running"}})
+	p.Observe(changes.CollectorVMs, []vm.VMInfo{{Name: "Windows 11", State: "shut off"}})
+
+	// Snapshots of the configured metrics are published whole
+	broker.waitFor(t, "uma/tower/system/cpu", `{"timestamp":0,"cpu_percent":12.34,"load_1m":1.5,"memory_percent":50,"memory_used":0,"memory_total":0,"network_rx":0,"network_tx":0}`)
+
+	for topic, want := range map[string]string{
+		"uma/tower/sensor/cpu_usage/state":             "12.3",
+		"uma/tower/binary_sensor/array_started/state":  "ON",
+		"uma/tower/binary_sensor/parity_check/state":   "OFF",
+		"uma/tower/sensor/ups_charge/state":            "100",
+		"uma/tower/binary_sensor/ups_on_battery/state": "OFF",
+		"uma/tower/switch/container_plex/state":        "ON",
+		"uma/tower/switch/vm_windows_11/state":         "OFF",
+	} {
+		broker.waitFor(t, topic, want)
+	}
+
+	config := broker.discovery(t, "homeassistant/switch/tower/container_plex/config")
+	if config.Name != "Plex" || config.UniqueID != "uma_tower_container_plex" ||
+		config.StateTopic != "uma/tower/switch/container_plex/state" ||
+		config.CommandTopic != "uma/tower/switch/container_plex/set" ||
+		config.AvailabilityTopic != "uma/tower/status" {
+		t.Fatalf("container switch discovery = %+v", config)
+	}
+	if config.Device.Identifiers[0] != "uma_tower" || config.Device.SWVersion != "1.2.3" {
+		t.Fatalf("device = %+v", config.Device)
+	}
+	if config := broker.discovery(t, "homeassistant/sensor/tower/ups_charge/config"); config.UnitOfMeasurement != "%" || config.DeviceClass != "battery" {
+		t.Fatalf("UPS charge discovery = %+v", config)
+	}
+	if config := broker.discovery(t, "homeassistant/button/tower/parity_check_start/config"); config.CommandTopic != "uma/tower/button/parity_check_start/press" || config.StateTopic != "" {
+		t.Fatalf("parity button discovery = %+v", config)
+	}
+
+	// Commands are routed to the controllers and the new state reported at once
+	for _, command := range []struct {
+		topic, payload, call string
+	}{
+		{"uma/tower/switch/container_plex/set", "OFF", "stop container Plex"},
+		{"uma/tower/button/container_plex_restart/press", "PRESS", "restart container Plex"},
+		{"uma/tower/switch/vm_windows_11/set", "ON", "start vm Windows 11"},
+		{"uma/tower/button/parity_check_start/press", "PRESS", "parity check"},
+	} {
+		if err := broker.Publish(command.topic, []byte(command.payload), false, 1); err != nil {
+			t.Fatal(err)
+		}
+		expectCall(t, fake.calls, command.call)
+	}
+	broker.waitFor(t, "uma/tower/switch/container_plex/state", "OFF")
+	broker.waitFor(t, "uma/tower/switch/vm_windows_11/state", "ON")
+
+	// Removed containers are withdrawn from Home Assistant
+	p.Observe(changes.CollectorContainers, []docker.ContainerInfo{})
+	broker.waitFor(t, "homeassistant/switch/tower/container_plex/config", "")
+	broker.waitFor(t, "homeassistant/button/tower/container_plex_restart/config", "")
+
+	p.Stop()
+	broker.waitFor(t, "uma/tower/status", "offline")
+}
+
+func TestPublisherReadOnly(t *testing.T) {
+	config := domain.DefaultConfig().MQTT
+	config.Broker = "tcp://127.0.0.1:1883"
+	config.NodeID = "tower"
+	// Commands are off unless enabled
+	fake := &fakeControllers{calls: make(chan string, 1)}
+	p, err := NewPublisher(config, "", Controllers{Containers: fake, VMs: fake, Parity: fake})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	var topics []string
+	for _, m := range p.handle(changes.CollectorContainers, []docker.ContainerInfo{{Name: "Plex", State: "running"}}, now) {
+		topics = append(topics, m.topic)
+	}
+	if fmt.Sprint(topics) != "[homeassistant/binary_sensor/tower/container_plex/config uma/tower/binary_sensor/container_plex/state]" {
+		t.Fatalf("topics = %v", topics)
+	}
+	if messages := p.handle(changes.CollectorParity, &storage.ParityCheckStatus{}, now); len(messages) != 4 {
+		t.Fatalf("parity published %d messages, want sensors only", len(messages))
+	}
+
+	// Snapshots within the interval are skipped
+	if messages := p.handle(changes.CollectorContainers, []docker.ContainerInfo{{Name: "Plex", State: "exited"}}, now.Add(time.Second)); messages != nil {
+		t.Fatalf("published %d messages within the interval", len(messages))
+	}
+	messages := p.handle(changes.CollectorContainers, []docker.ContainerInfo{{Name: "Plex", State: "exited"}}, now.Add(10*time.Second))
+	if len(messages) != 1 || string(messages[0].payload) != "OFF" {
+		t.Fatalf("state change published %v", messages)
+	}
+}
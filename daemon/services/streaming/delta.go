@@ -0,0 +1,122 @@
+package streaming
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PatchOperation is one RFC 6902 JSON Patch operation. Delta messages carry
+// a list of them to apply, in order, to the channel's last state.
+type PatchOperation struct {
+	Op    string      `json:"op"` // "add", "remove" or "replace"
+	Path  string      `json:"path"`
+	Value interface{} `json:"-"`
+}
+
+// MarshalJSON omits the value of remove operations but keeps null values elsewhere
+func (p PatchOperation) MarshalJSON() ([]byte, error) {
+	if p.Op == "remove" {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{p.Op, p.Path})
+	}
+	return json.Marshal(struct {
+		Op    string      `json:"op"`
+		Path  string      `json:"path"`
+		Value interface{} `json:"value"`
+	}{p.Op, p.Path, p.Value})
+}
+
+// toJSONValue converts data to its generic JSON form of maps, slices and scalars
+func toJSONValue(data interface{}) (interface{}, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := json.Unmarshal(encoded, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// diffJSON returns the JSON Patch turning from into to. Both must be in
+// generic JSON form. List elements are compared by position.
+func diffJSON(from, to interface{}) []PatchOperation {
+	return appendDiff(nil, "", from, to)
+}
+
+func appendDiff(ops []PatchOperation, path string, from, to interface{}) []PatchOperation {
+	switch a := from.(type) {
+	case map[string]interface{}:
+		b, ok := to.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		// Sorted so patches are deterministic
+		keys := make([]string, 0, len(a)+len(b))
+		for key := range a {
+			keys = append(keys, key)
+		}
+		for key := range b {
+			if _, exists := a[key]; !exists {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			child := path + "/" + escapePointer(key)
+			before, inA := a[key]
+			after, inB := b[key]
+			switch {
+			case !inB:
+				ops = append(ops, PatchOperation{Op: "remove", Path: child})
+			case !inA:
+				ops = append(ops, PatchOperation{Op: "add", Path: child, Value: after})
+			default:
+				ops = appendDiff(ops, child, before, after)
+			}
+		}
+		return ops
+
+	case []interface{}:
+		b, ok := to.([]interface{})
+		if !ok {
+			break
+		}
+
+		common := len(a)
+		if len(b) < common {
+			common = len(b)
+		}
+		for i := 0; i < common; i++ {
+			ops = appendDiff(ops, path+"/"+strconv.Itoa(i), a[i], b[i])
+		}
+		for i := common; i < len(b); i++ {
+			ops = append(ops, PatchOperation{Op: "add", Path: path + "/" + strconv.Itoa(i), Value: b[i]})
+		}
+		// Remove from the end so earlier indexes stay valid
+		for i := len(a) - 1; i >= common; i-- {
+			ops = append(ops, PatchOperation{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+		}
+		return ops
+
+	default:
+		if reflect.DeepEqual(from, to) {
+			return ops
+		}
+	}
+
+	return append(ops, PatchOperation{Op: "replace", Path: path, Value: to})
+}
+
+// escapePointer escapes a key for use in a JSON Pointer (RFC 6901)
+func escapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
@@ -0,0 +1,121 @@
+package streaming
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// applyPatch is a minimal RFC 6902 applier for add, remove and replace, as a client would implement
+func applyPatch(t *testing.T, doc interface{}, patch []PatchOperation) interface{} {
+	t.Helper()
+
+	for _, op := range patch {
+		if op.Path == "" {
+			doc = op.Value
+			continue
+		}
+
+		segments := strings.Split(op.Path[1:], "/")
+		for i, segment := range segments {
+			segments[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(segment)
+		}
+		doc = applyAt(t, doc, segments, op)
+	}
+	return doc
+}
+
+func applyAt(t *testing.T, node interface{}, path []string, op PatchOperation) interface{} {
+	t.Helper()
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if len(path) > 1 {
+			n[path[0]] = applyAt(t, n[path[0]], path[1:], op)
+		} else if op.Op == "remove" {
+			delete(n, path[0])
+		} else {
+			n[path[0]] = op.Value
+		}
+		return n
+	case []interface{}:
+		index, err := strconv.Atoi(path[0])
+		if err != nil {
+			t.Fatalf("bad list index in %s", op.Path)
+		}
+		switch {
+		case len(path) > 1:
+			n[index] = applyAt(t, n[index], path[1:], op)
+		case op.Op == "add":
+			n = append(n[:index], append([]interface{}{op.Value}, n[index:]...)...)
+		case op.Op == "remove":
+			n = append(n[:index], n[index+1:]...)
+		default:
+			n[index] = op.Value
+		}
+		return n
+	}
+	t.Fatalf("cannot apply %s %s", op.Op, op.Path)
+	return nil
+}
+
+func decodeJSON(t *testing.T, raw string) interface{} {
+	t.Helper()
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		t.Fatalf("invalid test JSON %s: %v", raw, err)
+	}
+	return value
+}
+
+func TestDiffJSON(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     string
+	}{
+		{`{"a":1,"b":2}`, `{"a":1,"b":2}`, `[]`},
+		{`{"a":1,"b":2}`, `{"a":1,"b":3}`, `[{"op":"replace","path":"/b","value":3}]`},
+		{`{"a":1}`, `{"a":1,"b/c":null}`, `[{"op":"add","path":"/b~1c","value":null}]`},
+		{`{"a":1,"b":2}`, `{"a":1}`, `[{"op":"remove","path":"/b"}]`},
+		{`{"list":[1,2,3]}`, `{"list":[1,5]}`, `[{"op":"replace","path":"/list/1","value":5},{"op":"remove","path":"/list/2"}]`},
+		{`[1]`, `[1,2,3]`, `[{"op":"add","path":"/1","value":2},{"op":"add","path":"/2","value":3}]`},
+		{`{"a":{"b":1}}`, `{"a":[1]}`, `[{"op":"replace","path":"/a","value":[1]}]`},
+		{`1`, `"x"`, `[{"op":"replace","path":"","value":"x"}]`},
+	}
+
+	for _, tt := range tests {
+		patch := diffJSON(decodeJSON(t, tt.from), decodeJSON(t, tt.to))
+		if patch == nil {
+			patch = []PatchOperation{}
+		}
+		encoded, err := json.Marshal(patch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(encoded) != tt.want {
+			t.Errorf("diff %s -> %s = %s, want %s", tt.from, tt.to, encoded, tt.want)
+		}
+	}
+}
+
+func TestDiffJSONRoundTrip(t *testing.T) {
+	states := []string{
+		`{"containers":[{"name":"plex","cpu":1.5},{"name":"sonarr","cpu":0}],"summary":{"running":2}}`,
+		`{"containers":[{"name":"plex","cpu":80},{"name":"sonarr","cpu":0},{"name":"radarr","cpu":3}],"summary":{"running":3}}`,
+		`{"containers":[{"name":"radarr","cpu":3}],"summary":{"running":1,"stopped":2}}`,
+		`{"containers":[],"summary":null}`,
+	}
+
+	for i := 1; i < len(states); i++ {
+		from, to := decodeJSON(t, states[i-1]), decodeJSON(t, states[i])
+		patch := diffJSON(from, to)
+
+		got := applyPatch(t, decodeJSON(t, states[i-1]), patch)
+		if !reflect.DeepEqual(got, to) {
+			t.Errorf("patch %d did not reproduce the new state: got %v", i, got)
+		}
+	}
+}
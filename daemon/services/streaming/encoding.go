@@ -0,0 +1,298 @@
+package streaming
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/gorilla/websocket"
+)
+
+// Wire formats a client can negotiate in its connect message. Binary
+// clients set capabilities.binary and pick a format, MessagePack by default.
+//
+// Every format carries the same objects as the JSON protocol: a binary frame
+// decodes to exactly the map its JSON text frame would. Integers are sent as
+// integers and everything else as floats.
+//
+// Clients that set capabilities.batch receive the updates produced in one
+// tick as a single frame:
+//
+//	{"type": "batch", "messages": [<message>, ...]}
+//
+// where each message is what would otherwise have been a frame of its own,
+// in order. Batches only ever hold two or more messages.
+const (
+	FormatJSON    = "json"
+	FormatMsgPack = "msgpack"
+	FormatCBOR    = "cbor"
+)
+
+// Encoder turns an encoded JSON message into a WebSocket frame
+type Encoder interface {
+	Name() string
+	FrameType() int // websocket.TextMessage or websocket.BinaryMessage
+	Encode(message []byte) ([]byte, error)
+}
+
+// NewEncoder returns the encoder for a wire format
+func NewEncoder(format string) (Encoder, error) {
+	switch format {
+	case FormatJSON:
+		return jsonEncoder{}, nil
+	case FormatMsgPack, "":
+		return binaryEncoder{name: FormatMsgPack, write: writeMsgPack}, nil
+	case FormatCBOR:
+		return binaryEncoder{name: FormatCBOR, write: writeCBOR}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) Name() string                          { return FormatJSON }
+func (jsonEncoder) FrameType() int                        { return websocket.TextMessage }
+func (jsonEncoder) Encode(message []byte) ([]byte, error) { return message, nil }
+
+// binaryEncoder transcodes JSON into a binary format
+type binaryEncoder struct {
+	name  string
+	write func(buf *bytes.Buffer, value interface{}) error
+}
+
+func (e binaryEncoder) Name() string   { return e.name }
+func (e binaryEncoder) FrameType() int { return websocket.BinaryMessage }
+
+func (e binaryEncoder) Encode(message []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(message))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := e.write(&buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// batchFrame wraps encoded messages in a batch message
+func batchFrame(messages [][]byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`{"type":"batch","messages":[`)
+	buf.Write(bytes.Join(messages, []byte(",")))
+	buf.WriteString(`]}`)
+	return buf.Bytes()
+}
+
+// sortedKeys returns map keys in order, so encoding is deterministic
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// numberValue returns a JSON number as an int64 if it is integral and fits, or else a float64
+func numberValue(n json.Number) (int64, float64, bool, error) {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		return i, 0, true, nil
+	}
+	f, err := n.Float64()
+	return 0, f, false, err
+}
+
+// writeMsgPack appends the MessagePack encoding of a decoded JSON value
+func writeMsgPack(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case json.Number:
+		i, f, integral, err := numberValue(v)
+		if err != nil {
+			return err
+		}
+		if integral {
+			writeMsgPackInt(buf, i)
+		} else {
+			buf.WriteByte(0xcb)
+			binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+		}
+	case string:
+		n := len(v)
+		switch {
+		case n < 32:
+			buf.WriteByte(0xa0 | byte(n))
+		case n <= math.MaxUint8:
+			buf.Write([]byte{0xd9, byte(n)})
+		case n <= math.MaxUint16:
+			buf.WriteByte(0xda)
+			binary.Write(buf, binary.BigEndian, uint16(n))
+		default:
+			buf.WriteByte(0xdb)
+			binary.Write(buf, binary.BigEndian, uint32(n))
+		}
+		buf.WriteString(v)
+	case []interface{}:
+		writeMsgPackLength(buf, len(v), 0x90, 0xdc, 0xdd)
+		for _, element := range v {
+			if err := writeMsgPack(buf, element); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		writeMsgPackLength(buf, len(v), 0x80, 0xde, 0xdf)
+		for _, key := range sortedKeys(v) {
+			if err := writeMsgPack(buf, key); err != nil {
+				return err
+			}
+			if err := writeMsgPack(buf, v[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cannot encode %T as MessagePack", value)
+	}
+	return nil
+}
+
+func writeMsgPackInt(buf *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0 && i <= 127:
+		buf.WriteByte(byte(i))
+	case i >= -32 && i < 0:
+		buf.WriteByte(byte(int8(i)))
+	case i >= 0 && i <= math.MaxUint8:
+		buf.Write([]byte{0xcc, byte(i)})
+	case i >= 0 && i <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		binary.Write(buf, binary.BigEndian, uint16(i))
+	case i >= 0 && i <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		binary.Write(buf, binary.BigEndian, uint32(i))
+	case i >= 0:
+		buf.WriteByte(0xcf)
+		binary.Write(buf, binary.BigEndian, uint64(i))
+	case i >= math.MinInt8:
+		buf.Write([]byte{0xd0, byte(int8(i))})
+	case i >= math.MinInt16:
+		buf.WriteByte(0xd1)
+		binary.Write(buf, binary.BigEndian, int16(i))
+	case i >= math.MinInt32:
+		buf.WriteByte(0xd2)
+		binary.Write(buf, binary.BigEndian, int32(i))
+	default:
+		buf.WriteByte(0xd3)
+		binary.Write(buf, binary.BigEndian, i)
+	}
+}
+
+// writeMsgPackLength writes an array or map header in its fix, 16 or 32 bit form
+func writeMsgPackLength(buf *bytes.Buffer, n int, fix, len16, len32 byte) {
+	switch {
+	case n < 16:
+		buf.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(len16)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(len32)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+// CBOR major types (RFC 8949)
+const (
+	cborUnsigned byte = 0
+	cborNegative byte = 1
+	cborText     byte = 3
+	cborArray    byte = 4
+	cborMap      byte = 5
+)
+
+// writeCBOR appends the CBOR encoding of a decoded JSON value
+func writeCBOR(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteByte(0xf6)
+	case bool:
+		if v {
+			buf.WriteByte(0xf5)
+		} else {
+			buf.WriteByte(0xf4)
+		}
+	case json.Number:
+		i, f, integral, err := numberValue(v)
+		if err != nil {
+			return err
+		}
+		switch {
+		case !integral:
+			buf.WriteByte(0xfb)
+			binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+		case i >= 0:
+			writeCBORHead(buf, cborUnsigned, uint64(i))
+		default:
+			writeCBORHead(buf, cborNegative, uint64(-1-i))
+		}
+	case string:
+		writeCBORHead(buf, cborText, uint64(len(v)))
+		buf.WriteString(v)
+	case []interface{}:
+		writeCBORHead(buf, cborArray, uint64(len(v)))
+		for _, element := range v {
+			if err := writeCBOR(buf, element); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		writeCBORHead(buf, cborMap, uint64(len(v)))
+		for _, key := range sortedKeys(v) {
+			if err := writeCBOR(buf, key); err != nil {
+				return err
+			}
+			if err := writeCBOR(buf, v[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cannot encode %T as CBOR", value)
+	}
+	return nil
+}
+
+// writeCBORHead writes a major type with its argument in the shortest form
+func writeCBORHead(buf *bytes.Buffer, major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		buf.WriteByte(major | byte(n))
+	case n <= math.MaxUint8:
+		buf.Write([]byte{major | 24, byte(n)})
+	case n <= math.MaxUint16:
+		buf.WriteByte(major | 25)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	case n <= math.MaxUint32:
+		buf.WriteByte(major | 26)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	default:
+		buf.WriteByte(major | 27)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
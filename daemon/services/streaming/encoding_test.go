@@ -0,0 +1,342 @@
+package streaming
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/domalab/uma/daemon/events"
+	"github.com/domalab/uma/daemon/services/collectors"
+	"github.com/gorilla/websocket"
+)
+
+// decodeMsgPack decodes the MessagePack subset the encoder produces, as a client would
+func decodeMsgPack(r *bytes.Reader) (interface{}, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	readUint := func(size int) uint64 {
+		buf := make([]byte, 8)
+		io.ReadFull(r, buf[8-size:])
+		return binary.BigEndian.Uint64(buf)
+	}
+	readString := func(n uint64) (interface{}, error) {
+		buf := make([]byte, n)
+		_, err := io.ReadFull(r, buf)
+		return string(buf), err
+	}
+	readArray := func(n uint64) (interface{}, error) {
+		list := make([]interface{}, 0, n)
+		for i := uint64(0); i < n; i++ {
+			element, err := decodeMsgPack(r)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, element)
+		}
+		return list, nil
+	}
+	readMap := func(n uint64) (interface{}, error) {
+		m := make(map[string]interface{}, n)
+		for i := uint64(0); i < n; i++ {
+			key, err := decodeMsgPack(r)
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeMsgPack(r)
+			if err != nil {
+				return nil, err
+			}
+			m[key.(string)] = value
+		}
+		return m, nil
+	}
+
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xe0 == 0xa0:
+		return readString(uint64(b & 0x1f))
+	case b&0xf0 == 0x90:
+		return readArray(uint64(b & 0x0f))
+	case b&0xf0 == 0x80:
+		return readMap(uint64(b & 0x0f))
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcb:
+		return math.Float64frombits(readUint(8)), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return int64(readUint(1 << (b - 0xcc))), nil
+	case 0xd0:
+		return int64(int8(readUint(1))), nil
+	case 0xd1:
+		return int64(int16(readUint(2))), nil
+	case 0xd2:
+		return int64(int32(readUint(4))), nil
+	case 0xd3:
+		return int64(readUint(8)), nil
+	case 0xd9, 0xda, 0xdb:
+		return readString(readUint(1 << (b - 0xd9)))
+	case 0xdc, 0xdd:
+		return readArray(readUint(2 << (b - 0xdc)))
+	case 0xde, 0xdf:
+		return readMap(readUint(2 << (b - 0xde)))
+	}
+	return nil, fmt.Errorf("unexpected MessagePack byte 0x%x", b)
+}
+
+// decodeCBOR decodes the CBOR subset the encoder produces, as a client would
+func decodeCBOR(r *bytes.Reader) (interface{}, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch b {
+	case 0xf4:
+		return false, nil
+	case 0xf5:
+		return true, nil
+	case 0xf6:
+		return nil, nil
+	case 0xfb:
+		var bits uint64
+		err := binary.Read(r, binary.BigEndian, &bits)
+		return math.Float64frombits(bits), err
+	}
+
+	major, info := b>>5, b&0x1f
+	n := uint64(info)
+	if info >= 24 {
+		size := 1 << (info - 24)
+		buf := make([]byte, 8)
+		if _, err := io.ReadFull(r, buf[8-size:]); err != nil {
+			return nil, err
+		}
+		n = binary.BigEndian.Uint64(buf)
+	}
+
+	switch major {
+	case cborUnsigned:
+		return int64(n), nil
+	case cborNegative:
+		return -1 - int64(n), nil
+	case cborText:
+		buf := make([]byte, n)
+		_, err := io.ReadFull(r, buf)
+		return string(buf), err
+	case cborArray:
+		list := make([]interface{}, 0, n)
+		for i := uint64(0); i < n; i++ {
+			element, err := decodeCBOR(r)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, element)
+		}
+		return list, nil
+	case cborMap:
+		m := make(map[string]interface{}, n)
+		for i := uint64(0); i < n; i++ {
+			key, err := decodeCBOR(r)
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeCBOR(r)
+			if err != nil {
+				return nil, err
+			}
+			m[key.(string)] = value
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("unexpected CBOR byte 0x%x", b)
+}
+
+// normalizeNumbers turns decoded integers into float64 so they compare equal to decoded JSON
+func normalizeNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int64:
+		return float64(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = normalizeNumbers(v[i])
+		}
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = normalizeNumbers(v[key])
+		}
+	}
+	return value
+}
+
+var binaryDecoders = map[string]func(*bytes.Reader) (interface{}, error){
+	FormatMsgPack: decodeMsgPack,
+	FormatCBOR:    decodeCBOR,
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	long := strings.Repeat("x", 70000)
+	many := make(map[string]interface{})
+	for i := 0; i < 20; i++ {
+		many[fmt.Sprintf("k%02d", i)] = i
+	}
+
+	message, _ := json.Marshal(StreamMessage{
+		Timestamp: 1700000000,
+		Channel:   "containers.stats",
+		Data: map[string]interface{}{
+			"containers": []map[string]interface{}{
+				{"name": "plex", "cpu_percent": 72.5, "memory_usage": int64(8) << 32, "healthy": true},
+				{"name": "sonarr", "cpu_percent": 0, "exit_code": -129, "labels": nil},
+			},
+			"numbers": []interface{}{0, 23, 24, 255, 256, 65535, 65536, -1, -24, -25, -32, -33, -200, -40000, -3000000000, math.MaxInt64, 1.5e300, -0.25},
+			"empty":   map[string]interface{}{},
+			"long":    long,
+			"many":    many,
+		},
+		Delta:    true,
+		Sequence: 42,
+	})
+	frames := [][]byte{
+		message,
+		batchFrame([][]byte{message, []byte(`{"type":"pong","timestamp":1}`)}),
+	}
+
+	for format, decode := range binaryDecoders {
+		encoder, err := NewEncoder(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if encoder.FrameType() != websocket.BinaryMessage {
+			t.Errorf("%s: expected binary frames", format)
+		}
+
+		for _, frame := range frames {
+			encoded, err := encoder.Encode(frame)
+			if err != nil {
+				t.Fatalf("%s: %v", format, err)
+			}
+			if len(encoded) >= len(frame) {
+				t.Errorf("%s: %d bytes is not smaller than %d bytes of JSON", format, len(encoded), len(frame))
+			}
+
+			reader := bytes.NewReader(encoded)
+			decoded, err := decode(reader)
+			if err != nil {
+				t.Fatalf("%s: decode: %v", format, err)
+			}
+			if reader.Len() != 0 {
+				t.Errorf("%s: %d trailing bytes", format, reader.Len())
+			}
+
+			var want interface{}
+			json.Unmarshal(frame, &want)
+			if got := normalizeNumbers(decoded); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: round trip mismatch\n got: %.300v\nwant: %.300v", format, got, want)
+			}
+		}
+	}
+
+	if _, err := NewEncoder("protobuf"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestBinaryBatchStream(t *testing.T) {
+	wse := NewWebSocketEngine(collectors.NewSystemCollector())
+	wse.RegisterChannel(events.TopicDisk, events.Event{})
+	server := httptest.NewServer(http.HandlerFunc(wse.HandleWebSocket))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	readFrame := func() map[string]interface{} {
+		t.Helper()
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		frameType, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frameType != websocket.BinaryMessage {
+			t.Fatalf("got frame type %d, want binary", frameType)
+		}
+		decoded, err := decodeCBOR(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return decoded.(map[string]interface{})
+	}
+
+	conn.WriteJSON(map[string]interface{}{
+		"type":         "connect",
+		"capabilities": map[string]interface{}{"binary": true, "format": "cbor", "batch": true},
+	})
+	if msg := readFrame(); msg["type"] != "connected" || msg["format"] != FormatCBOR {
+		t.Fatalf("unexpected acknowledgment %v", msg)
+	}
+
+	conn.WriteJSON(map[string]interface{}{"type": "subscribe", "channels": []interface{}{map[string]interface{}{"channel": events.TopicDisk}}})
+	if msg := readFrame(); msg["type"] != "subscribed" {
+		t.Fatalf("unexpected response %v", msg)
+	}
+
+	// Messages queued together go out as one batch frame
+	var client *StreamingClient
+	wse.mutex.RLock()
+	for _, c := range wse.clients {
+		client = c
+	}
+	wse.mutex.RUnlock()
+
+	var messages [][]byte
+	client.mutex.Lock()
+	for i := 0; i < 3; i++ {
+		encoded, err := client.stream(events.TopicDisk).next(StreamMessage{Channel: events.TopicDisk, Data: i})
+		if err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, encoded)
+	}
+	client.mutex.Unlock()
+
+	if err := wse.queueAll(client, messages, client.batches()); err != nil {
+		t.Fatal(err)
+	}
+
+	batch := readFrame()
+	if batch["type"] != "batch" {
+		t.Fatalf("expected a batch frame, got %v", batch)
+	}
+	batched := batch["messages"].([]interface{})
+	if len(batched) != 3 {
+		t.Fatalf("batch holds %d messages, want 3", len(batched))
+	}
+	if last := batched[2].(map[string]interface{}); last["seq"] != int64(3) || last["data"] != int64(2) {
+		t.Errorf("unexpected last message %v", last)
+	}
+}
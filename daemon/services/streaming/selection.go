@@ -0,0 +1,471 @@
+package streaming
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Filter operators accepted in Subscription.Filters, e.g. {"cpu_percent": {"gt": 50}}.
+// A list is shorthand for "in" and any other value for "eq". "matches" takes a
+// regular expression, e.g. {"line": {"matches": "(?i)error"}}.
+var filterOperators = map[string]bool{
+	"eq": true, "ne": true,
+	"gt": true, "gte": true, "lt": true, "lte": true,
+	"in": true, "contains": true, "matches": true,
+}
+
+// selection is the compiled form of a subscription's Fields and Filters.
+//
+// Fields are dotted paths from the payload root, such as
+// "containers.cpu_percent"; lists are traversed transparently. Filters are
+// matched against the payload's records: the elements of a top-level list,
+// the elements of any lists directly inside a top-level object, or the
+// object itself. Records that do not match are dropped; a payload without
+// lists that does not match is not sent at all.
+type selection struct {
+	fields  fieldTree
+	filters []filter
+}
+
+// fieldTree holds projected paths by segment. A nil subtree keeps the whole value.
+type fieldTree map[string]fieldTree
+
+type filter struct {
+	path       []string
+	predicates []predicate
+}
+
+type predicate struct {
+	op    string
+	value interface{}
+}
+
+// compileSelection parses and checks the syntax of fields and filters. It
+// returns nil when neither is set, so unfiltered subscriptions cost nothing.
+func compileSelection(fields []string, filters map[string]interface{}) (*selection, error) {
+	if len(fields) == 0 && len(filters) == 0 {
+		return nil, nil
+	}
+
+	sel := &selection{}
+	for _, field := range fields {
+		path, err := splitPath(field)
+		if err != nil {
+			return nil, err
+		}
+		sel.fields = sel.fields.add(path)
+	}
+
+	// Sorted so validation errors are stable
+	keys := make([]string, 0, len(filters))
+	for key := range filters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		path, err := splitPath(key)
+		if err != nil {
+			return nil, err
+		}
+		predicates, err := parsePredicates(key, filters[key])
+		if err != nil {
+			return nil, err
+		}
+		sel.filters = append(sel.filters, filter{path: path, predicates: predicates})
+	}
+
+	return sel, nil
+}
+
+func splitPath(path string) ([]string, error) {
+	segments := strings.Split(path, ".")
+	for _, segment := range segments {
+		if segment == "" {
+			return nil, fmt.Errorf("invalid field path %q", path)
+		}
+	}
+	return segments, nil
+}
+
+// add merges path into the tree. Selecting a parent overrides narrower selections of its children.
+func (t fieldTree) add(path []string) fieldTree {
+	if t == nil {
+		t = make(fieldTree)
+	}
+	head := path[0]
+	if len(path) == 1 {
+		t[head] = nil
+		return t
+	}
+	if sub, exists := t[head]; exists && sub == nil {
+		return t
+	}
+	t[head] = t[head].add(path[1:])
+	return t
+}
+
+func parsePredicates(key string, value interface{}) ([]predicate, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		return []predicate{{op: "in", value: v}}, nil
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return nil, fmt.Errorf("filter %q has no operators", key)
+		}
+		predicates := make([]predicate, 0, len(v))
+		for op, operand := range v {
+			if !filterOperators[op] {
+				return nil, fmt.Errorf("filter %q: unknown operator %q", key, op)
+			}
+			switch op {
+			case "gt", "gte", "lt", "lte":
+				if _, ok := operand.(float64); !ok {
+					return nil, fmt.Errorf("filter %q: %s needs a number", key, op)
+				}
+			case "in":
+				if _, ok := operand.([]interface{}); !ok {
+					return nil, fmt.Errorf("filter %q: in needs a list", key)
+				}
+			case "contains":
+				if _, ok := operand.(string); !ok {
+					return nil, fmt.Errorf("filter %q: contains needs a string", key)
+				}
+			case "matches":
+				pattern, ok := operand.(string)
+				if !ok {
+					return nil, fmt.Errorf("filter %q: matches needs a string", key)
+				}
+				re, err := regexp.Compile(pattern)
+				if err != nil {
+					return nil, fmt.Errorf("filter %q: %v", key, err)
+				}
+				operand = re
+			}
+			predicates = append(predicates, predicate{op: op, value: operand})
+		}
+		sort.Slice(predicates, func(i, j int) bool { return predicates[i].op < predicates[j].op })
+		return predicates, nil
+	default:
+		return []predicate{{op: "eq", value: v}}, nil
+	}
+}
+
+// validate checks that every field and filter path exists in payloads of type t
+func (s *selection) validate(t reflect.Type) error {
+	if s == nil || t == nil {
+		return nil
+	}
+
+	if err := validateTree(t, s.fields, ""); err != nil {
+		return err
+	}
+
+	records := recordTypes(t)
+	for _, f := range s.filters {
+		var err error
+		for _, record := range records {
+			if err = validatePath(record, f.path); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("unknown filter field %q", strings.Join(f.path, "."))
+		}
+	}
+	return nil
+}
+
+func validateTree(t reflect.Type, tree fieldTree, prefix string) error {
+	for segment, sub := range tree {
+		path := prefix + segment
+		child, ok := fieldType(t, segment)
+		if !ok {
+			return fmt.Errorf("unknown field %q", path)
+		}
+		if err := validateTree(child, sub, path+"."); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validatePath(t reflect.Type, path []string) error {
+	for _, segment := range path {
+		child, ok := fieldType(t, segment)
+		if !ok {
+			return fmt.Errorf("unknown field %q", segment)
+		}
+		t = child
+	}
+	return nil
+}
+
+// fieldType returns the type found under a JSON key of t, looking through
+// pointers and lists. Interfaces and maps accept any key.
+func fieldType(t reflect.Type, name string) (reflect.Type, bool) {
+	t = elemType(t)
+	switch t.Kind() {
+	case reflect.Interface:
+		return t, true
+	case reflect.Map:
+		return t.Elem(), true
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			switch {
+			case tag == "-" || !field.IsExported():
+				continue
+			case field.Anonymous && tag == "":
+				if child, ok := fieldType(field.Type, name); ok {
+					return child, true
+				}
+			case tag == name || (tag == "" && field.Name == name):
+				return field.Type, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// elemType strips pointers and list types
+func elemType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	return t
+}
+
+// recordTypes mirrors records for a payload type
+func recordTypes(t reflect.Type) []reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		return []reflect.Type{elemType(t)}
+	}
+
+	var types []reflect.Type
+	if t.Kind() == reflect.Struct {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if kind := field.Type.Kind(); field.IsExported() && (kind == reflect.Slice || kind == reflect.Array) {
+				if elem := elemType(field.Type); elem.Kind() == reflect.Struct || elem.Kind() == reflect.Map {
+					types = append(types, elem)
+				}
+			}
+		}
+	}
+	if len(types) == 0 {
+		types = append(types, t)
+	}
+	return types
+}
+
+// apply filters and projects a payload. It returns false when a payload
+// without lists does not match the filters and should not be sent.
+func (s *selection) apply(data interface{}) (interface{}, bool, error) {
+	if s == nil {
+		return data, true, nil
+	}
+
+	// Work on the JSON form so every payload type is handled alike
+	value, err := toJSONValue(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(s.filters) > 0 {
+		var keep bool
+		if value, keep = s.filterRecords(value); !keep {
+			return nil, false, nil
+		}
+	}
+
+	if s.fields != nil {
+		value = project(value, s.fields)
+	}
+	return value, true, nil
+}
+
+// filterRecords drops the records that do not match, as described on selection
+func (s *selection) filterRecords(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case []interface{}:
+		return s.filterList(v), true
+	case map[string]interface{}:
+		hasLists := false
+		for key, field := range v {
+			if list, ok := field.([]interface{}); ok && isRecordList(list) {
+				v[key] = s.filterList(list)
+				hasLists = true
+			}
+		}
+		if hasLists {
+			return v, true
+		}
+		return v, s.matches(v)
+	default:
+		return value, s.matches(value)
+	}
+}
+
+// isRecordList reports whether a list holds objects; empty lists are treated as records too
+func isRecordList(list []interface{}) bool {
+	if len(list) == 0 {
+		return true
+	}
+	_, ok := list[0].(map[string]interface{})
+	return ok
+}
+
+func (s *selection) filterList(list []interface{}) []interface{} {
+	kept := make([]interface{}, 0, len(list))
+	for _, record := range list {
+		if s.matches(record) {
+			kept = append(kept, record)
+		}
+	}
+	return kept
+}
+
+// matches reports whether a record satisfies every filter
+func (s *selection) matches(record interface{}) bool {
+	for _, f := range s.filters {
+		values := lookup(record, f.path)
+		if len(values) == 0 {
+			return false
+		}
+
+		// A path through a list matches when any of its values does
+		matched := false
+		for _, value := range values {
+			if matchesAll(value, f.predicates) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// lookup returns the values at path, fanning out over lists
+func lookup(value interface{}, path []string) []interface{} {
+	if len(path) == 0 {
+		if list, ok := value.([]interface{}); ok {
+			return list
+		}
+		return []interface{}{value}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		child, ok := v[path[0]]
+		if !ok {
+			return nil
+		}
+		return lookup(child, path[1:])
+	case []interface{}:
+		var values []interface{}
+		for _, element := range v {
+			values = append(values, lookup(element, path)...)
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+func matchesAll(value interface{}, predicates []predicate) bool {
+	for _, p := range predicates {
+		if !p.matches(value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p predicate) matches(value interface{}) bool {
+	switch p.op {
+	case "eq":
+		return equalValues(value, p.value)
+	case "ne":
+		return !equalValues(value, p.value)
+	case "in":
+		for _, candidate := range p.value.([]interface{}) {
+			if equalValues(value, candidate) {
+				return true
+			}
+		}
+		return false
+	case "contains":
+		s, ok := value.(string)
+		return ok && strings.Contains(s, p.value.(string))
+	case "matches":
+		s, ok := value.(string)
+		return ok && p.value.(*regexp.Regexp).MatchString(s)
+	}
+
+	number, ok := value.(float64)
+	if !ok {
+		return false
+	}
+	operand := p.value.(float64)
+	switch p.op {
+	case "gt":
+		return number > operand
+	case "gte":
+		return number >= operand
+	case "lt":
+		return number < operand
+	case "lte":
+		return number <= operand
+	}
+	return false
+}
+
+// equalValues compares JSON scalars; objects and lists never match
+func equalValues(a, b interface{}) bool {
+	switch a.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	switch b.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	return a == b
+}
+
+// project keeps only the selected paths of value
+func project(value interface{}, tree fieldTree) interface{} {
+	if tree == nil {
+		return value
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		projected := make(map[string]interface{}, len(tree))
+		for key, sub := range tree {
+			if child, ok := v[key]; ok {
+				projected[key] = project(child, sub)
+			}
+		}
+		return projected
+	case []interface{}:
+		projected := make([]interface{}, len(v))
+		for i, element := range v {
+			projected[i] = project(element, tree)
+		}
+		return projected
+	default:
+		return value
+	}
+}
@@ -0,0 +1,158 @@
+package streaming
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/domalab/uma/daemon/services/collectors"
+)
+
+// decodeFilters parses filters the way they arrive in a subscribe message
+func decodeFilters(t *testing.T, raw string) map[string]interface{} {
+	t.Helper()
+
+	var filters map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &filters); err != nil {
+		t.Fatalf("invalid test filters: %v", err)
+	}
+	return filters
+}
+
+func testContainerMetrics() *collectors.ContainerMetrics {
+	return &collectors.ContainerMetrics{
+		Timestamp: 1700000000,
+		Containers: []collectors.ContainerStats{
+			{ID: "a", Name: "plex", State: "running", CPUPercent: 72.5},
+			{ID: "b", Name: "sonarr", State: "running", CPUPercent: 3},
+			{ID: "c", Name: "radarr", State: "exited"},
+		},
+		Summary: collectors.ContainerSummary{Total: 3, Running: 2, Stopped: 1},
+	}
+}
+
+func TestCompileSelection(t *testing.T) {
+	if sel, err := compileSelection(nil, nil); sel != nil || err != nil {
+		t.Errorf("empty selection = %v, %v; want nil, nil", sel, err)
+	}
+
+	invalid := map[string]string{
+		`{"cpu_percent": {"gt": "high"}}`: "gt needs a number",
+		`{"name": {"like": "pl%"}}`:       `unknown operator "like"`,
+		`{"name": {}}`:                    "has no operators",
+		`{"name": {"in": "plex"}}`:        "in needs a list",
+		`{"line": {"matches": "("}}`:      "missing closing )",
+		`{"containers..name": "plex"}`:    "invalid field path",
+	}
+	for raw, want := range invalid {
+		_, err := compileSelection(nil, decodeFilters(t, raw))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("filters %s: got error %v, want %q", raw, err, want)
+		}
+	}
+
+	if _, err := compileSelection([]string{"summary."}, nil); err == nil {
+		t.Error("expected an error for a trailing dot")
+	}
+}
+
+func TestSelectionValidate(t *testing.T) {
+	payloadType := reflect.TypeOf(testContainerMetrics())
+
+	tests := []struct {
+		fields  []string
+		filters string
+		wantErr string
+	}{
+		{fields: []string{"containers.name", "summary.running"}},
+		{filters: `{"name": ["plex"], "cpu_percent": {"gt": 50}}`},
+		{fields: []string{"containers.uptime"}, wantErr: `unknown field "containers.uptime"`},
+		{filters: `{"uptime": {"gt": 1}}`, wantErr: `unknown filter field "uptime"`},
+	}
+
+	for _, tt := range tests {
+		var filters map[string]interface{}
+		if tt.filters != "" {
+			filters = decodeFilters(t, tt.filters)
+		}
+		sel, err := compileSelection(tt.fields, filters)
+		if err != nil {
+			t.Fatalf("compile %v %s: %v", tt.fields, tt.filters, err)
+		}
+
+		err = sel.validate(payloadType)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%v %s: unexpected error %v", tt.fields, tt.filters, err)
+		case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+			t.Errorf("%v %s: got error %v, want %q", tt.fields, tt.filters, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSelectionApply(t *testing.T) {
+	sel, err := compileSelection(
+		[]string{"containers.name", "containers.cpu_percent", "timestamp"},
+		decodeFilters(t, `{"name": ["plex", "sonarr"], "cpu_percent": {"gt": 50}}`),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, matched, err := sel.apply(testContainerMetrics())
+	if err != nil || !matched {
+		t.Fatalf("apply = %v, %v", matched, err)
+	}
+
+	encoded, _ := json.Marshal(data)
+	want := `{"containers":[{"cpu_percent":72.5,"name":"plex"}],"timestamp":1700000000}`
+	if string(encoded) != want {
+		t.Errorf("got %s, want %s", encoded, want)
+	}
+
+	// Payloads without lists are matched as a whole
+	sel, err = compileSelection(nil, decodeFilters(t, `{"cpu_percent": {"gte": 50}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, matched, _ := sel.apply(&collectors.SystemMetrics{CPUPercent: 12}); matched {
+		t.Error("expected an idle CPU reading to be filtered out")
+	}
+	if _, matched, _ := sel.apply(&collectors.SystemMetrics{CPUPercent: 90}); !matched {
+		t.Error("expected a busy CPU reading to pass")
+	}
+}
+
+func TestPredicates(t *testing.T) {
+	tests := []struct {
+		filters string
+		record  string
+		want    bool
+	}{
+		{`{"state": "running"}`, `{"state": "running"}`, true},
+		{`{"state": {"ne": "running"}}`, `{"state": "running"}`, false},
+		{`{"name": {"contains": "arr"}}`, `{"name": "sonarr"}`, true},
+		{`{"line": {"matches": "(?i)^error"}}`, `{"line": "Error: disk full"}`, true},
+		{`{"line": {"matches": "error"}}`, `{"line": 1}`, false},
+		{`{"size": {"gte": 10, "lt": 20}}`, `{"size": 20}`, false},
+		{`{"size": {"lte": 20}}`, `{"size": "20"}`, false},
+		{`{"data.name": ["plex"]}`, `{"data": {"name": "plex"}}`, true},
+		{`{"ports.host_port": "8080"}`, `{"ports": [{"host_port": "80"}, {"host_port": "8080"}]}`, true},
+		{`{"missing": {"ne": 1}}`, `{"present": 1}`, false},
+	}
+
+	for _, tt := range tests {
+		sel, err := compileSelection(nil, decodeFilters(t, tt.filters))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.filters, err)
+		}
+		var record interface{}
+		if err := json.Unmarshal([]byte(tt.record), &record); err != nil {
+			t.Fatal(err)
+		}
+		if got := sel.matches(record); got != tt.want {
+			t.Errorf("%s on %s = %v, want %v", tt.filters, tt.record, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package streaming
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/domalab/uma/daemon/logger"
+)
+
+const (
+	// replayBufferSize is how many recent messages per channel a client can resume from
+	replayBufferSize = 64
+
+	// resumeWindow is how long a disconnected client's session can be resumed
+	resumeWindow = 2 * time.Minute
+)
+
+// Resume outcomes reported per channel in the "resumed" message
+const (
+	ResumeReplayed = "replayed" // Missed messages were resent
+	ResumeSnapshot = "snapshot" // A full state was sent; the client must replace its copy
+	ResumeGap      = "gap"      // Messages were lost and the channel has no state to resend
+)
+
+// channelStream tracks what a client was sent on one channel
+type channelStream struct {
+	sequence int64
+	state    interface{}        // Last full state sent, in JSON form, which patches apply to
+	history  []sequencedMessage // Most recent messages, oldest first
+}
+
+type sequencedMessage struct {
+	sequence int64
+	message  []byte
+}
+
+// detachedSession holds the state of a disconnected client until it resumes or expires
+type detachedSession struct {
+	subscriptions map[string]*Subscription
+	streams       map[string]*channelStream
+	expires       time.Time
+}
+
+// ResumeMessage asks to continue a previous session. Channels maps each
+// channel to the last sequence number the client received.
+type ResumeMessage struct {
+	Type     string           `json:"type"`
+	Session  string           `json:"session"`
+	Channels map[string]int64 `json:"channels"`
+}
+
+// generateStreamSession returns an unguessable session ID, since presenting it takes over the session
+func generateStreamSession() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("stream-%d", time.Now().UnixNano())
+	}
+	return "stream-" + hex.EncodeToString(buf)
+}
+
+// next stamps a message with the channel's next sequence number, encodes it
+// and keeps it for replay. The caller must hold the client mutex.
+func (cs *channelStream) next(message StreamMessage) ([]byte, error) {
+	message.Sequence = cs.sequence + 1
+	encoded, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	cs.sequence = message.Sequence
+	cs.history = append(cs.history, sequencedMessage{sequence: cs.sequence, message: encoded})
+	if len(cs.history) > replayBufferSize {
+		cs.history = cs.history[len(cs.history)-replayBufferSize:]
+	}
+	return encoded, nil
+}
+
+// replay returns the messages after sequence, or false if some are no longer buffered
+func (cs *channelStream) replay(sequence int64) ([][]byte, bool) {
+	if sequence > cs.sequence || sequence < 0 {
+		return nil, false
+	}
+	if sequence == cs.sequence {
+		return nil, true
+	}
+	if len(cs.history) == 0 || cs.history[0].sequence > sequence+1 {
+		return nil, false
+	}
+
+	messages := make([][]byte, 0, cs.sequence-sequence)
+	for _, entry := range cs.history {
+		if entry.sequence > sequence {
+			messages = append(messages, entry.message)
+		}
+	}
+	return messages, true
+}
+
+// detach keeps a disconnected client's subscriptions so it can resume. The
+// client must already be cancelled and the caller must hold the engine mutex.
+func (wse *WebSocketEngine) detach(client *StreamingClient) {
+	client.mutex.RLock()
+	defer client.mutex.RUnlock()
+
+	if len(client.subscriptions) == 0 {
+		return
+	}
+
+	now := time.Now()
+	for id, session := range wse.detached {
+		if now.After(session.expires) {
+			delete(wse.detached, id)
+		}
+	}
+	if len(wse.detached) >= wse.maxClients {
+		return
+	}
+
+	// Copied, since the old connection's goroutines may still be winding down
+	session := &detachedSession{
+		subscriptions: make(map[string]*Subscription, len(client.subscriptions)),
+		streams:       make(map[string]*channelStream, len(client.streams)),
+		expires:       now.Add(resumeWindow),
+	}
+	for channel, subscription := range client.subscriptions {
+		copied := *subscription
+		session.subscriptions[channel] = &copied
+	}
+	for channel, stream := range client.streams {
+		copied := *stream
+		session.streams[channel] = &copied
+	}
+	wse.detached[client.session] = session
+}
+
+// takeSession removes a resumable session, disconnecting a client still holding it
+func (wse *WebSocketEngine) takeSession(id string, taker *StreamingClient) (*detachedSession, bool) {
+	// The previous connection may not have noticed it is dead yet
+	wse.mutex.RLock()
+	var holder *StreamingClient
+	for _, client := range wse.clients {
+		if client != taker && client.sessionID() == id {
+			holder = client
+			break
+		}
+	}
+	wse.mutex.RUnlock()
+	if holder != nil {
+		wse.removeClient(holder)
+	}
+
+	wse.mutex.Lock()
+	defer wse.mutex.Unlock()
+
+	session, exists := wse.detached[id]
+	delete(wse.detached, id)
+	if !exists || time.Now().After(session.expires) {
+		return nil, false
+	}
+	return session, true
+}
+
+// handleResume processes resume requests
+func (wse *WebSocketEngine) handleResume(client *StreamingClient, message []byte) error {
+	var resumeMsg ResumeMessage
+	if err := json.Unmarshal(message, &resumeMsg); err != nil {
+		return err
+	}
+
+	resumed, err := wse.resume(client, resumeMsg)
+	if resumed {
+		wse.syncSources()
+	}
+	if err != nil || resumed {
+		return err
+	}
+	return wse.sendToClient(client, map[string]interface{}{
+		"type":    "error",
+		"message": "session not found or expired; subscribe again",
+	})
+}
+
+// resume restores a previous session's subscriptions and resends what the
+// client missed on each channel, or a snapshot when that is no longer
+// possible. It returns false if the session is unknown or expired.
+func (wse *WebSocketEngine) resume(client *StreamingClient, resumeMsg ResumeMessage) (bool, error) {
+	session, ok := wse.takeSession(resumeMsg.Session, client)
+	if !ok {
+		return false, nil
+	}
+
+	// Hold the client while queueing so no live update overtakes the replay
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	client.session = resumeMsg.Session
+	client.subscriptions = session.subscriptions
+	client.streams = session.streams
+
+	outcomes := make(map[string]string, len(session.subscriptions))
+	var queued [][]byte
+	for channel, subscription := range session.subscriptions {
+		stream := client.stream(channel)
+
+		if last, given := resumeMsg.Channels[channel]; given {
+			if messages, ok := stream.replay(last); ok {
+				queued = append(queued, messages...)
+				outcomes[channel] = ResumeReplayed
+				continue
+			}
+		}
+
+		snapshot, ok, err := wse.snapshot(stream, subscription, channel)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			queued = append(queued, snapshot)
+			outcomes[channel] = ResumeSnapshot
+		} else {
+			outcomes[channel] = ResumeGap
+		}
+	}
+
+	if err := wse.sendToClient(client, map[string]interface{}{
+		"type":     "resumed",
+		"session":  client.session,
+		"channels": outcomes,
+	}); err != nil {
+		return false, err
+	}
+	if err := wse.queueAll(client, queued, client.capabilities.BatchUpdates); err != nil {
+		return false, err
+	}
+
+	logger.Blue("Client %s resumed session with %d channels", client.id, len(outcomes))
+	return true, nil
+}
+
+// snapshot encodes the current full state of a collector channel as a
+// non-delta message. Pushed channels have no state and return false.
+// The caller must hold the client mutex.
+func (wse *WebSocketEngine) snapshot(stream *channelStream, subscription *Subscription, channel string) ([]byte, bool, error) {
+	data, found := wse.collector.GetMetric(channel)
+	if !found {
+		return nil, false, nil
+	}
+
+	data, matched, err := subscription.selection.apply(data)
+	if err != nil || !matched {
+		return nil, false, err
+	}
+
+	value, err := toJSONValue(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	encoded, err := stream.next(StreamMessage{
+		Timestamp: time.Now().Unix(),
+		Channel:   channel,
+		Data:      value,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	stream.state = value
+	return encoded, true, nil
+}
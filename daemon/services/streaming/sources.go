@@ -0,0 +1,116 @@
+package streaming
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/domalab/uma/daemon/logger"
+)
+
+// channelSource feeds a family of channels sharing a prefix, such as one per
+// container, that are too costly to produce without anyone listening
+type channelSource struct {
+	sample reflect.Type
+	run    func(ctx context.Context, channel string) error
+}
+
+// sourceRun is a source feeding one channel
+type sourceRun struct {
+	cancel context.CancelFunc
+}
+
+// RegisterChannelSource declares the channels starting with prefix. While a
+// client is subscribed to one of them, run is called with the channel in its
+// own goroutine to Publish to it, and its context is cancelled once the last
+// subscriber leaves. An error ending run is sent to the subscribers.
+func (wse *WebSocketEngine) RegisterChannelSource(prefix string, sample interface{}, run func(ctx context.Context, channel string) error) {
+	wse.mutex.Lock()
+	defer wse.mutex.Unlock()
+	wse.sources[prefix] = &channelSource{sample: reflect.TypeOf(sample), run: run}
+}
+
+// sourceFor returns the source feeding channel, or nil. Callers hold wse.mutex.
+func (wse *WebSocketEngine) sourceFor(channel string) *channelSource {
+	for prefix, source := range wse.sources {
+		if len(channel) > len(prefix) && strings.HasPrefix(channel, prefix) {
+			return source
+		}
+	}
+	return nil
+}
+
+// syncSources starts sources for newly subscribed channels and stops those
+// nobody is subscribed to any more. It is called whenever subscriptions change.
+func (wse *WebSocketEngine) syncSources() {
+	wse.sourceMutex.Lock()
+	defer wse.sourceMutex.Unlock()
+
+	wanted := make(map[string]*channelSource)
+	wse.mutex.RLock()
+	if len(wse.sources) > 0 {
+		for _, client := range wse.clients {
+			client.mutex.RLock()
+			for channel := range client.subscriptions {
+				if source := wse.sourceFor(channel); source != nil {
+					wanted[channel] = source
+				}
+			}
+			client.mutex.RUnlock()
+		}
+	}
+	wse.mutex.RUnlock()
+
+	for channel, run := range wse.running {
+		if wanted[channel] == nil {
+			run.cancel()
+			delete(wse.running, channel)
+		}
+	}
+	for channel, source := range wanted {
+		if _, running := wse.running[channel]; running {
+			continue
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		run := &sourceRun{cancel: cancel}
+		wse.running[channel] = run
+		go wse.runSource(ctx, channel, source, run)
+	}
+}
+
+// runSource feeds channel until its source ends or is stopped
+func (wse *WebSocketEngine) runSource(ctx context.Context, channel string, source *channelSource, run *sourceRun) {
+	err := source.run(ctx, channel)
+
+	// Subscribing again restarts a source that ended by itself
+	wse.sourceMutex.Lock()
+	if wse.running[channel] == run {
+		delete(wse.running, channel)
+	}
+	wse.sourceMutex.Unlock()
+	run.cancel()
+
+	if err == nil || ctx.Err() != nil {
+		return
+	}
+	logger.Yellow("Channel %s ended: %v", channel, err)
+
+	wse.mutex.RLock()
+	clients := make([]*StreamingClient, 0, len(wse.clients))
+	for _, client := range wse.clients {
+		client.mutex.RLock()
+		if _, subscribed := client.subscriptions[channel]; subscribed {
+			clients = append(clients, client)
+		}
+		client.mutex.RUnlock()
+	}
+	wse.mutex.RUnlock()
+
+	for _, client := range clients {
+		wse.sendToClient(client, map[string]interface{}{
+			"type":    "error",
+			"channel": channel,
+			"message": err.Error(),
+		})
+	}
+}
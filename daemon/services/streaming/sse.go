@@ -0,0 +1,247 @@
+package streaming
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/domalab/uma/daemon/httputil"
+	"github.com/domalab/uma/daemon/logger"
+)
+
+// defaultEventsInterval applies to Server-Sent Events subscriptions without an interval
+const defaultEventsInterval = time.Second
+
+// eventsHeartbeatInterval is how often a heartbeat comment is sent so idle streams are not dropped by proxies
+var eventsHeartbeatInterval = 15 * time.Second
+
+// HandleEvents serves the streaming protocol as Server-Sent Events for
+// clients that cannot use the WebSocket, such as EventSource and curl:
+//
+//	GET /api/v2/events?channels=system.cpu,storage.usage&interval=5s
+//
+// Optional parameters are fields (comma separated, applied to every channel)
+// and delta=true for JSON Patch updates. Each stream message is an event
+// named after its channel; control messages are named after their type.
+//
+// Event IDs carry the session and the last sequence number of every
+// channel, so a reconnecting EventSource resumes through Last-Event-ID
+// exactly as a WebSocket client would with a resume message.
+func (wse *WebSocketEngine) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	query := r.URL.Query()
+	interval := defaultEventsInterval
+	if value := query.Get("interval"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 {
+			http.Error(w, fmt.Sprintf("invalid interval: %s", value), http.StatusBadRequest)
+			return
+		}
+		interval = parsed
+	}
+
+	channels := splitList(query.Get("channels"))
+	fields := splitList(query.Get("fields"))
+	delta := query.Get("delta") == "true"
+
+	subscriptions := make([]Subscription, 0, len(channels))
+	selections := make([]*selection, 0, len(channels))
+	for _, channel := range channels {
+		sub := Subscription{Channel: channel, Interval: interval, Fields: fields, DeltaOnly: delta}
+		sel, err := wse.validateSubscription(sub)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		subscriptions = append(subscriptions, sub)
+		selections = append(selections, sel)
+	}
+
+	session, sequences, resuming := parseEventID(r.Header.Get("Last-Event-ID"))
+	if len(subscriptions) == 0 && !resuming {
+		http.Error(w, "channels is required", http.StatusBadRequest)
+		return
+	}
+
+	wse.mutex.RLock()
+	clientCount := len(wse.clients)
+	wse.mutex.RUnlock()
+	if clientCount >= wse.maxClients {
+		http.Error(w, "Maximum clients reached", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	client := &StreamingClient{
+		id:            fmt.Sprintf("sse_%d", time.Now().UnixNano()),
+		send:          make(chan []byte, 256),
+		session:       generateStreamSession(),
+		subscriptions: make(map[string]*Subscription),
+		streams:       make(map[string]*channelStream),
+		clientType:    ClientGeneric,
+		encoder:       jsonEncoder{},
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+
+	wse.mutex.Lock()
+	wse.clients[client.id] = client
+	wse.mutex.Unlock()
+	defer wse.removeClient(client)
+
+	logger.Blue("SSE client connected: %s", client.id)
+
+	resumed := false
+	if resuming {
+		var err error
+		if resumed, err = wse.resume(client, ResumeMessage{Type: "resume", Session: session, Channels: sequences}); err != nil {
+			logger.Yellow("Failed to resume session for SSE client %s: %v", client.id, err)
+		}
+	}
+	if !resumed {
+		if len(subscriptions) == 0 {
+			http.Error(w, "session not found or expired; subscribe again", http.StatusBadRequest)
+			return
+		}
+		for i, sub := range subscriptions {
+			wse.subscribe(client, sub, selections[i])
+		}
+		sequences = nil
+		wse.sendToClient(client, map[string]interface{}{
+			"type":     "subscribed",
+			"session":  client.sessionID(),
+			"channels": subscriptions,
+		})
+	}
+	wse.syncSources()
+
+	httputil.DisableWriteDeadline(w, "SSE client "+client.id)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", 3000)
+	flusher.Flush()
+
+	go wse.streamToClient(client)
+	wse.eventsWriter(w, flusher, client, sequences)
+}
+
+// eventsWriter writes queued messages as events until the client goes away.
+// sequences holds the last sequence number the client has per channel.
+func (wse *WebSocketEngine) eventsWriter(w http.ResponseWriter, flusher http.Flusher, client *StreamingClient, sequences map[string]int64) {
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	if sequences == nil {
+		sequences = make(map[string]int64)
+	}
+
+	for {
+		select {
+		case <-client.ctx.Done():
+			return
+		case message := <-client.send:
+			if err := writeEvent(w, client.sessionID(), sequences, message); err != nil {
+				logger.Yellow("SSE write error for client %s: %v", client.id, err)
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes an encoded message as one event, with an ID if it is sequenced
+func writeEvent(w http.ResponseWriter, session string, sequences map[string]int64, message []byte) error {
+	var header struct {
+		Type     string `json:"type"`
+		Channel  string `json:"channel"`
+		Sequence int64  `json:"seq"`
+	}
+	if err := json.Unmarshal(message, &header); err != nil {
+		return err
+	}
+
+	var event strings.Builder
+	if header.Sequence > 0 {
+		sequences[header.Channel] = header.Sequence
+		fmt.Fprintf(&event, "id: %s\n", formatEventID(session, sequences))
+	}
+	if header.Channel != "" {
+		fmt.Fprintf(&event, "event: %s\n", header.Channel)
+	} else if header.Type != "" {
+		fmt.Fprintf(&event, "event: %s\n", header.Type)
+	}
+	fmt.Fprintf(&event, "data: %s\n\n", message)
+
+	_, err := w.Write([]byte(event.String()))
+	return err
+}
+
+// formatEventID encodes a session and its channel sequence numbers as
+// "session:channel=seq,channel=seq"
+func formatEventID(session string, sequences map[string]int64) string {
+	channels := make([]string, 0, len(sequences))
+	for channel := range sequences {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+
+	parts := make([]string, len(channels))
+	for i, channel := range channels {
+		parts[i] = channel + "=" + strconv.FormatInt(sequences[channel], 10)
+	}
+	return session + ":" + strings.Join(parts, ",")
+}
+
+// parseEventID decodes an event ID written by formatEventID
+func parseEventID(id string) (string, map[string]int64, bool) {
+	session, list, found := strings.Cut(id, ":")
+	if !found || session == "" {
+		return "", nil, false
+	}
+
+	sequences := make(map[string]int64)
+	for _, part := range splitList(list) {
+		channel, value, found := strings.Cut(part, "=")
+		sequence, err := strconv.ParseInt(value, 10, 64)
+		if !found || err != nil {
+			return "", nil, false
+		}
+		sequences[channel] = sequence
+	}
+	return session, sequences, true
+}
+
+// splitList splits a comma separated query parameter, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
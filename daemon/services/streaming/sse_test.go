@@ -0,0 +1,182 @@
+package streaming
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/domalab/uma/daemon/events"
+	"github.com/domalab/uma/daemon/services/collectors"
+)
+
+type sseEvent struct {
+	id, name string
+	data     map[string]interface{}
+}
+
+// readEvent reads the next event, returning heartbeat comments as events named ":"
+func readEvent(t *testing.T, reader *bufio.Reader) sseEvent {
+	t.Helper()
+
+	var event sseEvent
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("stream ended: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case line == "":
+			if event.name != "" || event.data != nil {
+				return event
+			}
+		case strings.HasPrefix(line, ":"):
+			event.name = ":"
+		case strings.HasPrefix(line, "id: "):
+			event.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.data); err != nil {
+				t.Fatalf("invalid event data %s: %v", line, err)
+			}
+		}
+	}
+}
+
+// openEvents starts a stream, failing unless it is accepted
+func openEvents(t *testing.T, url, lastEventID string) (*http.Response, *bufio.Reader) {
+	t.Helper()
+
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		resp.Body.Close()
+		t.Fatalf("got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	return resp, bufio.NewReader(resp.Body)
+}
+
+func TestHandleEventsValidation(t *testing.T) {
+	wse := NewWebSocketEngine(collectors.NewSystemCollector())
+	wse.RegisterChannel(events.TopicDisk, events.Event{})
+	server := httptest.NewServer(http.HandlerFunc(wse.HandleEvents))
+	defer server.Close()
+
+	for _, query := range []string{
+		"",
+		"?channels=no.such.channel",
+		"?channels=events.disk&interval=soon",
+		"?channels=events.disk&fields=nope",
+	} {
+		resp, err := http.Get(server.URL + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%q: got status %d, want 400", query, resp.StatusCode)
+		}
+	}
+}
+
+func TestHandleEventsResume(t *testing.T) {
+	defer func(interval time.Duration) { eventsHeartbeatInterval = interval }(eventsHeartbeatInterval)
+	eventsHeartbeatInterval = 50 * time.Millisecond
+
+	wse := NewWebSocketEngine(collectors.NewSystemCollector())
+	wse.RegisterChannel(events.TopicDisk, events.Event{})
+	server := httptest.NewServer(http.HandlerFunc(wse.HandleEvents))
+	defer server.Close()
+
+	url := server.URL + "?channels=" + events.TopicDisk + "&fields=id"
+	resp, reader := openEvents(t, url, "")
+
+	subscribed := readEvent(t, reader)
+	if subscribed.name != "subscribed" || subscribed.id != "" {
+		t.Fatalf("unexpected first event %+v", subscribed)
+	}
+
+	for i := 1; i <= 3; i++ {
+		wse.Publish(events.TopicDisk, events.Event{ID: strconv.Itoa(i), Type: events.DiskSpunDown})
+	}
+
+	first := readEvent(t, reader)
+	if first.name != events.TopicDisk || first.data["seq"] != float64(1) {
+		t.Fatalf("unexpected event %+v", first)
+	}
+	if data, _ := json.Marshal(first.data["data"]); string(data) != `{"id":"1"}` {
+		t.Errorf("fields were not applied: %s", data)
+	}
+	session := subscribed.data["session"].(string)
+	if want := session + ":" + events.TopicDisk + "=1"; first.id != want {
+		t.Errorf("got event ID %q, want %q", first.id, want)
+	}
+
+	// Idle streams get heartbeats
+	readEvent(t, reader)
+	readEvent(t, reader)
+	if heartbeat := readEvent(t, reader); heartbeat.name != ":" {
+		t.Errorf("expected a heartbeat, got %+v", heartbeat)
+	}
+
+	// The connection drops after the first event was processed
+	resp.Body.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		wse.mutex.RLock()
+		_, detached := wse.detached[session]
+		wse.mutex.RUnlock()
+		if detached {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("session was not kept for resuming")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	resp, reader = openEvents(t, server.URL, first.id)
+	defer resp.Body.Close()
+
+	resumed := readEvent(t, reader)
+	if resumed.name != "resumed" || resumed.data["session"] != session {
+		t.Fatalf("unexpected event %+v", resumed)
+	}
+	for _, seq := range []float64{2, 3} {
+		event := readEvent(t, reader)
+		if event.data["seq"] != seq {
+			t.Errorf("replayed %+v, want seq %v", event, seq)
+		}
+	}
+}
+
+func TestEventID(t *testing.T) {
+	id := formatEventID("stream-abc", map[string]int64{"system.cpu": 12, "events.*": 3})
+	if id != "stream-abc:events.*=3,system.cpu=12" {
+		t.Errorf("got %q", id)
+	}
+
+	session, sequences, ok := parseEventID(id)
+	if !ok || session != "stream-abc" || sequences["system.cpu"] != 12 || sequences["events.*"] != 3 {
+		t.Errorf("got %q %v %v", session, sequences, ok)
+	}
+
+	for _, invalid := range []string{"", "42", ":system.cpu=1", "stream-abc:system.cpu=x"} {
+		if _, _, ok := parseEventID(invalid); ok {
+			t.Errorf("%q should not parse", invalid)
+		}
+	}
+}
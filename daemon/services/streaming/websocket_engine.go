@@ -5,21 +5,37 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"reflect"
 	"sync"
 	"time"
 
+	"github.com/domalab/uma/daemon/events"
 	"github.com/domalab/uma/daemon/logger"
 	"github.com/domalab/uma/daemon/services/collectors"
 	"github.com/gorilla/websocket"
 )
 
+// ChannelOperations carries async operation progress and completion events.
+// Unlike collector channels it is pushed by the async manager rather than polled.
+const ChannelOperations = "operations"
+
+// ChannelContainerLogs prefixes the channels carrying a container's output as
+// it is written, such as logs.container.plex
+const ChannelContainerLogs = "logs.container."
+
 // WebSocketEngine provides real-time streaming
 type WebSocketEngine struct {
 	collector *collectors.SystemCollector
 	clients   map[string]*StreamingClient
+	detached  map[string]*detachedSession // Resumable sessions of disconnected clients
+	channels  map[string]reflect.Type     // Pushed channels and their payload types
+	sources   map[string]*channelSource   // Channel families fed on demand, by prefix
 	upgrader  websocket.Upgrader
 	mutex     sync.RWMutex
 
+	running     map[string]*sourceRun // Source channels with subscribers
+	sourceMutex sync.Mutex
+
 	// Performance configuration
 	maxClients       int
 	maxMessageSize   int64
@@ -32,16 +48,20 @@ type StreamingClient struct {
 	id            string
 	conn          *websocket.Conn
 	send          chan []byte
+	session       string // Presented in a resume message to continue after reconnecting
 	subscriptions map[string]*Subscription
-	lastData      map[string]interface{}
+	streams       map[string]*channelStream
 	clientType    ClientType
 	capabilities  ClientCapabilities
+	encoder       Encoder
 	mutex         sync.RWMutex
 	ctx           context.Context
 	cancel        context.CancelFunc
 }
 
-// Subscription defines what metrics a client wants to receive
+// Subscription defines what metrics a client wants to receive. Fields
+// projects the payload onto dotted paths and Filters drops records that do
+// not match; see selection for the exact rules.
 type Subscription struct {
 	Channel   string                 `json:"channel"`
 	Interval  time.Duration          `json:"interval"`
@@ -49,6 +69,8 @@ type Subscription struct {
 	Filters   map[string]interface{} `json:"filters,omitempty"`
 	LastSent  time.Time              `json:"-"`
 	DeltaOnly bool                   `json:"delta_only"`
+
+	selection *selection
 }
 
 // ClientType identifies the type of client
@@ -68,9 +90,15 @@ type ClientCapabilities struct {
 	BinaryProtocol   bool `json:"binary"`
 	DeltaCompression bool `json:"delta"`
 	BatchUpdates     bool `json:"batch"`
+
+	// Format selects the binary wire format, FormatMsgPack or FormatCBOR
+	Format string `json:"format,omitempty"`
 }
 
-// StreamMessage represents a message sent to clients
+// StreamMessage represents a message sent to clients. Sequence increases by
+// one per message on each channel of a connection, so a gap means messages
+// were dropped and the client should resume. Delta messages carry a JSON
+// Patch (RFC 6902) against the previous state instead of the full data.
 type StreamMessage struct {
 	Timestamp int64       `json:"timestamp"`
 	Channel   string      `json:"channel"`
@@ -98,6 +126,10 @@ func NewWebSocketEngine(collector *collectors.SystemCollector) *WebSocketEngine
 	return &WebSocketEngine{
 		collector: collector,
 		clients:   make(map[string]*StreamingClient),
+		detached:  make(map[string]*detachedSession),
+		channels:  make(map[string]reflect.Type),
+		sources:   make(map[string]*channelSource),
+		running:   make(map[string]*sourceRun),
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				return true // Allow all origins for internal network
@@ -113,6 +145,39 @@ func NewWebSocketEngine(collector *collectors.SystemCollector) *WebSocketEngine
 	}
 }
 
+// RegisterChannel declares a channel fed through Publish rather than by a
+// collector, so clients may subscribe to it. sample is a payload of the type
+// published, used to validate subscription fields, or nil if it varies.
+func (wse *WebSocketEngine) RegisterChannel(channel string, sample interface{}) {
+	wse.mutex.Lock()
+	defer wse.mutex.Unlock()
+	wse.channels[channel] = reflect.TypeOf(sample)
+}
+
+// channelType reports whether clients may subscribe to channel, and the type of its payloads if known
+func (wse *WebSocketEngine) channelType(channel string) (reflect.Type, bool) {
+	wse.mutex.RLock()
+	payloadType, pushed := wse.channels[channel]
+	source := wse.sourceFor(channel)
+	wse.mutex.RUnlock()
+	if pushed {
+		return payloadType, true
+	}
+	if source != nil {
+		return source.sample, true
+	}
+
+	if !wse.collector.HasCollector(channel) {
+		return nil, false
+	}
+
+	// Collector payload types are only known once they have collected
+	if data, found := wse.collector.GetMetric(channel); found && data != nil {
+		return reflect.TypeOf(data), true
+	}
+	return nil, true
+}
+
 // HandleWebSocket handles WebSocket upgrade and client management
 func (wse *WebSocketEngine) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	// Check client limit
@@ -140,9 +205,11 @@ func (wse *WebSocketEngine) HandleWebSocket(w http.ResponseWriter, r *http.Reque
 		id:            fmt.Sprintf("client_%d", time.Now().UnixNano()),
 		conn:          conn,
 		send:          make(chan []byte, 256),
+		session:       generateStreamSession(),
 		subscriptions: make(map[string]*Subscription),
-		lastData:      make(map[string]interface{}),
+		streams:       make(map[string]*channelStream),
 		clientType:    ClientGeneric,
+		encoder:       jsonEncoder{},
 		ctx:           ctx,
 		cancel:        cancel,
 	}
@@ -212,13 +279,20 @@ func (wse *WebSocketEngine) clientWriter(client *StreamingClient) {
 				return
 			}
 
+			// Messages are queued as JSON and transcoded for binary clients
+			encoder := client.frameEncoder()
+			frame, err := encoder.Encode(message)
+			if err != nil {
+				logger.Yellow("Failed to encode message for client %s: %v", client.id, err)
+				continue
+			}
+
 			// Use compression for large messages
-			messageType := websocket.TextMessage
-			if client.capabilities.Compression && len(message) > 1024 {
+			if client.capabilities.Compression && len(frame) > 1024 {
 				// Compression would be applied here
 			}
 
-			if err := client.conn.WriteMessage(messageType, message); err != nil {
+			if err := client.conn.WriteMessage(encoder.FrameType(), frame); err != nil {
 				logger.Yellow("WebSocket write error for client %s: %v", client.id, err)
 				return
 			}
@@ -251,6 +325,8 @@ func (wse *WebSocketEngine) processClientMessage(client *StreamingClient, messag
 		return wse.handleSubscribe(client, message)
 	case "unsubscribe":
 		return wse.handleUnsubscribe(client, message)
+	case "resume":
+		return wse.handleResume(client, message)
 	case "ping":
 		return wse.handlePing(client)
 	default:
@@ -265,21 +341,39 @@ func (wse *WebSocketEngine) handleConnect(client *StreamingClient, message []byt
 		return err
 	}
 
+	encoder := Encoder(jsonEncoder{})
+	if connMsg.Capabilities.BinaryProtocol {
+		var err error
+		if encoder, err = NewEncoder(connMsg.Capabilities.Format); err != nil {
+			return wse.sendToClient(client, map[string]interface{}{
+				"type":    "error",
+				"message": err.Error(),
+			})
+		}
+	}
+
 	client.mutex.Lock()
 	client.clientType = connMsg.Client
 	client.capabilities = connMsg.Capabilities
+	client.encoder = encoder
+	session := client.session
 	client.mutex.Unlock()
 
 	// Send connection acknowledgment
 	response := map[string]interface{}{
-		"type":    "connected",
-		"version": "2.0",
-		"server":  "uma-v2",
+		"type":         "connected",
+		"version":      "2.0",
+		"server":       "uma-v2",
+		"session":      session,
+		"delta_format": "json-patch",
+		"format":       encoder.Name(), // The acknowledgment is already sent in this format
+		"formats":      []string{FormatJSON, FormatMsgPack, FormatCBOR},
 		"features": map[string]bool{
 			"compression":   true,
 			"delta":         wse.deltaCompression,
-			"binary":        false, // Not implemented yet
+			"binary":        true,
 			"batch_updates": true,
+			"resume":        true,
 		},
 	}
 
@@ -293,30 +387,74 @@ func (wse *WebSocketEngine) handleSubscribe(client *StreamingClient, message []b
 		return err
 	}
 
-	client.mutex.Lock()
+	// Invalid channels are rejected one by one so the rest still subscribe
+	accepted := make([]Subscription, 0, len(subMsg.Channels))
 	for _, sub := range subMsg.Channels {
-		client.subscriptions[sub.Channel] = &Subscription{
-			Channel:   sub.Channel,
-			Interval:  sub.Interval,
-			Fields:    sub.Fields,
-			Filters:   sub.Filters,
-			LastSent:  time.Time{}, // Force immediate send
-			DeltaOnly: sub.DeltaOnly,
+		sel, err := wse.validateSubscription(sub)
+		if err != nil {
+			if err := wse.sendToClient(client, map[string]interface{}{
+				"type":    "error",
+				"channel": sub.Channel,
+				"message": err.Error(),
+			}); err != nil {
+				return err
+			}
+			continue
 		}
+
+		wse.subscribe(client, sub, sel)
+		accepted = append(accepted, sub)
 	}
-	client.mutex.Unlock()
 
-	logger.Blue("Client %s subscribed to %d channels", client.id, len(subMsg.Channels))
+	wse.syncSources()
+	logger.Blue("Client %s subscribed to %d channels", client.id, len(accepted))
 
 	// Send subscription confirmation
 	response := map[string]interface{}{
 		"type":     "subscribed",
-		"channels": subMsg.Channels,
+		"channels": accepted,
 	}
 
 	return wse.sendToClient(client, response)
 }
 
+// subscribe adds or replaces a validated subscription
+func (wse *WebSocketEngine) subscribe(client *StreamingClient, sub Subscription, sel *selection) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	client.subscriptions[sub.Channel] = &Subscription{
+		Channel:   sub.Channel,
+		Interval:  sub.Interval,
+		Fields:    sub.Fields,
+		Filters:   sub.Filters,
+		LastSent:  time.Time{}, // Force immediate send
+		DeltaOnly: sub.DeltaOnly,
+		selection: sel,
+	}
+	// Changed fields or filters change the state patches apply to
+	if stream, exists := client.streams[sub.Channel]; exists {
+		stream.state = nil
+	}
+}
+
+// validateSubscription checks the channel exists and compiles its fields and filters
+func (wse *WebSocketEngine) validateSubscription(sub Subscription) (*selection, error) {
+	payloadType, known := wse.channelType(sub.Channel)
+	if !known {
+		return nil, fmt.Errorf("unknown channel: %s", sub.Channel)
+	}
+
+	sel, err := compileSelection(sub.Fields, sub.Filters)
+	if err != nil {
+		return nil, err
+	}
+	if err := sel.validate(payloadType); err != nil {
+		return nil, err
+	}
+	return sel, nil
+}
+
 // handleUnsubscribe processes unsubscription requests
 func (wse *WebSocketEngine) handleUnsubscribe(client *StreamingClient, message []byte) error {
 	var unsubMsg struct {
@@ -331,9 +469,10 @@ func (wse *WebSocketEngine) handleUnsubscribe(client *StreamingClient, message [
 	client.mutex.Lock()
 	for _, channel := range unsubMsg.Channels {
 		delete(client.subscriptions, channel)
-		delete(client.lastData, channel)
+		delete(client.streams, channel)
 	}
 	client.mutex.Unlock()
+	wse.syncSources()
 
 	logger.Blue("Client %s unsubscribed from %d channels", client.id, len(unsubMsg.Channels))
 	return nil
@@ -375,33 +514,37 @@ func (wse *WebSocketEngine) processClientSubscriptions(client *StreamingClient)
 	}
 	client.mutex.RUnlock()
 
+	// Updates from one pass go out together so batching clients get one frame
+	var updates [][]byte
+	defer func() {
+		if err := wse.queueAll(client, updates, client.batches()); err != nil {
+			logger.Yellow("Failed to send to client %s: %v", client.id, err)
+		}
+	}()
+
 	for channel, subscription := range subscriptions {
 		if time.Since(subscription.LastSent) >= subscription.Interval {
 			if data, found := wse.collector.GetMetric(channel); found {
-				// Apply delta compression if enabled
-				var deltaData interface{}
-				var isDelta bool
-
-				if wse.deltaCompression && subscription.DeltaOnly {
-					deltaData, isDelta = wse.calculateDelta(client, channel, data)
-					if !isDelta && deltaData == nil {
-						continue // No changes, skip this update
-					}
-				} else {
-					deltaData = data
+				data, matched, err := subscription.selection.apply(data)
+				if err != nil {
+					logger.Yellow("Failed to select %s for client %s: %v", channel, client.id, err)
+					continue
 				}
-
-				message := StreamMessage{
-					Timestamp: time.Now().Unix(),
-					Channel:   channel,
-					Data:      deltaData,
-					Delta:     isDelta,
+				if !matched {
+					continue
 				}
 
-				if err := wse.sendToClient(client, message); err != nil {
+				// Apply delta compression if enabled
+				diff := wse.deltaCompression && subscription.DeltaOnly
+				encoded, err := wse.deliver(client, channel, time.Now(), data, diff)
+				if err != nil {
 					logger.Yellow("Failed to send to client %s: %v", client.id, err)
 					continue
 				}
+				if encoded == nil {
+					continue // No changes, skip this update
+				}
+				updates = append(updates, encoded)
 
 				// Update last sent time
 				client.mutex.Lock()
@@ -414,27 +557,88 @@ func (wse *WebSocketEngine) processClientSubscriptions(client *StreamingClient)
 	}
 }
 
-// calculateDelta calculates delta between current and last data
-func (wse *WebSocketEngine) calculateDelta(client *StreamingClient, channel string, currentData interface{}) (interface{}, bool) {
+// deliver encodes data as the client's next message on channel, stamped with
+// the channel's next sequence number, and keeps it for replay. With diff set,
+// data becomes a JSON Patch against the last state sent, and nil is returned
+// when nothing changed. The caller queues the message.
+func (wse *WebSocketEngine) deliver(client *StreamingClient, channel string, timestamp time.Time, data interface{}, diff bool) ([]byte, error) {
+	message := StreamMessage{
+		Timestamp: timestamp.Unix(),
+		Channel:   channel,
+		Data:      data,
+	}
+
+	var state interface{}
+	if diff {
+		var err error
+		if state, err = toJSONValue(data); err != nil {
+			return nil, err
+		}
+		message.Data = state
+	}
+
 	client.mutex.Lock()
-	lastData, exists := client.lastData[channel]
-	client.lastData[channel] = currentData
+	if client.ctx.Err() != nil {
+		// Disconnected; the stream now belongs to the detached session
+		client.mutex.Unlock()
+		return nil, nil
+	}
+
+	stream := client.stream(channel)
+	if diff && stream.state != nil {
+		patch := diffJSON(stream.state, state)
+		if len(patch) == 0 {
+			client.mutex.Unlock()
+			return nil, nil
+		}
+
+		// Send the full state instead when the patch would be larger
+		encodedPatch, err := json.Marshal(patch)
+		encodedState, _ := json.Marshal(state)
+		if err == nil && len(encodedPatch) < len(encodedState) {
+			message.Data = json.RawMessage(encodedPatch)
+			message.Delta = true
+		}
+	}
+
+	encoded, err := stream.next(message)
+	if err == nil && diff {
+		stream.state = state
+	}
 	client.mutex.Unlock()
 
+	return encoded, err
+}
+
+// stream returns the client's state for channel. The caller must hold the client mutex.
+func (c *StreamingClient) stream(channel string) *channelStream {
+	stream, exists := c.streams[channel]
 	if !exists {
-		return currentData, false // First time, send full data
+		stream = &channelStream{}
+		c.streams[channel] = stream
 	}
+	return stream
+}
 
-	// Simple delta calculation (would be more sophisticated in real implementation)
-	// For now, just compare JSON representations
-	currentJSON, _ := json.Marshal(currentData)
-	lastJSON, _ := json.Marshal(lastData)
+// frameEncoder returns the encoder for the client's negotiated wire format
+func (c *StreamingClient) frameEncoder() Encoder {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.encoder
+}
 
-	if string(currentJSON) == string(lastJSON) {
-		return nil, false // No changes
-	}
+// batches reports whether the client takes several messages per frame
+func (c *StreamingClient) batches() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.capabilities.BatchUpdates
+}
 
-	return currentData, true // Has changes
+// sessionID returns the client's resumable session ID
+func (c *StreamingClient) sessionID() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.session
 }
 
 // sendToClient sends a message to a specific client
@@ -443,7 +647,24 @@ func (wse *WebSocketEngine) sendToClient(client *StreamingClient, data interface
 	if err != nil {
 		return err
 	}
+	return wse.queue(client, message)
+}
 
+// queueAll queues encoded messages, as one batch frame if batch is set
+func (wse *WebSocketEngine) queueAll(client *StreamingClient, messages [][]byte, batch bool) error {
+	if len(messages) > 1 && batch {
+		return wse.queue(client, batchFrame(messages))
+	}
+	for _, message := range messages {
+		if err := wse.queue(client, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queue hands an encoded message to the client's writer without blocking
+func (wse *WebSocketEngine) queue(client *StreamingClient, message []byte) error {
 	select {
 	case client.send <- message:
 		return nil
@@ -453,16 +674,86 @@ func (wse *WebSocketEngine) sendToClient(client *StreamingClient, data interface
 	}
 }
 
+// Publish pushes data to every client subscribed to channel, independent of collector polling
+func (wse *WebSocketEngine) Publish(channel string, data interface{}) {
+	wse.publish(time.Now(), channel, data, channel)
+}
+
+// StreamEvents forwards hub events to clients subscribed to the event's
+// topic, such as events.container, or to events.* for every topic. It
+// returns immediately and stops when the hub shuts down.
+func (wse *WebSocketEngine) StreamEvents(bus *events.Bus) {
+	for _, topic := range events.Topics {
+		wse.RegisterChannel(topic, events.Event{})
+	}
+	wse.RegisterChannel(events.TopicAll, events.Event{})
+
+	ch := bus.Subscribe()
+	go func() {
+		for msg := range ch {
+			if event, ok := msg.(events.Event); ok {
+				topic := event.Type.Topic()
+				wse.publish(event.Timestamp, topic, event, topic, events.TopicAll)
+			}
+		}
+	}()
+}
+
+// publish sends data as channel to every client subscribed to any of subscriptions
+func (wse *WebSocketEngine) publish(timestamp time.Time, channel string, data interface{}, subscriptions ...string) {
+	wse.mutex.RLock()
+	clients := make([]*StreamingClient, 0, len(wse.clients))
+	for _, client := range wse.clients {
+		clients = append(clients, client)
+	}
+	wse.mutex.RUnlock()
+
+	for _, client := range clients {
+		var subscription *Subscription
+		client.mutex.RLock()
+		for _, name := range subscriptions {
+			if sub, ok := client.subscriptions[name]; ok {
+				subscription = sub
+				break
+			}
+		}
+		client.mutex.RUnlock()
+
+		if subscription == nil {
+			continue
+		}
+
+		selected, matched, err := subscription.selection.apply(data)
+		if err != nil {
+			logger.Yellow("Failed to select %s for client %s: %v", channel, client.id, err)
+			continue
+		}
+		if !matched {
+			continue
+		}
+
+		encoded, err := wse.deliver(client, channel, timestamp, selected, false)
+		if err == nil && encoded != nil {
+			err = wse.queue(client, encoded)
+		}
+		if err != nil {
+			logger.Yellow("Failed to publish %s to client %s: %v", channel, client.id, err)
+		}
+	}
+}
+
 // removeClient removes a client from the engine
 func (wse *WebSocketEngine) removeClient(client *StreamingClient) {
 	wse.mutex.Lock()
 	if _, ok := wse.clients[client.id]; ok {
+		// The writer exits on cancellation; send is left open so concurrent publishers cannot panic
 		delete(wse.clients, client.id)
-		close(client.send)
 		client.cancel()
+		wse.detach(client)
 		logger.Blue("WebSocket client disconnected: %s", client.id)
 	}
 	wse.mutex.Unlock()
+	wse.syncSources()
 }
 
 // GetStats returns streaming engine statistics
@@ -0,0 +1,250 @@
+package streaming
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/domalab/uma/daemon/events"
+	"github.com/domalab/uma/daemon/services/collectors"
+)
+
+// newTestClient registers a client without a connection; messages queue on its send channel
+func newTestClient(wse *WebSocketEngine, id string) *StreamingClient {
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &StreamingClient{
+		id:            id,
+		send:          make(chan []byte, 16),
+		session:       generateStreamSession(),
+		subscriptions: make(map[string]*Subscription),
+		streams:       make(map[string]*channelStream),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+
+	wse.mutex.Lock()
+	wse.clients[client.id] = client
+	wse.mutex.Unlock()
+	return client
+}
+
+// receive decodes the next queued message
+func receive(t *testing.T, client *StreamingClient) map[string]interface{} {
+	t.Helper()
+
+	select {
+	case raw := <-client.send:
+		var message map[string]interface{}
+		if err := json.Unmarshal(raw, &message); err != nil {
+			t.Fatalf("invalid message %s: %v", raw, err)
+		}
+		return message
+	case <-time.After(time.Second):
+		t.Fatal("no message sent")
+		return nil
+	}
+}
+
+func TestHandleSubscribeValidation(t *testing.T) {
+	wse := NewWebSocketEngine(collectors.NewSystemCollector())
+	wse.RegisterChannel(events.TopicContainer, events.Event{})
+	client := newTestClient(wse, "test")
+
+	err := wse.handleSubscribe(client, []byte(`{"type":"subscribe","channels":[
+		{"channel":"no.such.channel"},
+		{"channel":"events.container","fields":["nope"]},
+		{"channel":"events.container","fields":["type","data.name"],"filters":{"data.name":["plex"]}},
+		{"channel":"system.cpu","fields":["cpu_percent"]}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg := receive(t, client); msg["type"] != "error" || msg["message"] != "unknown channel: no.such.channel" {
+		t.Errorf("unexpected response %v", msg)
+	}
+	if msg := receive(t, client); msg["type"] != "error" || msg["message"] != `unknown field "nope"` {
+		t.Errorf("unexpected response %v", msg)
+	}
+
+	msg := receive(t, client)
+	if msg["type"] != "subscribed" {
+		t.Fatalf("unexpected response %v", msg)
+	}
+	if channels := msg["channels"].([]interface{}); len(channels) != 2 {
+		t.Errorf("subscribed to %d channels, want 2", len(channels))
+	}
+
+	// Events are filtered and projected per client
+	wse.Publish(events.TopicContainer, events.Event{ID: "1", Type: events.ContainerDied, Data: events.ContainerEvent{Name: "sonarr"}})
+	wse.Publish(events.TopicContainer, events.Event{ID: "2", Type: events.ContainerDied, Data: events.ContainerEvent{Name: "plex", State: "exited"}})
+
+	msg = receive(t, client)
+	data, _ := json.Marshal(msg["data"])
+	if want := `{"data":{"name":"plex"},"type":"container.died"}`; string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+
+	select {
+	case raw := <-client.send:
+		t.Errorf("unexpected message %s", raw)
+	default:
+	}
+}
+
+func TestChannelSource(t *testing.T) {
+	wse := NewWebSocketEngine(collectors.NewSystemCollector())
+	started := make(chan string, 4)
+	stopped := make(chan string, 4)
+	wse.RegisterChannelSource("logs.container.", map[string]interface{}{}, func(ctx context.Context, channel string) error {
+		started <- channel
+		wse.Publish(channel, map[string]interface{}{"line": "ready"})
+		<-ctx.Done()
+		stopped <- channel
+		return nil
+	})
+	first := newTestClient(wse, "first")
+	second := newTestClient(wse, "second")
+
+	if _, known := wse.channelType("logs.container."); known {
+		t.Error("a bare prefix should not be a channel")
+	}
+
+	subscribe := `{"type":"subscribe","channels":[{"channel":"logs.container.plex","filters":{"line":{"matches":"^rea"}}}]}`
+	if err := wse.handleSubscribe(first, []byte(subscribe)); err != nil {
+		t.Fatal(err)
+	}
+	if msg := receive(t, first); msg["type"] != "subscribed" {
+		t.Fatalf("unexpected response %v", msg)
+	}
+	select {
+	case channel := <-started:
+		if channel != "logs.container.plex" {
+			t.Fatalf("started %s", channel)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("source not started")
+	}
+	if msg := receive(t, first); msg["channel"] != "logs.container.plex" {
+		t.Errorf("unexpected message %v", msg)
+	}
+
+	// A second subscriber shares the running source, which stops with the last one
+	if err := wse.handleSubscribe(second, []byte(subscribe)); err != nil {
+		t.Fatal(err)
+	}
+	if err := wse.handleUnsubscribe(first, []byte(`{"type":"unsubscribe","channels":["logs.container.plex"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case channel := <-stopped:
+		t.Fatalf("%s stopped with a subscriber left", channel)
+	case <-time.After(50 * time.Millisecond):
+	}
+	wse.removeClient(second)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("source not stopped")
+	}
+	if len(started) != 0 {
+		t.Error("source started more than once")
+	}
+}
+
+func TestDeliverDeltas(t *testing.T) {
+	wse := NewWebSocketEngine(collectors.NewSystemCollector())
+	client := newTestClient(wse, "test")
+
+	for _, cpu := range []float64{10, 10, 25} {
+		encoded, err := wse.deliver(client, "system.cpu", time.Now(), collectors.SystemMetrics{CPUPercent: cpu, MemoryTotal: 1024}, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if encoded != nil {
+			client.send <- encoded
+		}
+	}
+
+	first := receive(t, client)
+	if first["seq"] != float64(1) || first["delta"] != nil {
+		t.Errorf("first message should be a full state with seq 1: %v", first)
+	}
+
+	// The unchanged reading was skipped without using a sequence number
+	second := receive(t, client)
+	if second["seq"] != float64(2) || second["delta"] != true {
+		t.Fatalf("second message should be a delta with seq 2: %v", second)
+	}
+	patch, _ := json.Marshal(second["data"])
+	if want := `[{"op":"replace","path":"/cpu_percent","value":25}]`; string(patch) != want {
+		t.Errorf("got patch %s, want %s", patch, want)
+	}
+}
+
+func TestResume(t *testing.T) {
+	wse := NewWebSocketEngine(collectors.NewSystemCollector())
+	wse.RegisterChannel(events.TopicVM, events.Event{})
+	wse.RegisterChannel(events.TopicDisk, events.Event{})
+
+	old := newTestClient(wse, "old")
+	if err := wse.handleSubscribe(old, []byte(`{"type":"subscribe","channels":[{"channel":"events.vm"},{"channel":"events.disk"}]}`)); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, old)
+
+	for i := 1; i <= 3; i++ {
+		wse.Publish(events.TopicVM, events.Event{ID: strconv.Itoa(i), Type: events.VMStateChanged})
+	}
+	for i := 0; i < replayBufferSize+2; i++ {
+		wse.Publish(events.TopicDisk, events.Event{Type: events.DiskSpunDown})
+	}
+
+	// The connection drops and the client reconnects having seen vm #1 and disk #1
+	old.mutex.RLock()
+	session := old.session
+	old.mutex.RUnlock()
+	wse.removeClient(old)
+
+	client := newTestClient(wse, "new")
+	err := wse.handleResume(client, []byte(`{"type":"resume","session":"`+session+`","channels":{"events.vm":1,"events.disk":1}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resumed := receive(t, client)
+	if resumed["type"] != "resumed" || resumed["session"] != session {
+		t.Fatalf("unexpected response %v", resumed)
+	}
+	outcomes := resumed["channels"].(map[string]interface{})
+	if outcomes["events.vm"] != ResumeReplayed || outcomes["events.disk"] != ResumeGap {
+		t.Errorf("unexpected outcomes %v", outcomes)
+	}
+
+	for _, seq := range []float64{2, 3} {
+		msg := receive(t, client)
+		if msg["channel"] != events.TopicVM || msg["seq"] != seq {
+			t.Errorf("replayed %v, want events.vm seq %v", msg, seq)
+		}
+	}
+
+	// Sequence numbers continue where the session left off
+	wse.Publish(events.TopicVM, events.Event{ID: "4", Type: events.VMStateChanged})
+	if msg := receive(t, client); msg["seq"] != float64(4) {
+		t.Errorf("got seq %v after resume, want 4", msg["seq"])
+	}
+
+	// Resuming a session still in use takes it over from its connection
+	again := newTestClient(wse, "again")
+	if err := wse.handleResume(again, []byte(`{"type":"resume","session":"`+session+`"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if msg := receive(t, again); msg["type"] != "resumed" {
+		t.Errorf("expected the live session to be taken over, got %v", msg)
+	}
+	if client.ctx.Err() == nil {
+		t.Error("expected the previous holder of the session to be disconnected")
+	}
+}
@@ -22,6 +22,7 @@ var cli struct {
 
 	Boot   cmd.Boot      `cmd:"" default:"1" help:"start processing"`
 	Config cmd.ConfigCmd `cmd:"" help:"manage configuration"`
+	MCP    cmd.MCPCmd    `cmd:"" name:"mcp" help:"serve MCP over stdio through the running daemon"`
 }
 
 func main() {